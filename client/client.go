@@ -0,0 +1,299 @@
+// Package client is a Go client for the document API.
+//
+// A Client is safe for concurrent use. Every call carries a request ID (taken from the context
+// with WithRequestID, or generated) in the X-Request-ID header, which is reused across retries and
+// reported in errors, and propagates the trace context of ctx using the global OpenTelemetry
+// propagator.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// RetryPolicy controls how failed requests are retried. Only requests that are safe to repeat
+// (reads and deletes) are retried, after network errors and 429, 502, 503 and 504 responses.
+// Uploads are never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one. Values below 1 mean 1.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry; it doubles with every further retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is used unless WithRetry is given.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: 200 * time.Millisecond, MaxBackoff: 5 * time.Second}
+
+// Client calls the document API.
+type Client struct {
+	base    *url.URL
+	http    *http.Client
+	token   string
+	retry   RetryPolicy
+	timeout time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the underlying HTTP client. Its Timeout should be zero, since it would also
+// cut off long downloads; use WithTimeout instead.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithToken sends token as a bearer token with every request.
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithRetry sets the retry policy.
+func WithRetry(p RetryPolicy) Option {
+	return func(c *Client) { c.retry = p }
+}
+
+// WithTimeout bounds each attempt. For downloads it bounds the time until the response headers
+// arrive; reading the body is limited only by ctx, as are uploads. Zero disables the timeout.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) { c.timeout = d }
+}
+
+// New returns a Client for the API at baseURL, e.g. "https://docapi.example.com".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid base url %q: want http(s)://host[:port]", baseURL)
+	}
+	c := &Client{base: u, http: http.DefaultClient, retry: DefaultRetryPolicy, timeout: 30 * time.Second}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose requests carry id in the X-Request-ID header.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// request describes one API call.
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	// body creates the request body for each attempt; nil means no body.
+	body func() (io.Reader, int64, error)
+	// retry allows the call to be repeated.
+	retry bool
+	// stream keeps the response body open for the caller instead of bounding it by the timeout.
+	stream bool
+	// untimed exempts the call from the timeout altogether.
+	untimed bool
+}
+
+// do runs r with retries and returns the successful response. Non-2xx responses are returned as
+// *Error. Unless r.stream is set, the attempt's timeout covers reading the body, so callers must
+// consume it before returning. The caller must close the response body.
+func (c *Client) do(ctx context.Context, r request) (*http.Response, error) {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	attempts := 1
+	if r.retry && c.retry.MaxAttempts > 1 {
+		attempts = c.retry.MaxAttempts
+	}
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, c.backoff(attempt, lastErr)); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := c.attempt(ctx, r, id)
+		if err == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil || !retryable(err) {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func (c *Client) attempt(ctx context.Context, r request, requestID string) (*http.Response, error) {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	stop := func() bool { return true }
+	if c.timeout > 0 && !r.untimed {
+		stop = time.AfterFunc(c.timeout, cancel).Stop
+	}
+	release := func() {
+		stop()
+		cancel()
+	}
+
+	req, err := c.newRequest(ctx, r, requestID)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if r.stream && !stop() && err == nil {
+		// The timeout fired just as the headers arrived.
+		resp.Body.Close()
+		err = context.Canceled
+	}
+	if err != nil {
+		release()
+		if parent.Err() == nil && ctx.Err() != nil {
+			return nil, fmt.Errorf("%s %s: no response within %s: %w", r.method, r.path, c.timeout, context.DeadlineExceeded)
+		}
+		return nil, err
+	}
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	return nil, decodeError(resp, requestID)
+}
+
+func (c *Client) newRequest(ctx context.Context, r request, requestID string) (*http.Request, error) {
+	var body io.Reader
+	var contentLength int64
+	if r.body != nil {
+		var err error
+		if body, contentLength, err = r.body(); err != nil {
+			return nil, err
+		}
+	}
+	u := *c.base
+	u.Path = strings.TrimRight(u.Path, "/") + r.path
+	u.RawQuery = r.query.Encode()
+	req, err := http.NewRequestWithContext(ctx, r.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if r.body != nil {
+		req.ContentLength = contentLength
+	}
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	req.Header.Set(requestIDHeader, requestID)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return req, nil
+}
+
+// backoff returns the delay before the given retry, honouring Retry-After on 429/503 responses.
+func (c *Client) backoff(attempt int, lastErr error) time.Duration {
+	var apiErr *Error
+	if errors.As(lastErr, &apiErr) && apiErr.retryAfter > 0 {
+		if c.retry.MaxBackoff > 0 {
+			return min(apiErr.retryAfter, c.retry.MaxBackoff)
+		}
+		return apiErr.retryAfter
+	}
+	d := c.retry.InitialBackoff << (attempt - 1)
+	if c.retry.MaxBackoff > 0 && (d > c.retry.MaxBackoff || d <= 0) {
+		d = c.retry.MaxBackoff
+	}
+	// Full jitter keeps many clients from retrying in lockstep.
+	if d > 0 {
+		d = time.Duration(rand.Int64N(int64(d)) + 1)
+	}
+	return d
+}
+
+// retryable reports whether a failed attempt may be repeated.
+func retryable(err error) bool {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	// Transport errors, including per-attempt timeouts.
+	return true
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// getJSON performs a retryable GET and decodes the JSON response into out.
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, out any) error {
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: path, query: query, retry: true})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeJSON(resp, out)
+}
+
+func decodeJSON(resp *http.Response, out any) error {
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// releaseOnClose releases the attempt's context and timer once the body is closed.
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
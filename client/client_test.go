@@ -0,0 +1,500 @@
+package client
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	handlers "docapi/internal/http/handler"
+	"docapi/internal/http/middleware"
+	"docapi/internal/repository/memory"
+	"docapi/internal/service"
+	"docapi/internal/storage"
+)
+
+// faultServer runs the real HTTP handlers over in-memory storage and repository, and records
+// requests and injects failures in front of them.
+type faultServer struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	// fail answers this many requests with 503 before passing them on.
+	fail int
+	// truncate cuts the next content download off after this many bytes.
+	truncate int
+	// delay holds every request back before handling it.
+	delay time.Duration
+}
+
+func (f *faultServer) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.requests = append(f.requests, r.Clone(context.Background()))
+		fail := f.fail > 0
+		if fail {
+			f.fail--
+		}
+		truncate := 0
+		if strings.HasSuffix(r.URL.Path, "/content") {
+			truncate, f.truncate = f.truncate, 0
+		}
+		delay := f.delay
+		f.mu.Unlock()
+
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if fail {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		if truncate == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := httptest.NewRecorder()
+		next.ServeHTTP(rec, r)
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes()[:truncate])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	})
+}
+
+func (f *faultServer) seen() []*http.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*http.Request(nil), f.requests...)
+}
+
+func newTestClient(t *testing.T, opts ...Option) (*Client, *faultServer) {
+	t.Helper()
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler(), DisableStartupMessage: true})
+	app.Use(middleware.RequestID())
+	handlers.RegisterRoutes(app, nil, service.NewDocumentService(storage.NewMemory(), memory.NewDocumentMemory()))
+
+	faults := &faultServer{}
+	srv := httptest.NewServer(faults.handler(adaptor.FiberApp(app)))
+	t.Cleanup(srv.Close)
+
+	opts = append([]Option{WithRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})}, opts...)
+	c, err := New(srv.URL, opts...)
+	require.NoError(t, err)
+	return c, faults
+}
+
+func upload(t *testing.T, c *Client, name, content string) *Document {
+	t.Helper()
+	doc, err := c.Upload(context.Background(), name, strings.NewReader(content), &UploadOptions{ContentType: "text/plain", Size: int64(len(content))})
+	require.NoError(t, err)
+	return doc
+}
+
+func TestClient_Documents(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+
+	doc := upload(t, c, "a.txt", "alpha")
+	assert.Equal(t, int64(5), doc.Size)
+
+	// chunked upload of unknown size
+	chunked, err := c.Upload(ctx, "b.bin", io.MultiReader(strings.NewReader("bra"), strings.NewReader("vo")), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "application/octet-stream", chunked.ContentType)
+	assert.Equal(t, int64(5), chunked.Size)
+
+	got, err := c.Get(ctx, doc.ID)
+	require.NoError(t, err)
+	assert.Equal(t, doc.ID, got.ID)
+
+	page, err := c.List(ctx, ListOptions{Limit: 1, WithTotal: true})
+	require.NoError(t, err)
+	assert.Len(t, page.Documents, 1)
+	require.NotNil(t, page.Total)
+	assert.Equal(t, 2, *page.Total)
+	require.NotEmpty(t, page.NextCursor)
+
+	next, err := c.List(ctx, ListOptions{Limit: 1, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, next.Documents, 1)
+	assert.NotEqual(t, page.Documents[0].ID, next.Documents[0].ID)
+	assert.Nil(t, next.Total)
+	assert.Empty(t, next.NextCursor)
+
+	dl, err := c.Download(ctx, doc.ID)
+	require.NoError(t, err)
+	content, err := io.ReadAll(dl)
+	require.NoError(t, err)
+	require.NoError(t, dl.Close())
+	assert.Equal(t, "alpha", string(content))
+	assert.Equal(t, doc.Name, dl.Filename)
+	assert.Equal(t, int64(5), dl.Size)
+
+	u, err := c.PresignURL(ctx, doc.ID, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(u.URL, "memory://"), u.URL)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), u.ExpiresAt, time.Minute)
+
+	require.NoError(t, c.Delete(ctx, doc.ID))
+	_, err = c.Get(ctx, doc.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "NOT_FOUND", apiErr.Code)
+	assert.NotEmpty(t, apiErr.RequestID)
+
+	_, err = c.Get(ctx, "not-a-uuid")
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+	_, err = c.Get(ctx, "../documents")
+	assert.ErrorIs(t, err, ErrInvalidID)
+}
+
+func TestClient_Copy(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+	src := upload(t, c, "template.txt", "template")
+
+	same, err := c.Copy(ctx, src.ID, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, src.ID, same.ID)
+	assert.Equal(t, "template.txt", same.Name)
+
+	renamed, err := c.Copy(ctx, src.ID, &CopyOptions{Name: "v2.txt", Metadata: map[string]string{"version": "2"}})
+	require.NoError(t, err)
+	assert.Equal(t, "v2.txt", renamed.Name)
+	assert.Equal(t, map[string]string{"version": "2"}, renamed.Metadata)
+
+	require.NoError(t, c.Delete(ctx, src.ID))
+	dl, err := c.Download(ctx, renamed.ID)
+	require.NoError(t, err)
+	content, err := io.ReadAll(dl)
+	require.NoError(t, err)
+	require.NoError(t, dl.Close())
+	assert.Equal(t, "template", string(content))
+
+	_, err = c.Copy(ctx, src.ID, nil)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestClient_Update(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+	doc := upload(t, c, "scan.bin", "data")
+	name, owner := "invoice.pdf", "alice"
+
+	updated, err := c.Update(ctx, doc.ID, Patch{
+		Name:      &name,
+		Tags:      []string{"invoice"},
+		Metadata:  map[string]*string{"owner": &owner},
+		IfVersion: doc.Version,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "invoice.pdf", updated.Name)
+	assert.Equal(t, []string{"invoice"}, updated.Tags)
+	assert.Equal(t, map[string]string{"owner": "alice"}, updated.Metadata)
+	assert.Equal(t, doc.Version+1, updated.Version)
+
+	_, err = c.Update(ctx, doc.ID, Patch{Name: &name, IfVersion: doc.Version})
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	cleared, err := c.Update(ctx, doc.ID, Patch{Tags: []string{}, ClearMetadata: true})
+	require.NoError(t, err)
+	assert.Empty(t, cleared.Tags)
+	assert.Empty(t, cleared.Metadata)
+
+	_, err = c.Update(ctx, "00000000-0000-0000-0000-000000000000", Patch{Name: &name})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestClient_BatchDelete(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+	a := upload(t, c, "a.txt", "alpha")
+	b := upload(t, c, "b.txt", "bravo")
+	missing := "00000000-0000-0000-0000-000000000000"
+
+	res, err := c.BatchDelete(ctx, []string{a.ID, missing, "bad", b.ID, a.ID})
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Deleted)
+	assert.Equal(t, 1, res.NotFound)
+	assert.Equal(t, 1, res.Failed)
+	assert.NotEmpty(t, res.RequestID)
+
+	require.Len(t, res.Results, 4)
+	assert.Equal(t, []string{a.ID, missing, "bad", b.ID},
+		[]string{res.Results[0].ID, res.Results[1].ID, res.Results[2].ID, res.Results[3].ID})
+	assert.NoError(t, res.Results[0].Err(res.RequestID))
+	assert.ErrorIs(t, res.Results[1].Err(res.RequestID), ErrNotFound)
+	assert.ErrorIs(t, res.Results[2].Err(res.RequestID), ErrInvalidRequest)
+	assert.Equal(t, DeleteStatusDeleted, res.Results[3].Status)
+
+	_, err = c.Get(ctx, b.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestClient_DownloadArchive(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+	a := upload(t, c, "a.txt", "alpha")
+	upload(t, c, "b.txt", "bravo")
+	missing := "00000000-0000-0000-0000-000000000000"
+
+	read := func(t *testing.T, opts ArchiveOptions) map[string]string {
+		t.Helper()
+		rc, err := c.DownloadArchive(ctx, opts)
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		require.NoError(t, err)
+		files := map[string]string{}
+		for _, f := range zr.File {
+			r, err := f.Open()
+			require.NoError(t, err)
+			content, _ := io.ReadAll(r)
+			r.Close()
+			files[f.Name] = string(content)
+		}
+		return files
+	}
+
+	files := read(t, ArchiveOptions{IDs: []string{a.ID, missing}})
+	assert.Equal(t, "alpha", files["a.txt"])
+	assert.Contains(t, files["manifest.json"], missing)
+	assert.NotContains(t, files, "b.txt")
+
+	files = read(t, ArchiveOptions{Filter: ListOptions{Name: "b.", Sort: "name"}})
+	assert.Equal(t, "bravo", files["b.txt"])
+	assert.NotContains(t, files, "a.txt")
+
+	_, err := c.DownloadArchive(ctx, ArchiveOptions{IDs: []string{"nope"}})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+}
+
+func TestClient_ListAll(t *testing.T) {
+	c, _ := newTestClient(t)
+	want := map[string]bool{}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		want[upload(t, c, name, name).ID] = true
+	}
+
+	got := map[string]bool{}
+	for doc, err := range c.ListAll(context.Background(), ListOptions{Limit: 2}) {
+		require.NoError(t, err)
+		got[doc.ID] = true
+	}
+	assert.Equal(t, want, got)
+
+	// filtered and sorted
+	var names []string
+	for doc, err := range c.ListAll(context.Background(), ListOptions{Limit: 2, Sort: "name", Name: "c"}) {
+		require.NoError(t, err)
+		names = append(names, doc.Name)
+	}
+	assert.Equal(t, []string{"c"}, names)
+
+	names = nil
+	maxSize := int64(1)
+	for doc, err := range c.ListAll(context.Background(), ListOptions{Limit: 2, Sort: "-name", ContentType: "text/*", MaxSize: &maxSize}) {
+		require.NoError(t, err)
+		names = append(names, doc.Name)
+	}
+	assert.Equal(t, []string{"e", "d", "c", "b", "a"}, names)
+
+	_, err := c.List(context.Background(), ListOptions{Sort: "storage_path"})
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "INVALID_SORT", apiErr.Code)
+
+	// stopping early
+	n := 0
+	for range c.ListAll(context.Background(), ListOptions{Limit: 2}) {
+		if n++; n == 3 {
+			break
+		}
+	}
+	assert.Equal(t, 3, n)
+}
+
+func TestClient_DownloadResumes(t *testing.T) {
+	c, faults := newTestClient(t)
+	content := strings.Repeat("0123456789", 100)
+	doc := upload(t, c, "digits.txt", content)
+
+	faults.mu.Lock()
+	faults.truncate = 123
+	faults.mu.Unlock()
+
+	dl, err := c.Download(context.Background(), doc.ID)
+	require.NoError(t, err)
+	defer dl.Close()
+	got, err := io.ReadAll(dl)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(got))
+
+	reqs := faults.seen()
+	last := reqs[len(reqs)-1]
+	assert.Equal(t, "bytes=123-", last.Header.Get("Range"))
+	assert.Equal(t, reqs[len(reqs)-2].Header.Get(requestIDHeader), last.Header.Get(requestIDHeader))
+}
+
+func TestClient_Retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		fail         int
+		call         func(*Client, *Document) error
+		wantErr      error
+		wantRequests int
+	}{
+		{
+			name:         "get recovers",
+			fail:         2,
+			call:         func(c *Client, d *Document) error { _, err := c.Get(context.Background(), d.ID); return err },
+			wantRequests: 3,
+		},
+		{
+			name:         "get gives up",
+			fail:         5,
+			call:         func(c *Client, d *Document) error { _, err := c.Get(context.Background(), d.ID); return err },
+			wantErr:      ErrUnavailable,
+			wantRequests: 3,
+		},
+		{
+			name:         "delete recovers",
+			fail:         1,
+			call:         func(c *Client, d *Document) error { return c.Delete(context.Background(), d.ID) },
+			wantRequests: 2,
+		},
+		{
+			name: "upload is not retried",
+			fail: 1,
+			call: func(c *Client, _ *Document) error {
+				_, err := c.Upload(context.Background(), "x", strings.NewReader("x"), nil)
+				return err
+			},
+			wantErr:      ErrUnavailable,
+			wantRequests: 1,
+		},
+		{
+			name: "not found is not retried",
+			call: func(c *Client, _ *Document) error {
+				return c.Delete(context.Background(), "00000000-0000-0000-0000-000000000000")
+			},
+			wantErr:      ErrNotFound,
+			wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, faults := newTestClient(t)
+			doc := upload(t, c, "a.txt", "alpha")
+			before := len(faults.seen())
+			faults.mu.Lock()
+			faults.fail = tt.fail
+			faults.mu.Unlock()
+
+			err := tt.call(c, doc)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			reqs := faults.seen()[before:]
+			require.Len(t, reqs, tt.wantRequests)
+			for _, r := range reqs {
+				assert.Equal(t, reqs[0].Header.Get(requestIDHeader), r.Header.Get(requestIDHeader), "request ID is reused across attempts")
+			}
+		})
+	}
+}
+
+func TestClient_Propagation(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+
+	c, faults := newTestClient(t, WithToken("secret"))
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := WithRequestID(trace.ContextWithSpanContext(context.Background(), sc), "req-42")
+
+	_, err := c.Get(ctx, "00000000-0000-0000-0000-000000000000")
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "req-42", apiErr.RequestID)
+
+	r := faults.seen()[0]
+	assert.Equal(t, "req-42", r.Header.Get(requestIDHeader))
+	assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+	assert.Equal(t, "00-01020300000000000000000000000000-0405060000000000-01", r.Header.Get("Traceparent"))
+}
+
+func TestClient_Timeout(t *testing.T) {
+	c, faults := newTestClient(t, WithTimeout(20*time.Millisecond), WithRetry(RetryPolicy{MaxAttempts: 2}))
+	faults.mu.Lock()
+	faults.delay = time.Second
+	faults.mu.Unlock()
+
+	start := time.Now()
+	_, err := c.List(context.Background(), ListOptions{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Len(t, faults.seen(), 2, "timeouts are retried")
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{url: "http://localhost:8080"},
+		{url: "https://docs.example.com/api/"},
+		{url: "ftp://example.com", wantErr: true},
+		{url: "localhost:8080", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			_, err := New(tt.url)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	err := error(&Error{StatusCode: http.StatusServiceUnavailable, Code: "SERVICE_UNAVAILABLE"})
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.False(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, "SERVICE_UNAVAILABLE", err.Error())
+	assert.Equal(t, "HTTP 404: gone (request r1)", (&Error{StatusCode: 404, Message: "gone", RequestID: "r1"}).Error())
+}
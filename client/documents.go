@@ -0,0 +1,441 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"iter"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"docapi/internal/model"
+)
+
+// Document is a stored document's metadata.
+type Document = model.Document
+
+// listPageSize is the page size ListAll uses unless told otherwise.
+const listPageSize = 100
+
+// UploadOptions describes an uploaded file.
+type UploadOptions struct {
+	// ContentType is the file's media type. Empty means application/octet-stream.
+	ContentType string
+	// Size is the file's length in bytes. When positive the request is sent with an exact
+	// Content-Length; otherwise it is sent chunked.
+	Size int64
+}
+
+// Upload stores the contents of r as a new document named filename. Uploads are not retried,
+// since r cannot be rewound, and are not subject to the client's timeout.
+func (c *Client) Upload(ctx context.Context, filename string, r io.Reader, opts *UploadOptions) (*Document, error) {
+	if opts == nil {
+		opts = &UploadOptions{}
+	}
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	// The multipart framing is built up front so the file itself can be streamed.
+	var framing bytes.Buffer
+	mw := multipart.NewWriter(&framing)
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{
+		"name":     "file",
+		"filename": filename,
+	}))
+	h.Set("Content-Type", contentType)
+	if _, err := mw.CreatePart(h); err != nil {
+		return nil, err
+	}
+	headLen := framing.Len()
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	head, tail := framing.Bytes()[:headLen], framing.Bytes()[headLen:]
+
+	length := int64(-1)
+	if opts.Size > 0 {
+		length = int64(len(head)) + opts.Size + int64(len(tail))
+	}
+	resp, err := c.do(ctx, request{
+		method:  http.MethodPost,
+		path:    "/documents",
+		header:  http.Header{"Content-Type": {mw.FormDataContentType()}},
+		untimed: true,
+		body: func() (io.Reader, int64, error) {
+			return io.MultiReader(bytes.NewReader(head), r, bytes.NewReader(tail)), length, nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var doc Document
+	if err := decodeJSON(resp, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// ListOptions selects a page of documents.
+type ListOptions struct {
+	// Limit is the page size; zero means the server default.
+	Limit int
+	// Offset is the number of documents to skip. It cannot be combined with Cursor.
+	Offset int
+	// Cursor continues a listing from a previous page's NextCursor.
+	Cursor string
+	// WithTotal requests the total number of matching documents, which is costly on large
+	// collections.
+	WithTotal bool
+
+	// Sort is a field name (created_at, name, size or content_type), prefixed with "-" for
+	// descending order. Empty means newest first. Cursors are only valid with the same Sort.
+	Sort string
+	// ContentType matches the media type exactly ("application/pdf") or by wildcard ("image/*").
+	ContentType string
+	// Name matches a case-insensitive substring of the original filename.
+	Name string
+	// CreatedAfter and CreatedBefore bound the creation time to [CreatedAfter, CreatedBefore).
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// MinSize and MaxSize bound the size in bytes inclusively.
+	MinSize *int64
+	MaxSize *int64
+}
+
+func (o ListOptions) query() url.Values {
+	q := url.Values{"total": {strconv.FormatBool(o.WithTotal)}}
+	set := func(k, v string) {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		q.Set("offset", strconv.Itoa(o.Offset))
+	}
+	set("cursor", o.Cursor)
+	set("sort", o.Sort)
+	set("content_type", o.ContentType)
+	set("name", o.Name)
+	if !o.CreatedAfter.IsZero() {
+		q.Set("created_after", o.CreatedAfter.Format(time.RFC3339Nano))
+	}
+	if !o.CreatedBefore.IsZero() {
+		q.Set("created_before", o.CreatedBefore.Format(time.RFC3339Nano))
+	}
+	if o.MinSize != nil {
+		q.Set("min_size", strconv.FormatInt(*o.MinSize, 10))
+	}
+	if o.MaxSize != nil {
+		q.Set("max_size", strconv.FormatInt(*o.MaxSize, 10))
+	}
+	return q
+}
+
+// ListResult is a page of documents.
+type ListResult struct {
+	Documents []Document `json:"data"`
+	// Total is the number of documents across all pages, if requested.
+	Total *int `json:"total,omitempty"`
+	// NextCursor fetches the following page; empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// List returns one page of documents.
+func (c *Client) List(ctx context.Context, opts ListOptions) (*ListResult, error) {
+	var res ListResult
+	if err := c.getJSON(ctx, "/documents", opts.query(), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListAll iterates over all documents from opts.Offset or opts.Cursor on, fetching pages of
+// opts.Limit documents (100 when zero) as needed. Pages after the first are fetched by cursor, so
+// documents added or removed meanwhile do not shift the listing. Iteration stops after the first
+// error, which is yielded with a zero Document.
+func (c *Client) ListAll(ctx context.Context, opts ListOptions) iter.Seq2[Document, error] {
+	return func(yield func(Document, error) bool) {
+		if opts.Limit <= 0 {
+			opts.Limit = listPageSize
+		}
+		opts.WithTotal = false
+		for {
+			page, err := c.List(ctx, opts)
+			if err != nil {
+				yield(Document{}, err)
+				return
+			}
+			for _, d := range page.Documents {
+				if !yield(d, nil) {
+					return
+				}
+			}
+			if page.NextCursor == "" {
+				return
+			}
+			opts.Offset, opts.Cursor = 0, page.NextCursor
+		}
+	}
+}
+
+// Get returns a document's metadata.
+func (c *Client) Get(ctx context.Context, id string) (*Document, error) {
+	p, err := documentPath(id, "")
+	if err != nil {
+		return nil, err
+	}
+	var doc Document
+	if err := c.getJSON(ctx, p, nil, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// Delete removes a document and its contents.
+func (c *Client) Delete(ctx context.Context, id string) error {
+	p, err := documentPath(id, "")
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, request{method: http.MethodDelete, path: p, retry: true})
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Patch changes the mutable fields of a document. Nil fields are left as they are.
+type Patch struct {
+	Name        *string
+	ContentType *string
+	// Tags replaces the document's tags when not nil; an empty slice clears them.
+	Tags []string
+	// Metadata is merged into the document's metadata; a nil value removes the key.
+	Metadata map[string]*string
+	// ClearMetadata removes all metadata and takes precedence over Metadata.
+	ClearMetadata bool
+	// IfVersion, when not zero, only updates the document while it is at this version; otherwise
+	// Update fails with ErrPreconditionFailed.
+	IfVersion int64
+}
+
+// Update applies patch to document id and returns the updated document. Updates without IfVersion
+// are retried, since applying the same patch twice has the same result.
+func (c *Client) Update(ctx context.Context, id string, patch Patch) (*Document, error) {
+	p, err := documentPath(id, "")
+	if err != nil {
+		return nil, err
+	}
+	req := map[string]any{}
+	if patch.Name != nil {
+		req["name"] = *patch.Name
+	}
+	if patch.ContentType != nil {
+		req["content_type"] = *patch.ContentType
+	}
+	if patch.Tags != nil {
+		req["tags"] = patch.Tags
+	}
+	switch {
+	case patch.ClearMetadata:
+		req["metadata"] = nil
+	case len(patch.Metadata) > 0:
+		req["metadata"] = patch.Metadata
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{"Content-Type": {"application/merge-patch+json"}}
+	if patch.IfVersion != 0 {
+		header.Set("If-Match", `"`+strconv.FormatInt(patch.IfVersion, 10)+`"`)
+	}
+	resp, err := c.do(ctx, request{
+		method: http.MethodPatch,
+		path:   p,
+		header: header,
+		body: func() (io.Reader, int64, error) {
+			return bytes.NewReader(body), int64(len(body)), nil
+		},
+		retry: patch.IfVersion == 0,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var doc Document
+	if err := decodeJSON(resp, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// CopyOptions customise a copied document. Zero fields keep the source's values.
+type CopyOptions struct {
+	// Name is the copy's name.
+	Name string
+	// Metadata replaces the source's metadata when not nil; an empty map clears it.
+	Metadata map[string]string
+}
+
+// Copy creates an independent document with the content of document id; the content is copied
+// by the server without passing through the client. Copies are not retried, since each attempt
+// creates a document.
+func (c *Client) Copy(ctx context.Context, id string, opts *CopyOptions) (*Document, error) {
+	p, err := documentPath(id, "/copy")
+	if err != nil {
+		return nil, err
+	}
+	body := []byte("{}")
+	if opts != nil {
+		// omitempty would drop an empty map, which clears the metadata.
+		req := map[string]any{}
+		if opts.Name != "" {
+			req["name"] = opts.Name
+		}
+		if opts.Metadata != nil {
+			req["metadata"] = opts.Metadata
+		}
+		if body, err = json.Marshal(req); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   p,
+		header: http.Header{"Content-Type": {"application/json"}},
+		body: func() (io.Reader, int64, error) {
+			return bytes.NewReader(body), int64(len(body)), nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var doc Document
+	if err := decodeJSON(resp, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// Batch delete outcomes reported in DeleteResult.Status.
+const (
+	DeleteStatusDeleted  = "deleted"
+	DeleteStatusNotFound = "not_found"
+	DeleteStatusFailed   = "failed"
+)
+
+// DeleteResult is the outcome of deleting one document of a batch.
+type DeleteResult struct {
+	ID string `json:"id"`
+	// Status is DeleteStatusDeleted, DeleteStatusNotFound or DeleteStatusFailed.
+	Status string `json:"status"`
+	// Error describes a failed deletion.
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// BatchDeleteResult is the response of BatchDelete.
+type BatchDeleteResult struct {
+	// Results holds one entry per distinct ID, in request order.
+	Results  []DeleteResult `json:"results"`
+	Deleted  int            `json:"deleted"`
+	NotFound int            `json:"not_found"`
+	Failed   int            `json:"failed"`
+	// RequestID identifies the batch request in the server's logs.
+	RequestID string `json:"-"`
+}
+
+// Err returns the item's outcome as an *Error shaped like the response of a single Delete, or nil
+// if the document was deleted.
+func (r DeleteResult) Err(requestID string) error {
+	switch r.Status {
+	case DeleteStatusDeleted:
+		return nil
+	case DeleteStatusNotFound:
+		return &Error{StatusCode: http.StatusNotFound, Code: "NOT_FOUND", Message: "document not found", RequestID: requestID}
+	}
+	e := &Error{StatusCode: http.StatusInternalServerError, Code: "INTERNAL_ERROR", RequestID: requestID}
+	if r.Error != nil {
+		e.Code, e.Message = r.Error.Code, r.Error.Message
+		if e.Code == "INVALID_ID" {
+			e.StatusCode = http.StatusBadRequest
+		}
+	}
+	return e
+}
+
+// BatchDelete removes several documents in one request. Items that could not be deleted are
+// reported in the result rather than as an error; the error is only set when the request as a
+// whole fails, e.g. with more IDs than the server accepts (ErrInvalidRequest).
+func (c *Client) BatchDelete(ctx context.Context, ids []string) (*BatchDeleteResult, error) {
+	body, err := json.Marshal(map[string][]string{"ids": ids})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/documents:batchDelete",
+		header: http.Header{"Content-Type": {"application/json"}},
+		body: func() (io.Reader, int64, error) {
+			return bytes.NewReader(body), int64(len(body)), nil
+		},
+		// Deleting is idempotent; a repeated item is reported as not found.
+		retry: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var res BatchDeleteResult
+	if err := decodeJSON(resp, &res); err != nil {
+		return nil, err
+	}
+	res.RequestID = resp.Request.Header.Get(requestIDHeader)
+	return &res, nil
+}
+
+// PresignedURL is a time-limited URL that downloads a document directly from storage.
+type PresignedURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PresignURL returns a URL valid for expiry. It fails with ErrUnsupported when the storage
+// backend cannot presign URLs.
+func (c *Client) PresignURL(ctx context.Context, id string, expiry time.Duration) (*PresignedURL, error) {
+	p, err := documentPath(id, "/url")
+	if err != nil {
+		return nil, err
+	}
+	var res PresignedURL
+	if err := c.getJSON(ctx, p, url.Values{"expiry": {expiry.String()}}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// documentPath returns the API path of a document, rejecting IDs that would escape it.
+func documentPath(id string, suffix string) (string, error) {
+	if id == "" || strings.ContainsAny(id, "/?#") {
+		return "", ErrInvalidID
+	}
+	return "/documents/" + url.PathEscape(id) + suffix, nil
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ErrInvalidID is returned for document IDs that cannot be part of a URL path.
+var ErrInvalidID = errors.New("invalid document id")
+
+// Sentinel errors matched by *Error with errors.Is.
+var (
+	// ErrNotFound matches 404 responses.
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidRequest matches 400 responses.
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrPreconditionFailed matches 412 responses, e.g. updates of a document that was changed since
+	// the given version.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrRangeNotSatisfiable matches 416 responses.
+	ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+	// ErrUnsupported matches 501 responses, e.g. presigned URLs on a backend without them.
+	ErrUnsupported = errors.New("unsupported")
+	// ErrUnavailable matches 502, 503 and 504 responses.
+	ErrUnavailable = errors.New("service unavailable")
+)
+
+// Error is an error response from the API, decoded from its JSON error body.
+type Error struct {
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// Code is the machine-readable error code, e.g. "NOT_FOUND". It is empty when the response
+	// had no JSON error body, e.g. one produced by a proxy.
+	Code string
+	// Message is the human-readable error message.
+	Message string
+	// RequestID identifies the request in the server's logs.
+	RequestID string
+
+	retryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	msg := e.Code
+	if msg == "" {
+		msg = fmt.Sprintf("HTTP %d", e.StatusCode)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// Is matches the sentinel errors of this package by status code.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrInvalidRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrPreconditionFailed:
+		return e.StatusCode == http.StatusPreconditionFailed
+	case ErrRangeNotSatisfiable:
+		return e.StatusCode == http.StatusRequestedRangeNotSatisfiable
+	case ErrUnsupported:
+		return e.StatusCode == http.StatusNotImplemented
+	case ErrUnavailable:
+		return e.StatusCode == http.StatusBadGateway || e.StatusCode == http.StatusServiceUnavailable ||
+			e.StatusCode == http.StatusGatewayTimeout
+	}
+	return false
+}
+
+// decodeError builds an *Error from a non-2xx response.
+func decodeError(resp *http.Response, requestID string) *Error {
+	e := &Error{
+		StatusCode: resp.StatusCode,
+		RequestID:  requestID,
+		retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+	if id := resp.Header.Get(requestIDHeader); id != "" {
+		e.RequestID = id
+	}
+	var payload struct {
+		RequestID string `json:"request_id"`
+		Error     struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&payload); err == nil {
+		e.Code = payload.Error.Code
+		e.Message = payload.Error.Message
+		if payload.RequestID != "" {
+			e.RequestID = payload.RequestID
+		}
+	}
+	return e
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"docapi/internal/config"
+)
+
+const configUsage = "usage: docapi config check [-file path]"
+
+// runConfig implements "docapi config check", which prints the effective configuration with
+// secrets redacted, then every problem found in it. It exits non-zero if there is any.
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintln(os.Stderr, configUsage)
+		return 2
+	}
+	fs := flag.NewFlagSet("config check", flag.ContinueOnError)
+	file := fs.String("file", os.Getenv(config.ConfigFileEnv), "config file (YAML or TOML) read under the environment variables")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	cfg, err := config.LoadFile(*file)
+	return checkConfig(os.Stdout, os.Stderr, cfg, err)
+}
+
+// checkConfig writes the settings of cfg to stdout and the problems in err to stderr, and
+// returns the exit code.
+func checkConfig(stdout, stderr io.Writer, cfg *config.AppConfig, err error) int {
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, s := range cfg.Settings() {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.Key, s.Value, s.Source)
+	}
+	w.Flush()
+
+	problems := configProblems(err)
+	if len(problems) == 0 {
+		fmt.Fprintln(stderr, "configuration is valid")
+		return 0
+	}
+	fmt.Fprintf(stderr, "configuration has %d problem(s):\n", len(problems))
+	for _, p := range problems {
+		fmt.Fprintf(stderr, "  %s\n", p)
+	}
+	return 1
+}
+
+// configProblems lists the errors joined in err by config.Load, one per problem.
+func configProblems(err error) []string {
+	if err == nil {
+		return nil
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []string{err.Error()}
+	}
+	var out []string
+	for _, e := range joined.Unwrap() {
+		out = append(out, configProblems(e)...)
+	}
+	return out
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"docapi/internal/config"
+)
+
+func TestCheckConfig(t *testing.T) {
+	t.Setenv("DB_PASSWORD", "hunter2")
+	t.Setenv("DB_HOST", "")
+	t.Setenv("JOBS_WORKERS", "many")
+	cfg, err := config.LoadFile("")
+	require.Error(t, err)
+
+	var stdout, stderr bytes.Buffer
+	code := checkConfig(&stdout, &stderr, cfg, err)
+
+	assert.Equal(t, 1, code)
+	assert.Regexp(t, `(?m)^KEY\s+VALUE\s+SOURCE$`, stdout.String())
+	assert.Regexp(t, `(?m)^DB_PASSWORD\s+\[redacted\]\s+env$`, stdout.String())
+	assert.Regexp(t, `(?m)^JOBS_WORKERS\s+4\s+default$`, stdout.String())
+	assert.NotContains(t, stdout.String(), "hunter2")
+	assert.Contains(t, stderr.String(), "problem(s):\n")
+	assert.Contains(t, stderr.String(), "\n  JOBS_WORKERS: \"many\" is not an integer\n")
+	assert.Contains(t, stderr.String(), "\n  DB_HOST: is required\n")
+}
+
+func TestConfigProblems(t *testing.T) {
+	a, b, c := errors.New("A: bad"), errors.New("B: bad"), errors.New("C: bad")
+
+	assert.Nil(t, configProblems(nil))
+	assert.Equal(t, []string{"A: bad"}, configProblems(a))
+	assert.Equal(t, []string{"A: bad", "B: bad", "C: bad"}, configProblems(errors.Join(a, errors.Join(b, c))))
+	assert.Equal(t, []string{"read: A: bad"}, configProblems(fmt.Errorf("read: %w", a)))
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+
+	"docapi/internal/config"
+	"docapi/internal/health"
+	"docapi/internal/jobs"
+	"docapi/internal/scanner"
+	"docapi/internal/storage"
+)
+
+// newHealthChecker creates the checker behind the readiness probe: the database, the object
+// storage bucket and, when enabled here, the job workers and the malware scanner.
+func newHealthChecker(cfg *config.AppConfig, db *sql.DB, objStore storage.Storage, pool *jobs.Pool, clamd *scanner.Clamd) *health.Checker {
+	checks := []health.Check{
+		{Name: "database", Run: db.PingContext},
+		{Name: "storage", Run: func(ctx context.Context) error { return storage.Ping(ctx, objStore) }},
+	}
+	if cfg.Jobs.Workers > 0 {
+		checks = append(checks, health.Check{Name: "jobs", Run: func(context.Context) error { return pool.Healthy() }})
+	}
+	if clamd != nil {
+		checks = append(checks, health.Check{Name: "scanner", Run: func(ctx context.Context) error {
+			_, err := clamd.Version(ctx)
+			return err
+		}})
+	}
+	return health.NewChecker(health.Options{
+		Timeout:  cfg.Health.CheckTimeout,
+		CacheTTL: cfg.Health.CacheTTL,
+		Log: func(level, msg string, fields map[string]any) {
+			logEvent(cfg.Location, level, msg, fields)
+		},
+	}, checks...)
+}
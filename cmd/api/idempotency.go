@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"docapi/internal/config"
+	"docapi/internal/repository"
+)
+
+// idempotencyCleanupInterval is how often expired idempotency keys are deleted.
+const idempotencyCleanupInterval = time.Hour
+
+// startIdempotencyJanitor periodically deletes expired idempotency keys until ctx is done.
+func startIdempotencyJanitor(ctx context.Context, cfg *config.AppConfig, repo repository.IdempotencyRepository) {
+	go func() {
+		ticker := time.NewTicker(idempotencyCleanupInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n, err := repo.DeleteExpired(ctx, time.Now())
+				if err != nil {
+					logEvent(cfg.Location, "error", "idempotency_cleanup_failed", map[string]any{"error": err.Error()})
+					continue
+				}
+				logEvent(cfg.Location, "info", "idempotency_cleanup_completed", map[string]any{"deleted": n})
+			}
+		}
+	}()
+}
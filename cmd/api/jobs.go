@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"docapi/internal/config"
+	"docapi/internal/jobs"
+	"docapi/internal/repository"
+	"docapi/internal/service"
+	"docapi/internal/storage"
+)
+
+// newJobPool creates the background job worker pool with the handlers of every job kind
+// registered. It is started by the caller.
+func newJobPool(cfg *config.AppConfig, repo repository.JobRepository, objStore storage.Storage) (*jobs.Pool, error) {
+	metrics, err := jobs.NewMetrics(prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, err
+	}
+	pool := jobs.NewPool(repo, jobs.PoolOptions{
+		Workers:           cfg.Jobs.Workers,
+		PollInterval:      cfg.Jobs.PollInterval,
+		VisibilityTimeout: cfg.Jobs.VisibilityTimeout,
+		BackoffBase:       cfg.Jobs.BackoffBase,
+		BackoffMax:        cfg.Jobs.BackoffMax,
+		Log: func(level, msg string, fields map[string]any) {
+			logEvent(cfg.Location, level, msg, fields)
+		},
+	}, metrics)
+
+	jobs.Register(pool, service.JobDeleteObject, service.DeleteObjectHandler(objStore))
+	return pool, nil
+}
+
+// drainJobs stops the pool from claiming jobs and gives the running ones DrainTimeout to
+// finish; jobs still running then are canceled and queued again.
+func drainJobs(cfg *config.AppConfig, pool *jobs.Pool) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Jobs.DrainTimeout)
+	defer cancel()
+	if err := pool.Shutdown(ctx); err != nil {
+		logEvent(cfg.Location, "warn", "jobs_drain_incomplete", map[string]any{"error": err.Error()})
+		return
+	}
+	logEvent(cfg.Location, "info", "jobs_drained", nil)
+}
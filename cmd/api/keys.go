@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"docapi/internal/config"
+	"docapi/internal/database"
+	"docapi/internal/repository"
+	"docapi/internal/repository/postgres"
+	"docapi/internal/storage"
+)
+
+// runKeys implements "docapi keys <subcommand>" for managing storage encryption keys.
+func runKeys(cfg *config.AppConfig, args []string) int {
+	if len(args) == 0 || args[0] != "rewrap" {
+		fmt.Fprintln(os.Stderr, "usage: docapi keys rewrap [-dry-run] [-batch N]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("keys rewrap", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only report objects that would be re-wrapped")
+	batch := fs.Int("batch", 100, "number of documents read from the database per page")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	if err := rewrapKeys(cfg, *dryRun, *batch); err != nil {
+		logEvent(cfg.Location, "error", "keys_rewrap_failed", map[string]any{"error": err.Error()})
+		return 1
+	}
+	return 0
+}
+
+// rewrapKeys re-wraps the data key of every stored document with the current master key.
+// Only object metadata is rewritten, never the encrypted payloads, so it is cheap to run after
+// adding a new key and switching STORAGE_ENCRYPTION_KEY_ID; once it reports zero remaining
+// objects the old key can be removed from the key ring.
+func rewrapKeys(cfg *config.AppConfig, dryRun bool, batch int) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	keys, err := storage.LoadKeyRing(cfg.Encryption)
+	if err != nil {
+		return fmt.Errorf("load encryption keys: %w", err)
+	}
+	// Re-wrapping operates on the raw objects, underneath the encryption decorator.
+	objStore, err := storage.NewMinIO(cfg.MinIO)
+	if err != nil {
+		return fmt.Errorf("initialize object storage: %w", err)
+	}
+	db, err := database.NewPostgres(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("connect to database: %w", err)
+	}
+	defer db.Close()
+	docRepo := postgres.NewDocumentPostgres(db)
+
+	var scanned, rewrapped, failed int
+	for offset := 0; ; offset += batch {
+		page, err := docRepo.List(ctx, repository.PageQuery{Limit: batch, Offset: offset})
+		if err != nil {
+			return fmt.Errorf("list documents: %w", err)
+		}
+		for _, doc := range page.Items {
+			scanned++
+			changed, err := rewrapOne(ctx, objStore, keys, doc.StoragePath, dryRun)
+			if err != nil {
+				failed++
+				logEvent(cfg.Location, "error", "keys_rewrap_object_failed", map[string]any{
+					"document_id":  doc.ID,
+					"storage_path": doc.StoragePath,
+					"error":        err.Error(),
+				})
+				continue
+			}
+			if changed {
+				rewrapped++
+			}
+		}
+		if len(page.Items) < batch {
+			break
+		}
+	}
+
+	logEvent(cfg.Location, "info", "keys_rewrap_completed", map[string]any{
+		"current_key_id": keys.CurrentKeyID(),
+		"dry_run":        dryRun,
+		"scanned":        scanned,
+		"rewrapped":      rewrapped,
+		"failed":         failed,
+	})
+	if failed > 0 {
+		return fmt.Errorf("%d objects could not be re-wrapped", failed)
+	}
+	return nil
+}
+
+func rewrapOne(ctx context.Context, objStore storage.Storage, keys *storage.KeyRing, key string, dryRun bool) (bool, error) {
+	if !dryRun {
+		return storage.RewrapKey(ctx, objStore, keys, key)
+	}
+	keyID, err := storage.EncryptionKeyID(ctx, objStore, key)
+	if err != nil {
+		return false, err
+	}
+	return keyID != "" && keyID != keys.CurrentKeyID(), nil
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -21,8 +22,10 @@ import (
 	"docapi/internal/database"
 	handlers "docapi/internal/http/handler"
 	"docapi/internal/http/middleware"
+	"docapi/internal/jobs"
 	"docapi/internal/otel"
 	"docapi/internal/repository/postgres"
+	"docapi/internal/scanner"
 	"docapi/internal/service"
 	"docapi/internal/storage"
 )
@@ -30,40 +33,118 @@ import (
 // @title Document API
 // @version 1.0
 // @BasePath /
+// @securityDefinitions.apikey AdminToken
+// @in header
+// @name Authorization
+// @description Administrator token configured with ADMIN_TOKEN, sent as "Bearer <token>".
 func main() {
-	// Load configuration from environment variables (.env auto-loaded if present)
-	cfg := config.Load()
+	// "config check" reports the problems of the configuration instead of failing on them
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfig(os.Args[2:]))
+	}
+
+	// Load configuration from defaults, CONFIG_FILE and environment variables (.env auto-loaded
+	// if present), and refuse to start with any problem in it
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("invalid configuration:\n  %s", strings.Join(configProblems(err), "\n  "))
+	}
+
+	// Without arguments (or with "serve") the HTTP server is started; other commands are
+	// one-off maintenance tasks that share the same configuration.
+	if len(os.Args) > 1 && os.Args[1] != "serve" {
+		switch os.Args[1] {
+		case "keys":
+			os.Exit(runKeys(cfg, os.Args[2:]))
+		case "migrate":
+			os.Exit(runMigrate(cfg, os.Args[2:]))
+		case "reconcile":
+			os.Exit(runReconcile(cfg, os.Args[2:]))
+		default:
+			log.Fatalf("unknown command %q (available: serve, config, keys, migrate, reconcile)", os.Args[1])
+		}
+	}
+
+	serve(cfg)
+}
 
+// serve runs the HTTP API until SIGINT/SIGTERM, then shuts it down gracefully.
+func serve(cfg *config.AppConfig) {
 	// Initialize OpenTelemetry tracing
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	// Tracing is flushed and the database closed by shutdown, once the requests and jobs using
+	// them are done.
 	otelShutdown, err := otel.Init(ctx, cfg.Location)
 	if err != nil {
 		log.Fatalf("failed to initialize tracing: %v", err)
 	}
-	defer func() {
-		if err := otelShutdown(context.Background()); err != nil {
-			log.Printf("failed to shutdown tracing: %v", err)
-		}
-	}()
 
 	// Initialize PostgreSQL connection (with pooling via database/sql)
-	db, err := database.NewPostgres(cfg.Database)
+	db, dbConnector, err := database.OpenPostgres(cfg.Database)
 	if err != nil {
 		log.Fatalf("failed to connect to database: %v", err)
 	}
-	defer db.Close()
+
+	if cfg.Database.AutoMigrate {
+		if err := migrateUp(ctx, cfg, db); err != nil {
+			log.Fatalf("failed to migrate database: %v", err)
+		}
+	}
 
 	// Initialize reusable S3-compatible object storage client (MinIO-supported)
-	objStore, err := storage.NewMinIO(cfg.MinIO)
+	objStore, minioStore, err := newObjectStorage(cfg)
 	if err != nil {
 		log.Fatalf("failed to initialize object storage: %v", err)
 	}
 
+	// Apply rotated database and MinIO credentials on SIGHUP or when their files change
+	startCredentialReloader(ctx, cfg, dbConnector, minioStore)
+
 	// Initialize repositories and services
 	docRepo := postgres.NewDocumentPostgres(db)
-	docSvc := service.NewDocumentService(objStore, docRepo)
+	docOpts := []service.Option{
+		service.WithBatchDelete(cfg.Batch.DeleteMaxIDs, cfg.Batch.DeleteConcurrency),
+		service.WithBatchUpload(cfg.Batch.UploadMaxFiles, cfg.Batch.UploadConcurrency),
+		service.WithArchiveLimit(cfg.Batch.ArchiveMaxDocuments),
+		service.WithImportLimits(service.ImportLimits{
+			MaxEntries:   cfg.Import.MaxEntries,
+			MaxEntrySize: cfg.Import.MaxEntrySize,
+			MaxTotalSize: cfg.Import.MaxTotalSize,
+			MaxRatio:     cfg.Import.MaxRatio,
+		}),
+	}
+	// Failed rollback deletions are retried by background jobs
+	jobRepo := postgres.NewJobPostgres(db)
+	docOpts = append(docOpts, service.WithJobQueue(jobs.NewQueue(jobRepo, cfg.Jobs.MaxAttempts)))
+	// Scan uploads for malware with clamd when it is configured
+	var clamd *scanner.Clamd
+	if cfg.Scan.ClamdAddr != "" {
+		clamd, err = scanner.NewClamd(cfg.Scan)
+		if err != nil {
+			log.Fatalf("failed to initialize malware scanner: %v", err)
+		}
+		docOpts = append(docOpts, service.WithScanner(clamd))
+	}
+	docSvc := service.NewDocumentService(objStore, docRepo, docOpts...)
+
+	// Periodically report, and optionally repair, drift between object storage and the database
+	reconcileMetrics, err := service.NewReconcileMetrics(prometheus.DefaultRegisterer)
+	if err != nil {
+		log.Fatalf("failed to initialize reconcile metrics: %v", err)
+	}
+	grace := cfg.Reconcile.GracePeriod
+	startReconciler(ctx, cfg, service.NewReconciler(objStore, docRepo, grace, reconcileMetrics))
+
+	// Run background jobs here unless workers are disabled; jobs stay queued for other instances
+	jobPool, err := newJobPool(cfg, jobRepo, objStore)
+	if err != nil {
+		log.Fatalf("failed to initialize job workers: %v", err)
+	}
+	if cfg.Jobs.Workers > 0 {
+		jobPool.Start()
+	}
 
 	app := fiber.New(fiber.Config{
 		ErrorHandler:          handlers.ErrorHandler(),
@@ -77,7 +158,11 @@ func main() {
 	}
 
 	// Register global middleware
-	// Tracing middleware should be first to capture the whole request
+	// Request contexts derive from requestCtx, which shutdown cancels when its grace period ends
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	app.Use(middleware.RequestContext(requestCtx))
+	// Tracing middleware should come next to capture the whole request
 	app.Use(otelfiber.Middleware())
 	// RequestID middleware adds/propagates X-Request-ID and stores it in context
 	app.Use(middleware.RequestID())
@@ -85,10 +170,36 @@ func main() {
 	app.Use(middleware.Logger(cfg.Location))
 	// Prometheus middleware to track request count
 	app.Use(promMiddleware.Handler())
+	// Idempotency middleware replays responses to retried requests with an Idempotency-Key
+	if cfg.Idempotency.TTL > 0 {
+		idemRepo := postgres.NewIdempotencyPostgres(db)
+		app.Use(middleware.Idempotency(idemRepo, middleware.IdempotencyOptions{
+			TTL:         cfg.Idempotency.TTL,
+			LockTimeout: cfg.Idempotency.LockTimeout,
+			Wait:        cfg.Idempotency.Wait,
+			OnError: func(err error) {
+				logEvent(cfg.Location, "error", "idempotency_store_failed", map[string]any{"error": err.Error()})
+			},
+		}))
+		startIdempotencyJanitor(ctx, cfg, idemRepo)
+	}
 
 	// Register HTTP routes with injected service
 	handlers.RegisterRoutes(app, db, docSvc)
 
+	// Startup, liveness and readiness probes; startup succeeds once the server listens
+	checker := newHealthChecker(cfg, db, objStore, jobPool, clamd)
+	handlers.RegisterProbeRoutes(app, checker)
+	app.Hooks().OnListen(func(fiber.ListenData) error {
+		checker.MarkStarted()
+		return nil
+	})
+
+	// Administrative routes, such as reviewing quarantined documents, only exist with a token
+	if cfg.Admin.Token != "" {
+		handlers.RegisterAdminRoutes(app.Group("/admin", middleware.BearerToken(cfg.Admin.Token)), docSvc)
+	}
+
 	// Swagger UI with dynamic host and scheme
 	app.Get("/swagger/*", func(c *fiber.Ctx) error {
 		scheme := c.Protocol()
@@ -124,8 +235,56 @@ func main() {
 	}()
 
 	<-ctx.Done()
-	log.Println("shutting down server...")
-	if err := app.Shutdown(); err != nil {
-		log.Printf("server shutdown error: %v", err)
+	// A second signal stops the process without waiting
+	stop()
+	shutdown(cfg, app, checker, cancelRequests, jobPool, otelShutdown, db)
+}
+
+// newObjectStorage builds the object storage stack: the MinIO client, wrapped with
+// envelope encryption when enabled. The MinIO client sits in the returned Swappable, so that
+// it can be replaced by one with rotated credentials.
+func newObjectStorage(cfg *config.AppConfig) (storage.Storage, *storage.Swappable, error) {
+	client, err := storage.NewMinIO(cfg.MinIO)
+	if err != nil {
+		return nil, nil, err
+	}
+	minioStore := storage.NewSwappable(client)
+	var objStore storage.Storage = minioStore
+	if cfg.Encryption.Enabled {
+		keys, err := storage.LoadKeyRing(cfg.Encryption)
+		if err != nil {
+			return nil, nil, fmt.Errorf("load encryption keys: %w", err)
+		}
+		if objStore, err = storage.NewEncrypted(objStore, keys); err != nil {
+			return nil, nil, err
+		}
+	}
+	// Compression wraps encryption: ciphertext doesn't compress.
+	if cfg.Compression.Algorithm != "" {
+		metrics, err := storage.NewCompressionMetrics(prometheus.DefaultRegisterer)
+		if err != nil {
+			return nil, nil, err
+		}
+		objStore, err = storage.NewCompressed(objStore, storage.CompressionOptionsFromConfig(cfg.Compression), metrics)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return objStore, minioStore, nil
+}
+
+// logEvent writes a single structured JSON log line in the same shape as the request logs.
+func logEvent(loc *time.Location, level, msg string, fields map[string]any) {
+	entry := map[string]any{
+		"ts":    time.Now().In(loc).Format(time.RFC3339Nano),
+		"level": level,
+		"msg":   msg,
+	}
+	for k, v := range fields {
+		entry[k] = v
+	}
+	if b, err := json.Marshal(entry); err == nil {
+		log.SetFlags(0)
+		log.Println(string(b))
 	}
 }
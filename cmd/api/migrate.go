@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"docapi/internal/config"
+	"docapi/internal/database"
+)
+
+const migrateUsage = "usage: docapi migrate up | down [-steps N] | status"
+
+// runMigrate implements "docapi migrate <subcommand>" for managing the database schema.
+func runMigrate(cfg *config.AppConfig, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	steps := 1
+	switch args[0] {
+	case "up", "status":
+		if len(args) > 1 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+	case "down":
+		fs := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		fs.IntVar(&steps, "steps", 1, "number of migrations to roll back")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := database.NewPostgres(cfg.Database)
+	if err != nil {
+		logEvent(cfg.Location, "error", "migrate_failed", map[string]any{"error": fmt.Sprintf("connect to database: %v", err)})
+		return 1
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		err = migrateUp(ctx, cfg, db)
+	case "down":
+		err = migrateDown(ctx, cfg, db, steps)
+	case "status":
+		err = migrateStatus(ctx, db)
+	}
+	if err != nil {
+		logEvent(cfg.Location, "error", "migrate_failed", map[string]any{"error": err.Error()})
+		return 1
+	}
+	return 0
+}
+
+// migrateUp applies all pending migrations; it is also used for auto-migration on startup.
+func migrateUp(ctx context.Context, cfg *config.AppConfig, db *sql.DB) error {
+	m, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	done, err := m.Up(ctx)
+	for _, mig := range done {
+		logEvent(cfg.Location, "info", "migration_applied", map[string]any{"version": mig.Version, "name": mig.Name})
+	}
+	if err != nil {
+		return err
+	}
+	logEvent(cfg.Location, "info", "migrate_up_completed", map[string]any{"applied": len(done)})
+	return nil
+}
+
+func migrateDown(ctx context.Context, cfg *config.AppConfig, db *sql.DB, steps int) error {
+	m, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	done, err := m.Down(ctx, steps)
+	for _, mig := range done {
+		logEvent(cfg.Location, "info", "migration_rolled_back", map[string]any{"version": mig.Version, "name": mig.Name})
+	}
+	if err != nil {
+		return err
+	}
+	logEvent(cfg.Location, "info", "migrate_down_completed", map[string]any{"rolled_back": len(done)})
+	return nil
+}
+
+func migrateStatus(ctx context.Context, db *sql.DB) error {
+	m, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+	for _, st := range status {
+		appliedAt := "-"
+		if !st.AppliedAt.IsZero() {
+			appliedAt = st.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", st.Version, st.Name, st.State, appliedAt)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"docapi/internal/config"
+	"docapi/internal/database"
+	"docapi/internal/repository/postgres"
+	"docapi/internal/service"
+)
+
+// runReconcile implements "docapi reconcile", a one-off run of the storage/database
+// reconciliation job.
+func runReconcile(cfg *config.AppConfig, args []string) int {
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", cfg.Reconcile.DryRun, "only report drift, do not delete anything")
+	grace := fs.Duration("grace", cfg.Reconcile.GracePeriod, "skip objects and documents younger than this")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "usage: docapi reconcile [-dry-run] [-grace D]")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	objStore, _, err := newObjectStorage(cfg)
+	if err != nil {
+		logEvent(cfg.Location, "error", "reconcile_failed", map[string]any{"error": fmt.Sprintf("initialize object storage: %v", err)})
+		return 1
+	}
+	db, err := database.NewPostgres(cfg.Database)
+	if err != nil {
+		logEvent(cfg.Location, "error", "reconcile_failed", map[string]any{"error": fmt.Sprintf("connect to database: %v", err)})
+		return 1
+	}
+	defer db.Close()
+
+	rec := service.NewReconciler(objStore, postgres.NewDocumentPostgres(db), *grace, nil)
+	report, err := reconcileOnce(ctx, cfg.Location, rec, *dryRun)
+	if err != nil || report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// startReconciler runs the reconciliation job every cfg.Reconcile.Interval until ctx
+// is done. It does nothing when no interval is configured.
+func startReconciler(ctx context.Context, cfg *config.AppConfig, rec *service.Reconciler) {
+	if cfg.Reconcile.Interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(cfg.Reconcile.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				reconcileOnce(ctx, cfg.Location, rec, cfg.Reconcile.DryRun)
+			}
+		}
+	}()
+}
+
+// reconcileOnce runs rec and logs one event per drifting item and a summary.
+func reconcileOnce(ctx context.Context, loc *time.Location, rec *service.Reconciler, dryRun bool) (*service.ReconcileReport, error) {
+	report, err := rec.Run(ctx, dryRun)
+	if err != nil {
+		logEvent(loc, "error", "reconcile_failed", map[string]any{"error": err.Error()})
+		return nil, err
+	}
+	for _, key := range report.OrphanObjects {
+		logEvent(loc, "warn", "reconcile_orphan_object", map[string]any{"storage_path": key, "dry_run": dryRun})
+	}
+	for _, doc := range report.DanglingDocuments {
+		logEvent(loc, "warn", "reconcile_dangling_document", map[string]any{
+			"document_id":  doc.ID,
+			"storage_path": doc.StoragePath,
+			"dry_run":      dryRun,
+		})
+	}
+	logEvent(loc, "info", "reconcile_completed", map[string]any{
+		"dry_run":            dryRun,
+		"scanned_objects":    report.ScannedObjects,
+		"scanned_documents":  report.ScannedDocuments,
+		"orphan_objects":     len(report.OrphanObjects),
+		"dangling_documents": len(report.DanglingDocuments),
+		"repaired":           report.Repaired,
+		"failed":             report.Failed,
+	})
+	return report, nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"docapi/internal/config"
+	"docapi/internal/database"
+	"docapi/internal/storage"
+)
+
+// credentialUpdateTimeout bounds the check of new database credentials before they are used.
+const credentialUpdateTimeout = 10 * time.Second
+
+// credentialReloader applies rotated credentials while the server runs. It reloads the
+// configuration on SIGHUP, or when the secret files or the config file change, and moves the
+// database and object storage to the new credentials once they are verified. Other settings
+// need a restart to change.
+type credentialReloader struct {
+	loc        *time.Location
+	configFile string
+	load       func() (*config.AppConfig, error)
+	// updateDB makes new database connections use the configuration; swapStorage replaces the
+	// MinIO client with one built from the configuration.
+	updateDB    func(ctx context.Context, c config.DatabaseConfig) error
+	swapStorage func(c config.MinIOConfig) error
+
+	// The credentials in use, the secret files they came from, and the fingerprint of the
+	// files when they were last loaded.
+	database config.DatabaseConfig
+	minio    config.MinIOConfig
+	files    []string
+	seen     [sha256.Size]byte
+}
+
+// startCredentialReloader reloads the credentials of connector and minioStore until ctx is done.
+func startCredentialReloader(ctx context.Context, cfg *config.AppConfig, connector *database.Connector, minioStore *storage.Swappable) {
+	r := &credentialReloader{
+		loc:        cfg.Location,
+		configFile: os.Getenv(config.ConfigFileEnv),
+		load:       config.Load,
+		updateDB:   connector.Update,
+		swapStorage: func(c config.MinIOConfig) error {
+			client, err := storage.NewMinIO(c)
+			if err != nil {
+				return err
+			}
+			minioStore.Swap(client)
+			return nil
+		},
+		database: cfg.Database,
+		minio:    cfg.MinIO,
+		files:    cfg.SecretFiles(),
+	}
+	r.seen = r.fingerprint()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		r.watch(ctx, cfg.Secrets.PollInterval, hup)
+	}()
+}
+
+// watch reloads on every value received from hup and, unless interval is zero, whenever the
+// content of the watched files differs from r.seen, their content at the previous load. The files are
+// hashed before they are loaded, so that a file still being written when it is loaded is
+// loaded again at the next check.
+func (r *credentialReloader) watch(ctx context.Context, interval time.Duration, hup <-chan os.Signal) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.seen = r.fingerprint()
+			r.reload(ctx, "signal")
+		case <-tick:
+			if fp := r.fingerprint(); fp != r.seen {
+				r.seen = fp
+				r.reload(ctx, "file_change")
+			}
+		}
+	}
+}
+
+// fingerprint hashes the paths and contents of the config file and the secret files. Secrets
+// mounted by Kubernetes are replaced through a symlink, so the content is compared rather than
+// modification times.
+func (r *credentialReloader) fingerprint() [sha256.Size]byte {
+	h := sha256.New()
+	for _, path := range append([]string{r.configFile}, r.files...) {
+		if path == "" {
+			continue
+		}
+		h.Write([]byte(path))
+		h.Write([]byte{0})
+		if b, err := os.ReadFile(path); err == nil {
+			h.Write(b)
+		}
+		h.Write([]byte{0})
+	}
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// reload loads the configuration and applies the credentials that changed. Credentials that
+// cannot be loaded or verified are not applied; the ones in use are kept and the problem logged.
+func (r *credentialReloader) reload(ctx context.Context, trigger string) {
+	cfg, err := r.load()
+	if err != nil {
+		logEvent(r.loc, "error", "credentials_reload_failed", map[string]any{
+			"trigger": trigger,
+			"error":   strings.Join(configProblems(err), "; "),
+		})
+		return
+	}
+	r.files = cfg.SecretFiles()
+
+	fields := map[string]any{"trigger": trigger, "database": "unchanged", "storage": "unchanged"}
+	level := "info"
+	if databaseCredentialsChanged(r.database, cfg.Database) {
+		updateCtx, cancel := context.WithTimeout(ctx, credentialUpdateTimeout)
+		err := r.updateDB(updateCtx, cfg.Database)
+		cancel()
+		if err != nil {
+			fields["database"], fields["database_error"], level = "failed", err.Error(), "error"
+		} else {
+			fields["database"], r.database = "reloaded", cfg.Database
+		}
+	}
+	if storageCredentialsChanged(r.minio, cfg.MinIO) {
+		if err := r.swapStorage(cfg.MinIO); err != nil {
+			fields["storage"], fields["storage_error"], level = "failed", err.Error(), "error"
+		} else {
+			fields["storage"], r.minio = "reloaded", cfg.MinIO
+		}
+	}
+	logEvent(r.loc, level, "credentials_reloaded", fields)
+}
+
+// databaseCredentialsChanged reports whether connections made with b differ from those made
+// with a. Pool settings are not compared; they need a restart.
+func databaseCredentialsChanged(a, b config.DatabaseConfig) bool {
+	dsnA, _ := database.BuildPostgresDSN(a)
+	dsnB, _ := database.BuildPostgresDSN(b)
+	return dsnA != dsnB
+}
+
+// storageCredentialsChanged reports whether a MinIO client built from b differs from one built
+// from a.
+func storageCredentialsChanged(a, b config.MinIOConfig) bool {
+	return a.Endpoint != b.Endpoint || a.AccessKey != b.AccessKey || a.SecretKey != b.SecretKey ||
+		a.Bucket != b.Bucket || a.UseSSL != b.UseSSL
+}
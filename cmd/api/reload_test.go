@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"docapi/internal/config"
+)
+
+func TestCredentialReloader_Reload(t *testing.T) {
+	current := &config.AppConfig{
+		Database: config.DatabaseConfig{Host: "db", Port: "5432", User: "app", Password: "old", Name: "docapi"},
+		MinIO:    config.MinIOConfig{Endpoint: "minio:9000", AccessKey: "app", SecretKey: "old", Bucket: "docs"},
+	}
+	tests := []struct {
+		name         string
+		change       func(c *config.AppConfig)
+		loadErr      error
+		dbErr        error
+		storageErr   error
+		wantDB       string
+		wantStorage  string
+		wantUpdateDB bool
+		wantSwap     bool
+	}{
+		{
+			name:        "unchanged",
+			change:      func(c *config.AppConfig) { c.Database.MaxOpenConns = 50 },
+			wantDB:      "old",
+			wantStorage: "old",
+		},
+		{
+			name:         "database password rotated",
+			change:       func(c *config.AppConfig) { c.Database.Password = "new" },
+			wantDB:       "new",
+			wantStorage:  "old",
+			wantUpdateDB: true,
+		},
+		{
+			name:        "storage secret rotated",
+			change:      func(c *config.AppConfig) { c.MinIO.SecretKey = "new" },
+			wantDB:      "old",
+			wantStorage: "new",
+			wantSwap:    true,
+		},
+		{
+			name: "rejected credentials are not applied",
+			change: func(c *config.AppConfig) {
+				c.Database.Password = "new"
+				c.MinIO.SecretKey = "new"
+			},
+			dbErr:        errors.New("password authentication failed"),
+			storageErr:   errors.New("access denied"),
+			wantDB:       "old",
+			wantStorage:  "old",
+			wantUpdateDB: true,
+			wantSwap:     true,
+		},
+		{
+			name:        "invalid configuration",
+			change:      func(c *config.AppConfig) { c.Database.Password = "new" },
+			loadErr:     errors.New("DB_PORT: must be a port number"),
+			wantDB:      "old",
+			wantStorage: "old",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var updatedDB, swapped bool
+			r := &credentialReloader{
+				loc: time.UTC,
+				load: func() (*config.AppConfig, error) {
+					next := *current
+					tt.change(&next)
+					return &next, tt.loadErr
+				},
+				updateDB: func(_ context.Context, c config.DatabaseConfig) error {
+					updatedDB = true
+					return tt.dbErr
+				},
+				swapStorage: func(c config.MinIOConfig) error {
+					swapped = true
+					return tt.storageErr
+				},
+				database: current.Database,
+				minio:    current.MinIO,
+			}
+
+			r.reload(context.Background(), "signal")
+
+			assert.Equal(t, tt.wantUpdateDB, updatedDB)
+			assert.Equal(t, tt.wantSwap, swapped)
+			assert.Equal(t, tt.wantDB, r.database.Password)
+			assert.Equal(t, tt.wantStorage, r.minio.SecretKey)
+		})
+	}
+}
+
+func TestCredentialReloader_Watch(t *testing.T) {
+	for k, v := range map[string]string{
+		"DB_HOST": "db", "DB_USER": "app", "DB_PASSWORD": "secret", "DB_NAME": "docapi",
+		"MINIO_ENDPOINT": "minio:9000", "MINIO_ACCESS_KEY": "app", "MINIO_BUCKET": "docs",
+	} {
+		t.Setenv(k, v)
+	}
+	secretFile := filepath.Join(t.TempDir(), "minio_secret_key")
+	require.NoError(t, os.WriteFile(secretFile, []byte("first\n"), 0o600))
+	t.Setenv("MINIO_SECRET_KEY_FILE", secretFile)
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	require.Equal(t, "first", cfg.MinIO.SecretKey)
+
+	swaps := make(chan string, 10)
+	r := &credentialReloader{
+		loc:  time.UTC,
+		load: config.Load,
+		updateDB: func(context.Context, config.DatabaseConfig) error {
+			t.Error("the database credentials did not change")
+			return nil
+		},
+		swapStorage: func(c config.MinIOConfig) error {
+			swaps <- c.SecretKey
+			return nil
+		},
+		database: cfg.Database,
+		minio:    cfg.MinIO,
+		files:    cfg.SecretFiles(),
+	}
+	r.seen = r.fingerprint()
+	hup := make(chan os.Signal, 1)
+	start := func(interval time.Duration) (stop func()) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			r.watch(ctx, interval, hup)
+		}()
+		return func() {
+			cancel()
+			<-done
+		}
+	}
+
+	stop := start(10 * time.Millisecond)
+	require.NoError(t, os.WriteFile(secretFile, []byte("second\n"), 0o600))
+	select {
+	case got := <-swaps:
+		assert.Equal(t, "second", got, "a changed secret file is reloaded")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the changed secret file was not reloaded")
+	}
+
+	stop()
+	assert.Empty(t, swaps, "unchanged files are not reloaded again")
+
+	// Without polling, a rotation is applied on SIGHUP.
+	stop = start(0)
+	defer stop()
+	require.NoError(t, os.WriteFile(secretFile, []byte("third"), 0o600))
+	hup <- syscall.SIGHUP
+	select {
+	case got := <-swaps:
+		assert.Equal(t, "third", got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("SIGHUP did not reload the credentials")
+	}
+}
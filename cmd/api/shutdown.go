@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"docapi/internal/config"
+	"docapi/internal/health"
+	"docapi/internal/jobs"
+)
+
+// traceFlushTimeout bounds the export of the spans still buffered at shutdown.
+const traceFlushTimeout = 5 * time.Second
+
+// shutdown stops the server in the order that loses the least work:
+//
+//  1. the readiness probe fails, for ReadinessDelay before anything else happens;
+//  2. the server stops accepting connections and waits for the requests in flight, including
+//     uploads still being received and downloads still being sent;
+//  3. requests still running after GracePeriod are canceled through cancelRequests, so their
+//     storage transfers abort and the services roll back, within AbortTimeout;
+//  4. the job workers drain;
+//  5. buffered traces are flushed and the database is closed.
+func shutdown(cfg *config.AppConfig, app *fiber.App, checker *health.Checker, cancelRequests context.CancelFunc,
+	pool *jobs.Pool, flushTraces func(context.Context) error, db *sql.DB) {
+	logEvent(cfg.Location, "info", "shutdown_started", nil)
+	checker.Drain()
+	time.Sleep(cfg.Shutdown.ReadinessDelay)
+
+	grace := cfg.Shutdown.GracePeriod
+	abort := cfg.Shutdown.AbortTimeout
+	ctx, cancel := context.WithTimeout(context.Background(), grace+abort)
+	defer cancel()
+	// The server waits for connections only while ShutdownWithContext runs, so the requests are
+	// canceled from a timer rather than after it returns.
+	timer := time.AfterFunc(grace, func() {
+		logEvent(cfg.Location, "warn", "shutdown_canceling_requests", map[string]any{
+			"open_connections": app.Server().GetOpenConnectionsCount(),
+		})
+		cancelRequests()
+	})
+	err := app.ShutdownWithContext(ctx)
+	canceled := !timer.Stop()
+	cancelRequests()
+	if err != nil {
+		logEvent(cfg.Location, "error", "http_drain_incomplete", map[string]any{
+			"error":            err.Error(),
+			"open_connections": app.Server().GetOpenConnectionsCount(),
+		})
+	} else {
+		logEvent(cfg.Location, "info", "http_drained", map[string]any{"canceled_requests": canceled})
+	}
+
+	drainJobs(cfg, pool)
+
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), traceFlushTimeout)
+	defer cancelFlush()
+	if err := flushTraces(flushCtx); err != nil {
+		logEvent(cfg.Location, "error", "tracing_shutdown_failed", map[string]any{"error": err.Error()})
+	}
+	if err := db.Close(); err != nil {
+		logEvent(cfg.Location, "error", "database_close_failed", map[string]any{"error": err.Error()})
+	}
+	logEvent(cfg.Location, "info", "shutdown_complete", nil)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"docapi/internal/config"
+	"docapi/internal/health"
+	"docapi/internal/http/middleware"
+	"docapi/internal/jobs"
+	"docapi/internal/repository/memory"
+)
+
+func TestShutdown(t *testing.T) {
+	cfg := &config.AppConfig{
+		Location: time.UTC,
+		Shutdown: config.ShutdownConfig{GracePeriod: time.Second, AbortTimeout: time.Second},
+		Jobs:     config.JobsConfig{DrainTimeout: time.Second},
+	}
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(middleware.RequestContext(requestCtx))
+	started := make(chan struct{}, 2)
+	var stuckCanceled atomic.Bool
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		started <- struct{}{}
+		time.Sleep(300 * time.Millisecond)
+		return c.SendString("done")
+	})
+	app.Get("/stuck", func(c *fiber.Ctx) error {
+		started <- struct{}{}
+		<-c.UserContext().Done()
+		stuckCanceled.Store(true)
+		return c.SendStatus(fiber.StatusServiceUnavailable)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go app.Listener(ln)
+	base := "http://" + ln.Addr().String()
+
+	statuses := make(chan int, 2)
+	for _, path := range []string{"/slow", "/stuck"} {
+		go func() {
+			resp, err := http.Get(base + path)
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	<-started
+	<-started
+
+	db, dbMock, err := sqlmock.New()
+	require.NoError(t, err)
+	dbMock.ExpectClose()
+	checker := health.NewChecker(health.Options{})
+	var flushed atomic.Bool
+	flush := func(context.Context) error {
+		assert.False(t, checker.Check(context.Background()).Up())
+		flushed.Store(true)
+		return nil
+	}
+
+	begin := time.Now()
+	shutdown(cfg, app, checker, cancelRequests, jobs.NewPool(memory.NewJobMemory(), jobs.PoolOptions{}, nil), flush, db)
+	took := time.Since(begin)
+
+	assert.ElementsMatch(t, []int{http.StatusOK, http.StatusServiceUnavailable}, []int{<-statuses, <-statuses},
+		"the slow request completes, the stuck one is canceled")
+	assert.True(t, stuckCanceled.Load())
+	assert.GreaterOrEqual(t, took, time.Second, "in-flight requests get the grace period")
+	assert.Less(t, took, 2*time.Second)
+	assert.Equal(t, health.StatusDraining, checker.Check(context.Background()).Status)
+	assert.True(t, flushed.Load())
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+
+	_, err = http.Get(base + "/slow")
+	assert.Error(t, err, "no new connections are accepted")
+}
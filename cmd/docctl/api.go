@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"docapi/client"
+)
+
+func newAPIClient(s settings) (*client.Client, error) {
+	return client.New(s.Endpoint, client.WithToken(s.Token))
+}
+
+// apiExitCode maps the error code to an exit code, falling back to the HTTP status for unknown codes.
+func apiExitCode(e *client.Error) int {
+	switch {
+	case e.Code == "NOT_FOUND":
+		return exitNotFound
+	case e.Code == "BAD_REQUEST", e.Code == "FILE_REQUIRED", e.Code == "FILE_OPEN_ERROR",
+		e.Code == "RANGE_NOT_SATISFIABLE", strings.HasPrefix(e.Code, "INVALID_"):
+		return exitInvalid
+	case e.Code == "PRESIGN_UNSUPPORTED", e.Code == "METHOD_NOT_ALLOWED":
+		return exitUnsupported
+	case e.Code == "INTERNAL_ERROR", e.Code == "SERVICE_UNAVAILABLE":
+		return exitServer
+	case e.StatusCode == http.StatusNotFound:
+		return exitNotFound
+	case e.StatusCode >= 500:
+		return exitServer
+	case e.StatusCode >= 400:
+		return exitInvalid
+	default:
+		return exitError
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const defaultEndpoint = "http://localhost:8080"
+
+// settings are the connection settings shared by all commands.
+type settings struct {
+	Endpoint string `json:"endpoint"`
+	Token    string `json:"token"`
+}
+
+// loadSettings layers flags over environment variables over the config file.
+// An explicitly named config file must exist; the default one is optional.
+func loadSettings(configPath string, flags settings, getenv func(string) string) (settings, error) {
+	explicit := true
+	if configPath == "" {
+		configPath = getenv("DOCCTL_CONFIG")
+	}
+	if configPath == "" {
+		explicit = false
+		if dir, err := os.UserConfigDir(); err == nil {
+			configPath = filepath.Join(dir, "docctl", "config.json")
+		}
+	}
+
+	var s settings
+	if configPath != "" {
+		b, err := os.ReadFile(configPath)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(b, &s); err != nil {
+				return settings{}, fmt.Errorf("config %s: %w", configPath, err)
+			}
+		case errors.Is(err, fs.ErrNotExist) && !explicit:
+		default:
+			return settings{}, fmt.Errorf("read config: %w", err)
+		}
+	}
+
+	overlay := func(dst *string, values ...string) {
+		for _, v := range values {
+			if v != "" {
+				*dst = v
+			}
+		}
+	}
+	overlay(&s.Endpoint, getenv("DOCCTL_ENDPOINT"), flags.Endpoint)
+	overlay(&s.Token, getenv("DOCCTL_TOKEN"), flags.Token)
+	if s.Endpoint == "" {
+		s.Endpoint = defaultEndpoint
+	}
+	return s, nil
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"docapi/client"
+	"docapi/internal/model"
+)
+
+// listPageSize is the page size used by ls -all.
+const listPageSize = 100
+
+func runList(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("ls", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(c.stderr, "usage: docctl ls [flags]")
+		fs.PrintDefaults()
+	}
+	limit := fs.Int("limit", 20, "maximum number of documents to show")
+	offset := fs.Int("offset", 0, "number of documents to skip")
+	all := fs.Bool("all", false, "show all documents, ignoring -limit")
+	output := fs.String("o", "table", "output format: table or json")
+	var opts client.ListOptions
+	fs.StringVar(&opts.Sort, "sort", "", "sort by created_at, name, size or content_type; prefix with - for descending (default -created_at)")
+	fs.StringVar(&opts.ContentType, "type", "", "content type, exact or type/* wildcard")
+	fs.StringVar(&opts.Name, "name", "", "filename substring (case-insensitive)")
+	since := fs.String("since", "", "created at or after (RFC 3339 or YYYY-MM-DD)")
+	until := fs.String("until", "", "created before (RFC 3339 or YYYY-MM-DD)")
+	minSize := fs.String("min-size", "", "minimum size, e.g. 10KB or 1MiB")
+	maxSize := fs.String("max-size", "", "maximum size, e.g. 10KB or 1MiB")
+	if err := parseFlags(fs, c, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return usagef("unexpected argument %q", fs.Arg(0))
+	}
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+	if *limit < 1 || *offset < 0 {
+		return usagef("-limit must be positive and -offset non-negative")
+	}
+
+	var err error
+	if opts.CreatedAfter, err = parseTimeFlag("since", *since); err != nil {
+		return err
+	}
+	if opts.CreatedBefore, err = parseTimeFlag("until", *until); err != nil {
+		return err
+	}
+	if opts.MinSize, err = parseSizeFlag("min-size", *minSize); err != nil {
+		return err
+	}
+	if opts.MaxSize, err = parseSizeFlag("max-size", *maxSize); err != nil {
+		return err
+	}
+
+	opts.Offset = *offset
+	opts.Limit = *limit
+	if *all {
+		opts.Limit = listPageSize
+	}
+	docs := make([]model.Document, 0)
+	for {
+		page, err := c.api.List(ctx, opts)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, page.Documents...)
+		if !*all || page.NextCursor == "" {
+			break
+		}
+		opts.Offset, opts.Cursor = 0, page.NextCursor
+	}
+
+	if *output == "json" {
+		return writeJSON(c.stdout, docs)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tTYPE\tSIZE\tCREATED")
+	for _, d := range docs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", d.ID, d.Name, d.ContentType, formatBytes(d.Size), d.CreatedAt.Local().Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func runGet(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("get", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(c.stderr, "usage: docctl get [-o table|json] <id>")
+		fs.PrintDefaults()
+	}
+	output := fs.String("o", "table", "output format: table or json")
+	if err := parseFlags(fs, c, args); err != nil {
+		return err
+	}
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("expected exactly one document id")
+	}
+	doc, err := c.api.Get(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *output == "json" {
+		return writeJSON(c.stdout, doc)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID:\t%s\n", doc.ID)
+	fmt.Fprintf(w, "Name:\t%s\n", doc.Name)
+	fmt.Fprintf(w, "Filename:\t%s\n", doc.Filename)
+	fmt.Fprintf(w, "Content type:\t%s\n", doc.ContentType)
+	fmt.Fprintf(w, "Size:\t%s (%d bytes)\n", formatBytes(doc.Size), doc.Size)
+	fmt.Fprintf(w, "Stored size:\t%s (%d bytes)\n", formatBytes(doc.StoredSize), doc.StoredSize)
+	fmt.Fprintf(w, "Storage path:\t%s\n", doc.StoragePath)
+	fmt.Fprintf(w, "Created:\t%s\n", doc.CreatedAt.Local().Format(time.RFC3339))
+	return w.Flush()
+}
+
+// rmBatchSize is the number of IDs rm sends per request, the server's default limit.
+const rmBatchSize = 100
+
+func runRemove(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("rm", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(c.stderr, "usage: docctl rm [-q] <id>...")
+		fs.PrintDefaults()
+	}
+	quiet := fs.Bool("q", false, "do not report deleted documents")
+	if err := parseFlags(fs, c, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usagef("expected at least one document id")
+	}
+
+	var errs []error
+	ids := fs.Args()
+	for len(ids) > 0 {
+		chunk := ids[:min(len(ids), rmBatchSize)]
+		ids = ids[len(chunk):]
+		res, err := c.api.BatchDelete(ctx, chunk)
+		if err != nil {
+			return err
+		}
+		for _, item := range res.Results {
+			if err := item.Err(res.RequestID); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", item.ID, err))
+				if fs.NArg() > 1 {
+					fmt.Fprintf(c.stderr, "failed %s: %v\n", item.ID, err)
+				}
+				continue
+			}
+			if !*quiet {
+				fmt.Fprintf(c.stderr, "deleted %s\n", item.ID)
+			}
+		}
+	}
+	return batchError("deletes", errs, fs.NArg())
+}
+
+func runURL(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("url", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(c.stderr, "usage: docctl url [-expiry 15m] [-o text|json] <id>")
+		fs.PrintDefaults()
+	}
+	expiry := fs.Duration("expiry", 15*time.Minute, "URL lifetime")
+	output := fs.String("o", "text", "output format: text or json")
+	if err := parseFlags(fs, c, args); err != nil {
+		return err
+	}
+	if *output != "text" && *output != "json" {
+		return usagef("-o must be text or json")
+	}
+	if fs.NArg() != 1 {
+		return usagef("expected exactly one document id")
+	}
+	res, err := c.api.PresignURL(ctx, fs.Arg(0), *expiry)
+	if err != nil {
+		return err
+	}
+	if *output == "json" {
+		return writeJSON(c.stdout, res)
+	}
+	_, err = fmt.Fprintln(c.stdout, res.URL)
+	return err
+}
+
+func checkOutput(format string) error {
+	if format != "table" && format != "json" {
+		return usagef("-o must be table or json")
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func parseSizeFlag(name, v string) (*int64, error) {
+	if v == "" {
+		return nil, nil
+	}
+	n, err := parseByteSize(v)
+	if err != nil {
+		return nil, usagef("-%s: %v", name, err)
+	}
+	return &n, nil
+}
+
+func parseTimeFlag(name, v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, v, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, usagef("-%s: want RFC 3339 time or YYYY-MM-DD, got %q", name, v)
+}
+
+// multiError reports a command that failed for some of its arguments. Its exit code is
+// that of the first failure.
+type multiError struct {
+	what  string
+	errs  []error
+	total int
+}
+
+func (e *multiError) Error() string {
+	return fmt.Sprintf("%d of %d %s failed", len(e.errs), e.total, e.what)
+}
+
+func (e *multiError) Unwrap() error { return e.errs[0] }
+
+func batchError(what string, errs []error, total int) error {
+	switch {
+	case len(errs) == 0:
+		return nil
+	case total == 1:
+		return errs[0]
+	default:
+		return &multiError{what: what, errs: errs, total: total}
+	}
+}
+
+// baseName returns a safe local file name for a server-provided name.
+func baseName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+	return name
+}
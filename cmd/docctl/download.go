@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+func runDownload(ctx context.Context, c *cli, args []string) error {
+	flags := flag.NewFlagSet("download", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(c.stderr, "usage: docctl download [-out FILE|-] [-f] [-q] <id>")
+		flags.PrintDefaults()
+	}
+	out := flags.String("out", "", "output file, or - for stdout (default: the document's filename)")
+	force := flags.Bool("f", false, "overwrite an existing file")
+	quiet := flags.Bool("q", false, "do not report progress")
+	if err := parseFlags(flags, c, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return usagef("expected exactly one document id")
+	}
+	id := flags.Arg(0)
+
+	dl, err := c.api.Download(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer dl.Close()
+
+	if *out == "-" {
+		_, err := io.Copy(c.stdout, dl)
+		return err
+	}
+	if *out == "" {
+		*out = id
+		if name := baseName(dl.Filename); name != "" {
+			*out = name
+		}
+	}
+	if !*force {
+		if _, err := os.Stat(*out); err == nil {
+			return usagef("%s already exists (use -f to overwrite)", *out)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	prog := newProgress(c, *quiet, 1, max(dl.Size, 0))
+	n, err := writeFileAtomic(*out, prog.reader(dl))
+	prog.close()
+	if err != nil {
+		return err
+	}
+	prog.printf("downloaded %s to %s (%s)", id, *out, formatBytes(n))
+	return nil
+}
+
+// writeFileAtomic writes r to a temporary file next to path and renames it into place,
+// so an interrupted download never leaves a truncated file under the final name.
+func writeFileAtomic(path string, r io.Reader) (int64, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.part")
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	return n, nil
+}
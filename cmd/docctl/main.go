@@ -0,0 +1,173 @@
+// Command docctl is a command-line client for the document API.
+//
+//	docctl [-endpoint URL] [-token TOKEN] [-config FILE] <command> [flags] [args]
+//
+// The endpoint and token are taken from flags, then DOCCTL_ENDPOINT / DOCCTL_TOKEN, then the
+// config file (DOCCTL_CONFIG or <user config dir>/docctl/config.json).
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"docapi/client"
+)
+
+// Exit codes. API errors are mapped from the errorPayload code, see exitCode.
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitUsage       = 2
+	exitNotFound    = 3
+	exitInvalid     = 4
+	exitUnsupported = 5
+	exitServer      = 6
+	exitUnreachable = 7
+)
+
+const usageText = `usage: docctl [global flags] <command> [flags] [args]
+
+Commands:
+  upload <file|glob>...   upload files
+  ls                      list documents
+  get <id>                show a document
+  download <id>           download a document's content
+  rm <id>...              delete documents
+  url <id>                print a presigned download URL
+
+Global flags:
+  -endpoint URL   API base URL (env DOCCTL_ENDPOINT, default http://localhost:8080)
+  -token TOKEN    bearer token sent with every request (env DOCCTL_TOKEN)
+  -config FILE    JSON config file with "endpoint" and "token" (env DOCCTL_CONFIG)
+
+Run "docctl <command> -h" for command flags.
+
+Exit codes: 0 ok, 1 error, 2 usage, 3 not found, 4 invalid request,
+5 unsupported, 6 server error, 7 server unreachable.
+`
+
+// cli carries what every command needs.
+type cli struct {
+	api         *client.Client
+	stdout      io.Writer
+	stderr      io.Writer
+	interactive bool // stderr is a terminal, so live progress can be drawn
+}
+
+type command func(ctx context.Context, c *cli, args []string) error
+
+var commands = map[string]command{
+	"upload":   runUpload,
+	"ls":       runList,
+	"get":      runGet,
+	"download": runDownload,
+	"rm":       runRemove,
+	"url":      runURL,
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr, isTerminal(os.Stderr))
+	stop()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer, interactive bool) int {
+	global := flag.NewFlagSet("docctl", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.Usage = func() { fmt.Fprint(stderr, usageText) }
+	var flags settings
+	global.StringVar(&flags.Endpoint, "endpoint", "", "API base URL")
+	global.StringVar(&flags.Token, "token", "", "bearer token")
+	configPath := global.String("config", "", "config file")
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if global.NArg() == 0 {
+		fmt.Fprint(stderr, usageText)
+		return exitUsage
+	}
+
+	name := global.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "docctl: unknown command %q\n\n%s", name, usageText)
+		return exitUsage
+	}
+
+	s, err := loadSettings(*configPath, flags, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(stderr, "docctl: %v\n", err)
+		return exitUsage
+	}
+	api, err := newAPIClient(s)
+	if err != nil {
+		fmt.Fprintf(stderr, "docctl: %v\n", err)
+		return exitUsage
+	}
+
+	err = cmd(ctx, &cli{api: api, stdout: stdout, stderr: stderr, interactive: interactive}, global.Args()[1:])
+	if err == nil {
+		return exitOK
+	}
+	if !errors.Is(err, flag.ErrHelp) && !errors.Is(err, errUsageShown) {
+		fmt.Fprintf(stderr, "docctl %s: %v\n", name, err)
+	}
+	return exitCode(err)
+}
+
+// errUsageShown is returned after a flag set already printed its own error and usage.
+var errUsageShown = errors.New("usage")
+
+// usageError marks invalid command-line input.
+type usageError struct{ msg string }
+
+func (e *usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// exitCode maps an error returned by a command to the process exit code.
+func exitCode(err error) int {
+	var ue *usageError
+	var ae *client.Error
+	var ne *url.Error
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, errUsageShown), errors.As(err, &ue), errors.Is(err, client.ErrInvalidID):
+		return exitUsage
+	case errors.As(err, &ae):
+		return apiExitCode(ae)
+	case errors.Is(err, context.Canceled):
+		return exitError
+	case errors.As(err, &ne), errors.Is(err, context.DeadlineExceeded):
+		return exitUnreachable
+	default:
+		return exitError
+	}
+}
+
+// parseFlags parses command flags, returning errUsageShown when the flag package already reported
+// the problem.
+func parseFlags(fs *flag.FlagSet, c *cli, args []string) error {
+	fs.SetOutput(c.stderr)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsageShown
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"docapi/client"
+	handlers "docapi/internal/http/handler"
+	"docapi/internal/http/middleware"
+	"docapi/internal/model"
+	"docapi/internal/repository/memory"
+	"docapi/internal/service"
+	"docapi/internal/storage"
+)
+
+// startServer runs the real HTTP handlers over in-memory storage and repository.
+func startServer(t *testing.T) string {
+	t.Helper()
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler(), DisableStartupMessage: true})
+	app.Use(middleware.RequestID())
+	handlers.RegisterRoutes(app, nil, service.NewDocumentService(storage.NewMemory(), memory.NewDocumentMemory()))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go app.Listener(ln)
+	t.Cleanup(func() { _ = app.Shutdown() })
+	return "http://" + ln.Addr().String()
+}
+
+type result struct {
+	code   int
+	stdout string
+	stderr string
+}
+
+func runCLI(t *testing.T, endpoint string, args ...string) result {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), append([]string{"-endpoint", endpoint}, args...), &stdout, &stderr, false)
+	return result{code: code, stdout: stdout.String(), stderr: stderr.String()}
+}
+
+func TestDocctl_EndToEnd(t *testing.T) {
+	t.Setenv("DOCCTL_CONFIG", "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	endpoint := startServer(t)
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a.txt": "alpha", "b.txt": "bravo!", "c.json": `{"c":1}`} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+
+	// upload with a glob and a literal path
+	res := runCLI(t, endpoint, "upload", "-p", "2", "-o", "json", filepath.Join(dir, "*.txt"), filepath.Join(dir, "c.json"))
+	require.Equal(t, exitOK, res.code, res.stderr)
+	var uploaded []uploadResult
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &uploaded))
+	require.Len(t, uploaded, 3)
+	ids := map[string]string{}
+	for _, u := range uploaded {
+		require.NotNil(t, u.Document, u.Error)
+		ids[filepath.Base(u.File)] = u.Document.ID
+	}
+	assert.Contains(t, res.stderr, "uploaded ")
+
+	// ls with server-side filters
+	res = runCLI(t, endpoint, "ls", "-o", "json", "-type", "text/*", "-min-size", "6")
+	require.Equal(t, exitOK, res.code, res.stderr)
+	var listed []model.Document
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &listed))
+	require.Len(t, listed, 1)
+	assert.Equal(t, ids["b.txt"], listed[0].ID)
+
+	res = runCLI(t, endpoint, "ls", "-o", "json", "-name", ".TXT", "-sort", "-name")
+	require.Equal(t, exitOK, res.code, res.stderr)
+	listed = nil
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &listed))
+	require.Len(t, listed, 2)
+	assert.Equal(t, []string{"b.txt", "a.txt"}, []string{listed[0].Name, listed[1].Name})
+
+	res = runCLI(t, endpoint, "ls", "-sort", "checksum")
+	assert.Equal(t, exitInvalid, res.code)
+	assert.Contains(t, res.stderr, "INVALID_SORT")
+
+	res = runCLI(t, endpoint, "ls")
+	require.Equal(t, exitOK, res.code, res.stderr)
+	assert.Equal(t, 4, strings.Count(res.stdout, "\n"), "header and three rows")
+
+	// get
+	res = runCLI(t, endpoint, "get", ids["c.json"])
+	require.Equal(t, exitOK, res.code, res.stderr)
+	assert.Contains(t, res.stdout, "application/json")
+
+	// download to a file and to stdout
+	out := filepath.Join(dir, "out.txt")
+	res = runCLI(t, endpoint, "download", "-out", out, ids["a.txt"])
+	require.Equal(t, exitOK, res.code, res.stderr)
+	got, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "alpha", string(got))
+
+	res = runCLI(t, endpoint, "download", "-out", out, ids["a.txt"])
+	assert.Equal(t, exitUsage, res.code, "refuses to overwrite")
+
+	res = runCLI(t, endpoint, "download", "-out", "-", ids["b.txt"])
+	require.Equal(t, exitOK, res.code, res.stderr)
+	assert.Equal(t, "bravo!", res.stdout)
+
+	// url
+	res = runCLI(t, endpoint, "url", "-expiry", "1h", ids["a.txt"])
+	require.Equal(t, exitOK, res.code, res.stderr)
+	assert.True(t, strings.HasPrefix(res.stdout, "memory://"), res.stdout)
+
+	// rm, then the document is gone
+	res = runCLI(t, endpoint, "rm", ids["a.txt"])
+	require.Equal(t, exitOK, res.code, res.stderr)
+	res = runCLI(t, endpoint, "get", ids["a.txt"])
+	assert.Equal(t, exitNotFound, res.code)
+	assert.Contains(t, res.stderr, "NOT_FOUND")
+
+	// partial failure reports the first failure's exit code
+	res = runCLI(t, endpoint, "rm", ids["b.txt"], ids["a.txt"])
+	assert.Equal(t, exitNotFound, res.code)
+	assert.Contains(t, res.stderr, "1 of 2 deletes failed")
+}
+
+func TestDocctl_ExitCodes(t *testing.T) {
+	t.Setenv("DOCCTL_CONFIG", "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	endpoint := startServer(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	unreachable := "http://" + ln.Addr().String()
+	ln.Close()
+
+	tests := []struct {
+		name     string
+		endpoint string
+		args     []string
+		want     int
+	}{
+		{name: "no command", endpoint: endpoint, want: exitUsage},
+		{name: "unknown command", endpoint: endpoint, args: []string{"frobnicate"}, want: exitUsage},
+		{name: "bad flag", endpoint: endpoint, args: []string{"ls", "-nope"}, want: exitUsage},
+		{name: "invalid id", endpoint: endpoint, args: []string{"get", "not-a-uuid"}, want: exitInvalid},
+		{name: "no matching files", endpoint: endpoint, args: []string{"upload", filepath.Join(t.TempDir(), "*.pdf")}, want: exitUsage},
+		{name: "unreachable", endpoint: unreachable, args: []string{"ls"}, want: exitUnreachable},
+		{name: "invalid endpoint", endpoint: "ftp://example.com", args: []string{"ls"}, want: exitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := runCLI(t, tt.endpoint, tt.args...)
+			assert.Equal(t, tt.want, res.code, res.stderr)
+		})
+	}
+}
+
+func TestAPIExitCode(t *testing.T) {
+	tests := []struct {
+		err  client.Error
+		want int
+	}{
+		{err: client.Error{StatusCode: 404, Code: "NOT_FOUND"}, want: exitNotFound},
+		{err: client.Error{StatusCode: 400, Code: "INVALID_LIMIT"}, want: exitInvalid},
+		{err: client.Error{StatusCode: 400, Code: "FILE_REQUIRED"}, want: exitInvalid},
+		{err: client.Error{StatusCode: 501, Code: "PRESIGN_UNSUPPORTED"}, want: exitUnsupported},
+		{err: client.Error{StatusCode: 503, Code: "SERVICE_UNAVAILABLE"}, want: exitServer},
+		{err: client.Error{StatusCode: 502}, want: exitServer},
+		{err: client.Error{StatusCode: 409, Code: "SOMETHING_NEW"}, want: exitInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tt.want, apiExitCode(&tt.err))
+		})
+	}
+}
+
+func TestLoadSettings(t *testing.T) {
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(cfgFile, []byte(`{"endpoint":"http://file:1","token":"file-token"}`), 0o600))
+
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		flags   settings
+		env     map[string]string
+		want    settings
+		wantErr bool
+	}{
+		{name: "file", path: cfgFile, want: settings{Endpoint: "http://file:1", Token: "file-token"}},
+		{
+			name: "env over file",
+			env:  map[string]string{"DOCCTL_CONFIG": cfgFile, "DOCCTL_ENDPOINT": "http://env:2"},
+			want: settings{Endpoint: "http://env:2", Token: "file-token"},
+		},
+		{
+			name:  "flags over env",
+			path:  cfgFile,
+			flags: settings{Token: "flag-token"},
+			env:   map[string]string{"DOCCTL_TOKEN": "env-token"},
+			want:  settings{Endpoint: "http://file:1", Token: "flag-token"},
+		},
+		{name: "missing explicit file", path: filepath.Join(dir, "missing.json"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadSettings(tt.path, tt.flags, env(tt.env))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "512", want: 512},
+		{in: "10k", want: 10_000},
+		{in: "1.5MiB", want: 1_572_864},
+		{in: "2 GB", want: 2_000_000_000},
+		{in: "12parsecs", wantErr: true},
+		{in: "-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseByteSize(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
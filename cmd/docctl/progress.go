@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progress reports transfer progress on stderr. Completion messages are always printed (unless
+// quiet); on a terminal a live summary line is redrawn in place as well.
+type progress struct {
+	w      io.Writer
+	live   bool
+	quiet  bool
+	total  int64
+	files  int
+	bytes  atomic.Int64
+	done   atomic.Int64
+	mu     sync.Mutex
+	stop   chan struct{}
+	exited chan struct{}
+}
+
+func newProgress(c *cli, quiet bool, files int, total int64) *progress {
+	p := &progress{w: c.stderr, live: c.interactive && !quiet, quiet: quiet, total: total, files: files}
+	if p.live {
+		p.stop = make(chan struct{})
+		p.exited = make(chan struct{})
+		go p.loop()
+	}
+	return p
+}
+
+func (p *progress) loop() {
+	defer close(p.exited)
+	t := time.NewTicker(200 * time.Millisecond)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			p.mu.Lock()
+			p.draw()
+			p.mu.Unlock()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// draw renders the live line; p.mu must be held.
+func (p *progress) draw() {
+	line := fmt.Sprintf("%s / %s", formatBytes(p.bytes.Load()), formatBytes(p.total))
+	if p.files > 1 {
+		line = fmt.Sprintf("[%d/%d] %s", p.done.Load(), p.files, line)
+	}
+	fmt.Fprintf(p.w, "\r\033[K%s", line)
+}
+
+// reader counts bytes read through r towards the progress total.
+func (p *progress) reader(r io.Reader) io.Reader {
+	return &progressReader{r: r, p: p}
+}
+
+// finishFile records a completed file and prints msg.
+func (p *progress) finishFile(format string, args ...any) {
+	p.done.Add(1)
+	p.printf(format, args...)
+}
+
+func (p *progress) printf(format string, args ...any) {
+	if p.quiet {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.live {
+		fmt.Fprint(p.w, "\r\033[K")
+	}
+	fmt.Fprintf(p.w, format+"\n", args...)
+	if p.live {
+		p.draw()
+	}
+}
+
+// close stops the live line and clears it.
+func (p *progress) close() {
+	if !p.live {
+		return
+	}
+	close(p.stop)
+	<-p.exited
+	fmt.Fprint(p.w, "\r\033[K")
+}
+
+type progressReader struct {
+	r io.Reader
+	p *progress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.p.bytes.Add(int64(n))
+	return n, err
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// formatBytes renders n with binary units, e.g. "1.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// parseByteSize parses sizes such as "512", "10k", "10KB", "1.5MiB" or "2G". Decimal suffixes
+// (k, KB, M, MB, ...) are powers of 1000; binary ones (KiB, MiB, ...) powers of 1024.
+func parseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	num, unit := s, ""
+	if i >= 0 {
+		num, unit = s[:i], strings.ToLower(strings.TrimSpace(s[i:]))
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	multipliers := map[string]float64{
+		"": 1, "b": 1,
+		"k": 1e3, "kb": 1e3, "kib": 1 << 10,
+		"m": 1e6, "mb": 1e6, "mib": 1 << 20,
+		"g": 1e9, "gb": 1e9, "gib": 1 << 30,
+		"t": 1e12, "tb": 1e12, "tib": 1 << 40,
+	}
+	m, ok := multipliers[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit in %q", s)
+	}
+	return int64(v * m), nil
+}
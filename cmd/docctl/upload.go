@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/sync/errgroup"
+
+	"docapi/client"
+)
+
+type uploadResult struct {
+	File     string           `json:"file"`
+	Document *client.Document `json:"document,omitempty"`
+	Error    string           `json:"error,omitempty"`
+	err      error
+}
+
+func runUpload(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("upload", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(c.stderr, "usage: docctl upload [-p N] [-type TYPE] [-o table|json] [-q] <file|glob>...")
+		fs.PrintDefaults()
+	}
+	parallel := fs.Int("p", 4, "number of parallel uploads")
+	contentType := fs.String("type", "", "content type for all files (default: detected per file)")
+	output := fs.String("o", "table", "output format: table or json")
+	quiet := fs.Bool("q", false, "do not report progress")
+	if err := parseFlags(fs, c, args); err != nil {
+		return err
+	}
+	if err := checkOutput(*output); err != nil {
+		return err
+	}
+	if *parallel < 1 {
+		return usagef("-p must be at least 1")
+	}
+	files, total, err := expandFiles(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	p := newProgress(c, *quiet, len(files), total)
+	results := make([]uploadResult, len(files))
+	var g errgroup.Group
+	g.SetLimit(*parallel)
+	for i, path := range files {
+		g.Go(func() error {
+			doc, err := upload(ctx, c.api, path, *contentType, p)
+			results[i] = uploadResult{File: path, Document: doc, err: err}
+			if err != nil {
+				results[i].Error = err.Error()
+				p.finishFile("failed %s: %v", path, err)
+			} else {
+				p.finishFile("uploaded %s (%s) as %s", path, formatBytes(doc.Size), doc.ID)
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+	p.close()
+
+	if *output == "json" {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FILE\tID\tSIZE")
+		for _, r := range results {
+			if r.Document != nil {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", r.File, r.Document.ID, formatBytes(r.Document.Size))
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	for _, r := range results {
+		if r.err != nil {
+			errs = append(errs, r.err)
+		}
+	}
+	return batchError("uploads", errs, len(results))
+}
+
+// expandFiles resolves arguments into regular files, expanding glob patterns. A pattern that
+// matches nothing is an error, as is a literal path that does not exist.
+func expandFiles(args []string) ([]string, int64, error) {
+	if len(args) == 0 {
+		return nil, 0, usagef("no files given")
+	}
+	var files []string
+	var total int64
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		matches := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(arg); err != nil {
+				return nil, 0, usagef("invalid pattern %q: %v", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, 0, usagef("no files match %q", arg)
+			}
+		}
+		for _, m := range matches {
+			fi, err := os.Stat(m)
+			if err != nil {
+				return nil, 0, usagef("%v", err)
+			}
+			if fi.IsDir() {
+				if len(matches) > 1 {
+					continue // directories matched by a glob are skipped
+				}
+				return nil, 0, usagef("%s is a directory", m)
+			}
+			if !seen[m] {
+				seen[m] = true
+				files = append(files, m)
+				total += fi.Size()
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, 0, usagef("no files to upload")
+	}
+	return files, total, nil
+}
+
+// upload sends a single file, streaming it with an exact Content-Length.
+func upload(ctx context.Context, api *client.Client, path, contentType string, p *progress) (*client.Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if contentType == "" {
+		if contentType, err = detectContentType(f); err != nil {
+			return nil, err
+		}
+	}
+	return api.Upload(ctx, filepath.Base(path), p.reader(f), &client.UploadOptions{ContentType: contentType, Size: fi.Size()})
+}
+
+// detectContentType guesses from the extension, then from the first bytes of the file.
+func detectContentType(f *os.File) (string, error) {
+	if ct := mime.TypeByExtension(filepath.Ext(f.Name())); ct != "" {
+		return ct, nil
+	}
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
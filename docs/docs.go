@@ -15,9 +15,137 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/documents/{id}/reject": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Mark a quarantined document as failed after review. Its content stays in quarantine and is never served; delete the\ndocument to remove it. Requires the admin bearer token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Reject quarantined document",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/docapi_internal_model.Document"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "409": {
+                        "description": "document is not quarantined",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/documents/{id}/release": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Make a quarantined document available after review, for example when its scan result was a false positive. Its\ncontent is moved out of quarantine; the scan result is kept. Requires the admin bearer token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Release quarantined document",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/docapi_internal_model.Document"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "409": {
+                        "description": "document is not quarantined",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    }
+                }
+            }
+        },
         "/documents": {
             "get": {
-                "description": "Get a list of documents with pagination",
+                "description": "Get a page of documents, filtered and sorted (newest first by default). Pages are selected by offset, or by the\ncursor returned as next_cursor by the previous page, which neither skips nor repeats documents when others are\nadded or removed meanwhile. A cursor is only valid with the sort it was issued for.\nThe total count is included by default for offset pages and on request for cursor pages.",
                 "produces": [
                     "application/json"
                 ],
@@ -27,9 +155,10 @@ const docTemplate = `{
                 "summary": "List documents",
                 "parameters": [
                     {
+                        "maximum": 1000,
                         "type": "integer",
                         "default": 10,
-                        "description": "Limit",
+                        "description": "Page size, at most 1000",
                         "name": "limit",
                         "in": "query"
                     },
@@ -39,16 +168,74 @@ const docTemplate = `{
                         "description": "Offset",
                         "name": "offset",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor from a previous page's next_cursor; cannot be combined with offset",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include the total count (default true without cursor, false with cursor)",
+                        "name": "total",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "-created_at",
+                        "description": "Sort field: created_at, name, size or content_type; prefix with - for descending",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Media type, exact (application/pdf) or wildcard (image/*); parameters and case are ignored",
+                        "name": "content_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created at or after (RFC 3339 or YYYY-MM-DD, UTC)",
+                        "name": "created_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created before (RFC 3339 or YYYY-MM-DD, UTC)",
+                        "name": "created_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum size in bytes",
+                        "name": "min_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum size in bytes",
+                        "name": "max_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Case-insensitive substring of the original filename",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Processing statuses, separated by commas: available, quarantined or failed",
+                        "name": "status",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/docapi_internal_model.Document"
-                            }
+                            "$ref": "#/definitions/docapi_internal_service.DocumentListResult"
                         }
                     },
                     "400": {
@@ -66,7 +253,7 @@ const docTemplate = `{
                 }
             },
             "post": {
-                "description": "Upload a new document",
+                "description": "Upload one or more documents as \"file\" parts. A single file without atomic is answered with the created document.\nOtherwise the files are uploaded in parallel and the response lists a result per file, in request order: 201 when\nevery file was created and 207 otherwise. With atomic=true the first failure aborts the batch and removes every\ndocument created so far; the other files are then reported as rolled_back.\nCreated documents are processed before they are returned: their status is available, or quarantined when malware\nwas found.",
                 "consumes": [
                     "multipart/form-data"
                 ],
@@ -76,23 +263,136 @@ const docTemplate = `{
                 "tags": [
                     "documents"
                 ],
-                "summary": "Upload document",
+                "summary": "Upload documents",
                 "parameters": [
                     {
                         "type": "file",
-                        "description": "Document file",
+                        "description": "Document file; repeat the part to upload several files",
                         "name": "file",
                         "in": "formData",
                         "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Create all files or none",
+                        "name": "atomic",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "201": {
-                        "description": "Created",
+                        "description": "single file",
                         "schema": {
                             "$ref": "#/definitions/docapi_internal_model.Document"
                         }
                     },
+                    "207": {
+                        "description": "several files, or atomic",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.uploadResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "503": {
+                        "description": "content could not be scanned for malware",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    }
+                }
+            }
+        },
+        "/documents/archive": {
+            "post": {
+                "description": "Stream a ZIP of the documents selected by ID, or of all documents matching a filter (same parameters as the listing;\nan empty body selects everything), up to a configured limit. Entries are named after the original filenames,\nwith a numbered suffix when names collide, and the archive ends with manifest.json listing each entry's document ID\nand SHA-256 checksum, and any selected documents that were missing or unreadable. ZIP64 is used for large archives.\nIf reading a document fails midway, the connection is closed before the archive is complete.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/zip"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Download documents as a ZIP archive",
+                "parameters": [
+                    {
+                        "description": "Documents to include",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.archiveRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    }
+                }
+            }
+        },
+        "/documents/import-archive": {
+            "post": {
+                "description": "Create one document per file of a ZIP, tar or tar.gz archive, named after the file, with the archive's name and\nthe file's relative path in its metadata (\"archive\", \"archive_path\"). Directories are skipped. Files with absolute\nor parent-relative paths, links and special files, and files over the size or compression ratio limits are\nrejected and reported; the import goes on. Exceeding the entry count, the total size or, for tar.gz, the stream\ncompression ratio stops the import: before any file was handled the response is 400, otherwise 207 with \"error\"\nset and the documents created so far kept. The response is 201 when every file was created and 207 otherwise.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Import documents from an archive",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "ZIP, tar or tar.gz archive",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.importResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Multi-Status",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.importResponse"
+                        }
+                    },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
@@ -110,7 +410,7 @@ const docTemplate = `{
         },
         "/documents/{id}": {
             "get": {
-                "description": "Get a document by ID",
+                "description": "Get a document by ID. The ETag is the document's version, for use in If-Match when updating or deleting it.\nConditional requests with If-None-Match or If-Modified-Since get 304 while the document is unchanged.",
                 "produces": [
                     "application/json"
                 ],
@@ -125,6 +425,18 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a cached version",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last-Modified of a cached version",
+                        "name": "If-Modified-Since",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -132,8 +444,21 @@ const docTemplate = `{
                         "description": "OK",
                         "schema": {
                             "$ref": "#/definitions/docapi_internal_model.Document"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the document"
+                            },
+                            "Last-Modified": {
+                                "type": "string",
+                                "description": "When the document was last changed"
+                            }
                         }
                     },
+                    "304": {
+                        "description": "Not Modified"
+                    },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
@@ -146,6 +471,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/internal_http_handler.errorPayload"
                         }
                     },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -155,7 +486,7 @@ const docTemplate = `{
                 }
             },
             "delete": {
-                "description": "Delete a document by ID",
+                "description": "Delete a document by ID. With If-Match or If-Unmodified-Since, the document is only deleted while it is at the\ngiven version; otherwise the response is 412.",
                 "tags": [
                     "documents"
                 ],
@@ -167,6 +498,18 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version to delete",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last-Modified of the version to delete",
+                        "name": "If-Unmodified-Since",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -185,6 +528,440 @@ const docTemplate = `{
                             "$ref": "#/definitions/internal_http_handler.errorPayload"
                         }
                     },
+                    "409": {
+                        "description": "document is being changed concurrently",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Change the mutable fields of a document with a JSON Merge Patch (RFC 7396): fields left out are kept, \"tags\" replaces\nthe tags (null clears them), and \"metadata\" is merged key by key, where a null value removes the key and a null\nobject clears all metadata. Every change increments the document's version, returned as its ETag. Send the ETag\nin If-Match (or its Last-Modified in If-Unmodified-Since) to update only the version you read; a document changed\nsince then fails with 412.",
+                "consumes": [
+                    "application/merge-patch+json",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Update document",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version to update",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last-Modified of the version to update",
+                        "name": "If-Unmodified-Since",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.updateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/docapi_internal_model.Document"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the document"
+                            },
+                            "Last-Modified": {
+                                "type": "string",
+                                "description": "When the document was last changed"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    }
+                }
+            }
+        },
+        "/documents/{id}/content": {
+            "get": {
+                "description": "Download a document's content. A single byte range may be requested with the Range header, guarded by If-Range.\nThe ETag combines the stored object's ETag with the document's version; conditional requests with If-None-Match or\nIf-Modified-Since get 304 while it is unchanged. Responses are cached as immutable when \"version\" names the\ndocument's current version, and must be revalidated otherwise.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Download document",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Current version of the document, to make the response cacheable as immutable",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag or Last-Modified the range is valid for",
+                        "name": "If-Range",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a cached version",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last-Modified of a cached version",
+                        "name": "If-Modified-Since",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        },
+                        "headers": {
+                            "Content-Disposition": {
+                                "type": "string",
+                                "description": "attachment with the document's name, as filename* (RFC 6266) and an ASCII filename"
+                            },
+                            "ETag": {
+                                "type": "string",
+                                "description": "Entity tag of the content"
+                            }
+                        }
+                    },
+                    "206": {
+                        "description": "Partial Content",
+                        "schema": {
+                            "type": "file"
+                        },
+                        "headers": {
+                            "Content-Disposition": {
+                                "type": "string",
+                                "description": "attachment with the document's name, as filename* (RFC 6266) and an ASCII filename"
+                            },
+                            "ETag": {
+                                "type": "string",
+                                "description": "Entity tag of the content"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "403": {
+                        "description": "content is quarantined",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "409": {
+                        "description": "document failed processing or was rejected",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "416": {
+                        "description": "Requested Range Not Satisfiable",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    }
+                }
+            }
+        },
+        "/documents/{id}/copy": {
+            "post": {
+                "description": "Create an independent document with the content of another, copied within object storage without passing through\nthe client. The copy gets a new ID and keeps the source's name, metadata and scan result unless the body replaces\nthem. Only available documents can be copied; the copy is available right away.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Copy document",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Name and metadata of the copy",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.copyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/docapi_internal_model.Document"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "403": {
+                        "description": "source content is quarantined",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "409": {
+                        "description": "source failed processing or was rejected",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    }
+                }
+            }
+        },
+        "/documents/{id}/url": {
+            "get": {
+                "description": "Get a time-limited URL to download a document directly from object storage",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Presigned download URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "default": "15m",
+                        "description": "URL lifetime as a Go duration (1s to 168h)",
+                        "name": "expiry",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.presignedURL"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "403": {
+                        "description": "content is quarantined",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "409": {
+                        "description": "document failed processing or was rejected",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
+                    "501": {
+                        "description": "storage cannot presign this document, e.g. encrypted or compressed content",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    }
+                }
+            }
+        },
+        "/documents:batchDelete": {
+            "post": {
+                "description": "Delete up to a configured number of documents (100 by default) in one request. Every distinct ID is reported\nonce, in request order, as deleted, not_found or failed with an error code; a failed item is left in place and\ncan be retried. The response is 200 when every document was deleted and 207 otherwise.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Delete documents in bulk",
+                "parameters": [
+                    {
+                        "description": "IDs to delete",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.batchDeleteRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.batchDeleteResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Multi-Status",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.batchDeleteResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http_handler.errorPayload"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -196,7 +973,7 @@ const docTemplate = `{
         },
         "/health": {
             "get": {
-                "description": "Check database connectivity",
+                "description": "Check database connectivity. /readyz checks every dependency.",
                 "produces": [
                     "application/json"
                 ],
@@ -225,7 +1002,24 @@ const docTemplate = `{
         },
         "/healthz": {
             "get": {
-                "description": "Simple liveness probe",
+                "description": "Simple liveness probe: succeeds while the process serves requests, whatever the state of its dependencies",
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/livez": {
+            "get": {
+                "description": "Simple liveness probe: succeeds while the process serves requests, whatever the state of its dependencies",
                 "tags": [
                     "health"
                 ],
@@ -239,9 +1033,103 @@ const docTemplate = `{
                     }
                 }
             }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Check that the components the API depends on (database, object storage bucket and, when enabled, job\nworkers and malware scanner) are usable, each within its own timeout. Results are cached for a short time.\nResponds 503 with the same body if any component is down, so that no traffic is routed to this instance.\nDuring shutdown it responds 503 with status draining, without running the checks.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/docapi_internal_health.Report"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/docapi_internal_health.Report"
+                        }
+                    }
+                }
+            }
+        },
+        "/startupz": {
+            "get": {
+                "description": "Succeeds once the API finished initializing and its dependencies were up at least once. It keeps\nsucceeding afterwards: dependency outages are reported by the readiness probe.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Startup probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
         }
     },
     "definitions": {
+        "docapi_internal_health.Report": {
+            "type": "object",
+            "properties": {
+                "checked_at": {
+                    "type": "string"
+                },
+                "checks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/docapi_internal_health.Result"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "docapi_internal_health.Result": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error tells why a component is down: \"timeout\" or \"unavailable\". Details are logged rather\nthan reported, as probes are usually unauthenticated.",
+                    "type": "string"
+                },
+                "latency_ms": {
+                    "description": "LatencyMs is how long the check took, in milliseconds.",
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
         "docapi_internal_model.Document": {
             "type": "object",
             "properties": {
@@ -257,11 +1145,198 @@ const docTemplate = `{
                 "id": {
                     "type": "string"
                 },
+                "metadata": {
+                    "description": "Metadata holds free-form string attributes, such as where an imported document came from.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "description": "original filename as uploaded",
+                    "type": "string"
+                },
+                "scan_engine": {
+                    "description": "ScanEngine identifies the engine and signature database that scanned the content.",
+                    "type": "string"
+                },
+                "scan_signature": {
+                    "description": "ScanSignature names the malware found in infected content.",
+                    "type": "string"
+                },
+                "scan_status": {
+                    "description": "ScanStatus is the verdict of the malware scan of the content: ScanUnscanned, ScanClean or\nScanInfected.",
+                    "type": "string"
+                },
+                "scanned_at": {
+                    "description": "ScannedAt is when the content was scanned; nil if it was not.",
+                    "type": "string"
+                },
                 "size": {
                     "type": "integer"
                 },
+                "status": {
+                    "description": "Status is the processing status: StatusAvailable, StatusQuarantined or StatusFailed.\nStatusPending and StatusProcessing only last while the document is being uploaded.",
+                    "type": "string"
+                },
                 "storage_path": {
                     "type": "string"
+                },
+                "stored_size": {
+                    "type": "integer"
+                },
+                "tags": {
+                    "description": "Tags are free-form labels, in the order they were set.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "updated_at": {
+                    "description": "UpdatedAt is when the document was created or its attributes last changed.",
+                    "type": "string"
+                },
+                "version": {
+                    "description": "Version starts at 1 and is incremented by every update, for optimistic concurrency control.",
+                    "type": "integer"
+                }
+            }
+        },
+        "docapi_internal_service.DocumentListResult": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/docapi_internal_model.Document"
+                    }
+                },
+                "next_cursor": {
+                    "description": "NextCursor fetches the following page; empty on the last page.",
+                    "type": "string"
+                },
+                "total": {
+                    "description": "Total is only set when requested.",
+                    "type": "integer"
+                }
+            }
+        },
+        "internal_http_handler.archiveFilter": {
+            "type": "object",
+            "properties": {
+                "content_type": {
+                    "type": "string",
+                    "example": "image/*"
+                },
+                "created_after": {
+                    "type": "string",
+                    "example": "2024-01-01"
+                },
+                "created_before": {
+                    "type": "string"
+                },
+                "max_size": {
+                    "type": "integer"
+                },
+                "min_size": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status lists processing statuses separated by commas, e.g. \"available\".",
+                    "type": "string",
+                    "example": "available"
+                }
+            }
+        },
+        "internal_http_handler.archiveRequest": {
+            "type": "object",
+            "properties": {
+                "filter": {
+                    "description": "Filter selects the documents a listing with the same parameters would return.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/internal_http_handler.archiveFilter"
+                        }
+                    ]
+                },
+                "ids": {
+                    "description": "IDs selects documents by ID. It cannot be combined with Filter or Sort.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "sort": {
+                    "type": "string",
+                    "example": "-created_at"
+                }
+            }
+        },
+        "internal_http_handler.batchDeleteItem": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/internal_http_handler.errorEnvelope"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "deleted",
+                        "not_found",
+                        "failed"
+                    ]
+                }
+            }
+        },
+        "internal_http_handler.batchDeleteRequest": {
+            "type": "object",
+            "properties": {
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "internal_http_handler.batchDeleteResponse": {
+            "type": "object",
+            "properties": {
+                "deleted": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "not_found": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_http_handler.batchDeleteItem"
+                    }
+                }
+            }
+        },
+        "internal_http_handler.copyRequest": {
+            "type": "object",
+            "properties": {
+                "metadata": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "example": "contract-v2.docx"
                 }
             }
         },
@@ -286,6 +1361,142 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "internal_http_handler.importItem": {
+            "type": "object",
+            "properties": {
+                "document": {
+                    "$ref": "#/definitions/docapi_internal_model.Document"
+                },
+                "error": {
+                    "$ref": "#/definitions/internal_http_handler.errorEnvelope"
+                },
+                "path": {
+                    "type": "string",
+                    "example": "legacy/2019/report.pdf"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "created",
+                        "failed",
+                        "rejected"
+                    ]
+                }
+            }
+        },
+        "internal_http_handler.importResponse": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "error": {
+                    "$ref": "#/definitions/internal_http_handler.errorEnvelope"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "rejected": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_http_handler.importItem"
+                    }
+                }
+            }
+        },
+        "internal_http_handler.presignedURL": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_http_handler.updateRequest": {
+            "type": "object",
+            "properties": {
+                "content_type": {
+                    "type": "string",
+                    "example": "application/pdf"
+                },
+                "metadata": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "example": "invoice-2024-03.pdf"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "invoice",
+                        "2024"
+                    ]
+                }
+            }
+        },
+        "internal_http_handler.uploadItem": {
+            "type": "object",
+            "properties": {
+                "document": {
+                    "$ref": "#/definitions/docapi_internal_model.Document"
+                },
+                "error": {
+                    "$ref": "#/definitions/internal_http_handler.errorEnvelope"
+                },
+                "filename": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "created",
+                        "failed",
+                        "rolled_back"
+                    ]
+                }
+            }
+        },
+        "internal_http_handler.uploadResponse": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_http_handler.uploadItem"
+                    }
+                },
+                "rolled_back": {
+                    "type": "integer"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "AdminToken": {
+            "description": "Administrator token configured with ADMIN_TOKEN, sent as \"Bearer \u003ctoken\u003e\".",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
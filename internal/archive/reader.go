@@ -0,0 +1,326 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// Errors reported by Walk, either for the whole archive or for single members.
+var (
+	// ErrUnsupportedFormat is returned for uploads that are neither ZIP, tar nor gzip-compressed tar.
+	ErrUnsupportedFormat = errors.New("unsupported archive format")
+	// ErrInvalidArchive is returned when the archive structure cannot be read.
+	ErrInvalidArchive = errors.New("invalid archive")
+	// ErrTooManyEntries stops a walk once the archive holds more members than allowed.
+	ErrTooManyEntries = errors.New("too many entries")
+	// ErrArchiveTooLarge stops a walk once the extracted content exceeds the total size limit.
+	ErrArchiveTooLarge = errors.New("archive content too large")
+	// ErrEntryTooLarge marks members larger than the per-entry size limit.
+	ErrEntryTooLarge = errors.New("entry too large")
+	// ErrRatioExceeded marks members, or whole compressed tar streams, that expand more than allowed.
+	ErrRatioExceeded = errors.New("compression ratio too high")
+	// ErrUnsafePath marks members whose path is absolute or leaves the extraction directory.
+	ErrUnsafePath = errors.New("unsafe path")
+	// ErrNotRegular marks links, devices and other members that are not regular files.
+	ErrNotRegular = errors.New("not a regular file")
+)
+
+// Format is an archive format Walk can read.
+type Format string
+
+const (
+	FormatZip     Format = "zip"
+	FormatTar     Format = "tar"
+	FormatTarGzip Format = "tar.gz"
+)
+
+// ratioGrace is the content a member, or a compressed tar stream, may expand to before the
+// compression ratio is enforced, so that small, highly compressible files are accepted.
+const ratioGrace = 1 << 20
+
+// Limits bound what Walk extracts, to defend against archive bombs. Zero fields are unlimited.
+type Limits struct {
+	// MaxEntries is the number of members, directories excluded.
+	MaxEntries int
+	// MaxEntrySize is the uncompressed size of one member.
+	MaxEntrySize int64
+	// MaxTotalSize is the uncompressed size of all members together.
+	MaxTotalSize int64
+	// MaxRatio is the uncompressed size a member may have per compressed byte. For gzip-compressed
+	// tar archives it applies to the stream as a whole.
+	MaxRatio int64
+}
+
+// Member is a file in an archive.
+type Member struct {
+	// Path is the member's cleaned, slash-separated path relative to the archive root. For
+	// members rejected with ErrUnsafePath it is the name as stored.
+	Path string
+	// Size is the uncompressed size declared by the archive.
+	Size     int64
+	Modified time.Time
+	// Err is set when the member is rejected without being read.
+	Err error
+}
+
+// WalkFunc is called for every member. r yields the member's content and is nil when m.Err is
+// set; reading it fails once a limit is exceeded. A non-nil error stops the walk and is returned
+// by Walk.
+type WalkFunc func(m Member, r io.Reader) error
+
+// DetectFormat identifies the archive format from the first bytes of the content.
+func DetectFormat(r io.ReaderAt) (Format, error) {
+	head := make([]byte, 512)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	head = head[:n]
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return FormatZip, nil
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return FormatTarGzip, nil
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return FormatTar, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// Walk calls fn for every file of the ZIP, tar or gzip-compressed tar archive in r, in archive
+// order; directories are skipped. Members that break a per-member rule (unsafe path, not a
+// regular file, declared size or ratio over the limit) are passed to fn with Err set, and the
+// walk continues. Exceeding the entry count or the total size ends the walk with ErrTooManyEntries
+// or ErrArchiveTooLarge; for ZIP archives both are checked against the central directory before
+// fn is called at all.
+func Walk(r io.ReaderAt, size int64, limits Limits, fn WalkFunc) error {
+	format, err := DetectFormat(r)
+	if err != nil {
+		return err
+	}
+	w := &walker{limits: limits, fn: fn}
+	switch format {
+	case FormatZip:
+		return w.zip(r, size)
+	case FormatTar:
+		return w.tar(io.NewSectionReader(r, 0, size))
+	default:
+		w.compressed = &countingReader{r: io.NewSectionReader(r, 0, size)}
+		gz, err := gzip.NewReader(w.compressed)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+		}
+		defer gz.Close()
+		return w.tar(gz)
+	}
+}
+
+type walker struct {
+	limits  Limits
+	fn      WalkFunc
+	entries int
+	// total is the uncompressed content read so far.
+	total int64
+	// compressed counts the bytes read from a gzip-compressed tar stream.
+	compressed *countingReader
+	// abort is set when an archive-wide limit is exceeded while reading a member.
+	abort error
+}
+
+func (w *walker) zip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	// Insecure names are rejected per member below; the reader is still usable.
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+
+	var files []*zip.File
+	var declared uint64
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		files = append(files, f)
+		declared += f.UncompressedSize64
+	}
+	if err := w.checkEntries(len(files)); err != nil {
+		return err
+	}
+	if w.limits.MaxTotalSize > 0 && declared > uint64(w.limits.MaxTotalSize) {
+		return fmt.Errorf("%w: at most %d bytes", ErrArchiveTooLarge, w.limits.MaxTotalSize)
+	}
+
+	for _, f := range files {
+		m := Member{Path: f.Name, Size: int64(f.UncompressedSize64), Modified: f.Modified}
+		if !f.Mode().IsRegular() {
+			m.Err = ErrNotRegular
+		}
+		if err := w.visit(m, int64(f.CompressedSize64), func() (io.ReadCloser, error) { return f.Open() }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *walker) tar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil && !errors.Is(err, tar.ErrInsecurePath) {
+			return fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+		}
+		if hdr.Typeflag == tar.TypeDir || hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		w.entries++
+		if err := w.checkEntries(w.entries); err != nil {
+			return err
+		}
+		m := Member{Path: hdr.Name, Size: hdr.Size, Modified: hdr.ModTime}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			m.Err = ErrNotRegular
+		}
+		// Tar members are not compressed individually; the stream ratio is checked while reading.
+		if err := w.visit(m, 0, func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *walker) checkEntries(n int) error {
+	if w.limits.MaxEntries > 0 && n > w.limits.MaxEntries {
+		return fmt.Errorf("%w: at most %d", ErrTooManyEntries, w.limits.MaxEntries)
+	}
+	return nil
+}
+
+// visit checks a member against the limits and hands it to fn. compressedSize is the member's own
+// compressed size, or 0 if it has none.
+func (w *walker) visit(m Member, compressedSize int64, open func() (io.ReadCloser, error)) error {
+	if clean, err := cleanPath(m.Path); err != nil {
+		m.Err = err
+	} else {
+		m.Path = clean
+	}
+	if m.Err == nil {
+		m.Err = w.checkDeclared(m.Size, compressedSize)
+	}
+	if m.Err != nil {
+		if errors.Is(m.Err, ErrArchiveTooLarge) {
+			w.abort = m.Err
+		}
+		if err := w.fn(m, nil); err != nil {
+			return err
+		}
+		return w.abort
+	}
+
+	rc, err := open()
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidArchive, m.Path, err)
+	}
+	defer rc.Close()
+	if err := w.fn(m, &limitedReader{w: w, r: rc, compressed: compressedSize}); err != nil {
+		return err
+	}
+	return w.abort
+}
+
+// checkDeclared applies the limits to the sizes an archive declares for a member, so that members
+// are rejected before anything is read where possible. The content is checked again while reading.
+func (w *walker) checkDeclared(size, compressedSize int64) error {
+	switch {
+	case w.limits.MaxEntrySize > 0 && size > w.limits.MaxEntrySize:
+		return fmt.Errorf("%w: at most %d bytes", ErrEntryTooLarge, w.limits.MaxEntrySize)
+	case w.limits.MaxTotalSize > 0 && w.total+size > w.limits.MaxTotalSize:
+		return fmt.Errorf("%w: at most %d bytes", ErrArchiveTooLarge, w.limits.MaxTotalSize)
+	case compressedSize > 0 && w.ratioExceeded(size, compressedSize):
+		return fmt.Errorf("%w: at most %d:1", ErrRatioExceeded, w.limits.MaxRatio)
+	}
+	return nil
+}
+
+func (w *walker) ratioExceeded(out, in int64) bool {
+	return w.limits.MaxRatio > 0 && out > ratioGrace && out/w.limits.MaxRatio > in
+}
+
+// limitedReader enforces the limits on the content actually extracted, which archives cannot
+// misstate.
+type limitedReader struct {
+	w          *walker
+	r          io.Reader
+	compressed int64
+	n          int64
+	err        error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	l.w.total += int64(n)
+
+	w := l.w
+	switch {
+	case w.limits.MaxEntrySize > 0 && l.n > w.limits.MaxEntrySize:
+		l.err = fmt.Errorf("%w: at most %d bytes", ErrEntryTooLarge, w.limits.MaxEntrySize)
+	case w.limits.MaxTotalSize > 0 && w.total > w.limits.MaxTotalSize:
+		l.err = fmt.Errorf("%w: at most %d bytes", ErrArchiveTooLarge, w.limits.MaxTotalSize)
+		w.abort = l.err
+	case l.compressed > 0 && w.ratioExceeded(l.n, l.compressed):
+		l.err = fmt.Errorf("%w: at most %d:1", ErrRatioExceeded, w.limits.MaxRatio)
+	case w.compressed != nil && w.ratioExceeded(w.total, w.compressed.n):
+		l.err = fmt.Errorf("%w: at most %d:1", ErrRatioExceeded, w.limits.MaxRatio)
+		w.abort = l.err
+	}
+	if l.err != nil {
+		return n, l.err
+	}
+	return n, err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// cleanPath validates a member name and returns it as a clean relative path. Backslashes are
+// treated as separators, since some ZIP tools write Windows paths.
+func cleanPath(name string) (string, error) {
+	p := strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(p, "/") || (len(p) >= 2 && p[1] == ':') {
+		return "", ErrUnsafePath
+	}
+	for _, elem := range strings.Split(p, "/") {
+		if elem == ".." {
+			return "", ErrUnsafePath
+		}
+	}
+	if strings.IndexFunc(p, func(r rune) bool { return r < 0x20 || r == 0x7f }) >= 0 {
+		return "", ErrUnsafePath
+	}
+	p = path.Clean(p)
+	if p == "." {
+		return "", ErrUnsafePath
+	}
+	return p, nil
+}
@@ -0,0 +1,221 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testMember struct {
+	name    string
+	content string
+	dir     bool
+	symlink bool
+}
+
+func buildZip(t *testing.T, members []testMember) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, m := range members {
+		hdr := &zip.FileHeader{Name: m.name, Method: zip.Deflate}
+		switch {
+		case m.dir:
+			hdr.SetMode(fs.ModeDir | 0o755)
+		case m.symlink:
+			hdr.SetMode(fs.ModeSymlink | 0o777)
+		}
+		fw, err := zw.CreateHeader(hdr)
+		require.NoError(t, err)
+		_, err = io.WriteString(fw, m.content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func buildTar(t *testing.T, members []testMember, compress bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var out io.Writer = &buf
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		out = gz
+	}
+	tw := tar.NewWriter(out)
+	for _, m := range members {
+		hdr := &tar.Header{Name: m.name, Mode: 0o644, Size: int64(len(m.content)), Typeflag: tar.TypeReg}
+		switch {
+		case m.dir:
+			hdr.Typeflag, hdr.Size = tar.TypeDir, 0
+		case m.symlink:
+			hdr.Typeflag, hdr.Size, hdr.Linkname = tar.TypeSymlink, 0, m.content
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		if hdr.Size > 0 {
+			_, err := io.WriteString(tw, m.content)
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+	if gz != nil {
+		require.NoError(t, gz.Close())
+	}
+	return buf.Bytes()
+}
+
+type walked struct {
+	path    string
+	content string
+	err     error
+}
+
+func walk(t *testing.T, data []byte, limits Limits) ([]walked, error) {
+	t.Helper()
+	var got []walked
+	err := Walk(bytes.NewReader(data), int64(len(data)), limits, func(m Member, r io.Reader) error {
+		w := walked{path: m.Path, err: m.Err}
+		if r != nil {
+			b, err := io.ReadAll(r)
+			w.content, w.err = string(b), err
+		}
+		got = append(got, w)
+		return nil
+	})
+	return got, err
+}
+
+func TestWalk(t *testing.T) {
+	members := []testMember{
+		{name: "docs/", dir: true},
+		{name: "docs/a.txt", content: "alpha"},
+		{name: "./docs//b.txt", content: "bravo"},
+		{name: "docs/../up.txt", content: "x"},
+		{name: "../evil.txt", content: "x"},
+		{name: "/etc/passwd", content: "x"},
+		{name: `C:\temp\c.txt`, content: "x"},
+		{name: "link", content: "docs/a.txt", symlink: true},
+		{name: "empty.txt"},
+	}
+	want := []walked{
+		{path: "docs/a.txt", content: "alpha"},
+		{path: "docs/b.txt", content: "bravo"},
+		{path: "docs/../up.txt", err: ErrUnsafePath},
+		{path: "../evil.txt", err: ErrUnsafePath},
+		{path: "/etc/passwd", err: ErrUnsafePath},
+		{path: `C:\temp\c.txt`, err: ErrUnsafePath},
+		{path: "link", err: ErrNotRegular},
+		{path: "empty.txt"},
+	}
+
+	archives := map[string][]byte{
+		"zip":    buildZip(t, members),
+		"tar":    buildTar(t, members, false),
+		"tar.gz": buildTar(t, members, true),
+	}
+	for name, data := range archives {
+		t.Run(name, func(t *testing.T) {
+			format, err := DetectFormat(bytes.NewReader(data))
+			require.NoError(t, err)
+			assert.Equal(t, Format(name), format)
+
+			got, err := walk(t, data, Limits{})
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestWalk_Unsupported(t *testing.T) {
+	_, err := walk(t, []byte("just some text"), Limits{})
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	_, err = walk(t, []byte("PK\x03\x04 truncated"), Limits{})
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+}
+
+func TestWalk_Limits(t *testing.T) {
+	three := []testMember{{name: "a", content: "0123456789"}, {name: "b", content: "0123456789"}, {name: "c", content: "0123456789"}}
+	zeros := strings.Repeat("\x00", 4<<20)
+
+	t.Run("entries in zip are checked upfront", func(t *testing.T) {
+		got, err := walk(t, buildZip(t, three), Limits{MaxEntries: 2})
+		assert.ErrorIs(t, err, ErrTooManyEntries)
+		assert.Empty(t, got)
+	})
+
+	t.Run("entries in tar stop the walk", func(t *testing.T) {
+		got, err := walk(t, buildTar(t, three, false), Limits{MaxEntries: 2})
+		assert.ErrorIs(t, err, ErrTooManyEntries)
+		assert.Len(t, got, 2)
+	})
+
+	t.Run("entry size", func(t *testing.T) {
+		members := []testMember{{name: "small", content: "abc"}, {name: "big", content: "0123456789"}}
+		for _, data := range [][]byte{buildZip(t, members), buildTar(t, members, true)} {
+			got, err := walk(t, data, Limits{MaxEntrySize: 5})
+			require.NoError(t, err)
+			require.Len(t, got, 2)
+			assert.NoError(t, got[0].err)
+			assert.ErrorIs(t, got[1].err, ErrEntryTooLarge)
+		}
+	})
+
+	t.Run("total size in zip is checked upfront", func(t *testing.T) {
+		got, err := walk(t, buildZip(t, three), Limits{MaxTotalSize: 25})
+		assert.ErrorIs(t, err, ErrArchiveTooLarge)
+		assert.Empty(t, got)
+	})
+
+	t.Run("total size in tar stops the walk", func(t *testing.T) {
+		got, err := walk(t, buildTar(t, three, false), Limits{MaxTotalSize: 25})
+		assert.ErrorIs(t, err, ErrArchiveTooLarge)
+		require.Len(t, got, 3)
+		assert.NoError(t, got[1].err)
+		assert.ErrorIs(t, got[2].err, ErrArchiveTooLarge)
+	})
+
+	t.Run("ratio of a zip entry", func(t *testing.T) {
+		got, err := walk(t, buildZip(t, []testMember{{name: "bomb", content: zeros}, {name: "ok", content: "fine"}}), Limits{MaxRatio: 100})
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.ErrorIs(t, got[0].err, ErrRatioExceeded)
+		assert.NoError(t, got[1].err)
+	})
+
+	t.Run("ratio of a compressed tar stops the walk", func(t *testing.T) {
+		got, err := walk(t, buildTar(t, []testMember{{name: "bomb", content: zeros}, {name: "ok", content: "fine"}}, true), Limits{MaxRatio: 100})
+		assert.ErrorIs(t, err, ErrRatioExceeded)
+		require.Len(t, got, 1)
+		assert.ErrorIs(t, got[0].err, ErrRatioExceeded)
+	})
+
+	t.Run("small compressible entries are accepted", func(t *testing.T) {
+		got, err := walk(t, buildZip(t, []testMember{{name: "zeros", content: zeros[:64<<10]}}), Limits{MaxRatio: 10})
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.NoError(t, got[0].err)
+	})
+}
+
+func TestWalk_CallbackError(t *testing.T) {
+	data := buildZip(t, []testMember{{name: "a", content: "1"}, {name: "b", content: "2"}})
+	stop := errors.New("stop")
+	calls := 0
+	err := Walk(bytes.NewReader(data), int64(len(data)), Limits{}, func(Member, io.Reader) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,191 @@
+// Package archive packs documents into ZIP archives and unpacks uploaded archives.
+package archive
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+// ManifestName is the archive entry holding the Manifest. Documents never use this name.
+const ManifestName = "manifest.json"
+
+// Reasons a document is listed in Manifest.Skipped.
+const (
+	SkipNotFound   = "not_found"
+	SkipUnreadable = "unreadable"
+	// SkipQuarantined marks documents whose content was found infected.
+	SkipQuarantined = "quarantined"
+	// SkipNotAvailable marks documents that are pending, processing or failed.
+	SkipNotAvailable = "not_available"
+)
+
+// Entry describes a document added to an archive.
+type Entry struct {
+	ID          string
+	Name        string
+	ContentType string
+	Modified    time.Time
+}
+
+// ManifestEntry describes one document stored in the archive.
+type ManifestEntry struct {
+	// Path is the entry name inside the archive.
+	Path        string    `json:"path"`
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SkippedEntry is a selected document that is not in the archive.
+type SkippedEntry struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// Manifest lists the contents of an archive. It is written as the last entry.
+type Manifest struct {
+	CreatedAt time.Time       `json:"created_at"`
+	Documents []ManifestEntry `json:"documents"`
+	Skipped   []SkippedEntry  `json:"skipped"`
+}
+
+// Writer streams documents into a ZIP archive without buffering them. Entries are written with
+// data descriptors, so sizes need not be known upfront, and ZIP64 records are used automatically
+// for entries of 4 GiB or more and archives of more than 65535 entries.
+type Writer struct {
+	zw    *zip.Writer
+	names map[string]bool
+	// next holds the next collision suffix to try per name, so that many documents with the same
+	// name do not rescan all suffixes taken before.
+	next     map[string]int
+	manifest Manifest
+}
+
+// NewWriter returns a Writer that writes the archive to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		zw:       zip.NewWriter(w),
+		names:    map[string]bool{strings.ToLower(ManifestName): true},
+		next:     map[string]int{},
+		manifest: Manifest{CreatedAt: time.Now().UTC(), Documents: []ManifestEntry{}, Skipped: []SkippedEntry{}},
+	}
+}
+
+// Add copies r into a new entry named after e.Name, renamed if necessary to be unique within the
+// archive, and records it in the manifest with its SHA-256 checksum. A failed Add leaves the
+// archive unusable.
+func (w *Writer) Add(e Entry, r io.Reader) error {
+	name := w.uniqueName(e.Name, e.ID)
+	method := zip.Deflate
+	if incompressible(e.ContentType) {
+		method = zip.Store
+	}
+	fw, err := w.zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: e.Modified})
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(fw, h), r)
+	if err != nil {
+		return err
+	}
+	w.manifest.Documents = append(w.manifest.Documents, ManifestEntry{
+		Path:        name,
+		ID:          e.ID,
+		Name:        e.Name,
+		ContentType: e.ContentType,
+		Size:        n,
+		SHA256:      hex.EncodeToString(h.Sum(nil)),
+		CreatedAt:   e.Modified,
+	})
+	return nil
+}
+
+// Skip records a selected document that could not be added.
+func (w *Writer) Skip(id, reason string) {
+	w.manifest.Skipped = append(w.manifest.Skipped, SkippedEntry{ID: id, Reason: reason})
+}
+
+// Close writes the manifest and the central directory. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	fw, err := w.zw.CreateHeader(&zip.FileHeader{Name: ManifestName, Method: zip.Deflate, Modified: w.manifest.CreatedAt})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(fw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(w.manifest); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+// uniqueName turns a document name into a safe entry name that is not yet taken, comparing names
+// case-insensitively so the archive also extracts cleanly on case-insensitive file systems.
+// Collisions get a numbered suffix before the extension: "a.txt", "a (1).txt", "a (2).txt".
+func (w *Writer) uniqueName(name, fallback string) string {
+	base := sanitizeName(name)
+	if base == "" {
+		base = fallback
+	}
+	ext := path.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	key := strings.ToLower(base)
+	candidate := base
+	for w.names[strings.ToLower(candidate)] {
+		w.next[key]++
+		candidate = fmt.Sprintf("%s (%d)%s", stem, w.next[key], ext)
+	}
+	w.names[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// sanitizeName reduces a client-supplied filename to a single path element without control
+// characters, so entries cannot escape the extraction directory.
+func sanitizeName(name string) string {
+	name = strings.ReplaceAll(name, `\`, "/")
+	name = path.Base(name)
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+	return name
+}
+
+// incompressible reports whether content of this type is already compressed, so deflating it
+// would only cost CPU.
+func incompressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "image/svg+xml":
+		return false
+	case strings.HasPrefix(mediaType, "image/"), strings.HasPrefix(mediaType, "video/"), strings.HasPrefix(mediaType, "audio/"):
+		return true
+	}
+	switch mediaType {
+	case "application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+		"application/x-7z-compressed", "application/x-rar-compressed", "application/x-bzip2", "application/x-xz":
+		return true
+	}
+	return false
+}
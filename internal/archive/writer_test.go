@@ -0,0 +1,128 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readZip(t *testing.T, data []byte) (map[string]string, []string, Manifest) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	files := map[string]string{}
+	var order []string
+	var manifest Manifest
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		if f.Name == ManifestName {
+			require.NoError(t, json.Unmarshal(content, &manifest))
+			continue
+		}
+		files[f.Name] = string(content)
+		order = append(order, f.Name)
+	}
+	return files, order, manifest
+}
+
+func TestWriter(t *testing.T) {
+	modified := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	entries := []struct {
+		entry   Entry
+		content string
+	}{
+		{Entry{ID: "1", Name: "report.txt", ContentType: "text/plain", Modified: modified}, "one"},
+		{Entry{ID: "2", Name: "Report.TXT", ContentType: "text/plain", Modified: modified}, "two"},
+		{Entry{ID: "3", Name: "report.txt", ContentType: "text/plain", Modified: modified}, "three"},
+		{Entry{ID: "4", Name: "../../etc/passwd", ContentType: "text/plain", Modified: modified}, "four"},
+		{Entry{ID: "5", Name: `C:\scans\page.png`, ContentType: "image/png", Modified: modified}, "five"},
+		{Entry{ID: "6", Name: "manifest.json", ContentType: "application/json", Modified: modified}, "six"},
+		{Entry{ID: "7", Name: "", ContentType: "text/plain", Modified: modified}, "seven"},
+		{Entry{ID: "8", Name: "..", ContentType: "text/plain", Modified: modified}, ""},
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, e := range entries {
+		require.NoError(t, w.Add(e.entry, strings.NewReader(e.content)))
+	}
+	w.Skip("9", SkipNotFound)
+	require.NoError(t, w.Close())
+
+	files, order, manifest := readZip(t, buf.Bytes())
+	wantPaths := []string{"report.txt", "Report (1).TXT", "report (2).txt", "passwd", "page.png", "manifest (1).json", "7", "8"}
+	assert.Equal(t, wantPaths, order)
+
+	require.Len(t, manifest.Documents, len(entries))
+	for i, e := range entries {
+		doc := manifest.Documents[i]
+		sum := sha256.Sum256([]byte(e.content))
+		assert.Equal(t, wantPaths[i], doc.Path)
+		assert.Equal(t, e.entry.ID, doc.ID)
+		assert.Equal(t, e.entry.Name, doc.Name)
+		assert.Equal(t, int64(len(e.content)), doc.Size)
+		assert.Equal(t, hex.EncodeToString(sum[:]), doc.SHA256)
+		assert.True(t, modified.Equal(doc.CreatedAt))
+		assert.Equal(t, e.content, files[doc.Path])
+	}
+	assert.Equal(t, []SkippedEntry{{ID: "9", Reason: SkipNotFound}}, manifest.Skipped)
+}
+
+func TestWriter_CompressionMethod(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	require.NoError(t, w.Add(Entry{ID: "1", Name: "a.txt", ContentType: "text/plain; charset=utf-8"}, strings.NewReader("text")))
+	require.NoError(t, w.Add(Entry{ID: "2", Name: "b.jpg", ContentType: "image/jpeg"}, strings.NewReader("jpeg")))
+	require.NoError(t, w.Add(Entry{ID: "3", Name: "c.svg", ContentType: "image/svg+xml"}, strings.NewReader("<svg/>")))
+	require.NoError(t, w.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	methods := map[string]uint16{}
+	for _, f := range zr.File {
+		methods[f.Name] = f.Method
+	}
+	assert.Equal(t, zip.Deflate, methods["a.txt"])
+	assert.Equal(t, zip.Store, methods["b.jpg"])
+	assert.Equal(t, zip.Deflate, methods["c.svg"])
+}
+
+func TestWriter_ManyEntries(t *testing.T) {
+	// More entries than a ZIP without ZIP64 records can count.
+	const n = 1<<16 + 10
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for i := 0; i < n; i++ {
+		require.NoError(t, w.Add(Entry{ID: "x", Name: "page.png", ContentType: "image/png"}, strings.NewReader("")))
+	}
+	require.NoError(t, w.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Len(t, zr.File, n+1)
+	assert.Equal(t, "page (65545).png", zr.File[n-1].Name)
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("read failed") }
+
+func TestWriter_AddError(t *testing.T) {
+	w := NewWriter(io.Discard)
+	err := w.Add(Entry{ID: "1", Name: "a.txt"}, failingReader{})
+	assert.EqualError(t, err, "read failed")
+}
@@ -1,106 +1,298 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"maps"
 	"os"
-	"strconv"
+	"slices"
+	"strings"
 	"time"
 )
 
-// DatabaseConfig holds PostgreSQL database connection settings.
+// DatabaseConfig holds PostgreSQL database connection settings. Password is read from
+// PasswordFile when that is set.
 type DatabaseConfig struct {
-	Host               string
-	Port               string
-	User               string
-	Password           string
-	Name               string
-	SSLMode            string
-	MaxOpenConns       int
-	MaxIdleConns       int
-	ConnMaxLifetimeSec int
-}
-
-// MinIOConfig holds object storage settings for MinIO.
+	Host            string
+	Port            string
+	User            string
+	Password        string
+	PasswordFile    string
+	Name            string
+	SSLMode         string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	// AutoMigrate applies pending schema migrations when the server starts.
+	AutoMigrate bool
+}
+
+// MinIOConfig holds object storage settings for MinIO. AccessKey and SecretKey are read from
+// AccessKeyFile and SecretKeyFile when those are set.
 type MinIOConfig struct {
-	Endpoint  string
-	AccessKey string
-	SecretKey string
-	Bucket    string
-	UseSSL    bool
+	Endpoint      string
+	AccessKey     string
+	AccessKeyFile string
+	SecretKey     string
+	SecretKeyFile string
+	Bucket        string
+	UseSSL        bool
+}
+
+// EncryptionConfig holds settings for envelope encryption of stored objects.
+// Keys is a comma- or newline-separated list of "id:base64key" master keys (32 bytes each);
+// KeysFile names a file in the same format. KeyID selects the master key used for new objects,
+// the remaining keys are kept only to unwrap existing objects until they are re-wrapped.
+type EncryptionConfig struct {
+	Enabled  bool
+	KeyID    string
+	Keys     string
+	KeysFile string
+}
+
+// CompressionConfig holds settings for transparent compression of stored objects.
+// Algorithm is "zstd", "gzip" or empty to disable. Only objects whose content type matches one of
+// ContentTypes (comma-separated, "type/*" wildcards allowed) and whose size is at least MinSize
+// bytes are compressed.
+type CompressionConfig struct {
+	Algorithm    string
+	MinSize      int64
+	ContentTypes string
+}
+
+// DefaultCompressibleTypes lists the content types compressed when STORAGE_COMPRESSION_TYPES is unset.
+const DefaultCompressibleTypes = "text/*,application/json,application/xml,application/csv,application/x-ndjson,application/javascript,application/x-yaml,image/svg+xml"
+
+// BatchConfig holds limits for batch endpoints.
+// DeleteMaxIDs caps the number of IDs accepted by one batch delete request; DeleteConcurrency
+// bounds the storage deletions it runs in parallel. UploadMaxFiles and UploadConcurrency do the
+// same for multi-file uploads. ArchiveMaxDocuments caps the documents of one ZIP download.
+type BatchConfig struct {
+	DeleteMaxIDs        int
+	DeleteConcurrency   int
+	UploadMaxFiles      int
+	UploadConcurrency   int
+	ArchiveMaxDocuments int
+}
+
+// ImportConfig holds the limits applied to archive imports, which guard against archive bombs.
+// MaxEntries caps the files of one archive; MaxEntrySize and MaxTotalSize cap the uncompressed
+// bytes of one file and of all files; MaxRatio caps how far content may expand per compressed
+// byte. Zero disables a limit.
+type ImportConfig struct {
+	MaxEntries   int
+	MaxEntrySize int64
+	MaxTotalSize int64
+	MaxRatio     int64
+}
+
+// ReconcileConfig holds settings for the job that finds drift between object storage and the
+// database. Interval schedules the job in the server; zero disables it. Objects and documents
+// younger than GracePeriod are skipped so that uploads in progress are not reported. DryRun
+// only reports drift; otherwise orphan objects and dangling documents are deleted.
+type ReconcileConfig struct {
+	Interval    time.Duration
+	GracePeriod time.Duration
+	DryRun      bool
+}
+
+// IdempotencyConfig holds settings for requests sent with an Idempotency-Key header. Responses
+// are kept for replay for TTL; zero disables idempotency keys. A request holds its key for at
+// most LockTimeout, after which a crashed request no longer blocks it, and a duplicate waits up
+// to Wait for the request in flight before it is rejected.
+type IdempotencyConfig struct {
+	TTL         time.Duration
+	LockTimeout time.Duration
+	Wait        time.Duration
+}
+
+// ScanConfig holds settings for malware scanning of uploads with clamd. An empty ClamdAddr
+// disables scanning. Timeout bounds every read and write on the clamd connection; content is
+// streamed to clamd in chunks of ChunkSize bytes.
+type ScanConfig struct {
+	ClamdAddr string
+	Timeout   time.Duration
+	ChunkSize int
+}
+
+// JobsConfig holds settings for the background job workers. Workers jobs run at once in this
+// process; zero disables the workers, leaving jobs queued for other processes. Idle workers look
+// for due jobs every PollInterval. A job holds its claim for VisibilityTimeout, extended while it
+// runs, so the job of a crashed worker runs again after that long. Failed jobs run again after a
+// backoff from BackoffBase, doubled per attempt up to BackoffMax, and at most MaxAttempts times.
+// On shutdown, running jobs get DrainTimeout to finish.
+type JobsConfig struct {
+	Workers           int
+	PollInterval      time.Duration
+	VisibilityTimeout time.Duration
+	MaxAttempts       int
+	BackoffBase       time.Duration
+	BackoffMax        time.Duration
+	DrainTimeout      time.Duration
+}
+
+// HealthConfig holds settings for the readiness probe. Every dependency check is bounded by
+// CheckTimeout; a report is reused for CacheTTL before the checks run again.
+type HealthConfig struct {
+	CheckTimeout time.Duration
+	CacheTTL     time.Duration
+}
+
+// ShutdownConfig holds settings for stopping the server on SIGINT/SIGTERM. The readiness probe
+// fails for ReadinessDelay before the server stops accepting connections, giving load balancers
+// time to notice. Requests in flight then get GracePeriod to complete; those still running are
+// canceled and get AbortTimeout to roll back.
+type ShutdownConfig struct {
+	ReadinessDelay time.Duration
+	GracePeriod    time.Duration
+	AbortTimeout   time.Duration
+}
+
+// AdminConfig holds settings for the administrative endpoints under /admin. They require
+// "Authorization: Bearer <Token>"; an empty Token disables them. Token is read from TokenFile
+// when that is set.
+type AdminConfig struct {
+	Token     string
+	TokenFile string
+}
+
+// SecretsConfig holds settings for reloading credentials while the server runs. The files of
+// the *_FILE settings and the config file are checked for changes every PollInterval; zero
+// leaves reloading to SIGHUP.
+type SecretsConfig struct {
+	PollInterval time.Duration
 }
 
 // AppConfig is the centralized configuration struct for the application.
-// It is populated from environment variables. Sensitive values are not hardcoded.
+// It is populated by Load from defaults, an optional config file and environment variables.
+// Sensitive values are not hardcoded.
 type AppConfig struct {
-	AppHost  string
-	Port     string
-	Timezone string
-	Location *time.Location
-	Database DatabaseConfig
-	MinIO    MinIOConfig
-}
-
-// Load reads configuration from environment variables.
-// A .env file can be auto-loaded by importing: _ "github.com/joho/godotenv/autoload"
-// This function does not require a .env file; real environment variables take precedence.
-func Load() *AppConfig {
-	tzStr := getEnv("APP_TZ", "Asia/Jakarta")
-	loc, err := time.LoadLocation(tzStr)
-	if err != nil {
-		log.Printf("warning: invalid APP_TZ %q, falling back to Asia/Jakarta: %v", tzStr, err)
-		// Fallback to Asia/Jakarta (UTC+7)
-		loc = time.FixedZone("Asia/Jakarta", 7*60*60)
+	AppHost     string
+	Port        string
+	Timezone    string
+	Location    *time.Location
+	Database    DatabaseConfig
+	MinIO       MinIOConfig
+	Encryption  EncryptionConfig
+	Compression CompressionConfig
+	Batch       BatchConfig
+	Import      ImportConfig
+	Reconcile   ReconcileConfig
+	Idempotency IdempotencyConfig
+	Scan        ScanConfig
+	Jobs        JobsConfig
+	Health      HealthConfig
+	Shutdown    ShutdownConfig
+	Admin       AdminConfig
+	Secrets     SecretsConfig
+
+	// sources records where Load read each setting from, by key.
+	sources map[string]string
+}
+
+// ConfigFileEnv names the environment variable holding the path of the optional config file.
+const ConfigFileEnv = "CONFIG_FILE"
+
+// Load reads the configuration. Every setting has a default, which the config file named by
+// CONFIG_FILE overrides, which environment variables override in turn. A .env file can be
+// auto-loaded by importing: _ "github.com/joho/godotenv/autoload"; real environment variables
+// take precedence over it.
+//
+// A secret such as DB_PASSWORD may instead be read from the file named by the same key with a
+// _FILE suffix, as with Docker and Kubernetes secrets; trailing line breaks are removed.
+//
+// The error lists every problem found: values that don't parse, keys of the config file that
+// are not settings, and the problems reported by Validate. The configuration is returned even
+// then, with defaults in place of the values that don't parse, so that it can be inspected.
+func Load() (*AppConfig, error) {
+	return LoadFile(os.Getenv(ConfigFileEnv))
+}
+
+// LoadFile is Load with the config file at path instead of CONFIG_FILE. An empty path reads no
+// config file.
+func LoadFile(path string) (*AppConfig, error) {
+	c := &AppConfig{sources: make(map[string]string)}
+	settings := c.settings()
+	for _, s := range settings {
+		if err := s.value.Set(s.def); err != nil {
+			panic(fmt.Sprintf("config: invalid default for %s: %v", s.key, err))
+		}
+		c.sources[s.key] = SourceDefault
 	}
 
-	return &AppConfig{
-		AppHost:  getEnv("APP_HOST", "localhost:8080"),
-		Port:     getEnv("PORT", "8080"), // default only for non-sensitive value
-		Timezone: tzStr,
-		Location: loc,
-		Database: DatabaseConfig{
-			Host:               getEnv("DB_HOST", ""),
-			Port:               getEnv("DB_PORT", "5432"),
-			User:               getEnv("DB_USER", ""),
-			Password:           getEnv("DB_PASSWORD", ""),
-			Name:               getEnv("DB_NAME", ""),
-			SSLMode:            getEnv("DB_SSLMODE", "disable"),
-			MaxOpenConns:       getEnvInt("DB_MAX_OPEN_CONNS", 10),
-			MaxIdleConns:       getEnvInt("DB_MAX_IDLE_CONNS", 5),
-			ConnMaxLifetimeSec: getEnvInt("DB_CONN_MAX_LIFETIME_SEC", 300),
-		},
-		MinIO: MinIOConfig{
-			Endpoint:  getEnv("MINIO_ENDPOINT", ""),
-			AccessKey: getEnv("MINIO_ACCESS_KEY", ""),
-			SecretKey: getEnv("MINIO_SECRET_KEY", ""),
-			Bucket:    getEnv("MINIO_BUCKET", ""),
-			UseSSL:    getEnvBool("MINIO_USE_SSL", false),
-		},
+	var errs []error
+	var file map[string]fileValue
+	if path != "" {
+		var err error
+		if file, err = readFile(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	known := make(map[string]bool, len(settings))
+	for _, s := range settings {
+		known[s.key] = true
+		if v, ok := file[s.key]; ok {
+			if err := s.value.Set(v.value); err != nil {
+				errs = append(errs, fmt.Errorf("%s (%s in %s): %w", s.key, v.key, path, err))
+			} else {
+				c.sources[s.key] = SourceFile
+			}
+		}
+		if v := os.Getenv(s.key); v != "" {
+			if err := s.value.Set(v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.key, err))
+			} else {
+				c.sources[s.key] = SourceEnv
+			}
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(file)) {
+		if !known[key] {
+			errs = append(errs, fmt.Errorf("%s in %s: unknown setting %s", file[key].key, path, key))
+		}
+	}
+	for _, s := range settings {
+		if err := c.readSecretFile(s); err != nil {
+			errs = append(errs, err)
+		}
 	}
-}
 
-func getEnv(key, def string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+	c.Location = time.FixedZone("Asia/Jakarta", 7*60*60)
+	if loc, err := time.LoadLocation(c.Timezone); err == nil {
+		c.Location = loc
 	}
-	return def
+	if err := c.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return c, errors.Join(errs...)
 }
 
-func getEnvBool(key string, def bool) bool {
-	if v := os.Getenv(key); v != "" {
-		b, err := strconv.ParseBool(v)
-		if err == nil {
-			return b
-		}
+// readSecretFile sets the secret s from the file named by its _FILE setting, if that is set.
+func (c *AppConfig) readSecretFile(s setting) error {
+	if s.file == nil || *s.file == "" {
+		return nil
+	}
+	if c.sources[s.key] != SourceDefault {
+		return fmt.Errorf("%s: set either %s or %s_FILE, not both", s.key, s.key, s.key)
+	}
+	b, err := os.ReadFile(*s.file)
+	if err != nil {
+		return fmt.Errorf("%s_FILE: %w", s.key, err)
+	}
+	if err := s.value.Set(strings.TrimRight(string(b), "\r\n")); err != nil {
+		return fmt.Errorf("%s (%s): %w", s.key, *s.file, err)
 	}
-	return def
+	c.sources[s.key] = SourceSecretFile
+	return nil
 }
 
-func getEnvInt(key string, def int) int {
-	if v := os.Getenv(key); v != "" {
-		i, err := strconv.Atoi(v)
-		if err == nil {
-			return i
+// SecretFiles returns the files secrets were read from, in the order of Settings.
+func (c *AppConfig) SecretFiles() []string {
+	var files []string
+	for _, s := range c.settings() {
+		if s.file != nil && *s.file != "" {
+			files = append(files, *s.file)
 		}
 	}
-	return def
+	return files
 }
@@ -2,61 +2,541 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestLoad(t *testing.T) {
-	// Save current env and restore later
-	origHost := os.Getenv("DB_HOST")
-	defer os.Setenv("DB_HOST", origHost)
+// setRequired sets the settings without defaults that Validate requires.
+func setRequired(t *testing.T) {
+	t.Helper()
+	for k, v := range map[string]string{
+		"DB_HOST":          "test-host",
+		"DB_USER":          "docapi",
+		"DB_NAME":          "docapi",
+		"MINIO_ENDPOINT":   "localhost:9000",
+		"MINIO_ACCESS_KEY": "minio",
+		"MINIO_SECRET_KEY": "minio-secret",
+		"MINIO_BUCKET":     "documents",
+	} {
+		t.Setenv(k, v)
+	}
+}
+
+// writeFile writes a config file named name in a temporary directory and returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
 
-	os.Setenv("DB_HOST", "test-host")
-	os.Setenv("DB_MAX_OPEN_CONNS", "20")
-	os.Setenv("MINIO_USE_SSL", "true")
+func TestLoad(t *testing.T) {
+	setRequired(t)
+	t.Setenv(ConfigFileEnv, "")
+	t.Setenv("DB_MAX_OPEN_CONNS", "20")
+	t.Setenv("MINIO_USE_SSL", "true")
+	t.Setenv("DB_AUTO_MIGRATE", "true")
+	t.Setenv("BATCH_DELETE_MAX_IDS", "250")
 
-	cfg := Load()
+	cfg, err := Load()
+	require.NoError(t, err)
 
 	assert.Equal(t, "test-host", cfg.Database.Host)
 	assert.Equal(t, 20, cfg.Database.MaxOpenConns)
+	assert.Equal(t, 5*time.Minute, cfg.Database.ConnMaxLifetime)
 	assert.True(t, cfg.MinIO.UseSSL)
+	assert.True(t, cfg.Database.AutoMigrate)
+	assert.Equal(t, 250, cfg.Batch.DeleteMaxIDs)
+	assert.Equal(t, 8, cfg.Batch.DeleteConcurrency)
+	assert.Equal(t, 100, cfg.Batch.UploadMaxFiles)
+	assert.Equal(t, 4, cfg.Batch.UploadConcurrency)
+	assert.Equal(t, 1000, cfg.Batch.ArchiveMaxDocuments)
+	assert.Equal(t, int64(1024), cfg.Compression.MinSize)
+	assert.Equal(t, 10000, cfg.Import.MaxEntries)
+	assert.Equal(t, int64(1<<30), cfg.Import.MaxEntrySize)
+	assert.Equal(t, int64(10<<30), cfg.Import.MaxTotalSize)
+	assert.Equal(t, int64(100), cfg.Import.MaxRatio)
+	assert.Zero(t, cfg.Reconcile.Interval)
+	assert.Equal(t, time.Hour, cfg.Reconcile.GracePeriod)
+	assert.True(t, cfg.Reconcile.DryRun)
+	assert.Equal(t, 24*time.Hour, cfg.Idempotency.TTL)
+	assert.Equal(t, 5*time.Minute, cfg.Idempotency.LockTimeout)
+	assert.Equal(t, 30*time.Second, cfg.Idempotency.Wait)
+	assert.Empty(t, cfg.Scan.ClamdAddr)
+	assert.Equal(t, 30*time.Second, cfg.Scan.Timeout)
+	assert.Equal(t, 64<<10, cfg.Scan.ChunkSize)
+	assert.Equal(t, JobsConfig{
+		Workers: 4, PollInterval: time.Second, VisibilityTimeout: 5 * time.Minute, MaxAttempts: 5,
+		BackoffBase: 10 * time.Second, BackoffMax: time.Hour, DrainTimeout: 30 * time.Second,
+	}, cfg.Jobs)
+	assert.Equal(t, HealthConfig{CheckTimeout: 2 * time.Second, CacheTTL: time.Second}, cfg.Health)
+	assert.Equal(t, ShutdownConfig{GracePeriod: 30 * time.Second, AbortTimeout: 5 * time.Second}, cfg.Shutdown)
+	assert.Empty(t, cfg.Admin.Token)
+}
+
+func TestLoad_Values(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		check func(t *testing.T, cfg *AppConfig)
+	}{
+		{
+			name: "seconds",
+			env:  map[string]string{"JOBS_VISIBILITY_TIMEOUT_SEC": "90"},
+			check: func(t *testing.T, cfg *AppConfig) {
+				assert.Equal(t, 90*time.Second, cfg.Jobs.VisibilityTimeout)
+			},
+		},
+		{
+			name: "duration",
+			env:  map[string]string{"JOBS_VISIBILITY_TIMEOUT_SEC": "1m30s", "RECONCILE_INTERVAL_SEC": "6h"},
+			check: func(t *testing.T, cfg *AppConfig) {
+				assert.Equal(t, 90*time.Second, cfg.Jobs.VisibilityTimeout)
+				assert.Equal(t, 6*time.Hour, cfg.Reconcile.Interval)
+			},
+		},
+		{
+			name: "byte sizes",
+			env:  map[string]string{"IMPORT_MAX_ENTRY_SIZE": "512MiB", "CLAMD_CHUNK_SIZE": "4096", "STORAGE_COMPRESSION_MIN_SIZE": "2kB"},
+			check: func(t *testing.T, cfg *AppConfig) {
+				assert.Equal(t, int64(512<<20), cfg.Import.MaxEntrySize)
+				assert.Equal(t, 4096, cfg.Scan.ChunkSize)
+				assert.Equal(t, int64(2000), cfg.Compression.MinSize)
+			},
+		},
+		{
+			name: "empty variable keeps the default",
+			env:  map[string]string{"DB_MAX_OPEN_CONNS": ""},
+			check: func(t *testing.T, cfg *AppConfig) {
+				assert.Equal(t, 10, cfg.Database.MaxOpenConns)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRequired(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := LoadFile("")
+			require.NoError(t, err)
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	setRequired(t)
+	t.Setenv("DB_MAX_OPEN_CONNS", "ten")
+	t.Setenv("MINIO_USE_SSL", "yes please")
+	t.Setenv("JOBS_POLL_INTERVAL_SEC", "soon")
+	t.Setenv("IMPORT_MAX_ENTRY_SIZE", "1 PiB")
+	t.Setenv("DB_SSLMODE", "sometimes")
+	t.Setenv("DB_HOST", "")
+
+	cfg, err := LoadFile("")
+	require.Error(t, err)
+	for _, want := range []string{
+		`DB_MAX_OPEN_CONNS: "ten" is not an integer`,
+		`MINIO_USE_SSL: "yes please" is not a boolean`,
+		`JOBS_POLL_INTERVAL_SEC: "soon" is not a duration`,
+		`IMPORT_MAX_ENTRY_SIZE: "1 PiB" is not a byte size`,
+		`DB_SSLMODE: must be one of`,
+		`DB_HOST: is required`,
+	} {
+		assert.Contains(t, err.Error(), want)
+	}
+
+	require.NotNil(t, cfg, "the configuration can be inspected despite its problems")
+	assert.Equal(t, 10, cfg.Database.MaxOpenConns, "values that don't parse keep their defaults")
+}
+
+func TestLoad_InvalidTimezone(t *testing.T) {
+	setRequired(t)
+	t.Setenv("APP_TZ", "Mars/Olympus_Mons")
+
+	cfg, err := LoadFile("")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `APP_TZ: unknown time zone "Mars/Olympus_Mons"`)
+	assert.Equal(t, "Asia/Jakarta", cfg.Location.String())
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		body string
+	}{
+		{
+			name: "yaml",
+			file: "docapi.yaml",
+			body: `
+port: 9090
+db:
+  host: file-host
+  max_open_conns: 30
+  conn_max_lifetime_sec: 10m
+jobs_workers: 2
+storage:
+  compression: zstd
+  compression_types: [text/plain, application/json]
+import:
+  max_entry_size: 256MiB
+admin_token:
+`,
+		},
+		{
+			name: "toml",
+			file: "docapi.toml",
+			body: `
+port = 9090
+jobs_workers = 2
+
+[db]
+host = "file-host"
+max_open_conns = 30
+conn_max_lifetime_sec = "10m"
+
+[storage]
+compression = "zstd"
+compression_types = ["text/plain", "application/json"]
+
+[import]
+max_entry_size = "256MiB"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRequired(t)
+			t.Setenv("DB_HOST", "")
+			t.Setenv("DB_MAX_OPEN_CONNS", "40")
+
+			cfg, err := LoadFile(writeFile(t, tt.file, tt.body))
+			require.NoError(t, err)
+
+			assert.Equal(t, "9090", cfg.Port)
+			assert.Equal(t, "file-host", cfg.Database.Host)
+			assert.Equal(t, 40, cfg.Database.MaxOpenConns, "environment variables override the file")
+			assert.Equal(t, 10*time.Minute, cfg.Database.ConnMaxLifetime)
+			assert.Equal(t, 2, cfg.Jobs.Workers)
+			assert.Equal(t, "zstd", cfg.Compression.Algorithm)
+			assert.Equal(t, "text/plain,application/json", cfg.Compression.ContentTypes)
+			assert.Equal(t, int64(256<<20), cfg.Import.MaxEntrySize)
+			assert.Equal(t, 5, cfg.Database.MaxIdleConns, "settings missing from the file keep their defaults")
+		})
+	}
+}
+
+func TestLoadFile_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		body    string
+		wantErr []string
+	}{
+		{
+			name:    "unknown key",
+			file:    "docapi.yaml",
+			body:    "db:\n  hots: localhost\n",
+			wantErr: []string{"db.hots in ", "unknown setting DB_HOTS"},
+		},
+		{
+			name:    "invalid value",
+			file:    "docapi.toml",
+			body:    "[jobs]\nmax_attempts = \"many\"\n",
+			wantErr: []string{`JOBS_MAX_ATTEMPTS (jobs.max_attempts in `, `"many" is not an integer`},
+		},
+		{
+			name:    "same setting twice",
+			file:    "docapi.yaml",
+			body:    "db_host: a\ndb:\n  host: b\n",
+			wantErr: []string{"db_host: DB_HOST is also set by db.host"},
+		},
+		{
+			name:    "syntax error",
+			file:    "docapi.yaml",
+			body:    "db: [unclosed\n",
+			wantErr: []string{"parse config file"},
+		},
+		{
+			name:    "unsupported format",
+			file:    "docapi.json",
+			body:    "{}",
+			wantErr: []string{`unsupported format ".json"`},
+		},
+		{
+			name:    "unsupported value",
+			file:    "docapi.yaml",
+			body:    "db_port: [[5432]]\n",
+			wantErr: []string{"db_port: unsupported value of type"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRequired(t)
+			_, err := LoadFile(writeFile(t, tt.file, tt.body))
+			require.Error(t, err)
+			for _, want := range tt.wantErr {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		setRequired(t)
+		_, err := LoadFile(filepath.Join(t.TempDir(), "missing.yaml"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
 }
 
-func TestGetEnv(t *testing.T) {
-	key := "TEST_ENV_VAR"
-	os.Setenv(key, "value")
-	defer os.Unsetenv(key)
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr []string
+	}{
+		{name: "valid"},
+		{
+			name: "ports",
+			env:  map[string]string{"PORT": "0", "DB_PORT": "postgres"},
+			wantErr: []string{
+				`PORT: must be a port number (1-65535), not "0"`,
+				`DB_PORT: must be a port number (1-65535), not "postgres"`,
+			},
+		},
+		{
+			name:    "encryption without keys",
+			env:     map[string]string{"STORAGE_ENCRYPTION_ENABLED": "true"},
+			wantErr: []string{"STORAGE_ENCRYPTION_KEY_ID: is required", "STORAGE_ENCRYPTION_KEYS: is required"},
+		},
+		{
+			name:    "compression",
+			env:     map[string]string{"STORAGE_COMPRESSION": "brotli"},
+			wantErr: []string{`STORAGE_COMPRESSION: must be zstd, gzip or empty, not "brotli"`},
+		},
+		{
+			name: "limits",
+			env:  map[string]string{"BATCH_DELETE_MAX_IDS": "0", "JOBS_MAX_ATTEMPTS": "0", "IMPORT_MAX_RATIO": "-1"},
+			wantErr: []string{
+				"BATCH_DELETE_MAX_IDS: must be at least 1, not 0",
+				"JOBS_MAX_ATTEMPTS: must be at least 1, not 0",
+				"IMPORT_MAX_RATIO: must be at least 0, not -1",
+			},
+		},
+		{
+			name: "durations",
+			env:  map[string]string{"HEALTH_CHECK_TIMEOUT_SEC": "0", "SHUTDOWN_GRACE_PERIOD_SEC": "-5s", "JOBS_BACKOFF_MAX_SEC": "5s"},
+			wantErr: []string{
+				"HEALTH_CHECK_TIMEOUT_SEC: must be positive, not 0s",
+				"SHUTDOWN_GRACE_PERIOD_SEC: must not be negative, not -5s",
+				"JOBS_BACKOFF_MAX_SEC: must be at least JOBS_BACKOFF_BASE_SEC (10s), not 5s",
+			},
+		},
+		{
+			name:    "scanner settings only checked when enabled",
+			env:     map[string]string{"CLAMD_CHUNK_SIZE": "0"},
+			wantErr: nil,
+		},
+		{
+			name:    "scanner",
+			env:     map[string]string{"CLAMD_ADDR": "localhost:3310", "CLAMD_CHUNK_SIZE": "0"},
+			wantErr: []string{"CLAMD_CHUNK_SIZE: must be at least 1, not 0"},
+		},
+	}
 
-	assert.Equal(t, "value", getEnv(key, "default"))
-	assert.Equal(t, "default", getEnv("NON_EXISTENT", "default"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRequired(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, _ := LoadFile("")
+			err := cfg.Validate()
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, want := range tt.wantErr {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
 }
 
-func TestGetEnvBool(t *testing.T) {
-	key := "TEST_BOOL_VAR"
+func TestSettings(t *testing.T) {
+	setRequired(t)
+	t.Setenv("DB_PASSWORD", "hunter2")
+	t.Setenv("ADMIN_TOKEN", "")
+	path := writeFile(t, "docapi.yaml", "jobs:\n  workers: 2\n")
 
-	os.Setenv(key, "true")
-	assert.True(t, getEnvBool(key, false))
+	cfg, err := LoadFile(path)
+	require.NoError(t, err)
 
-	os.Setenv(key, "false")
-	assert.False(t, getEnvBool(key, true))
+	settings := make(map[string]Setting)
+	for _, s := range cfg.Settings() {
+		settings[s.Key] = s
+	}
+	assert.Equal(t, Setting{Key: "DB_PASSWORD", Value: Redacted, Source: SourceEnv}, settings["DB_PASSWORD"])
+	assert.Equal(t, Setting{Key: "MINIO_SECRET_KEY", Value: Redacted, Source: SourceEnv}, settings["MINIO_SECRET_KEY"])
+	assert.Equal(t, Setting{Key: "ADMIN_TOKEN", Value: "", Source: SourceDefault}, settings["ADMIN_TOKEN"], "unset secrets show as unset")
+	assert.Equal(t, Setting{Key: "JOBS_WORKERS", Value: "2", Source: SourceFile}, settings["JOBS_WORKERS"])
+	assert.Equal(t, Setting{Key: "IMPORT_MAX_ENTRY_SIZE", Value: "1GiB", Source: SourceDefault}, settings["IMPORT_MAX_ENTRY_SIZE"])
+	assert.Equal(t, Setting{Key: "JOBS_VISIBILITY_TIMEOUT_SEC", Value: "5m0s", Source: SourceDefault}, settings["JOBS_VISIBILITY_TIMEOUT_SEC"])
+
+	for _, s := range cfg.Settings() {
+		assert.NotContains(t, s.Value, "hunter2")
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "0", want: 0},
+		{in: "300", want: 5 * time.Minute},
+		{in: "-5", want: -5 * time.Second},
+		{in: "1h30m", want: 90 * time.Minute},
+		{in: "250ms", want: 250 * time.Millisecond},
+		{in: "99999999999999", wantErr: true},
+		{in: "5 minutes", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseDuration(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
 
-	os.Setenv(key, "invalid")
-	assert.True(t, getEnvBool(key, true))
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "0", want: 0},
+		{in: "1048576", want: 1 << 20},
+		{in: "512B", want: 512},
+		{in: "2kB", want: 2000},
+		{in: "10MB", want: 10_000_000},
+		{in: "1GB", want: 1_000_000_000},
+		{in: "64KiB", want: 64 << 10},
+		{in: "512 MiB", want: 512 << 20},
+		{in: "10gib", want: 10 << 30},
+		{in: "2TiB", want: 2 << 40},
+		{in: "1.5GiB", wantErr: true},
+		{in: "1PiB", wantErr: true},
+		{in: "MiB", wantErr: true},
+		{in: "9999999TiB", wantErr: true},
+		{in: "", wantErr: true},
+	}
 
-	os.Unsetenv(key)
-	assert.True(t, getEnvBool(key, true))
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseByteSize(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
 }
 
-func TestGetEnvInt(t *testing.T) {
-	key := "TEST_INT_VAR"
+func TestFormatByteSize(t *testing.T) {
+	for _, n := range []int64{0, 1, 1000, 1024, 1536, 64 << 10, 1 << 30, 10 << 30, 3 << 40} {
+		s := FormatByteSize(n)
+		got, err := ParseByteSize(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, n, got, s)
+	}
+	assert.Equal(t, "64KiB", FormatByteSize(64<<10))
+	assert.Equal(t, "1536", FormatByteSize(1536))
+	assert.Equal(t, "10GiB", FormatByteSize(10<<30))
+}
+
+func TestLoad_SecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	secret := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	t.Run("read from files", func(t *testing.T) {
+		setRequired(t)
+		t.Setenv("MINIO_SECRET_KEY", "")
+		t.Setenv("DB_PASSWORD_FILE", secret("db_password", "s3cret\n"))
+		t.Setenv("MINIO_SECRET_KEY_FILE", secret("minio_secret_key", "minio-s3cret\r\n"))
+		t.Setenv("ADMIN_TOKEN_FILE", secret("admin_token", "token"))
+
+		cfg, err := LoadFile("")
+		require.NoError(t, err)
+		assert.Equal(t, "s3cret", cfg.Database.Password)
+		assert.Equal(t, "minio-s3cret", cfg.MinIO.SecretKey)
+		assert.Equal(t, "token", cfg.Admin.Token)
+		assert.Equal(t, []string{filepath.Join(dir, "db_password"), filepath.Join(dir, "minio_secret_key"), filepath.Join(dir, "admin_token")}, cfg.SecretFiles())
+
+		settings := make(map[string]Setting)
+		for _, s := range cfg.Settings() {
+			settings[s.Key] = s
+		}
+		assert.Equal(t, Setting{Key: "DB_PASSWORD", Value: Redacted, Source: SourceSecretFile}, settings["DB_PASSWORD"])
+		assert.Equal(t, Setting{Key: "DB_PASSWORD_FILE", Value: filepath.Join(dir, "db_password"), Source: SourceEnv}, settings["DB_PASSWORD_FILE"])
+	})
+
+	t.Run("file named in the config file", func(t *testing.T) {
+		setRequired(t)
+		t.Setenv("MINIO_SECRET_KEY", "")
+		path := writeFile(t, "docapi.yaml", "minio:\n  secret_key_file: "+secret("from_config", "configured")+"\n")
+
+		cfg, err := LoadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "configured", cfg.MinIO.SecretKey)
+	})
+
+	t.Run("both set", func(t *testing.T) {
+		setRequired(t)
+		t.Setenv("MINIO_SECRET_KEY_FILE", secret("minio_secret_key", "minio-s3cret"))
+
+		_, err := LoadFile("")
+		assert.ErrorContains(t, err, "MINIO_SECRET_KEY: set either MINIO_SECRET_KEY or MINIO_SECRET_KEY_FILE, not both")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		setRequired(t)
+		t.Setenv("DB_PASSWORD_FILE", filepath.Join(dir, "missing"))
 
-	os.Setenv(key, "123")
-	assert.Equal(t, 123, getEnvInt(key, 0))
+		_, err := LoadFile("")
+		assert.ErrorContains(t, err, "DB_PASSWORD_FILE: open ")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
 
-	os.Setenv(key, "invalid")
-	assert.Equal(t, 10, getEnvInt(key, 10))
+	t.Run("empty file", func(t *testing.T) {
+		setRequired(t)
+		t.Setenv("MINIO_SECRET_KEY", "")
+		t.Setenv("MINIO_SECRET_KEY_FILE", secret("empty", "\n"))
 
-	os.Unsetenv(key)
-	assert.Equal(t, 10, getEnvInt(key, 10))
+		_, err := LoadFile("")
+		assert.ErrorContains(t, err, "MINIO_SECRET_KEY: is required")
+	})
 }
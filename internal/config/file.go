@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// fileValue is a value read from the config file.
+type fileValue struct {
+	// key is the key as written in the file, such as "db.max_open_conns", for errors.
+	key   string
+	value string
+}
+
+// readFile reads the YAML (.yaml, .yml) or TOML (.toml) config file at path. Its keys are the
+// keys of the settings in lower case, and may be nested at underscores: "db_host: x" and
+// "db: {host: x}" both set DB_HOST. Values may be strings, numbers or booleans; a list sets the
+// comma-separated list of its items, and an empty value leaves the setting unset. The values are
+// returned keyed by setting.
+func readFile(path string) (map[string]fileValue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	var doc map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format %q (use .yaml, .yml or .toml)", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]fileValue)
+	if err := flatten(values, nil, doc); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// flatten adds the values of m, whose keys are below path, to values.
+func flatten(values map[string]fileValue, path []string, m map[string]any) error {
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		v := m[k]
+		p := append(path[:len(path):len(path)], k)
+		key := strings.Join(p, ".")
+		if nested, ok := v.(map[string]any); ok {
+			if err := flatten(values, p, nested); err != nil {
+				return err
+			}
+			continue
+		}
+		if v == nil {
+			// An empty value leaves the setting unset, as an empty environment variable does.
+			continue
+		}
+		s, err := scalar(v)
+		if list, ok := v.([]any); ok {
+			s, err = join(list)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		name := strings.ToUpper(strings.Join(p, "_"))
+		if prev, ok := values[name]; ok {
+			return fmt.Errorf("%s: %s is also set by %s", key, name, prev.key)
+		}
+		values[name] = fileValue{key: key, value: s}
+	}
+	return nil
+}
+
+// join formats a list from the config file as the comma-separated list of its items.
+func join(list []any) (string, error) {
+	items := make([]string, len(list))
+	for i, item := range list {
+		s, err := scalar(item)
+		if err != nil {
+			return "", err
+		}
+		items[i] = s
+	}
+	return strings.Join(items, ","), nil
+}
+
+// scalar formats a string, number or boolean from the config file in the syntax of settings.
+func scalar(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unsupported value of type %T", v)
+	}
+}
@@ -0,0 +1,298 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sources of a setting's value, as reported by Settings.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	// SourceSecretFile is the source of a secret read from the file named by its _FILE setting.
+	SourceSecretFile = "secret-file"
+)
+
+// Redacted replaces the value of a secret setting in Settings.
+const Redacted = "[redacted]"
+
+// Setting is one setting of the effective configuration.
+type Setting struct {
+	// Key is the name of the environment variable setting it.
+	Key string
+	// Value is the value in the syntax of the key, or Redacted for a secret that is set.
+	Value string
+	// Source tells where the value came from: SourceDefault, SourceFile, SourceEnv or
+	// SourceSecretFile.
+	Source string
+}
+
+// Settings returns every setting of c in a stable order, with secrets redacted.
+func (c *AppConfig) Settings() []Setting {
+	settings := c.settings()
+	out := make([]Setting, len(settings))
+	for i, s := range settings {
+		v := s.value.String()
+		if s.secret && v != "" {
+			v = Redacted
+		}
+		source := c.sources[s.key]
+		if source == "" {
+			source = SourceDefault
+		}
+		out[i] = Setting{Key: s.key, Value: v, Source: source}
+	}
+	return out
+}
+
+// setting binds a key to the field of AppConfig holding its value. A secret may have a file,
+// the field of its _FILE setting, naming a file to read the value from.
+type setting struct {
+	key    string
+	def    string
+	value  value
+	secret bool
+	file   *string
+}
+
+// settings lists the settings of c. Keys are the names of the environment variables setting
+// them; defaults are written in the syntax of the values.
+func (c *AppConfig) settings() []setting {
+	return []setting{
+		{key: "APP_HOST", def: "localhost:8080", value: (*stringValue)(&c.AppHost)},
+		{key: "PORT", def: "8080", value: (*stringValue)(&c.Port)},
+		{key: "APP_TZ", def: "Asia/Jakarta", value: (*stringValue)(&c.Timezone)},
+
+		{key: "DB_HOST", value: (*stringValue)(&c.Database.Host)},
+		{key: "DB_PORT", def: "5432", value: (*stringValue)(&c.Database.Port)},
+		{key: "DB_USER", value: (*stringValue)(&c.Database.User)},
+		{key: "DB_PASSWORD", value: (*stringValue)(&c.Database.Password), secret: true, file: &c.Database.PasswordFile},
+		{key: "DB_PASSWORD_FILE", value: (*stringValue)(&c.Database.PasswordFile)},
+		{key: "DB_NAME", value: (*stringValue)(&c.Database.Name)},
+		{key: "DB_SSLMODE", def: "disable", value: (*stringValue)(&c.Database.SSLMode)},
+		{key: "DB_MAX_OPEN_CONNS", def: "10", value: (*intValue)(&c.Database.MaxOpenConns)},
+		{key: "DB_MAX_IDLE_CONNS", def: "5", value: (*intValue)(&c.Database.MaxIdleConns)},
+		{key: "DB_CONN_MAX_LIFETIME_SEC", def: "5m", value: (*durationValue)(&c.Database.ConnMaxLifetime)},
+		{key: "DB_AUTO_MIGRATE", def: "false", value: (*boolValue)(&c.Database.AutoMigrate)},
+
+		{key: "MINIO_ENDPOINT", value: (*stringValue)(&c.MinIO.Endpoint)},
+		{key: "MINIO_ACCESS_KEY", value: (*stringValue)(&c.MinIO.AccessKey), file: &c.MinIO.AccessKeyFile},
+		{key: "MINIO_ACCESS_KEY_FILE", value: (*stringValue)(&c.MinIO.AccessKeyFile)},
+		{key: "MINIO_SECRET_KEY", value: (*stringValue)(&c.MinIO.SecretKey), secret: true, file: &c.MinIO.SecretKeyFile},
+		{key: "MINIO_SECRET_KEY_FILE", value: (*stringValue)(&c.MinIO.SecretKeyFile)},
+		{key: "MINIO_BUCKET", value: (*stringValue)(&c.MinIO.Bucket)},
+		{key: "MINIO_USE_SSL", def: "false", value: (*boolValue)(&c.MinIO.UseSSL)},
+
+		{key: "STORAGE_ENCRYPTION_ENABLED", def: "false", value: (*boolValue)(&c.Encryption.Enabled)},
+		{key: "STORAGE_ENCRYPTION_KEY_ID", value: (*stringValue)(&c.Encryption.KeyID)},
+		{key: "STORAGE_ENCRYPTION_KEYS", value: (*stringValue)(&c.Encryption.Keys), secret: true},
+		{key: "STORAGE_ENCRYPTION_KEYS_FILE", value: (*stringValue)(&c.Encryption.KeysFile)},
+
+		{key: "STORAGE_COMPRESSION", value: (*stringValue)(&c.Compression.Algorithm)},
+		{key: "STORAGE_COMPRESSION_MIN_SIZE", def: "1KiB", value: byteSize(&c.Compression.MinSize)},
+		{key: "STORAGE_COMPRESSION_TYPES", def: DefaultCompressibleTypes, value: (*stringValue)(&c.Compression.ContentTypes)},
+
+		{key: "BATCH_DELETE_MAX_IDS", def: "100", value: (*intValue)(&c.Batch.DeleteMaxIDs)},
+		{key: "BATCH_DELETE_CONCURRENCY", def: "8", value: (*intValue)(&c.Batch.DeleteConcurrency)},
+		{key: "BATCH_UPLOAD_MAX_FILES", def: "100", value: (*intValue)(&c.Batch.UploadMaxFiles)},
+		{key: "BATCH_UPLOAD_CONCURRENCY", def: "4", value: (*intValue)(&c.Batch.UploadConcurrency)},
+		{key: "ARCHIVE_MAX_DOCUMENTS", def: "1000", value: (*intValue)(&c.Batch.ArchiveMaxDocuments)},
+
+		{key: "IMPORT_MAX_ENTRIES", def: "10000", value: (*intValue)(&c.Import.MaxEntries)},
+		{key: "IMPORT_MAX_ENTRY_SIZE", def: "1GiB", value: byteSize(&c.Import.MaxEntrySize)},
+		{key: "IMPORT_MAX_TOTAL_SIZE", def: "10GiB", value: byteSize(&c.Import.MaxTotalSize)},
+		{key: "IMPORT_MAX_RATIO", def: "100", value: (*int64Value)(&c.Import.MaxRatio)},
+
+		{key: "RECONCILE_INTERVAL_SEC", def: "0", value: (*durationValue)(&c.Reconcile.Interval)},
+		{key: "RECONCILE_GRACE_PERIOD_SEC", def: "1h", value: (*durationValue)(&c.Reconcile.GracePeriod)},
+		{key: "RECONCILE_DRY_RUN", def: "true", value: (*boolValue)(&c.Reconcile.DryRun)},
+
+		{key: "IDEMPOTENCY_TTL_SEC", def: "24h", value: (*durationValue)(&c.Idempotency.TTL)},
+		{key: "IDEMPOTENCY_LOCK_TIMEOUT_SEC", def: "5m", value: (*durationValue)(&c.Idempotency.LockTimeout)},
+		{key: "IDEMPOTENCY_WAIT_SEC", def: "30s", value: (*durationValue)(&c.Idempotency.Wait)},
+
+		{key: "CLAMD_ADDR", value: (*stringValue)(&c.Scan.ClamdAddr)},
+		{key: "CLAMD_TIMEOUT_SEC", def: "30s", value: (*durationValue)(&c.Scan.Timeout)},
+		{key: "CLAMD_CHUNK_SIZE", def: "64KiB", value: byteSize(&c.Scan.ChunkSize)},
+
+		{key: "JOBS_WORKERS", def: "4", value: (*intValue)(&c.Jobs.Workers)},
+		{key: "JOBS_POLL_INTERVAL_SEC", def: "1s", value: (*durationValue)(&c.Jobs.PollInterval)},
+		{key: "JOBS_VISIBILITY_TIMEOUT_SEC", def: "5m", value: (*durationValue)(&c.Jobs.VisibilityTimeout)},
+		{key: "JOBS_MAX_ATTEMPTS", def: "5", value: (*intValue)(&c.Jobs.MaxAttempts)},
+		{key: "JOBS_BACKOFF_BASE_SEC", def: "10s", value: (*durationValue)(&c.Jobs.BackoffBase)},
+		{key: "JOBS_BACKOFF_MAX_SEC", def: "1h", value: (*durationValue)(&c.Jobs.BackoffMax)},
+		{key: "JOBS_DRAIN_TIMEOUT_SEC", def: "30s", value: (*durationValue)(&c.Jobs.DrainTimeout)},
+
+		{key: "HEALTH_CHECK_TIMEOUT_SEC", def: "2s", value: (*durationValue)(&c.Health.CheckTimeout)},
+		{key: "HEALTH_CACHE_TTL_SEC", def: "1s", value: (*durationValue)(&c.Health.CacheTTL)},
+
+		{key: "SHUTDOWN_READINESS_DELAY_SEC", def: "0", value: (*durationValue)(&c.Shutdown.ReadinessDelay)},
+		{key: "SHUTDOWN_GRACE_PERIOD_SEC", def: "30s", value: (*durationValue)(&c.Shutdown.GracePeriod)},
+		{key: "SHUTDOWN_ABORT_TIMEOUT_SEC", def: "5s", value: (*durationValue)(&c.Shutdown.AbortTimeout)},
+
+		{key: "ADMIN_TOKEN", value: (*stringValue)(&c.Admin.Token), secret: true, file: &c.Admin.TokenFile},
+		{key: "ADMIN_TOKEN_FILE", value: (*stringValue)(&c.Admin.TokenFile)},
+
+		{key: "SECRETS_POLL_INTERVAL_SEC", def: "10s", value: (*durationValue)(&c.Secrets.PollInterval)},
+	}
+}
+
+// value is the field of a setting, parsed from and formatted to the setting's syntax.
+type value interface {
+	Set(s string) error
+	String() string
+}
+
+type stringValue string
+
+func (v *stringValue) Set(s string) error { *v = stringValue(s); return nil }
+func (v *stringValue) String() string     { return string(*v) }
+
+type boolValue bool
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("%q is not a boolean (true or false)", s)
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+func (v *boolValue) String() string { return strconv.FormatBool(bool(*v)) }
+
+type intValue int
+
+func (v *intValue) Set(s string) error {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", s)
+	}
+	*v = intValue(i)
+	return nil
+}
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+type int64Value int64
+
+func (v *int64Value) Set(s string) error {
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", s)
+	}
+	*v = int64Value(i)
+	return nil
+}
+
+func (v *int64Value) String() string { return strconv.FormatInt(int64(*v), 10) }
+
+// durationValue accepts Go durations such as "90s" or "1h30m". A bare number is a number of
+// seconds, which keeps the *_SEC settings compatible with their earlier integer values.
+type durationValue time.Duration
+
+func (v *durationValue) Set(s string) error {
+	d, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+
+// ParseDuration parses a duration setting: a Go duration such as "90s" or "1h30m", or a bare
+// number of seconds.
+func ParseDuration(s string) (time.Duration, error) {
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if secs > math.MaxInt64/int64(time.Second) || secs < math.MinInt64/int64(time.Second) {
+			return 0, fmt.Errorf("%q is out of range", s)
+		}
+		return time.Duration(secs) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a duration (such as 30s, 5m or a number of seconds)", s)
+	}
+	return d, nil
+}
+
+// sizeValue accepts byte sizes such as "512KiB" or "10MB"; see ParseByteSize.
+type sizeValue[T int | int64] struct{ p *T }
+
+func byteSize[T int | int64](p *T) value { return sizeValue[T]{p} }
+
+func (v sizeValue[T]) Set(s string) error {
+	n, err := ParseByteSize(s)
+	if err != nil {
+		return err
+	}
+	if int64(T(n)) != n {
+		return fmt.Errorf("%q is out of range", s)
+	}
+	*v.p = T(n)
+	return nil
+}
+
+func (v sizeValue[T]) String() string { return FormatByteSize(int64(*v.p)) }
+
+// byteUnits are the units of byte sizes, in lower case: decimal (kB = 1000 bytes) and binary
+// (KiB = 1024 bytes).
+var byteUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// ParseByteSize parses a byte size: a whole number of bytes, optionally followed by a decimal
+// (kB, MB, GB, TB) or binary (KiB, MiB, GiB, TiB) unit, case-insensitively.
+func ParseByteSize(s string) (int64, error) {
+	invalid := fmt.Errorf("%q is not a byte size (such as 1048576, 512KiB or 10MB)", s)
+	num := strings.TrimSpace(s)
+	i := strings.IndexFunc(num, func(r rune) bool { return (r < '0' || r > '9') && r != '-' })
+	unit := ""
+	if i >= 0 {
+		num, unit = strings.TrimSpace(num[:i]), strings.ToLower(strings.TrimSpace(num[i:]))
+	}
+	mult, ok := byteUnits[unit]
+	if !ok {
+		return 0, invalid
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return 0, fmt.Errorf("%q is out of range", s)
+		}
+		return 0, invalid
+	}
+	if n > math.MaxInt64/mult || n < math.MinInt64/mult {
+		return 0, fmt.Errorf("%q is out of range", s)
+	}
+	return n * mult, nil
+}
+
+// FormatByteSize formats n with the largest binary unit dividing it, so that it parses back to n.
+func FormatByteSize(n int64) string {
+	for _, u := range []struct {
+		name string
+		size int64
+	}{{"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}} {
+		if n != 0 && n%u.size == 0 {
+			return strconv.FormatInt(n/u.size, 10) + u.name
+		}
+	}
+	return strconv.FormatInt(n, 10)
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// sslModes are the values of DB_SSLMODE understood by PostgreSQL clients.
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// Validate checks that c is complete and consistent. The error lists every problem, each
+// prefixed with the key of the setting at fault.
+func (c *AppConfig) Validate() error {
+	v := &validator{}
+
+	v.port("PORT", c.Port)
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		v.addf("APP_TZ", "unknown time zone %q", c.Timezone)
+	}
+
+	v.required("DB_HOST", c.Database.Host)
+	v.port("DB_PORT", c.Database.Port)
+	v.required("DB_USER", c.Database.User)
+	v.required("DB_NAME", c.Database.Name)
+	if !slices.Contains(sslModes, c.Database.SSLMode) {
+		v.addf("DB_SSLMODE", "must be one of %v, not %q", sslModes, c.Database.SSLMode)
+	}
+	v.atLeast("DB_MAX_OPEN_CONNS", int64(c.Database.MaxOpenConns), 0)
+	v.atLeast("DB_MAX_IDLE_CONNS", int64(c.Database.MaxIdleConns), 0)
+	v.nonNegative("DB_CONN_MAX_LIFETIME_SEC", c.Database.ConnMaxLifetime)
+
+	v.required("MINIO_ENDPOINT", c.MinIO.Endpoint)
+	v.required("MINIO_ACCESS_KEY", c.MinIO.AccessKey)
+	v.required("MINIO_SECRET_KEY", c.MinIO.SecretKey)
+	v.required("MINIO_BUCKET", c.MinIO.Bucket)
+
+	if c.Encryption.Enabled {
+		v.required("STORAGE_ENCRYPTION_KEY_ID", c.Encryption.KeyID)
+		if c.Encryption.Keys == "" && c.Encryption.KeysFile == "" {
+			v.addf("STORAGE_ENCRYPTION_KEYS", "is required, or STORAGE_ENCRYPTION_KEYS_FILE, when encryption is enabled")
+		}
+	}
+	switch c.Compression.Algorithm {
+	case "", "zstd", "gzip":
+	default:
+		v.addf("STORAGE_COMPRESSION", "must be zstd, gzip or empty, not %q", c.Compression.Algorithm)
+	}
+	v.atLeast("STORAGE_COMPRESSION_MIN_SIZE", c.Compression.MinSize, 0)
+
+	v.atLeast("BATCH_DELETE_MAX_IDS", int64(c.Batch.DeleteMaxIDs), 1)
+	v.atLeast("BATCH_DELETE_CONCURRENCY", int64(c.Batch.DeleteConcurrency), 1)
+	v.atLeast("BATCH_UPLOAD_MAX_FILES", int64(c.Batch.UploadMaxFiles), 1)
+	v.atLeast("BATCH_UPLOAD_CONCURRENCY", int64(c.Batch.UploadConcurrency), 1)
+	v.atLeast("ARCHIVE_MAX_DOCUMENTS", int64(c.Batch.ArchiveMaxDocuments), 1)
+
+	v.atLeast("IMPORT_MAX_ENTRIES", int64(c.Import.MaxEntries), 0)
+	v.atLeast("IMPORT_MAX_ENTRY_SIZE", c.Import.MaxEntrySize, 0)
+	v.atLeast("IMPORT_MAX_TOTAL_SIZE", c.Import.MaxTotalSize, 0)
+	v.atLeast("IMPORT_MAX_RATIO", c.Import.MaxRatio, 0)
+
+	v.nonNegative("RECONCILE_INTERVAL_SEC", c.Reconcile.Interval)
+	v.nonNegative("RECONCILE_GRACE_PERIOD_SEC", c.Reconcile.GracePeriod)
+
+	v.nonNegative("IDEMPOTENCY_TTL_SEC", c.Idempotency.TTL)
+	if c.Idempotency.TTL > 0 {
+		v.positive("IDEMPOTENCY_LOCK_TIMEOUT_SEC", c.Idempotency.LockTimeout)
+		v.nonNegative("IDEMPOTENCY_WAIT_SEC", c.Idempotency.Wait)
+	}
+
+	if c.Scan.ClamdAddr != "" {
+		v.positive("CLAMD_TIMEOUT_SEC", c.Scan.Timeout)
+		v.atLeast("CLAMD_CHUNK_SIZE", int64(c.Scan.ChunkSize), 1)
+	}
+
+	v.atLeast("JOBS_WORKERS", int64(c.Jobs.Workers), 0)
+	v.positive("JOBS_POLL_INTERVAL_SEC", c.Jobs.PollInterval)
+	v.positive("JOBS_VISIBILITY_TIMEOUT_SEC", c.Jobs.VisibilityTimeout)
+	v.atLeast("JOBS_MAX_ATTEMPTS", int64(c.Jobs.MaxAttempts), 1)
+	v.positive("JOBS_BACKOFF_BASE_SEC", c.Jobs.BackoffBase)
+	if c.Jobs.BackoffMax < c.Jobs.BackoffBase {
+		v.addf("JOBS_BACKOFF_MAX_SEC", "must be at least JOBS_BACKOFF_BASE_SEC (%s), not %s", c.Jobs.BackoffBase, c.Jobs.BackoffMax)
+	}
+	v.nonNegative("JOBS_DRAIN_TIMEOUT_SEC", c.Jobs.DrainTimeout)
+
+	v.positive("HEALTH_CHECK_TIMEOUT_SEC", c.Health.CheckTimeout)
+	v.positive("HEALTH_CACHE_TTL_SEC", c.Health.CacheTTL)
+
+	v.nonNegative("SHUTDOWN_READINESS_DELAY_SEC", c.Shutdown.ReadinessDelay)
+	v.nonNegative("SHUTDOWN_GRACE_PERIOD_SEC", c.Shutdown.GracePeriod)
+	v.nonNegative("SHUTDOWN_ABORT_TIMEOUT_SEC", c.Shutdown.AbortTimeout)
+
+	v.nonNegative("SECRETS_POLL_INTERVAL_SEC", c.Secrets.PollInterval)
+
+	return errors.Join(v.errs...)
+}
+
+// validator collects the problems found by Validate.
+type validator struct {
+	errs []error
+}
+
+func (v *validator) addf(key, format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) required(key, value string) {
+	if value == "" {
+		v.addf(key, "is required")
+	}
+}
+
+func (v *validator) port(key, value string) {
+	if p, err := strconv.Atoi(value); err != nil || p < 1 || p > 65535 {
+		v.addf(key, "must be a port number (1-65535), not %q", value)
+	}
+}
+
+func (v *validator) atLeast(key string, value, min int64) {
+	if value < min {
+		v.addf(key, "must be at least %d, not %d", min, value)
+	}
+}
+
+func (v *validator) positive(key string, d time.Duration) {
+	if d <= 0 {
+		v.addf(key, "must be positive, not %s", d)
+	}
+}
+
+func (v *validator) nonNegative(key string, d time.Duration) {
+	if d < 0 {
+		v.addf(key, "must not be negative, not %s", d)
+	}
+}
@@ -0,0 +1,256 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testMigrations = fstest.MapFS{
+	"0001_create_things.up.sql":   {Data: []byte("CREATE TABLE things (id INT)")},
+	"0001_create_things.down.sql": {Data: []byte("DROP TABLE things")},
+	"0002_add_name.up.sql":        {Data: []byte("ALTER TABLE things ADD COLUMN name TEXT")},
+	"0002_add_name.down.sql":      {Data: []byte("ALTER TABLE things DROP COLUMN name")},
+}
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []int64
+		wantErr string
+	}{
+		{name: "sorted by version", fsys: testMigrations, want: []int64{1, 2}},
+		{name: "down is optional", fsys: fstest.MapFS{"0003_x.up.sql": {Data: []byte("SELECT 1")}}, want: []int64{3}},
+		{name: "invalid name", fsys: fstest.MapFS{"create.sql": {}}, wantErr: "invalid migration file name"},
+		{name: "missing up", fsys: fstest.MapFS{"0001_x.down.sql": {Data: []byte("SELECT 1")}}, wantErr: "has no up script"},
+		{
+			name: "conflicting names",
+			fsys: fstest.MapFS{
+				"0001_a.up.sql": {Data: []byte("SELECT 1")},
+				"0001_b.up.sql": {Data: []byte("SELECT 2")},
+			},
+			wantErr: "conflicting names",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadMigrations(tt.fsys)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			var versions []int64
+			for _, m := range got {
+				versions = append(versions, m.Version)
+			}
+			assert.Equal(t, tt.want, versions)
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	m, err := NewMigrator(nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, m.Migrations())
+	for i, mig := range m.Migrations() {
+		assert.Equal(t, int64(i+1), mig.Version, "migration versions must be contiguous")
+		assert.NotEmpty(t, mig.Down, "migration %d has no down script", mig.Version)
+	}
+}
+
+func newTestMigrator(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	m, err := newMigrator(db, testMigrations)
+	require.NoError(t, err)
+	return m, mock
+}
+
+func expectLocked(mock sqlmock.Sqlmock, applied *sqlmock.Rows) {
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WithArgs(migrationLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, name, checksum, applied_at FROM schema_migrations`).WillReturnRows(applied)
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WithArgs(migrationLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func appliedRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"version", "name", "checksum", "applied_at"})
+}
+
+func TestMigrator_Up(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("applies pending migrations", func(t *testing.T) {
+		m, mock := newTestMigrator(t)
+		first := m.Migrations()[0]
+		expectLocked(mock, appliedRows().AddRow(1, first.Name, first.Checksum(), time.Now()))
+		mock.ExpectBegin()
+		mock.ExpectExec(`ALTER TABLE things ADD COLUMN name TEXT`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO schema_migrations`).
+			WithArgs(int64(2), "add_name", m.Migrations()[1].Checksum()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		expectUnlock(mock)
+
+		done, err := m.Up(ctx)
+		require.NoError(t, err)
+		require.Len(t, done, 1)
+		assert.Equal(t, int64(2), done[0].Version)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rolls back failed migration", func(t *testing.T) {
+		m, mock := newTestMigrator(t)
+		expectLocked(mock, appliedRows())
+		mock.ExpectBegin()
+		mock.ExpectExec(`CREATE TABLE things`).WillReturnError(errors.New("boom"))
+		mock.ExpectRollback()
+		expectUnlock(mock)
+
+		done, err := m.Up(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "apply migration 1 (create_things): boom")
+		assert.Empty(t, done)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("refuses modified migration", func(t *testing.T) {
+		m, mock := newTestMigrator(t)
+		expectLocked(mock, appliedRows().AddRow(1, "create_things", "stale", time.Now()))
+		expectUnlock(mock)
+
+		_, err := m.Up(ctx)
+		assert.ErrorIs(t, err, ErrChecksumMismatch)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestMigrator_Down(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("rolls back latest", func(t *testing.T) {
+		m, mock := newTestMigrator(t)
+		migs := m.Migrations()
+		expectLocked(mock, appliedRows().
+			AddRow(1, migs[0].Name, migs[0].Checksum(), time.Now()).
+			AddRow(2, migs[1].Name, migs[1].Checksum(), time.Now()))
+		mock.ExpectBegin()
+		mock.ExpectExec(`ALTER TABLE things DROP COLUMN name`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`DELETE FROM schema_migrations WHERE version = \$1`).WithArgs(int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		expectUnlock(mock)
+
+		done, err := m.Down(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, done, 1)
+		assert.Equal(t, int64(2), done[0].Version)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown applied migration", func(t *testing.T) {
+		m, mock := newTestMigrator(t)
+		expectLocked(mock, appliedRows().AddRow(9, "from_the_future", "x", time.Now()))
+		expectUnlock(mock)
+
+		_, err := m.Down(ctx, 1)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not known to this binary")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("invalid steps", func(t *testing.T) {
+		m, _ := newTestMigrator(t)
+		_, err := m.Down(ctx, 0)
+		assert.Error(t, err)
+	})
+}
+
+func TestMigrator_Status(t *testing.T) {
+	ctx := context.Background()
+	appliedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("no schema_migrations table", func(t *testing.T) {
+		m, mock := newTestMigrator(t)
+		mock.ExpectQuery(`SELECT to_regclass`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		got, err := m.Status(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []MigrationStatus{
+			{Version: 1, Name: "create_things", State: MigrationPending},
+			{Version: 2, Name: "add_name", State: MigrationPending},
+		}, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("mixed states", func(t *testing.T) {
+		m, mock := newTestMigrator(t)
+		mock.ExpectQuery(`SELECT to_regclass`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(`SELECT version, name, checksum, applied_at FROM schema_migrations`).WillReturnRows(appliedRows().
+			AddRow(1, "create_things", m.Migrations()[0].Checksum(), appliedAt).
+			AddRow(2, "add_name", "stale", appliedAt).
+			AddRow(7, "gone", "x", appliedAt))
+
+		got, err := m.Status(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []MigrationStatus{
+			{Version: 1, Name: "create_things", State: MigrationApplied, AppliedAt: appliedAt},
+			{Version: 2, Name: "add_name", State: MigrationModified, AppliedAt: appliedAt},
+			{Version: 7, Name: "gone", State: MigrationUnknown, AppliedAt: appliedAt},
+		}, got)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// TestMigrator_Postgres runs the embedded migrations down and up against a real database.
+// It is skipped unless DOCAPI_TEST_DATABASE_DSN is set; it drops the documents table.
+func TestMigrator_Postgres(t *testing.T) {
+	dsn := os.Getenv("DOCAPI_TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("DOCAPI_TEST_DATABASE_DSN not set")
+	}
+	ctx := context.Background()
+	db, err := sql.Open("pgx", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	m, err := NewMigrator(db)
+	require.NoError(t, err)
+	n := len(m.Migrations())
+
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	done, err := m.Down(ctx, n)
+	require.NoError(t, err)
+	assert.Len(t, done, n)
+
+	done, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, done, n)
+	done, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, done)
+
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	for _, st := range status {
+		assert.Equal(t, MigrationApplied, st.State, "migration %d", st.Version)
+	}
+}
@@ -0,0 +1,15 @@
+-- Idempotent so databases created from the DDL previously documented in the README can adopt migrations.
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS documents (
+  id           UUID        PRIMARY KEY DEFAULT uuid_generate_v4(),
+  filename     TEXT        NOT NULL,
+  storage_path TEXT        NOT NULL UNIQUE,
+  size         BIGINT      NOT NULL CHECK (size >= 0),
+  content_type TEXT        NOT NULL,
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_documents_filename ON documents (filename);
+CREATE INDEX IF NOT EXISTS idx_documents_content_type ON documents (content_type);
+CREATE INDEX IF NOT EXISTS idx_documents_created_at ON documents (created_at);
@@ -0,0 +1,4 @@
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS stored_size BIGINT NOT NULL DEFAULT 0 CHECK (stored_size >= 0);
+
+-- Rows written before compression existed are stored as-is.
+UPDATE documents SET stored_size = size WHERE stored_size = 0;
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_documents_created_at ON documents (created_at);
+DROP INDEX IF EXISTS idx_documents_created_at_id;
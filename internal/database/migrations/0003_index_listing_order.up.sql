@@ -0,0 +1,3 @@
+-- Serves keyset pagination on (created_at, id) in the listing order; supersedes the created_at index.
+CREATE INDEX IF NOT EXISTS idx_documents_created_at_id ON documents (created_at DESC, id DESC);
+DROP INDEX IF EXISTS idx_documents_created_at;
@@ -0,0 +1,6 @@
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS name TEXT NOT NULL DEFAULT '';
+
+-- The original filename of earlier uploads was only kept in object metadata; fall back to the stored name.
+UPDATE documents SET name = filename WHERE name = '';
+
+CREATE INDEX IF NOT EXISTS idx_documents_size ON documents (size);
@@ -0,0 +1,4 @@
+ALTER TABLE documents
+  DROP COLUMN IF EXISTS version,
+  DROP COLUMN IF EXISTS updated_at,
+  DROP COLUMN IF EXISTS tags;
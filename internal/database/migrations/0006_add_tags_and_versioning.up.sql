@@ -0,0 +1,11 @@
+ALTER TABLE documents
+  ADD COLUMN IF NOT EXISTS tags       JSONB       NOT NULL DEFAULT '[]'::jsonb,
+  ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS version    BIGINT      NOT NULL DEFAULT 1;
+
+-- Documents that were never updated were last changed when they were created.
+UPDATE documents SET updated_at = created_at WHERE updated_at IS NULL;
+
+ALTER TABLE documents
+  ALTER COLUMN updated_at SET NOT NULL,
+  ALTER COLUMN updated_at SET DEFAULT now();
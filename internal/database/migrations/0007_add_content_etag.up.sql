@@ -0,0 +1,4 @@
+-- The storage ETag of each document's object, recorded when it is stored. Documents stored
+-- before keep an empty value and fall back to validators derived from their ID.
+ALTER TABLE documents
+  ADD COLUMN IF NOT EXISTS content_etag TEXT NOT NULL DEFAULT '';
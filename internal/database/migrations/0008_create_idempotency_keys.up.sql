@@ -0,0 +1,16 @@
+-- Responses to requests sent with an Idempotency-Key, replayed when a client retries. A NULL status
+-- marks a request still in flight; its row expires after the lock timeout so that a crashed server
+-- does not block the key.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  principal   TEXT        NOT NULL,
+  key         TEXT        NOT NULL,
+  fingerprint TEXT        NOT NULL,
+  status      INT,
+  headers     JSONB,
+  body        BYTEA,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  expires_at  TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (principal, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,5 @@
+ALTER TABLE documents
+  DROP COLUMN IF EXISTS scanned_at,
+  DROP COLUMN IF EXISTS scan_signature,
+  DROP COLUMN IF EXISTS scan_engine,
+  DROP COLUMN IF EXISTS scan_status;
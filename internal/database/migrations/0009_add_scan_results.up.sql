@@ -0,0 +1,6 @@
+-- Malware scan verdicts. Documents stored before scanning was introduced are unscanned.
+ALTER TABLE documents
+  ADD COLUMN IF NOT EXISTS scan_status    TEXT        NOT NULL DEFAULT 'unscanned',
+  ADD COLUMN IF NOT EXISTS scan_engine    TEXT        NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS scan_signature TEXT        NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS scanned_at     TIMESTAMPTZ;
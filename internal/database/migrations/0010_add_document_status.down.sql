@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_documents_status;
+
+ALTER TABLE documents
+  DROP COLUMN IF EXISTS status;
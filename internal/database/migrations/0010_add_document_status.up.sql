@@ -0,0 +1,12 @@
+-- Processing status of each document. Documents stored before are available, unless their content
+-- was found infected; new documents start pending.
+ALTER TABLE documents
+  ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'available'
+    CHECK (status IN ('pending', 'processing', 'available', 'quarantined', 'failed'));
+
+UPDATE documents SET status = 'quarantined' WHERE scan_status = 'infected';
+
+ALTER TABLE documents ALTER COLUMN status SET DEFAULT 'pending';
+
+-- Serves listings of the few documents in a given status, such as those awaiting review.
+CREATE INDEX IF NOT EXISTS idx_documents_status ON documents (status);
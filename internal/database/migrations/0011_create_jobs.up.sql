@@ -0,0 +1,19 @@
+-- Background jobs, claimed by workers with FOR UPDATE SKIP LOCKED. A running job holds its row
+-- until locked_until; a worker that crashes leaves the job to be claimed again after that
+-- visibility timeout. Succeeded jobs are deleted; failed ones are kept for inspection.
+CREATE TABLE IF NOT EXISTS jobs (
+  id           UUID        PRIMARY KEY,
+  kind         TEXT        NOT NULL,
+  payload      JSONB       NOT NULL DEFAULT '{}'::jsonb,
+  status       TEXT        NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'failed')),
+  attempts     INT         NOT NULL DEFAULT 0,
+  max_attempts INT         NOT NULL CHECK (max_attempts > 0),
+  run_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+  locked_until TIMESTAMPTZ,
+  last_error   TEXT        NOT NULL DEFAULT '',
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_queued ON jobs (run_at) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS idx_jobs_running ON jobs (locked_until) WHERE status = 'running';
@@ -0,0 +1,265 @@
+// Package health reports whether the API can serve requests, for the startup, liveness and
+// readiness probes of an orchestrator.
+//
+// A Checker runs the checks of the components the API depends on, such as the database and the
+// object storage bucket, each with its own timeout. Results are cached for a short time and
+// concurrent probes share one run, so that frequent probes from many sources do not multiply
+// the load on the components.
+package health
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses of a component and of a whole report.
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+	// StatusDraining is the status of a report while the process shuts down.
+	StatusDraining = "draining"
+)
+
+// Defaults for Options.
+const (
+	DefaultTimeout  = 2 * time.Second
+	DefaultCacheTTL = time.Second
+)
+
+// Check is a component check.
+type Check struct {
+	// Name identifies the component in reports, for example "database".
+	Name string
+	// Timeout bounds one run of the check; zero means the Checker's timeout.
+	Timeout time.Duration
+	// Run returns nil if the component is usable.
+	Run func(ctx context.Context) error
+}
+
+// Options configures a Checker. Zero values select the defaults.
+type Options struct {
+	// Timeout bounds the checks that don't set their own.
+	Timeout time.Duration
+	// CacheTTL is how long a report is reused before the checks run again.
+	CacheTTL time.Duration
+	// Log receives the components going down or coming back up, in the shape of the API's
+	// structured logs.
+	Log func(level, msg string, fields map[string]any)
+}
+
+func (o Options) withDefaults() Options {
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
+	if o.CacheTTL <= 0 {
+		o.CacheTTL = DefaultCacheTTL
+	}
+	if o.Log == nil {
+		o.Log = func(string, string, map[string]any) {}
+	}
+	return o
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// LatencyMs is how long the check took, in milliseconds.
+	LatencyMs float64 `json:"latency_ms"`
+	// Error tells why a component is down: "timeout" or "unavailable". Details are logged rather
+	// than reported, as probes are usually unauthenticated.
+	Error string `json:"error,omitempty"`
+}
+
+// Report is the outcome of all checks. Its status is up if every component is up.
+type Report struct {
+	Status    string    `json:"status"`
+	CheckedAt time.Time `json:"checked_at"`
+	Checks    []Result  `json:"checks"`
+}
+
+// Up reports whether every component is up.
+func (r Report) Up() bool { return r.Status == StatusUp }
+
+// clone returns a copy of r that callers may change without affecting the cached report.
+func (r *Report) clone() Report {
+	out := *r
+	out.Checks = slices.Clone(r.Checks)
+	return out
+}
+
+// Checker runs checks and tracks the lifecycle of the process for the probes.
+type Checker struct {
+	checks []Check
+	opts   Options
+
+	mu      sync.Mutex
+	last    *Report
+	expires time.Time
+	// running is closed when the checks in progress are done; nil if none are.
+	running chan struct{}
+	// lastState is the status of each component as last reported, to log changes.
+	lastState map[string]string
+
+	started  atomic.Bool
+	everUp   atomic.Bool
+	draining atomic.Bool
+}
+
+// NewChecker creates a checker running checks in the given order.
+func NewChecker(opts Options, checks ...Check) *Checker {
+	return &Checker{
+		checks:    checks,
+		opts:      opts.withDefaults(),
+		lastState: make(map[string]string),
+	}
+}
+
+// MarkStarted records that the process finished initializing and serves requests.
+func (c *Checker) MarkStarted() { c.started.Store(true) }
+
+// Drain makes every later report draining, without running the checks, so that the readiness
+// probe fails and traffic moves to other instances before the server stops.
+func (c *Checker) Drain() { c.draining.Store(true) }
+
+// Started reports whether the process finished initializing and its components were up at least
+// once since, which is when the startup probe succeeds.
+func (c *Checker) Started(ctx context.Context) bool {
+	if !c.started.Load() {
+		return false
+	}
+	if !c.everUp.Load() {
+		c.Check(ctx)
+	}
+	return c.everUp.Load()
+}
+
+// Check returns the report of the latest run of the checks, running them if the cached report
+// expired, or a draining report once Drain was called. Concurrent callers wait for the same run; a caller whose ctx ends first gets a report
+// of its own saying every component timed out.
+func (c *Checker) Check(ctx context.Context) Report {
+	if c.draining.Load() {
+		return Report{Status: StatusDraining, CheckedAt: time.Now().UTC(), Checks: []Result{}}
+	}
+	c.mu.Lock()
+	if c.last != nil && time.Now().Before(c.expires) {
+		r := c.last.clone()
+		c.mu.Unlock()
+		return r
+	}
+	running := c.running
+	if running == nil {
+		running = make(chan struct{})
+		c.running = running
+		// The run is shared, so it must not end with the context of the caller that started it.
+		go c.run(context.WithoutCancel(ctx), running)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-running:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.last.clone()
+	case <-ctx.Done():
+		return c.timedOut()
+	}
+}
+
+// run runs the checks concurrently and caches the report, then closes done.
+func (c *Checker) run(ctx context.Context, done chan struct{}) {
+	report := Report{Status: StatusUp, CheckedAt: time.Now().UTC(), Checks: make([]Result, len(c.checks))}
+	errs := make([]error, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i], errs[i] = c.runCheck(ctx, check)
+		}()
+	}
+	wg.Wait()
+	for _, r := range report.Checks {
+		if r.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	if report.Up() {
+		c.everUp.Store(true)
+	}
+
+	c.mu.Lock()
+	c.last = &report
+	c.expires = time.Now().Add(c.opts.CacheTTL)
+	c.running = nil
+	changed := make([]int, 0, len(report.Checks))
+	for i, r := range report.Checks {
+		if prev, ok := c.lastState[r.Name]; (ok && prev != r.Status) || (!ok && r.Status != StatusUp) {
+			changed = append(changed, i)
+		}
+		c.lastState[r.Name] = r.Status
+	}
+	c.mu.Unlock()
+	close(done)
+
+	for _, i := range changed {
+		r := report.Checks[i]
+		fields := map[string]any{"component": r.Name, "latency_ms": r.LatencyMs}
+		if r.Status == StatusUp {
+			c.opts.Log("info", "dependency_up", fields)
+			continue
+		}
+		fields["error"] = errs[i].Error()
+		c.opts.Log("error", "dependency_down", fields)
+	}
+}
+
+// runCheck runs one check within its timeout. A panicking check is reported down.
+func (c *Checker) runCheck(ctx context.Context, check Check) (res Result, err error) {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = c.opts.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- errors.New("check panicked")
+			}
+		}()
+		done <- check.Run(ctx)
+	}()
+	// A check ignoring its context must not hold up the report.
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res = Result{Name: check.Name, Status: StatusUp, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+	switch {
+	case err == nil:
+	case errors.Is(err, context.DeadlineExceeded):
+		res.Status, res.Error = StatusDown, "timeout"
+	default:
+		res.Status, res.Error = StatusDown, "unavailable"
+	}
+	return res, err
+}
+
+// timedOut is the report for a caller that stopped waiting for the checks.
+func (c *Checker) timedOut() Report {
+	report := Report{Status: StatusDown, CheckedAt: time.Now().UTC(), Checks: make([]Result, len(c.checks))}
+	for i, check := range c.checks {
+		report.Checks[i] = Result{Name: check.Name, Status: StatusDown, Error: "timeout"}
+	}
+	return report
+}
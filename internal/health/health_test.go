@@ -0,0 +1,202 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func up(context.Context) error { return nil }
+
+func TestChecker_Check(t *testing.T) {
+	ctx := context.Background()
+	hang := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	tests := []struct {
+		name   string
+		checks []Check
+		status string
+		want   []Result
+	}{
+		{
+			name:   "all up",
+			checks: []Check{{Name: "database", Run: up}, {Name: "storage", Run: up}},
+			status: StatusUp,
+			want:   []Result{{Name: "database", Status: StatusUp}, {Name: "storage", Status: StatusUp}},
+		},
+		{
+			name: "one down",
+			checks: []Check{
+				{Name: "database", Run: up},
+				{Name: "storage", Run: func(context.Context) error { return errors.New("bucket not found") }},
+			},
+			status: StatusDown,
+			want:   []Result{{Name: "database", Status: StatusUp}, {Name: "storage", Status: StatusDown, Error: "unavailable"}},
+		},
+		{
+			name:   "timeout",
+			checks: []Check{{Name: "database", Run: up}, {Name: "storage", Timeout: 10 * time.Millisecond, Run: hang}},
+			status: StatusDown,
+			want:   []Result{{Name: "database", Status: StatusUp}, {Name: "storage", Status: StatusDown, Error: "timeout"}},
+		},
+		{
+			name: "check ignoring its context",
+			checks: []Check{{Name: "storage", Timeout: 10 * time.Millisecond, Run: func(context.Context) error {
+				time.Sleep(time.Second)
+				return nil
+			}}},
+			status: StatusDown,
+			want:   []Result{{Name: "storage", Status: StatusDown, Error: "timeout"}},
+		},
+		{
+			name:   "panic",
+			checks: []Check{{Name: "jobs", Run: func(context.Context) error { panic("boom") }}},
+			status: StatusDown,
+			want:   []Result{{Name: "jobs", Status: StatusDown, Error: "unavailable"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			report := NewChecker(Options{}, tt.checks...).Check(ctx)
+			assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+			assert.Equal(t, tt.status, report.Status)
+			require.Len(t, report.Checks, len(tt.want))
+			for i := range report.Checks {
+				assert.GreaterOrEqual(t, report.Checks[i].LatencyMs, 0.0)
+				report.Checks[i].LatencyMs = 0
+			}
+			assert.Equal(t, tt.want, report.Checks)
+		})
+	}
+}
+
+func TestChecker_Cache(t *testing.T) {
+	ctx := context.Background()
+	var runs atomic.Int32
+	release := make(chan struct{})
+	c := NewChecker(Options{CacheTTL: 50 * time.Millisecond}, Check{Name: "database", Run: func(context.Context) error {
+		runs.Add(1)
+		<-release
+		return nil
+	}})
+
+	// Concurrent probes share one run.
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.True(t, c.Check(ctx).Up())
+		}()
+	}
+	assert.Eventually(t, func() bool { return runs.Load() == 1 }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), runs.Load())
+
+	// The report is reused until it expires.
+	c.Check(ctx)
+	assert.Equal(t, int32(1), runs.Load())
+	time.Sleep(60 * time.Millisecond)
+	c.Check(ctx)
+	assert.Equal(t, int32(2), runs.Load())
+}
+
+func TestChecker_CallerGivesUp(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	c := NewChecker(Options{}, Check{Name: "database", Run: func(context.Context) error {
+		<-release
+		return nil
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	report := c.Check(ctx)
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, []Result{{Name: "database", Status: StatusDown, Error: "timeout"}}, report.Checks)
+}
+
+func TestChecker_Started(t *testing.T) {
+	ctx := context.Background()
+	var fail atomic.Bool
+	fail.Store(true)
+	c := NewChecker(Options{CacheTTL: time.Nanosecond}, Check{Name: "database", Run: func(context.Context) error {
+		if fail.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	}})
+
+	fail.Store(false)
+	assert.False(t, c.Started(ctx), "not started before MarkStarted")
+	c.MarkStarted()
+	fail.Store(true)
+	assert.False(t, c.Started(ctx), "not started before the dependencies were up")
+	fail.Store(false)
+	assert.True(t, c.Started(ctx))
+	fail.Store(true)
+	assert.True(t, c.Started(ctx), "stays started when a dependency goes down")
+	assert.False(t, c.Check(ctx).Up())
+}
+
+func TestChecker_LogsChanges(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	var logged []string
+	var fail atomic.Bool
+	c := NewChecker(Options{
+		CacheTTL: time.Nanosecond,
+		Log: func(level, msg string, fields map[string]any) {
+			mu.Lock()
+			defer mu.Unlock()
+			logged = append(logged, level+" "+msg+" "+fields["component"].(string))
+		},
+	}, Check{Name: "storage", Run: func(context.Context) error {
+		if fail.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	}})
+
+	c.Check(ctx)
+	fail.Store(true)
+	c.Check(ctx)
+	c.Check(ctx)
+	fail.Store(false)
+	c.Check(ctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"error dependency_down storage", "info dependency_up storage"}, logged)
+}
+
+func TestChecker_Drain(t *testing.T) {
+	ctx := context.Background()
+	var runs atomic.Int32
+	c := NewChecker(Options{CacheTTL: time.Nanosecond}, Check{Name: "database", Run: func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+	c.MarkStarted()
+	require.True(t, c.Check(ctx).Up())
+
+	c.Drain()
+	report := c.Check(ctx)
+	assert.Equal(t, StatusDraining, report.Status)
+	assert.False(t, report.Up())
+	assert.Empty(t, report.Checks)
+	assert.Equal(t, int32(1), runs.Load(), "checks don't run while draining")
+	assert.True(t, c.Started(ctx), "the startup probe is unaffected")
+}
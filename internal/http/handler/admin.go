@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"docapi/internal/model"
+	"docapi/internal/service"
+)
+
+// ReleaseDocument handles releasing a quarantined document after review.
+// @Summary Release quarantined document
+// @Description Make a quarantined document available after review, for example when its scan result was a false positive. Its
+// @Description content is moved out of quarantine; the scan result is kept. Requires the admin bearer token.
+// @Tags admin
+// @Produce json
+// @Security AdminToken
+// @Param id path string true "Document ID"
+// @Success 200 {object} model.Document
+// @Failure 400 {object} errorPayload
+// @Failure 401 {object} errorPayload
+// @Failure 404 {object} errorPayload
+// @Failure 409 {object} errorPayload "document is not quarantined"
+// @Failure 500 {object} errorPayload
+// @Router /admin/documents/{id}/release [post]
+func ReleaseDocument(docSvc service.DocumentService) fiber.Handler {
+	return reviewDocument(docSvc.Release)
+}
+
+// RejectDocument handles rejecting a quarantined document after review.
+// @Summary Reject quarantined document
+// @Description Mark a quarantined document as failed after review. Its content stays in quarantine and is never served; delete the
+// @Description document to remove it. Requires the admin bearer token.
+// @Tags admin
+// @Produce json
+// @Security AdminToken
+// @Param id path string true "Document ID"
+// @Success 200 {object} model.Document
+// @Failure 400 {object} errorPayload
+// @Failure 401 {object} errorPayload
+// @Failure 404 {object} errorPayload
+// @Failure 409 {object} errorPayload "document is not quarantined"
+// @Failure 500 {object} errorPayload
+// @Router /admin/documents/{id}/reject [post]
+func RejectDocument(docSvc service.DocumentService) fiber.Handler {
+	return reviewDocument(docSvc.Reject)
+}
+
+// reviewDocument answers a review decision on a quarantined document with the document.
+func reviewDocument(decide func(ctx context.Context, id string) (*model.Document, error)) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if _, err := uuid.Parse(id); err != nil {
+			return writeError(c, fiber.StatusBadRequest, "INVALID_ID", "invalid id format")
+		}
+		doc, err := decide(c.UserContext(), id)
+		if err != nil {
+			if isNotFound(err) {
+				return writeError(c, fiber.StatusNotFound, "NOT_FOUND", "document not found")
+			}
+			if errors.Is(err, service.ErrInvalidTransition) {
+				return writeError(c, fiber.StatusConflict, "NOT_QUARANTINED", "only quarantined documents can be released or rejected")
+			}
+			if errors.Is(err, service.ErrConflict) {
+				return writeError(c, fiber.StatusConflict, "CONFLICT", "document is being changed concurrently, retry")
+			}
+			return writeError(c, fiber.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		}
+		return c.JSON(doc)
+	}
+}
+
+// RegisterAdminRoutes attaches the administrative routes to router, which must restrict access to
+// administrators.
+func RegisterAdminRoutes(router fiber.Router, docSvc service.DocumentService) {
+	// Review decisions on quarantined documents
+	router.Post("/documents/:id/release", ReleaseDocument(docSvc))
+	router.Post("/documents/:id/reject", RejectDocument(docSvc))
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"docapi/internal/service"
+)
+
+type archiveRequest struct {
+	// IDs selects documents by ID. It cannot be combined with Filter or Sort.
+	IDs []string `json:"ids"`
+	// Filter selects the documents a listing with the same parameters would return.
+	Filter *archiveFilter `json:"filter"`
+	Sort   string         `json:"sort" example:"-created_at"`
+}
+
+// archiveFilter mirrors the filter parameters of GET /documents.
+type archiveFilter struct {
+	ContentType   string `json:"content_type" example:"image/*"`
+	CreatedAfter  string `json:"created_after" example:"2024-01-01"`
+	CreatedBefore string `json:"created_before"`
+	MinSize       *int64 `json:"min_size"`
+	MaxSize       *int64 `json:"max_size"`
+	Name          string `json:"name"`
+	// Status lists processing statuses separated by commas, e.g. "available".
+	Status string `json:"status" example:"available"`
+}
+
+// param returns the filter value for a GET /documents parameter name.
+func (f *archiveFilter) param(key string) string {
+	size := func(n *int64) string {
+		if n == nil {
+			return ""
+		}
+		return strconv.FormatInt(*n, 10)
+	}
+	switch key {
+	case "content_type":
+		return f.ContentType
+	case "created_after":
+		return f.CreatedAfter
+	case "created_before":
+		return f.CreatedBefore
+	case "min_size":
+		return size(f.MinSize)
+	case "max_size":
+		return size(f.MaxSize)
+	case "name":
+		return f.Name
+	case "status":
+		return f.Status
+	}
+	return ""
+}
+
+// DownloadArchive streams a ZIP archive of several documents.
+// @Summary Download documents as a ZIP archive
+// @Description Stream a ZIP of the documents selected by ID, or of all documents matching a filter (same parameters as the listing;
+// @Description an empty body selects everything), up to a configured limit. Entries are named after the original filenames,
+// @Description with a numbered suffix when names collide, and the archive ends with manifest.json listing each entry's document ID
+// @Description and SHA-256 checksum, and any selected documents that were missing or unreadable. ZIP64 is used for large archives.
+// @Description If reading a document fails midway, the connection is closed before the archive is complete.
+// @Tags documents
+// @Accept json
+// @Produce application/zip
+// @Param request body archiveRequest false "Documents to include"
+// @Success 200 {file} file
+// @Failure 400 {object} errorPayload
+// @Failure 500 {object} errorPayload
+// @Router /documents/archive [post]
+func DownloadArchive(docSvc service.DocumentService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req archiveRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return writeError(c, fiber.StatusBadRequest, "INVALID_BODY", `body must be a JSON object with "ids", or "filter" and "sort"`)
+			}
+		}
+		if len(req.IDs) > 0 && (req.Filter != nil || req.Sort != "") {
+			return writeError(c, fiber.StatusBadRequest, "INVALID_BODY", "ids cannot be combined with filter or sort")
+		}
+
+		sel := service.ArchiveSelection{IDs: req.IDs}
+		filter := req.Filter
+		if filter == nil {
+			filter = &archiveFilter{}
+		}
+		var perr *paramError
+		sel.Filter, sel.Sort, perr = parseFilterParams(func(key string) string {
+			if key == "sort" {
+				return req.Sort
+			}
+			return filter.param(key)
+		})
+		if perr != nil {
+			return writeError(c, fiber.StatusBadRequest, perr.code, perr.message)
+		}
+
+		ctx := c.UserContext()
+		a, err := docSvc.PrepareArchive(ctx, sel)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidID):
+				return writeError(c, fiber.StatusBadRequest, "INVALID_ID", "invalid id format")
+			case errors.Is(err, service.ErrTooManyDocuments):
+				return writeError(c, fiber.StatusBadRequest, "TOO_MANY_DOCUMENTS", err.Error())
+			}
+			return writeError(c, fiber.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		}
+
+		// The archive is produced while the response is sent. An error closes the pipe with it,
+		// which makes the server abort the connection instead of ending the body normally.
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(docSvc.WriteArchive(ctx, a, pw))
+		}()
+
+		c.Set(fiber.HeaderContentType, "application/zip")
+		c.Set(fiber.HeaderContentDisposition,
+			fmt.Sprintf("attachment; filename=%q", "documents-"+time.Now().UTC().Format("20060102T150405Z")+".zip"))
+		return c.Status(fiber.StatusOK).SendStream(pr)
+	}
+}
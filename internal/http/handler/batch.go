@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"docapi/internal/service"
+)
+
+type batchDeleteRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// batchDeleteItem is the outcome for one requested ID. Error is only set when Status is "failed".
+type batchDeleteItem struct {
+	ID     string         `json:"id"`
+	Status string         `json:"status" enums:"deleted,not_found,failed"`
+	Error  *errorEnvelope `json:"error,omitempty"`
+}
+
+type batchDeleteResponse struct {
+	Results  []batchDeleteItem `json:"results"`
+	Deleted  int               `json:"deleted"`
+	NotFound int               `json:"not_found"`
+	Failed   int               `json:"failed"`
+}
+
+// BatchDeleteDocuments handles deleting several documents at once.
+// @Summary Delete documents in bulk
+// @Description Delete up to a configured number of documents (100 by default) in one request. Every distinct ID is reported
+// @Description once, in request order, as deleted, not_found or failed with an error code; a failed item is left in place and
+// @Description can be retried. The response is 200 when every document was deleted and 207 otherwise.
+// @Tags documents
+// @Accept json
+// @Produce json
+// @Param request body batchDeleteRequest true "IDs to delete"
+// @Success 200 {object} batchDeleteResponse
+// @Success 207 {object} batchDeleteResponse
+// @Failure 400 {object} errorPayload
+// @Failure 500 {object} errorPayload
+// @Router /documents:batchDelete [post]
+func BatchDeleteDocuments(docSvc service.DocumentService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req batchDeleteRequest
+		if err := c.BodyParser(&req); err != nil {
+			return writeError(c, fiber.StatusBadRequest, "INVALID_BODY", `body must be a JSON object like {"ids": ["..."]}`)
+		}
+		if len(req.IDs) == 0 {
+			return writeError(c, fiber.StatusBadRequest, "IDS_REQUIRED", "ids must not be empty")
+		}
+
+		results, err := docSvc.DeleteMany(c.UserContext(), req.IDs)
+		if err != nil {
+			if errors.Is(err, service.ErrTooManyIDs) {
+				return writeError(c, fiber.StatusBadRequest, "TOO_MANY_IDS", err.Error())
+			}
+			return writeError(c, fiber.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		}
+
+		res := batchDeleteResponse{Results: make([]batchDeleteItem, len(results))}
+		for i, r := range results {
+			item := batchDeleteItem{ID: r.ID, Status: string(r.Status)}
+			switch r.Status {
+			case service.DeleteStatusDeleted:
+				res.Deleted++
+			case service.DeleteStatusNotFound:
+				res.NotFound++
+			default:
+				res.Failed++
+				item.Error = &errorEnvelope{Code: "INTERNAL_ERROR", Message: "internal server error"}
+				if errors.Is(r.Err, service.ErrInvalidID) {
+					item.Error = &errorEnvelope{Code: "INVALID_ID", Message: "invalid id format"}
+				}
+			}
+			res.Results[i] = item
+		}
+
+		status := fiber.StatusOK
+		if res.Deleted < len(results) {
+			status = fiber.StatusMultiStatus
+		}
+		return c.Status(status).JSON(res)
+	}
+}
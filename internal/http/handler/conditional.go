@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"docapi/internal/model"
+	"docapi/internal/service"
+)
+
+// Cache-Control values. Document records and content may change (an update can correct the
+// content type), so caches must revalidate them; content requested for the document's current
+// version pins that representation and never changes.
+const (
+	cacheRevalidate = "private, no-cache"
+	cacheImmutable  = "private, max-age=31536000, immutable"
+)
+
+// documentETag returns the strong entity tag of a document's record, which changes with every
+// update.
+func documentETag(doc *model.Document) string {
+	return `"` + strconv.FormatInt(doc.Version, 10) + `"`
+}
+
+// contentETag returns the strong entity tag of a document's content: the storage ETag of its
+// object, which never changes, and the record version, which covers the content type. Documents
+// stored before storage ETags were recorded use their ID instead.
+func contentETag(doc *model.Document) string {
+	digest := doc.ContentETag
+	if digest == "" {
+		digest = doc.ID
+	}
+	return `"` + digest + "." + strconv.FormatInt(doc.Version, 10) + `"`
+}
+
+// lastModified returns the Last-Modified time of a document and its content, at the one second
+// resolution of HTTP dates.
+func lastModified(doc *model.Document) time.Time {
+	return doc.UpdatedAt.UTC().Truncate(time.Second)
+}
+
+// setValidators sets the ETag, Last-Modified and Cache-Control headers of a response.
+func setValidators(c *fiber.Ctx, etag string, modified time.Time, cacheControl string) {
+	c.Set(fiber.HeaderETag, etag)
+	if !modified.IsZero() {
+		c.Set(fiber.HeaderLastModified, modified.Format(http.TimeFormat))
+	}
+	c.Set(fiber.HeaderCacheControl, cacheControl)
+}
+
+// etagMatches reports whether the If-Match or If-None-Match header h lists etag or is "*". With
+// weak comparison, as If-None-Match uses, a W/ prefix is ignored; otherwise weak tags never match.
+func etagMatches(h, etag string, weak bool) bool {
+	for _, tag := range strings.Split(h, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// modifiedSince reports whether modified is after the HTTP date in h. Headers that are not a
+// valid date yield ok false and are ignored, as RFC 9110 requires.
+func modifiedSince(h string, modified time.Time) (since, ok bool) {
+	t, err := http.ParseTime(h)
+	if err != nil {
+		return false, false
+	}
+	return modified.After(t), true
+}
+
+// conditions are the conditional headers of a request. They are only valid while the request is
+// being handled.
+type conditions struct {
+	ifMatch, ifNoneMatch, ifModifiedSince, ifUnmodifiedSince string
+}
+
+func conditionsOf(c *fiber.Ctx) conditions {
+	return conditions{
+		ifMatch:           c.Get(fiber.HeaderIfMatch),
+		ifNoneMatch:       c.Get(fiber.HeaderIfNoneMatch),
+		ifModifiedSince:   c.Get(fiber.HeaderIfModifiedSince),
+		ifUnmodifiedSince: c.Get(fiber.HeaderIfUnmodifiedSince),
+	}
+}
+
+// hold evaluates If-Match and, without it, If-Unmodified-Since (RFC 9110, section 13.2.2). For
+// requests that change the document, a matching If-None-Match fails too.
+func (cd conditions) hold(etag string, modified time.Time, write bool) bool {
+	if cd.ifMatch != "" {
+		if !etagMatches(cd.ifMatch, etag, false) {
+			return false
+		}
+	} else if cd.ifUnmodifiedSince != "" {
+		if since, ok := modifiedSince(cd.ifUnmodifiedSince, modified); ok && since {
+			return false
+		}
+	}
+	return !write || cd.ifNoneMatch == "" || !etagMatches(cd.ifNoneMatch, etag, true)
+}
+
+// notModified reports whether If-None-Match or, without it, If-Modified-Since shows that the
+// client's cached representation is current.
+func (cd conditions) notModified(etag string, modified time.Time) bool {
+	if cd.ifNoneMatch != "" {
+		return etagMatches(cd.ifNoneMatch, etag, true)
+	}
+	if cd.ifModifiedSince != "" {
+		since, ok := modifiedSince(cd.ifModifiedSince, modified)
+		return ok && !since
+	}
+	return false
+}
+
+// evaluateRead answers a conditional GET: it returns the status to respond with, 412 or 304, or
+// zero when the representation should be sent.
+func evaluateRead(c *fiber.Ctx, etag string, modified time.Time) int {
+	cd := conditionsOf(c)
+	if !cd.hold(etag, modified, false) {
+		return fiber.StatusPreconditionFailed
+	}
+	if cd.notModified(etag, modified) {
+		return fiber.StatusNotModified
+	}
+	return 0
+}
+
+// writeConditional sends the response chosen by evaluateRead.
+func writeConditional(c *fiber.Ctx, status int) error {
+	if status == fiber.StatusPreconditionFailed {
+		return writeError(c, status, "PRECONDITION_FAILED", "document does not match the request's preconditions")
+	}
+	return c.SendStatus(status)
+}
+
+// isConditional reports whether a GET request carries headers that depend on the document.
+func isConditional(c *fiber.Ctx) bool {
+	return conditionsOf(c) != conditions{} || c.Get(fiber.HeaderIfRange) != ""
+}
+
+// writePrecondition returns the precondition expressed by the If-Match, If-Unmodified-Since and
+// If-None-Match headers of a request changing a document, or nil when it has none. The service
+// checks it against the document's current record.
+func writePrecondition(c *fiber.Ctx) service.Precondition {
+	cd := conditionsOf(c)
+	if cd.ifMatch == "" && cd.ifUnmodifiedSince == "" && cd.ifNoneMatch == "" {
+		return nil
+	}
+	return func(doc *model.Document) bool {
+		return cd.hold(documentETag(doc), lastModified(doc), true)
+	}
+}
+
+// rangeApplies evaluates If-Range: a Range request is only served partially while the content
+// still has the given strong ETag or was not modified after the given date.
+func rangeApplies(c *fiber.Ctx, etag string, modified time.Time) bool {
+	h := strings.TrimSpace(c.Get(fiber.HeaderIfRange))
+	if h == "" {
+		return true
+	}
+	if strings.HasPrefix(h, `"`) {
+		return h == etag
+	}
+	since, ok := modifiedSince(h, modified)
+	return ok && !since
+}
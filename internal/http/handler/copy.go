@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	_ "docapi/internal/model"
+	"docapi/internal/service"
+)
+
+// copyRequest customises a copied document. Omitted fields keep the source's values; an empty
+// metadata object clears the metadata.
+type copyRequest struct {
+	Name     string            `json:"name" example:"contract-v2.docx"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// CopyDocument handles duplicating a document.
+// @Summary Copy document
+// @Description Create an independent document with the content of another, copied within object storage without passing through
+// @Description the client. The copy gets a new ID and keeps the source's name, metadata and scan result unless the body replaces
+// @Description them. Only available documents can be copied; the copy is available right away.
+// @Tags documents
+// @Accept json
+// @Produce json
+// @Param id path string true "Document ID"
+// @Param request body copyRequest false "Name and metadata of the copy"
+// @Success 201 {object} model.Document
+// @Failure 400 {object} errorPayload
+// @Failure 403 {object} errorPayload "source content is quarantined"
+// @Failure 404 {object} errorPayload
+// @Failure 409 {object} errorPayload "source failed processing or was rejected"
+// @Failure 500 {object} errorPayload
+// @Router /documents/{id}/copy [post]
+func CopyDocument(docSvc service.DocumentService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if _, err := uuid.Parse(id); err != nil {
+			return writeError(c, fiber.StatusBadRequest, "INVALID_ID", "invalid id format")
+		}
+		var req copyRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return writeError(c, fiber.StatusBadRequest, "INVALID_BODY", `body must be a JSON object with optional "name" and "metadata"`)
+			}
+		}
+
+		doc, err := docSvc.Copy(c.UserContext(), id, service.CopyOptions{Name: req.Name, Metadata: req.Metadata})
+		if err != nil {
+			if isNotFound(err) {
+				return writeError(c, fiber.StatusNotFound, "NOT_FOUND", "document not found")
+			}
+			if errors.Is(err, service.ErrQuarantined) {
+				return writeQuarantined(c)
+			}
+			if errors.Is(err, service.ErrNotAvailable) {
+				return writeNotAvailable(c)
+			}
+			return writeError(c, fiber.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		}
+		return c.Status(fiber.StatusCreated).JSON(doc)
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"docapi/internal/service"
+)
+
+// maxPresignExpiry is the longest lifetime S3-compatible stores accept for presigned URLs.
+const maxPresignExpiry = 7 * 24 * time.Hour
+
+type presignedURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// isNotFound reports whether err means the document does not exist.
+func isNotFound(err error) bool {
+	return errors.Is(err, service.ErrNotFound) || errors.Is(err, sql.ErrNoRows)
+}
+
+// parseRange parses a single-range "bytes=" Range header into the offset/length form used by
+// DocumentService.Download. A suffix range ("bytes=-N") yields a negative offset. Headers that
+// are absent, malformed or request multiple ranges are ignored and the whole content is served,
+// as RFC 9110 permits.
+func parseRange(h string) (offset, length int64, ok bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(h), "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, -1, false
+	}
+	startStr, endStr, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, -1, false
+	}
+
+	if startStr == "" {
+		n, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, -1, false
+		}
+		return -n, -1, true
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return 0, -1, false
+	}
+	if endStr == "" {
+		return start, -1, true
+	}
+	end, err := strconv.ParseInt(endStr, 10, 64)
+	if err != nil || end < start {
+		return 0, -1, false
+	}
+	return start, end - start + 1, true
+}
@@ -44,6 +44,21 @@ func writeError(c *fiber.Ctx, status int, code, message string) error {
 	return c.Status(status).JSON(res)
 }
 
+// scanUnavailable reports content that could not be scanned for malware, and so was not stored.
+// The cause, such as clamd being unreachable, is not the client's concern.
+var scanUnavailable = errorEnvelope{Code: "SCAN_UNAVAILABLE", Message: "content could not be scanned for malware, retry later"}
+
+// writeQuarantined answers a request for the content of a document found infected.
+func writeQuarantined(c *fiber.Ctx) error {
+	return writeError(c, fiber.StatusForbidden, "QUARANTINED", "document content is quarantined because malware was found")
+}
+
+// writeNotAvailable answers a request for the content of a document that is not available, such
+// as one that failed processing or was rejected.
+func writeNotAvailable(c *fiber.Ctx) error {
+	return writeError(c, fiber.StatusConflict, "NOT_AVAILABLE", "document content is not available because its processing failed or it was rejected")
+}
+
 // ErrorHandler returns a Fiber global error handler that standardizes error responses.
 func ErrorHandler() fiber.ErrorHandler {
 	return func(c *fiber.Ctx, err error) error {
@@ -2,15 +2,24 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"docapi/internal/health"
 	"docapi/internal/model"
+	"docapi/internal/repository"
 	"docapi/internal/service"
 	serviceMocks "docapi/internal/service/mocks"
 
@@ -65,6 +74,90 @@ func TestLivenessProbe(t *testing.T) {
 	resp, _ := app.Test(req)
 
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The liveness probe doesn't depend on the checks.
+	app = fiber.New()
+	RegisterProbeRoutes(app, health.NewChecker(health.Options{}, health.Check{Name: "database", Run: func(context.Context) error {
+		return errors.New("db error")
+	}}))
+	resp, _ = app.Test(httptest.NewRequest(http.MethodGet, "/livez", nil))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestReadinessProbe(t *testing.T) {
+	storageErr := errors.New("bucket not found")
+	tests := []struct {
+		name       string
+		storageErr error
+		wantCode   int
+		wantStatus string
+		wantError  string
+	}{
+		{"ready", nil, http.StatusOK, health.StatusUp, ""},
+		{"storage down", storageErr, http.StatusServiceUnavailable, health.StatusDown, "unavailable"},
+	}
+
+	t.Run("draining", func(t *testing.T) {
+		checker := health.NewChecker(health.Options{}, health.Check{Name: "database", Run: func(context.Context) error { return nil }})
+		checker.Drain()
+		app := fiber.New()
+		RegisterProbeRoutes(app, checker)
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		var body health.Report
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, health.StatusDraining, body.Status)
+	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := health.NewChecker(health.Options{},
+				health.Check{Name: "database", Run: func(context.Context) error { return nil }},
+				health.Check{Name: "storage", Run: func(context.Context) error { return tt.storageErr }},
+			)
+			app := fiber.New()
+			RegisterProbeRoutes(app, checker)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCode, resp.StatusCode)
+			assert.Equal(t, "no-store", resp.Header.Get(fiber.HeaderCacheControl))
+
+			var body health.Report
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, tt.wantStatus, body.Status)
+			require.Len(t, body.Checks, 2)
+			assert.Equal(t, "database", body.Checks[0].Name)
+			assert.Equal(t, health.StatusUp, body.Checks[0].Status)
+			assert.Equal(t, "storage", body.Checks[1].Name)
+			assert.Equal(t, tt.wantError, body.Checks[1].Error)
+			assert.NotContains(t, body.Checks[1].Error, "bucket", "details are only logged")
+		})
+	}
+}
+
+func TestStartupProbe(t *testing.T) {
+	checker := health.NewChecker(health.Options{}, health.Check{Name: "database", Run: func(context.Context) error { return nil }})
+	app := fiber.New()
+	RegisterProbeRoutes(app, checker)
+
+	probe := func() (int, string) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/startupz", nil))
+		require.NoError(t, err)
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode, body["status"]
+	}
+
+	code, status := probe()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "starting", status)
+
+	checker.MarkStarted()
+	code, status = probe()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "started", status)
 }
 
 func TestListDocuments(t *testing.T) {
@@ -73,11 +166,12 @@ func TestListDocuments(t *testing.T) {
 	app.Get("/documents", ListDocuments(mockSvc))
 
 	t.Run("success", func(t *testing.T) {
+		total := 1
 		expectedRes := &service.DocumentListResult{
 			Items: []model.Document{{ID: uuid.New().String(), Filename: "test.pdf"}},
-			Total: 1,
+			Total: &total,
 		}
-		mockSvc.On("List", mock.Anything, 10, 0).Return(expectedRes, nil).Once()
+		mockSvc.On("List", mock.Anything, service.ListParams{Limit: 10, WithTotal: true}).Return(expectedRes, nil).Once()
 
 		req := httptest.NewRequest(http.MethodGet, "/documents?limit=10&offset=0", nil)
 		resp, _ := app.Test(req)
@@ -87,10 +181,108 @@ func TestListDocuments(t *testing.T) {
 		var result service.DocumentListResult
 		json.NewDecoder(resp.Body).Decode(&result)
 		assert.Len(t, result.Items, 1)
-		assert.Equal(t, 1, result.Total)
+		assert.Equal(t, &total, result.Total)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("cursor", func(t *testing.T) {
+		mockSvc.On("List", mock.Anything, service.ListParams{Limit: 5, Cursor: "abc"}).
+			Return(&service.DocumentListResult{Items: []model.Document{}, NextCursor: "def"}, nil).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/documents?limit=5&cursor=abc", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var body map[string]any
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, "def", body["next_cursor"])
+		assert.NotContains(t, body, "total")
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("cursor with total", func(t *testing.T) {
+		mockSvc.On("List", mock.Anything, service.ListParams{Limit: 10, Cursor: "abc", WithTotal: true}).
+			Return(&service.DocumentListResult{Items: []model.Document{}}, nil).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/documents?cursor=abc&total=true", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
 		mockSvc.AssertExpectations(t)
 	})
 
+	t.Run("invalid cursor", func(t *testing.T) {
+		mockSvc.On("List", mock.Anything, mock.Anything).Return(nil, service.ErrInvalidCursor).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/documents?cursor=bogus", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		var body errorPayload
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, "INVALID_CURSOR", body.Error.Code)
+	})
+
+	t.Run("filters and sort", func(t *testing.T) {
+		minSize, maxSize := int64(10), int64(2048)
+		mockSvc.On("List", mock.Anything, service.ListParams{
+			Limit:     10,
+			WithTotal: true,
+			Sort:      service.Sort{Field: repository.SortSize},
+			Filter: service.DocumentFilter{
+				ContentType: "image/*",
+				CreatedFrom: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+				CreatedTo:   time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC),
+				MinSize:     &minSize,
+				MaxSize:     &maxSize,
+				Name:        "report",
+				Statuses:    []string{model.StatusAvailable, model.StatusQuarantined},
+			},
+		}).Return(&service.DocumentListResult{Items: []model.Document{}}, nil).Once()
+
+		q := "sort=-size&content_type=image/*&created_after=2024-01-01&created_before=2024-02-01T12:00:00Z&min_size=10&max_size=2048&name=report&status=available,quarantined"
+		req := httptest.NewRequest(http.MethodGet, "/documents?"+q, nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("bad parameters", func(t *testing.T) {
+		tests := []struct {
+			query string
+			code  string
+		}{
+			{query: "limit=1001", code: "INVALID_LIMIT"},
+			{query: "cursor=abc&offset=10", code: "INVALID_OFFSET"},
+			{query: "total=maybe", code: "INVALID_TOTAL"},
+			{query: "sort=storage_path", code: "INVALID_SORT"},
+			{query: "sort=size%3B%20DROP%20TABLE%20documents", code: "INVALID_SORT"},
+			{query: "content_type=image", code: "INVALID_CONTENT_TYPE"},
+			{query: "content_type=*/*", code: "INVALID_CONTENT_TYPE"},
+			{query: "content_type=image/%25", code: "INVALID_CONTENT_TYPE"},
+			{query: "created_after=yesterday", code: "INVALID_CREATED_AFTER"},
+			{query: "created_before=2024-13-01", code: "INVALID_CREATED_BEFORE"},
+			{query: "created_after=2024-02-01&created_before=2024-01-01", code: "INVALID_DATE_RANGE"},
+			{query: "min_size=-1", code: "INVALID_MIN_SIZE"},
+			{query: "max_size=1KB", code: "INVALID_MAX_SIZE"},
+			{query: "min_size=10&max_size=5", code: "INVALID_SIZE_RANGE"},
+			{query: "name=" + strings.Repeat("x", 256), code: "INVALID_NAME"},
+			{query: "status=deleted", code: "INVALID_STATUS"},
+			{query: "status=pending", code: "INVALID_STATUS"},
+			{query: "status=available,", code: "INVALID_STATUS"},
+		}
+		for _, tt := range tests {
+			req := httptest.NewRequest(http.MethodGet, "/documents?"+tt.query, nil)
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, tt.query)
+			var body errorPayload
+			json.NewDecoder(resp.Body).Decode(&body)
+			assert.Equal(t, tt.code, body.Error.Code, tt.query)
+		}
+	})
+
 	t.Run("invalid limit", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/documents?limit=abc", nil)
 		resp, _ := app.Test(req)
@@ -102,7 +294,7 @@ func TestListDocuments(t *testing.T) {
 	})
 
 	t.Run("service error", func(t *testing.T) {
-		mockSvc.On("List", mock.Anything, 10, 0).Return(nil, errors.New("service error")).Once()
+		mockSvc.On("List", mock.Anything, service.ListParams{Limit: 10, WithTotal: true}).Return(nil, errors.New("service error")).Once()
 
 		req := httptest.NewRequest(http.MethodGet, "/documents", nil)
 		resp, _ := app.Test(req)
@@ -166,6 +358,171 @@ func TestUploadDocument(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
 		mockSvc.AssertExpectations(t)
 	})
+
+	t.Run("scan unavailable", func(t *testing.T) {
+		body, contentType := multipartFiles(t, "test.txt")
+		mockSvc.On("Upload", mock.Anything, mock.Anything, "test.txt", mock.Anything, mock.Anything).
+			Return(nil, fmt.Errorf("scan: %w: connect to clamd: connection refused", service.ErrScanFailed)).Once()
+
+		req := httptest.NewRequest(http.MethodPost, "/documents", body)
+		req.Header.Set("Content-Type", contentType)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		var res errorPayload
+		json.NewDecoder(resp.Body).Decode(&res)
+		assert.Equal(t, "SCAN_UNAVAILABLE", res.Error.Code)
+		assert.NotContains(t, res.Error.Message, "clamd")
+		mockSvc.AssertExpectations(t)
+	})
+}
+
+func multipartFiles(t *testing.T, names ...string) (io.Reader, string) {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, name := range names {
+		part, err := writer.CreateFormFile("file", name)
+		require.NoError(t, err)
+		part.Write([]byte("content of " + name))
+	}
+	require.NoError(t, writer.Close())
+	return body, writer.FormDataContentType()
+}
+
+func TestUploadDocument_Many(t *testing.T) {
+	docA := &model.Document{ID: uuid.NewString(), Name: "a.txt"}
+	docB := &model.Document{ID: uuid.NewString(), Name: "b.txt"}
+	filesNamed := func(names ...string) any {
+		return mock.MatchedBy(func(files []service.UploadFile) bool {
+			if len(files) != len(names) {
+				return false
+			}
+			for i, f := range files {
+				rc, err := f.Open()
+				if err != nil {
+					return false
+				}
+				content, _ := io.ReadAll(rc)
+				rc.Close()
+				if f.Filename != names[i] || string(content) != "content of "+names[i] || f.Size != int64(len(content)) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		files      []string
+		setupMock  func(m *serviceMocks.MockDocumentService)
+		wantStatus int
+		wantCode   string
+		wantBody   uploadResponse
+	}{
+		{
+			name:  "all created",
+			files: []string{"a.txt", "b.txt"},
+			setupMock: func(m *serviceMocks.MockDocumentService) {
+				m.On("UploadMany", mock.Anything, filesNamed("a.txt", "b.txt"), false).Return([]service.UploadResult{
+					{Filename: "a.txt", Status: service.UploadStatusCreated, Document: docA},
+					{Filename: "b.txt", Status: service.UploadStatusCreated, Document: docB},
+				}, nil).Once()
+			},
+			wantStatus: http.StatusCreated,
+			wantBody: uploadResponse{
+				Results: []uploadItem{
+					{Filename: "a.txt", Status: "created", Document: docA},
+					{Filename: "b.txt", Status: "created", Document: docB},
+				},
+				Created: 2,
+			},
+		},
+		{
+			name:  "atomic rollback",
+			query: "?atomic=true",
+			files: []string{"a.txt", "b.txt", "c.txt"},
+			setupMock: func(m *serviceMocks.MockDocumentService) {
+				m.On("UploadMany", mock.Anything, filesNamed("a.txt", "b.txt", "c.txt"), true).Return([]service.UploadResult{
+					{Filename: "a.txt", Status: service.UploadStatusRolledBack},
+					{Filename: "b.txt", Status: service.UploadStatusFailed, Err: errors.New("upload to storage: boom")},
+					{Filename: "c.txt", Status: service.UploadStatusFailed, Document: docB, Err: service.ErrRollbackFailed},
+				}, nil).Once()
+			},
+			wantStatus: http.StatusMultiStatus,
+			wantBody: uploadResponse{
+				Results: []uploadItem{
+					{Filename: "a.txt", Status: "rolled_back"},
+					{Filename: "b.txt", Status: "failed", Error: &errorEnvelope{Code: "INTERNAL_ERROR", Message: "internal server error"}},
+					{Filename: "c.txt", Status: "failed", Document: docB,
+						Error: &errorEnvelope{Code: "ROLLBACK_FAILED", Message: "document could not be removed after the batch failed"}},
+				},
+				Failed:     2,
+				RolledBack: 1,
+			},
+		},
+		{
+			name:  "single file with atomic gets results",
+			query: "?atomic=1",
+			files: []string{"a.txt"},
+			setupMock: func(m *serviceMocks.MockDocumentService) {
+				m.On("UploadMany", mock.Anything, filesNamed("a.txt"), true).Return([]service.UploadResult{
+					{Filename: "a.txt", Status: service.UploadStatusFailed, Err: service.ErrFileOpen},
+				}, nil).Once()
+			},
+			wantStatus: http.StatusMultiStatus,
+			wantBody: uploadResponse{
+				Results: []uploadItem{{Filename: "a.txt", Status: "failed", Error: &errorEnvelope{Code: "FILE_OPEN_ERROR", Message: "cannot open uploaded file"}}},
+				Failed:  1,
+			},
+		},
+		{
+			name:       "invalid atomic",
+			query:      "?atomic=maybe",
+			files:      []string{"a.txt"},
+			setupMock:  func(m *serviceMocks.MockDocumentService) {},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "INVALID_ATOMIC",
+		},
+		{
+			name:  "too many files",
+			files: []string{"a.txt", "b.txt"},
+			setupMock: func(m *serviceMocks.MockDocumentService) {
+				m.On("UploadMany", mock.Anything, mock.Anything, false).Return(nil, service.ErrTooManyFiles).Once()
+			},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "TOO_MANY_FILES",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(serviceMocks.MockDocumentService)
+			tt.setupMock(mockSvc)
+			app := fiber.New()
+			app.Post("/documents", UploadDocument(mockSvc))
+
+			body, contentType := multipartFiles(t, tt.files...)
+			req := httptest.NewRequest(http.MethodPost, "/documents"+tt.query, body)
+			req.Header.Set("Content-Type", contentType)
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantCode != "" {
+				var res errorPayload
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+				assert.Equal(t, tt.wantCode, res.Error.Code)
+			} else {
+				var res uploadResponse
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+				assert.Equal(t, tt.wantBody, res)
+			}
+			mockSvc.AssertExpectations(t)
+		})
+	}
 }
 
 func TestGetDocument(t *testing.T) {
@@ -175,13 +532,14 @@ func TestGetDocument(t *testing.T) {
 
 	t.Run("success", func(t *testing.T) {
 		id := uuid.New().String()
-		expectedDoc := &model.Document{ID: id, Filename: "test.txt"}
+		expectedDoc := &model.Document{ID: id, Filename: "test.txt", Version: 2}
 		mockSvc.On("Get", mock.Anything, id).Return(expectedDoc, nil).Once()
 
 		req := httptest.NewRequest(http.MethodGet, "/documents/"+id, nil)
 		resp, _ := app.Test(req)
 
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
 
 		var result model.Document
 		json.NewDecoder(resp.Body).Decode(&result)
@@ -232,7 +590,7 @@ func TestDeleteDocument(t *testing.T) {
 
 	t.Run("success", func(t *testing.T) {
 		id := uuid.New().String()
-		mockSvc.On("Delete", mock.Anything, id).Return(nil).Once()
+		mockSvc.On("Delete", mock.Anything, id, mock.Anything).Return(nil).Once()
 
 		req := httptest.NewRequest(http.MethodDelete, "/documents/"+id, nil)
 		resp, _ := app.Test(req)
@@ -243,7 +601,7 @@ func TestDeleteDocument(t *testing.T) {
 
 	t.Run("not found", func(t *testing.T) {
 		id := uuid.New().String()
-		mockSvc.On("Delete", mock.Anything, id).Return(sql.ErrNoRows).Once()
+		mockSvc.On("Delete", mock.Anything, id, mock.Anything).Return(sql.ErrNoRows).Once()
 
 		req := httptest.NewRequest(http.MethodDelete, "/documents/"+id, nil)
 		resp, _ := app.Test(req)
@@ -255,9 +613,23 @@ func TestDeleteDocument(t *testing.T) {
 		mockSvc.AssertExpectations(t)
 	})
 
+	t.Run("concurrent change", func(t *testing.T) {
+		id := uuid.New().String()
+		mockSvc.On("Delete", mock.Anything, id, mock.Anything).Return(service.ErrConflict).Once()
+
+		req := httptest.NewRequest(http.MethodDelete, "/documents/"+id, nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		var res errorPayload
+		json.NewDecoder(resp.Body).Decode(&res)
+		assert.Equal(t, "CONFLICT", res.Error.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
 	t.Run("service error", func(t *testing.T) {
 		id := uuid.New().String()
-		mockSvc.On("Delete", mock.Anything, id).Return(errors.New("delete error")).Once()
+		mockSvc.On("Delete", mock.Anything, id, mock.Anything).Return(errors.New("delete error")).Once()
 
 		req := httptest.NewRequest(http.MethodDelete, "/documents/"+id, nil)
 		resp, _ := app.Test(req)
@@ -267,6 +639,617 @@ func TestDeleteDocument(t *testing.T) {
 	})
 }
 
+func TestConditionalRequests(t *testing.T) {
+	mockSvc := new(serviceMocks.MockDocumentService)
+	app := fiber.New()
+	app.Get("/documents/:id", GetDocument(mockSvc))
+	app.Delete("/documents/:id", DeleteDocument(mockSvc))
+	app.Get("/documents/:id/content", DownloadDocument(mockSvc))
+
+	updated := time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+	doc := &model.Document{ID: uuid.NewString(), Filename: "a.txt", ContentType: "text/plain", Size: 10, UpdatedAt: updated, Version: 3, ContentETag: "abc", Status: model.StatusAvailable}
+	mockSvc.On("Get", mock.Anything, doc.ID).Return(doc, nil)
+
+	send := func(method, path string, header map[string]string) *http.Response {
+		req := httptest.NewRequest(method, path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, _ := app.Test(req)
+		return resp
+	}
+	lastMod := updated.Format(http.TimeFormat)
+	before := updated.Add(-time.Hour).Format(http.TimeFormat)
+
+	t.Run("document", func(t *testing.T) {
+		for _, tc := range []struct {
+			name   string
+			header map[string]string
+			status int
+		}{
+			{"unconditional", nil, http.StatusOK},
+			{"if-none-match current", map[string]string{"If-None-Match": `"1", W/"3"`}, http.StatusNotModified},
+			{"if-none-match stale", map[string]string{"If-None-Match": `"2"`}, http.StatusOK},
+			{"if-none-match wins over date", map[string]string{"If-None-Match": `"2"`, "If-Modified-Since": lastMod}, http.StatusOK},
+			{"if-modified-since current", map[string]string{"If-Modified-Since": lastMod}, http.StatusNotModified},
+			{"if-modified-since stale", map[string]string{"If-Modified-Since": before}, http.StatusOK},
+			{"if-modified-since invalid", map[string]string{"If-Modified-Since": "yesterday"}, http.StatusOK},
+			{"if-match stale", map[string]string{"If-Match": `"2"`}, http.StatusPreconditionFailed},
+			{"if-unmodified-since stale", map[string]string{"If-Unmodified-Since": before}, http.StatusPreconditionFailed},
+		} {
+			resp := send(http.MethodGet, "/documents/"+doc.ID, tc.header)
+			assert.Equal(t, tc.status, resp.StatusCode, tc.name)
+			assert.Equal(t, `"3"`, resp.Header.Get("ETag"), tc.name)
+			assert.Equal(t, lastMod, resp.Header.Get("Last-Modified"), tc.name)
+			assert.Equal(t, "private, no-cache", resp.Header.Get("Cache-Control"), tc.name)
+		}
+	})
+
+	t.Run("content", func(t *testing.T) {
+		resp := send(http.MethodGet, "/documents/"+doc.ID+"/content", map[string]string{"If-None-Match": `"abc.3"`})
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+		assert.Equal(t, `"abc.3"`, resp.Header.Get("ETag"))
+		mockSvc.AssertNotCalled(t, "Download", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+		mockSvc.On("Download", mock.Anything, doc.ID, int64(0), int64(-1)).
+			Return(io.NopCloser(strings.NewReader("0123456789")), doc, nil).Once()
+		resp = send(http.MethodGet, "/documents/"+doc.ID+"/content?version=3", map[string]string{"Range": "bytes=0-3", "If-Range": `"abc.2"`})
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "a stale If-Range serves the whole content")
+		assert.Equal(t, "private, max-age=31536000, immutable", resp.Header.Get("Cache-Control"))
+
+		mockSvc.On("Download", mock.Anything, doc.ID, int64(0), int64(4)).
+			Return(io.NopCloser(strings.NewReader("0123")), doc, nil).Once()
+		resp = send(http.MethodGet, "/documents/"+doc.ID+"/content?version=2", map[string]string{"Range": "bytes=0-3", "If-Range": `"abc.3"`})
+		assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+		assert.Equal(t, "private, no-cache", resp.Header.Get("Cache-Control"))
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		holds := func(want bool) any {
+			return mock.MatchedBy(func(pre service.Precondition) bool { return pre != nil && pre(doc) == want })
+		}
+		mockSvc.On("Delete", mock.Anything, doc.ID, holds(false)).Return(service.ErrPreconditionFailed).Once()
+		mockSvc.On("Delete", mock.Anything, doc.ID, holds(true)).Return(nil).Once()
+
+		resp := send(http.MethodDelete, "/documents/"+doc.ID, map[string]string{"If-Match": `"2"`})
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+		resp = send(http.MethodDelete, "/documents/"+doc.ID, map[string]string{"If-Unmodified-Since": lastMod})
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		mockSvc.AssertExpectations(t)
+	})
+}
+
+func TestBatchDeleteDocuments(t *testing.T) {
+	idA, idB := uuid.NewString(), uuid.NewString()
+
+	tests := []struct {
+		name       string
+		body       string
+		setupMock  func(m *serviceMocks.MockDocumentService)
+		wantStatus int
+		wantCode   string
+		wantBody   batchDeleteResponse
+	}{
+		{
+			name: "all deleted",
+			body: `{"ids":["` + idA + `","` + idB + `"]}`,
+			setupMock: func(m *serviceMocks.MockDocumentService) {
+				m.On("DeleteMany", mock.Anything, []string{idA, idB}).Return([]service.DeleteResult{
+					{ID: idA, Status: service.DeleteStatusDeleted},
+					{ID: idB, Status: service.DeleteStatusDeleted},
+				}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantBody: batchDeleteResponse{
+				Results: []batchDeleteItem{{ID: idA, Status: "deleted"}, {ID: idB, Status: "deleted"}},
+				Deleted: 2,
+			},
+		},
+		{
+			name: "mixed results",
+			body: `{"ids":["` + idA + `","` + idB + `","bad","x"]}`,
+			setupMock: func(m *serviceMocks.MockDocumentService) {
+				m.On("DeleteMany", mock.Anything, []string{idA, idB, "bad", "x"}).Return([]service.DeleteResult{
+					{ID: idA, Status: service.DeleteStatusNotFound},
+					{ID: idB, Status: service.DeleteStatusFailed, Err: errors.New("delete storage: boom")},
+					{ID: "bad", Status: service.DeleteStatusFailed, Err: service.ErrInvalidID},
+					{ID: "x", Status: service.DeleteStatusDeleted},
+				}, nil).Once()
+			},
+			wantStatus: http.StatusMultiStatus,
+			wantBody: batchDeleteResponse{
+				Results: []batchDeleteItem{
+					{ID: idA, Status: "not_found"},
+					{ID: idB, Status: "failed", Error: &errorEnvelope{Code: "INTERNAL_ERROR", Message: "internal server error"}},
+					{ID: "bad", Status: "failed", Error: &errorEnvelope{Code: "INVALID_ID", Message: "invalid id format"}},
+					{ID: "x", Status: "deleted"},
+				},
+				Deleted:  1,
+				NotFound: 1,
+				Failed:   2,
+			},
+		},
+		{
+			name:       "malformed body",
+			body:       `{"ids":`,
+			setupMock:  func(m *serviceMocks.MockDocumentService) {},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "INVALID_BODY",
+		},
+		{
+			name:       "no ids",
+			body:       `{"ids":[]}`,
+			setupMock:  func(m *serviceMocks.MockDocumentService) {},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "IDS_REQUIRED",
+		},
+		{
+			name: "too many ids",
+			body: `{"ids":["` + idA + `"]}`,
+			setupMock: func(m *serviceMocks.MockDocumentService) {
+				m.On("DeleteMany", mock.Anything, []string{idA}).Return(nil, service.ErrTooManyIDs).Once()
+			},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "TOO_MANY_IDS",
+		},
+		{
+			name: "lookup error",
+			body: `{"ids":["` + idA + `"]}`,
+			setupMock: func(m *serviceMocks.MockDocumentService) {
+				m.On("DeleteMany", mock.Anything, []string{idA}).Return(nil, errors.New("db down")).Once()
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   "INTERNAL_ERROR",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(serviceMocks.MockDocumentService)
+			tt.setupMock(mockSvc)
+			app := fiber.New()
+			RegisterRoutes(app, nil, mockSvc)
+
+			req := httptest.NewRequest(http.MethodPost, "/documents:batchDelete", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantCode != "" {
+				var res errorPayload
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+				assert.Equal(t, tt.wantCode, res.Error.Code)
+			} else {
+				var res batchDeleteResponse
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+				assert.Equal(t, tt.wantBody, res)
+			}
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDownloadArchive(t *testing.T) {
+	id := uuid.NewString()
+	archive := &service.Archive{Documents: []model.Document{{ID: id}}}
+	size := func(n int64) *int64 { return &n }
+
+	tests := []struct {
+		name       string
+		body       string
+		setupMock  func(m *serviceMocks.MockDocumentService)
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name: "by ids",
+			body: `{"ids":["` + id + `"]}`,
+			setupMock: func(m *serviceMocks.MockDocumentService) {
+				m.On("PrepareArchive", mock.Anything, service.ArchiveSelection{IDs: []string{id}}).Return(archive, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "by filter",
+			body: `{"filter":{"content_type":"image/*","created_after":"2024-01-01","min_size":10},"sort":"name"}`,
+			setupMock: func(m *serviceMocks.MockDocumentService) {
+				m.On("PrepareArchive", mock.Anything, service.ArchiveSelection{
+					Filter: service.DocumentFilter{
+						ContentType: "image/*",
+						CreatedFrom: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+						MinSize:     size(10),
+					},
+					Sort: service.Sort{Field: repository.SortName, Asc: true},
+				}).Return(archive, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "empty body selects everything",
+			setupMock: func(m *serviceMocks.MockDocumentService) {
+				m.On("PrepareArchive", mock.Anything, service.ArchiveSelection{}).Return(archive, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "malformed body",
+			body:       `[`,
+			setupMock:  func(m *serviceMocks.MockDocumentService) {},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "INVALID_BODY",
+		},
+		{
+			name:       "ids with filter",
+			body:       `{"ids":["` + id + `"],"sort":"name"}`,
+			setupMock:  func(m *serviceMocks.MockDocumentService) {},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "INVALID_BODY",
+		},
+		{
+			name:       "invalid filter",
+			body:       `{"filter":{"min_size":-1}}`,
+			setupMock:  func(m *serviceMocks.MockDocumentService) {},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "INVALID_MIN_SIZE",
+		},
+		{
+			name: "invalid id",
+			body: `{"ids":["nope"]}`,
+			setupMock: func(m *serviceMocks.MockDocumentService) {
+				m.On("PrepareArchive", mock.Anything, mock.Anything).Return(nil, service.ErrInvalidID).Once()
+			},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "INVALID_ID",
+		},
+		{
+			name: "too many documents",
+			body: `{}`,
+			setupMock: func(m *serviceMocks.MockDocumentService) {
+				m.On("PrepareArchive", mock.Anything, mock.Anything).Return(nil, service.ErrTooManyDocuments).Once()
+			},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "TOO_MANY_DOCUMENTS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(serviceMocks.MockDocumentService)
+			tt.setupMock(mockSvc)
+			mockSvc.On("WriteArchive", mock.Anything, archive, mock.Anything).Run(func(args mock.Arguments) {
+				args.Get(2).(io.Writer).Write([]byte("PK zip bytes"))
+			}).Return(nil).Maybe()
+			app := fiber.New()
+			RegisterRoutes(app, nil, mockSvc)
+
+			req := httptest.NewRequest(http.MethodPost, "/documents/archive", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantCode != "" {
+				var res errorPayload
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+				assert.Equal(t, tt.wantCode, res.Error.Code)
+			} else {
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.Equal(t, "PK zip bytes", string(body))
+				assert.Equal(t, "application/zip", resp.Header.Get("Content-Type"))
+				assert.Regexp(t, `^attachment; filename="documents-\d{8}T\d{6}Z\.zip"$`, resp.Header.Get("Content-Disposition"))
+			}
+			mockSvc.AssertExpectations(t)
+		})
+	}
+
+	t.Run("write error aborts the response", func(t *testing.T) {
+		mockSvc := new(serviceMocks.MockDocumentService)
+		mockSvc.On("PrepareArchive", mock.Anything, mock.Anything).Return(archive, nil).Once()
+		mockSvc.On("WriteArchive", mock.Anything, archive, mock.Anything).Run(func(args mock.Arguments) {
+			args.Get(2).(io.Writer).Write([]byte("PK partial"))
+		}).Return(errors.New("storage went away")).Once()
+		app := fiber.New()
+		app.Post("/documents/archive", DownloadArchive(mockSvc))
+
+		req := httptest.NewRequest(http.MethodPost, "/documents/archive", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err == nil {
+			_, err = io.ReadAll(resp.Body)
+		}
+		assert.Error(t, err)
+	})
+}
+
+func TestImportArchive(t *testing.T) {
+	doc := &model.Document{ID: uuid.NewString(), Name: "a.txt", Metadata: map[string]string{"archive": "legacy.zip", "archive_path": "docs/a.txt"}}
+	size := int64(len("content of legacy.zip"))
+
+	tests := []struct {
+		name       string
+		files      []string
+		setupMock  func(m *serviceMocks.MockDocumentService)
+		wantStatus int
+		wantCode   string
+		wantBody   importResponse
+	}{
+		{
+			name:  "all created",
+			files: []string{"legacy.zip"},
+			setupMock: func(m *serviceMocks.MockDocumentService) {
+				m.On("ImportArchive", mock.Anything, mock.Anything, size, "legacy.zip").Return([]service.ImportResult{
+					{Path: "docs/a.txt", Status: service.ImportStatusCreated, Document: doc},
+				}, nil).Once()
+			},
+			wantStatus: http.StatusCreated,
+			wantBody: importResponse{
+				Results: []importItem{{Path: "docs/a.txt", Status: "created", Document: doc}},
+				Created: 1,
+			},
+		},
+		{
+			name:  "rejected and failed entries",
+			files: []string{"legacy.zip"},
+			setupMock: func(m *serviceMocks.MockDocumentService) {
+				m.On("ImportArchive", mock.Anything, mock.Anything, size, "legacy.zip").Return([]service.ImportResult{
+					{Path: "docs/a.txt", Status: service.ImportStatusCreated, Document: doc},
+					{Path: "../etc/passwd", Status: service.ImportStatusRejected, Err: service.ErrUnsafePath},
+					{Path: "b.txt", Status: service.ImportStatusFailed, Err: errors.New("upload to storage: boom")},
+				}, nil).Once()
+			},
+			wantStatus: http.StatusMultiStatus,
+			wantBody: importResponse{
+				Results: []importItem{
+					{Path: "docs/a.txt", Status: "created", Document: doc},
+					{Path: "../etc/passwd", Status: "rejected", Error: &errorEnvelope{Code: "UNSAFE_PATH", Message: "unsafe path"}},
+					{Path: "b.txt", Status: "failed", Error: &errorEnvelope{Code: "INTERNAL_ERROR", Message: "internal server error"}},
+				},
+				Created:  1,
+				Failed:   1,
+				Rejected: 1,
+			},
+		},
+		{
+			name:  "stopped midway",
+			files: []string{"legacy.zip"},
+			setupMock: func(m *serviceMocks.MockDocumentService) {
+				m.On("ImportArchive", mock.Anything, mock.Anything, size, "legacy.zip").Return([]service.ImportResult{
+					{Path: "docs/a.txt", Status: service.ImportStatusCreated, Document: doc},
+				}, fmt.Errorf("%w: at most 1", service.ErrTooManyEntries)).Once()
+			},
+			wantStatus: http.StatusMultiStatus,
+			wantBody: importResponse{
+				Results: []importItem{{Path: "docs/a.txt", Status: "created", Document: doc}},
+				Created: 1,
+				Error:   &errorEnvelope{Code: "TOO_MANY_ENTRIES", Message: "too many entries: at most 1"},
+			},
+		},
+		{
+			name:  "unsupported format",
+			files: []string{"legacy.zip"},
+			setupMock: func(m *serviceMocks.MockDocumentService) {
+				m.On("ImportArchive", mock.Anything, mock.Anything, size, "legacy.zip").Return(nil, service.ErrUnsupportedArchive).Once()
+			},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "UNSUPPORTED_ARCHIVE",
+		},
+		{
+			name:  "internal error",
+			files: []string{"legacy.zip"},
+			setupMock: func(m *serviceMocks.MockDocumentService) {
+				m.On("ImportArchive", mock.Anything, mock.Anything, size, "legacy.zip").Return(nil, errors.New("boom")).Once()
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   "INTERNAL_ERROR",
+		},
+		{
+			name:       "missing file",
+			setupMock:  func(m *serviceMocks.MockDocumentService) {},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "FILE_REQUIRED",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(serviceMocks.MockDocumentService)
+			tt.setupMock(mockSvc)
+			app := fiber.New()
+			app.Post("/documents/import-archive", ImportArchive(mockSvc))
+
+			body, contentType := multipartFiles(t, tt.files...)
+			req := httptest.NewRequest(http.MethodPost, "/documents/import-archive", body)
+			req.Header.Set("Content-Type", contentType)
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantCode != "" {
+				var res errorPayload
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+				assert.Equal(t, tt.wantCode, res.Error.Code)
+			} else {
+				var res importResponse
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+				assert.Equal(t, tt.wantBody, res)
+			}
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCopyDocument(t *testing.T) {
+	mockSvc := new(serviceMocks.MockDocumentService)
+	app := fiber.New()
+	app.Post("/documents/:id/copy", CopyDocument(mockSvc))
+
+	post := func(id, body string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/documents/"+id+"/copy", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return resp
+	}
+
+	t.Run("without body", func(t *testing.T) {
+		id := uuid.NewString()
+		copied := &model.Document{ID: uuid.NewString(), Name: "a.txt"}
+		mockSvc.On("Copy", mock.Anything, id, service.CopyOptions{}).Return(copied, nil).Once()
+
+		resp := post(id, "")
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		var result model.Document
+		json.NewDecoder(resp.Body).Decode(&result)
+		assert.Equal(t, copied.ID, result.ID)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("with name and metadata", func(t *testing.T) {
+		id := uuid.NewString()
+		opts := service.CopyOptions{Name: "v2.txt", Metadata: map[string]string{"version": "2"}}
+		mockSvc.On("Copy", mock.Anything, id, opts).Return(&model.Document{ID: uuid.NewString()}, nil).Once()
+
+		resp := post(id, `{"name":"v2.txt","metadata":{"version":"2"}}`)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		missing, failing := uuid.NewString(), uuid.NewString()
+		mockSvc.On("Copy", mock.Anything, missing, mock.Anything).Return(nil, service.ErrNotFound).Once()
+		mockSvc.On("Copy", mock.Anything, failing, mock.Anything).Return(nil, errors.New("storage down")).Once()
+
+		for _, tc := range []struct {
+			id, body string
+			status   int
+			code     string
+		}{
+			{"invalid-uuid", "", http.StatusBadRequest, "INVALID_ID"},
+			{uuid.NewString(), "[1]", http.StatusBadRequest, "INVALID_BODY"},
+			{missing, "", http.StatusNotFound, "NOT_FOUND"},
+			{failing, "", http.StatusInternalServerError, "INTERNAL_ERROR"},
+		} {
+			resp := post(tc.id, tc.body)
+			assert.Equal(t, tc.status, resp.StatusCode)
+			var res errorPayload
+			json.NewDecoder(resp.Body).Decode(&res)
+			assert.Equal(t, tc.code, res.Error.Code)
+		}
+		mockSvc.AssertExpectations(t)
+	})
+}
+
+func TestUpdateDocument(t *testing.T) {
+	mockSvc := new(serviceMocks.MockDocumentService)
+	app := fiber.New()
+	app.Patch("/documents/:id", UpdateDocument(mockSvc))
+
+	patch := func(id, body string, header map[string]string) *http.Response {
+		req := httptest.NewRequest(http.MethodPatch, "/documents/"+id, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, _ := app.Test(req)
+		return resp
+	}
+	ptr := func(s string) *string { return &s }
+
+	t.Run("merge patch", func(t *testing.T) {
+		id := uuid.NewString()
+		want := service.DocumentPatch{
+			Name:     ptr("invoice.pdf"),
+			Tags:     &[]string{},
+			Metadata: map[string]*string{"owner": ptr("bob"), "draft": nil},
+		}
+		updated := &model.Document{ID: id, Name: "invoice.pdf", Version: 4}
+		noPrecondition := mock.MatchedBy(func(pre service.Precondition) bool { return pre == nil })
+		mockSvc.On("Update", mock.Anything, id, want, noPrecondition).Return(updated, nil).Once()
+
+		resp := patch(id, `{"name":"invoice.pdf","tags":null,"metadata":{"owner":"bob","draft":null}}`, nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `"4"`, resp.Header.Get("ETag"))
+		var result model.Document
+		json.NewDecoder(resp.Body).Decode(&result)
+		assert.Equal(t, "invoice.pdf", result.Name)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("null metadata clears it", func(t *testing.T) {
+		id := uuid.NewString()
+		mockSvc.On("Update", mock.Anything, id, service.DocumentPatch{ClearMetadata: true}, mock.Anything).Return(&model.Document{ID: id}, nil).Once()
+
+		resp := patch(id, `{"metadata":null}`, map[string]string{"Content-Type": "application/json"})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("If-Match", func(t *testing.T) {
+		id := uuid.NewString()
+		matches := func(versions ...int64) any {
+			return mock.MatchedBy(func(pre service.Precondition) bool {
+				for v := range int64(5) {
+					if pre(&model.Document{Version: v}) != slices.Contains(versions, v) {
+						return false
+					}
+				}
+				return true
+			})
+		}
+		mockSvc.On("Update", mock.Anything, id, mock.Anything, matches(2, 3)).Return(nil, service.ErrPreconditionFailed).Once()
+		mockSvc.On("Update", mock.Anything, id, mock.Anything, matches()).Return(nil, service.ErrPreconditionFailed).Once()
+
+		resp := patch(id, `{"name":"a.txt"}`, map[string]string{"If-Match": `"2", W/"1", "3"`})
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+		var res errorPayload
+		json.NewDecoder(resp.Body).Decode(&res)
+		assert.Equal(t, "PRECONDITION_FAILED", res.Error.Code)
+
+		resp = patch(id, `{"name":"a.txt"}`, map[string]string{"If-Match": `W/"2"`})
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		missing, invalid, busy, failing := uuid.NewString(), uuid.NewString(), uuid.NewString(), uuid.NewString()
+		mockSvc.On("Update", mock.Anything, missing, mock.Anything, mock.Anything).Return(nil, service.ErrNotFound).Once()
+		mockSvc.On("Update", mock.Anything, invalid, mock.Anything, mock.Anything).Return(nil, fmt.Errorf("%w: name must not be empty", service.ErrInvalidPatch)).Once()
+		mockSvc.On("Update", mock.Anything, busy, mock.Anything, mock.Anything).Return(nil, service.ErrConflict).Once()
+		mockSvc.On("Update", mock.Anything, failing, mock.Anything, mock.Anything).Return(nil, errors.New("db down")).Once()
+
+		for _, tc := range []struct {
+			id, body, contentType string
+			status                int
+			code                  string
+		}{
+			{"invalid-uuid", `{}`, "", http.StatusBadRequest, "INVALID_ID"},
+			{uuid.NewString(), `{}`, "text/plain", http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE"},
+			{uuid.NewString(), `[]`, "", http.StatusBadRequest, "INVALID_PATCH"},
+			{uuid.NewString(), `{"size":1}`, "", http.StatusBadRequest, "INVALID_PATCH"},
+			{uuid.NewString(), `{"name":null}`, "", http.StatusBadRequest, "INVALID_PATCH"},
+			{uuid.NewString(), `{"tags":"a"}`, "", http.StatusBadRequest, "INVALID_PATCH"},
+			{uuid.NewString(), `{"metadata":{"a":1}}`, "", http.StatusBadRequest, "INVALID_PATCH"},
+			{missing, `{"name":"a"}`, "", http.StatusNotFound, "NOT_FOUND"},
+			{invalid, `{"name":" "}`, "", http.StatusBadRequest, "INVALID_PATCH"},
+			{busy, `{"name":"a"}`, "", http.StatusConflict, "CONFLICT"},
+			{failing, `{"name":"a"}`, "", http.StatusInternalServerError, "INTERNAL_ERROR"},
+		} {
+			header := map[string]string{}
+			if tc.contentType != "" {
+				header["Content-Type"] = tc.contentType
+			}
+			resp := patch(tc.id, tc.body, header)
+			assert.Equal(t, tc.status, resp.StatusCode, tc.body)
+			var res errorPayload
+			json.NewDecoder(resp.Body).Decode(&res)
+			assert.Equal(t, tc.code, res.Error.Code, tc.body)
+		}
+		mockSvc.AssertExpectations(t)
+	})
+}
+
 func TestRouting(t *testing.T) {
 	app := fiber.New(fiber.Config{
 		ErrorHandler: ErrorHandler(),
@@ -298,3 +1281,252 @@ func TestRouting(t *testing.T) {
 		assert.Equal(t, "METHOD_NOT_ALLOWED", res.Error.Code)
 	})
 }
+
+func TestDownloadDocument(t *testing.T) {
+	mockSvc := new(serviceMocks.MockDocumentService)
+	app := fiber.New()
+	app.Get("/documents/:id/content", DownloadDocument(mockSvc))
+	id := uuid.New().String()
+	doc := &model.Document{ID: id, Filename: uuid.NewString() + ".txt", Name: "report.txt", ContentType: "text/plain", Size: 10}
+
+	tests := []struct {
+		name        string
+		rangeHeader string
+		setup       func()
+		wantStatus  int
+		wantBody    string
+		wantRange   string
+		wantCode    string
+	}{
+		{
+			name: "whole document",
+			setup: func() {
+				mockSvc.On("Download", mock.Anything, id, int64(0), int64(-1)).
+					Return(io.NopCloser(strings.NewReader("0123456789")), doc, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "0123456789",
+		},
+		{
+			name:        "bounded range",
+			rangeHeader: "bytes=2-5",
+			setup: func() {
+				mockSvc.On("Download", mock.Anything, id, int64(2), int64(4)).
+					Return(io.NopCloser(strings.NewReader("2345")), doc, nil).Once()
+			},
+			wantStatus: http.StatusPartialContent,
+			wantBody:   "2345",
+			wantRange:  "bytes 2-5/10",
+		},
+		{
+			name:        "suffix range",
+			rangeHeader: "bytes=-3",
+			setup: func() {
+				mockSvc.On("Download", mock.Anything, id, int64(-3), int64(-1)).
+					Return(io.NopCloser(strings.NewReader("789")), doc, nil).Once()
+			},
+			wantStatus: http.StatusPartialContent,
+			wantBody:   "789",
+			wantRange:  "bytes 7-9/10",
+		},
+		{
+			name:        "unsatisfiable",
+			rangeHeader: "bytes=20-",
+			setup: func() {
+				mockSvc.On("Download", mock.Anything, id, int64(20), int64(-1)).
+					Return(nil, nil, service.ErrInvalidRange).Once()
+			},
+			wantStatus: http.StatusRequestedRangeNotSatisfiable,
+			wantCode:   "RANGE_NOT_SATISFIABLE",
+		},
+		{
+			name: "not found",
+			setup: func() {
+				mockSvc.On("Download", mock.Anything, id, int64(0), int64(-1)).
+					Return(nil, nil, service.ErrNotFound).Once()
+			},
+			wantStatus: http.StatusNotFound,
+			wantCode:   "NOT_FOUND",
+		},
+		{
+			name: "quarantined",
+			setup: func() {
+				mockSvc.On("Download", mock.Anything, id, int64(0), int64(-1)).
+					Return(nil, nil, service.ErrQuarantined).Once()
+			},
+			wantStatus: http.StatusForbidden,
+			wantCode:   "QUARANTINED",
+		},
+		{
+			name: "not available",
+			setup: func() {
+				mockSvc.On("Download", mock.Anything, id, int64(0), int64(-1)).
+					Return(nil, nil, fmt.Errorf("%w: document is pending", service.ErrNotAvailable)).Once()
+			},
+			wantStatus: http.StatusConflict,
+			wantCode:   "NOT_AVAILABLE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+			req := httptest.NewRequest(http.MethodGet, "/documents/"+id+"/content", nil)
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			if tt.wantCode != "" {
+				var res errorPayload
+				json.NewDecoder(resp.Body).Decode(&res)
+				assert.Equal(t, tt.wantCode, res.Error.Code)
+				return
+			}
+			body, _ := io.ReadAll(resp.Body)
+			assert.Equal(t, tt.wantBody, string(body))
+			assert.Equal(t, tt.wantRange, resp.Header.Get("Content-Range"))
+			assert.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+			assert.Equal(t, "bytes", resp.Header.Get("Accept-Ranges"))
+			assert.Equal(t, `attachment; filename="report.txt"; filename*=UTF-8''report.txt`, resp.Header.Get("Content-Disposition"))
+		})
+	}
+	mockSvc.AssertExpectations(t)
+}
+
+func TestAttachment(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"report.pdf", `attachment; filename="report.pdf"; filename*=UTF-8''report.pdf`},
+		{"Q1 report (final).pdf", `attachment; filename="Q1 report (final).pdf"; filename*=UTF-8''Q1%20report%20%28final%29.pdf`},
+		{`say "hi"\.txt`, `attachment; filename="say _hi__.txt"; filename*=UTF-8''say%20%22hi%22%5C.txt`},
+		{"Résumé 履歴書.pdf", `attachment; filename="R_sum_ ___.pdf"; filename*=UTF-8''R%C3%A9sum%C3%A9%20%E5%B1%A5%E6%AD%B4%E6%9B%B8.pdf`},
+		{"a\r\nSet-Cookie: x", `attachment; filename="a__Set-Cookie: x"; filename*=UTF-8''a%0D%0ASet-Cookie%3A%20x`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := attachment(tt.name)
+			assert.Equal(t, tt.want, got)
+
+			// Clients that understand filename* get the name back unchanged.
+			_, params, err := mime.ParseMediaType(got)
+			require.NoError(t, err)
+			assert.Equal(t, tt.name, params["filename"])
+		})
+	}
+}
+
+func TestReviewDocument(t *testing.T) {
+	mockSvc := new(serviceMocks.MockDocumentService)
+	app := fiber.New()
+	RegisterAdminRoutes(app.Group("/admin"), mockSvc)
+
+	released, rejected, clean, missing, busy, failing := uuid.NewString(), uuid.NewString(), uuid.NewString(), uuid.NewString(), uuid.NewString(), uuid.NewString()
+	mockSvc.On("Release", mock.Anything, released).Return(&model.Document{ID: released, Status: model.StatusAvailable}, nil).Once()
+	mockSvc.On("Reject", mock.Anything, rejected).Return(&model.Document{ID: rejected, Status: model.StatusFailed}, nil).Once()
+	mockSvc.On("Release", mock.Anything, clean).Return(nil, service.ErrInvalidTransition).Once()
+	mockSvc.On("Reject", mock.Anything, missing).Return(nil, service.ErrNotFound).Once()
+	mockSvc.On("Release", mock.Anything, busy).Return(nil, service.ErrConflict).Once()
+	mockSvc.On("Reject", mock.Anything, failing).Return(nil, errors.New("db down")).Once()
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantCode   string
+		wantDoc    string
+	}{
+		{"release", "/admin/documents/" + released + "/release", http.StatusOK, "", model.StatusAvailable},
+		{"reject", "/admin/documents/" + rejected + "/reject", http.StatusOK, "", model.StatusFailed},
+		{"not quarantined", "/admin/documents/" + clean + "/release", http.StatusConflict, "NOT_QUARANTINED", ""},
+		{"not found", "/admin/documents/" + missing + "/reject", http.StatusNotFound, "NOT_FOUND", ""},
+		{"concurrent change", "/admin/documents/" + busy + "/release", http.StatusConflict, "CONFLICT", ""},
+		{"service error", "/admin/documents/" + failing + "/reject", http.StatusInternalServerError, "INTERNAL_ERROR", ""},
+		{"invalid id", "/admin/documents/invalid-uuid/release", http.StatusBadRequest, "INVALID_ID", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest(http.MethodPost, tt.path, nil))
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			if tt.wantCode != "" {
+				var res errorPayload
+				json.NewDecoder(resp.Body).Decode(&res)
+				assert.Equal(t, tt.wantCode, res.Error.Code)
+				return
+			}
+			var doc model.Document
+			json.NewDecoder(resp.Body).Decode(&doc)
+			assert.Equal(t, tt.wantDoc, doc.Status)
+		})
+	}
+	mockSvc.AssertExpectations(t)
+}
+
+func TestPresignDocumentURL(t *testing.T) {
+	mockSvc := new(serviceMocks.MockDocumentService)
+	app := fiber.New()
+	app.Get("/documents/:id/url", PresignDocumentURL(mockSvc))
+	id := uuid.New().String()
+
+	t.Run("success", func(t *testing.T) {
+		mockSvc.On("PresignURL", mock.Anything, id, time.Hour).Return("https://minio/obj?sig", nil).Once()
+
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/documents/"+id+"/url?expiry=1h", nil))
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var res presignedURL
+		json.NewDecoder(resp.Body).Decode(&res)
+		assert.Equal(t, "https://minio/obj?sig", res.URL)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), res.ExpiresAt, time.Minute)
+	})
+
+	t.Run("invalid expiry", func(t *testing.T) {
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/documents/"+id+"/url?expiry=1000h", nil))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		var res errorPayload
+		json.NewDecoder(resp.Body).Decode(&res)
+		assert.Equal(t, "INVALID_EXPIRY", res.Error.Code)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		mockSvc.On("PresignURL", mock.Anything, id, 15*time.Minute).Return("", service.ErrPresignUnsupported).Once()
+
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/documents/"+id+"/url", nil))
+		assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+		var res errorPayload
+		json.NewDecoder(resp.Body).Decode(&res)
+		assert.Equal(t, "PRESIGN_UNSUPPORTED", res.Error.Code)
+	})
+	mockSvc.AssertExpectations(t)
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header     string
+		wantOffset int64
+		wantLength int64
+		wantOK     bool
+	}{
+		{header: "", wantOffset: 0, wantLength: -1},
+		{header: "bytes=0-0", wantOffset: 0, wantLength: 1, wantOK: true},
+		{header: "bytes=10-", wantOffset: 10, wantLength: -1, wantOK: true},
+		{header: "bytes=-5", wantOffset: -5, wantLength: -1, wantOK: true},
+		{header: "bytes=5-2", wantOffset: 0, wantLength: -1},
+		{header: "bytes=0-1,4-5", wantOffset: 0, wantLength: -1},
+		{header: "items=0-1", wantOffset: 0, wantLength: -1},
+		{header: "bytes=-0", wantOffset: 0, wantLength: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			offset, length, ok := parseRange(tt.header)
+			assert.Equal(t, tt.wantOffset, offset)
+			assert.Equal(t, tt.wantLength, length)
+			assert.Equal(t, tt.wantOK, ok)
+		})
+	}
+}
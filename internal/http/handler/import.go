@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"docapi/internal/model"
+	"docapi/internal/service"
+)
+
+// importItem is the outcome for one file of an imported archive. Document is set when Status is
+// "created"; Error otherwise.
+type importItem struct {
+	Path     string          `json:"path" example:"legacy/2019/report.pdf"`
+	Status   string          `json:"status" enums:"created,failed,rejected"`
+	Document *model.Document `json:"document,omitempty"`
+	Error    *errorEnvelope  `json:"error,omitempty"`
+}
+
+// importResponse reports an archive import. Error is set when the import stopped before the end
+// of the archive; the results then cover the files handled until then.
+type importResponse struct {
+	Results  []importItem   `json:"results"`
+	Created  int            `json:"created"`
+	Failed   int            `json:"failed"`
+	Rejected int            `json:"rejected"`
+	Error    *errorEnvelope `json:"error,omitempty"`
+}
+
+// importError maps an archive import error to an error code, or returns nil for errors that are
+// not the client's fault.
+func importError(err error) *errorEnvelope {
+	codes := []struct {
+		err  error
+		code string
+	}{
+		{service.ErrUnsupportedArchive, "UNSUPPORTED_ARCHIVE"},
+		{service.ErrInvalidArchive, "INVALID_ARCHIVE"},
+		{service.ErrTooManyEntries, "TOO_MANY_ENTRIES"},
+		{service.ErrArchiveTooLarge, "ARCHIVE_TOO_LARGE"},
+		{service.ErrEntryTooLarge, "ENTRY_TOO_LARGE"},
+		{service.ErrRatioExceeded, "COMPRESSION_RATIO_EXCEEDED"},
+		{service.ErrUnsafePath, "UNSAFE_PATH"},
+		{service.ErrNotRegular, "NOT_REGULAR_FILE"},
+	}
+	for _, c := range codes {
+		if errors.Is(err, c.err) {
+			return &errorEnvelope{Code: c.code, Message: err.Error()}
+		}
+	}
+	return nil
+}
+
+// ImportArchive handles creating documents from the files of an uploaded archive.
+// @Summary Import documents from an archive
+// @Description Create one document per file of a ZIP, tar or tar.gz archive, named after the file, with the archive's name and
+// @Description the file's relative path in its metadata ("archive", "archive_path"). Directories are skipped. Files with absolute
+// @Description or parent-relative paths, links and special files, and files over the size or compression ratio limits are
+// @Description rejected and reported; the import goes on. Exceeding the entry count, the total size or, for tar.gz, the stream
+// @Description compression ratio stops the import: before any file was handled the response is 400, otherwise 207 with "error"
+// @Description set and the documents created so far kept. The response is 201 when every file was created and 207 otherwise.
+// @Tags documents
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "ZIP, tar or tar.gz archive"
+// @Success 201 {object} importResponse
+// @Success 207 {object} importResponse
+// @Failure 400 {object} errorPayload
+// @Failure 500 {object} errorPayload
+// @Router /documents/import-archive [post]
+func ImportArchive(docSvc service.DocumentService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return writeError(c, fiber.StatusBadRequest, "FILE_REQUIRED", "file is required")
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return writeError(c, fiber.StatusBadRequest, "FILE_OPEN_ERROR", "cannot open uploaded file")
+		}
+		defer f.Close()
+
+		results, err := docSvc.ImportArchive(c.UserContext(), f, fh.Size, fh.Filename)
+		res := importResponse{Results: make([]importItem, len(results))}
+		if err != nil {
+			res.Error = importError(err)
+			if res.Error == nil {
+				return writeError(c, fiber.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+			}
+			if len(results) == 0 {
+				return writeError(c, fiber.StatusBadRequest, res.Error.Code, res.Error.Message)
+			}
+		}
+
+		for i, r := range results {
+			item := importItem{Path: r.Path, Status: string(r.Status)}
+			switch r.Status {
+			case service.ImportStatusCreated:
+				res.Created++
+				item.Document = r.Document
+			case service.ImportStatusRejected:
+				res.Rejected++
+				item.Error = importError(r.Err)
+			default:
+				res.Failed++
+				item.Error = importError(r.Err)
+			}
+			if item.Error == nil && errors.Is(r.Err, service.ErrScanFailed) {
+				item.Error = &scanUnavailable
+			}
+			if item.Error == nil && r.Status != service.ImportStatusCreated {
+				item.Error = &errorEnvelope{Code: "INTERNAL_ERROR", Message: "internal server error"}
+			}
+			res.Results[i] = item
+		}
+
+		status := fiber.StatusCreated
+		if res.Error != nil || res.Created < len(results) {
+			status = fiber.StatusMultiStatus
+		}
+		return c.Status(status).JSON(res)
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+
+	"docapi/internal/service"
+)
+
+// maxNameFilter bounds the filename substring filter.
+const maxNameFilter = 255
+
+// maxListLimit bounds the page size of GET /documents.
+const maxListLimit = 1000
+
+// contentTypePattern accepts "type/subtype" and "type/*" made of RFC 6838 name characters.
+var contentTypePattern = regexp.MustCompile(`^(?i)[a-z0-9][a-z0-9!#$&^_.+-]*/(\*|[a-z0-9][a-z0-9!#$&^_.+-]*)$`)
+
+// paramError is a rejected query parameter, reported as a 400 with its own error code.
+type paramError struct {
+	code    string
+	message string
+}
+
+func invalidParam(code, format string, args ...any) *paramError {
+	return &paramError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// parseListQuery validates the query parameters of GET /documents.
+func parseListQuery(c *fiber.Ctx) (p service.ListParams, perr *paramError) {
+	var err error
+	if p.Limit, err = strconv.Atoi(c.Query("limit", "10")); err != nil {
+		return p, invalidParam("INVALID_LIMIT", "invalid limit")
+	}
+	if p.Limit > maxListLimit {
+		return p, invalidParam("INVALID_LIMIT", "limit must not exceed %d", maxListLimit)
+	}
+	if p.Offset, err = strconv.Atoi(c.Query("offset", "0")); err != nil {
+		return p, invalidParam("INVALID_OFFSET", "invalid offset")
+	}
+	p.Cursor = c.Query("cursor")
+	if p.Cursor != "" && p.Offset != 0 {
+		return p, invalidParam("INVALID_OFFSET", "offset cannot be combined with cursor")
+	}
+	p.WithTotal = p.Cursor == ""
+	if v := c.Query("total"); v != "" {
+		if p.WithTotal, err = strconv.ParseBool(v); err != nil {
+			return p, invalidParam("INVALID_TOTAL", "invalid total")
+		}
+	}
+
+	p.Filter, p.Sort, perr = parseFilterParams(func(key string) string { return c.Query(key) })
+	return p, perr
+}
+
+// parseFilterParams validates the filter and sort parameters shared by listings and archives;
+// get returns a parameter's value or "" when it is absent.
+func parseFilterParams(get func(key string) string) (f service.DocumentFilter, s service.Sort, perr *paramError) {
+	var err error
+	if v := get("sort"); v != "" {
+		if s, err = service.ParseSort(v); err != nil {
+			fields := make([]string, len(service.SortFields))
+			for i, field := range service.SortFields {
+				fields[i] = string(field)
+			}
+			return f, s, invalidParam("INVALID_SORT", "sort must be one of %s, optionally prefixed with -", strings.Join(fields, ", "))
+		}
+	}
+
+	if v := get("content_type"); v != "" {
+		if !contentTypePattern.MatchString(v) {
+			return f, s, invalidParam("INVALID_CONTENT_TYPE", "content_type must be a media type like application/pdf or a wildcard like image/*")
+		}
+		f.ContentType = v
+	}
+	if f.CreatedFrom, err = parseTimeParam(get("created_after")); err != nil {
+		return f, s, invalidParam("INVALID_CREATED_AFTER", "created_after must be an RFC 3339 time or YYYY-MM-DD date")
+	}
+	if f.CreatedTo, err = parseTimeParam(get("created_before")); err != nil {
+		return f, s, invalidParam("INVALID_CREATED_BEFORE", "created_before must be an RFC 3339 time or YYYY-MM-DD date")
+	}
+	if !f.CreatedFrom.IsZero() && !f.CreatedTo.IsZero() && !f.CreatedFrom.Before(f.CreatedTo) {
+		return f, s, invalidParam("INVALID_DATE_RANGE", "created_after must be before created_before")
+	}
+	if f.MinSize, err = parseSizeParam(get("min_size")); err != nil {
+		return f, s, invalidParam("INVALID_MIN_SIZE", "min_size must be a non-negative number of bytes")
+	}
+	if f.MaxSize, err = parseSizeParam(get("max_size")); err != nil {
+		return f, s, invalidParam("INVALID_MAX_SIZE", "max_size must be a non-negative number of bytes")
+	}
+	if f.MinSize != nil && f.MaxSize != nil && *f.MinSize > *f.MaxSize {
+		return f, s, invalidParam("INVALID_SIZE_RANGE", "min_size must not exceed max_size")
+	}
+	if v := get("name"); v != "" {
+		if utf8.RuneCountInString(v) > maxNameFilter || !utf8.ValidString(v) {
+			return f, s, invalidParam("INVALID_NAME", "name must be valid UTF-8 of at most %d characters", maxNameFilter)
+		}
+		f.Name = v
+	}
+	if v := get("status"); v != "" {
+		for _, status := range strings.Split(v, ",") {
+			if !slices.Contains(service.Statuses, status) {
+				return f, s, invalidParam("INVALID_STATUS", "status must be one or more of %s, separated by commas", strings.Join(service.Statuses, ", "))
+			}
+			f.Statuses = append(f.Statuses, status)
+		}
+	}
+	return f, s, nil
+}
+
+// parseTimeParam parses an RFC 3339 time or a date, taken as midnight UTC. Empty means unset.
+func parseTimeParam(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, v)
+}
+
+// parseSizeParam parses a byte count. Empty means unset.
+func parseSizeParam(v string) (*int64, error) {
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid size %q", v)
+	}
+	return &n, nil
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"docapi/internal/health"
+)
+
+// ReadinessProbe handles the readiness probe request.
+// @Summary Readiness probe
+// @Description Check that the components the API depends on (database, object storage bucket and, when enabled, job
+// @Description workers and malware scanner) are usable, each within its own timeout. Results are cached for a short time.
+// @Description Responds 503 with the same body if any component is down, so that no traffic is routed to this instance.
+// @Description During shutdown it responds 503 with status draining, without running the checks.
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Failure 503 {object} health.Report
+// @Router /readyz [get]
+func ReadinessProbe(checker *health.Checker) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report := checker.Check(c.UserContext())
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		if !report.Up() {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(report)
+		}
+		return c.JSON(report)
+	}
+}
+
+// StartupProbe handles the startup probe request.
+// @Summary Startup probe
+// @Description Succeeds once the API finished initializing and its dependencies were up at least once. It keeps
+// @Description succeeding afterwards: dependency outages are reported by the readiness probe.
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /startupz [get]
+func StartupProbe(checker *health.Checker) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		if !checker.Started(c.UserContext()) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"status": "starting"})
+		}
+		return c.JSON(fiber.Map{"status": "started"})
+	}
+}
+
+// RegisterProbeRoutes attaches the startup, liveness and readiness probes. Liveness only reports
+// that the process serves requests, so that an outage of a dependency doesn't get the API
+// restarted; readiness takes the instance out of rotation instead.
+func RegisterProbeRoutes(app *fiber.App, checker *health.Checker) {
+	app.Get("/startupz", StartupProbe(checker))
+	app.Get("/livez", LivenessProbe())
+	app.Get("/readyz", ReadinessProbe(checker))
+}
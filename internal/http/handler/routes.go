@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,13 +15,13 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	_ "docapi/docs"
-	_ "docapi/internal/model"
+	"docapi/internal/model"
 	"docapi/internal/service"
 )
 
 // HealthCheck handles the health check request.
 // @Summary Health check
-// @Description Check database connectivity
+// @Description Check database connectivity. /readyz checks every dependency.
 // @Tags health
 // @Produce json
 // @Success 200 {object} map[string]string
@@ -38,9 +40,10 @@ func HealthCheck(db *sql.DB) fiber.Handler {
 
 // LivenessProbe handles the liveness probe request.
 // @Summary Liveness probe
-// @Description Simple liveness probe
+// @Description Simple liveness probe: succeeds while the process serves requests, whatever the state of its dependencies
 // @Tags health
 // @Success 200 {string} string "OK"
+// @Router /livez [get]
 // @Router /healthz [get]
 func LivenessProbe() fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -50,30 +53,40 @@ func LivenessProbe() fiber.Handler {
 
 // ListDocuments handles listing documents.
 // @Summary List documents
-// @Description Get a list of documents with pagination
+// @Description Get a page of documents, filtered and sorted (newest first by default). Pages are selected by offset, or by the
+// @Description cursor returned as next_cursor by the previous page, which neither skips nor repeats documents when others are
+// @Description added or removed meanwhile. A cursor is only valid with the sort it was issued for.
+// @Description The total count is included by default for offset pages and on request for cursor pages.
 // @Tags documents
 // @Produce json
-// @Param limit query int false "Limit" default(10)
+// @Param limit query int false "Page size, at most 1000" default(10) maximum(1000)
 // @Param offset query int false "Offset" default(0)
-// @Success 200 {array} model.Document
+// @Param cursor query string false "Cursor from a previous page's next_cursor; cannot be combined with offset"
+// @Param total query bool false "Include the total count (default true without cursor, false with cursor)"
+// @Param sort query string false "Sort field: created_at, name, size or content_type; prefix with - for descending" default(-created_at)
+// @Param content_type query string false "Media type, exact (application/pdf) or wildcard (image/*); parameters and case are ignored"
+// @Param created_after query string false "Created at or after (RFC 3339 or YYYY-MM-DD, UTC)"
+// @Param created_before query string false "Created before (RFC 3339 or YYYY-MM-DD, UTC)"
+// @Param min_size query int false "Minimum size in bytes"
+// @Param max_size query int false "Maximum size in bytes"
+// @Param name query string false "Case-insensitive substring of the original filename"
+// @Param status query string false "Processing statuses, separated by commas: available, quarantined or failed"
+// @Success 200 {object} service.DocumentListResult
 // @Failure 400 {object} errorPayload
 // @Failure 500 {object} errorPayload
 // @Router /documents [get]
 func ListDocuments(docSvc service.DocumentService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		limitStr := c.Query("limit", "10")
-		offsetStr := c.Query("offset", "0")
-		limit, err := strconv.Atoi(limitStr)
-		if err != nil {
-			return writeError(c, fiber.StatusBadRequest, "INVALID_LIMIT", "invalid limit")
-		}
-		offset, err := strconv.Atoi(offsetStr)
-		if err != nil {
-			return writeError(c, fiber.StatusBadRequest, "INVALID_OFFSET", "invalid offset")
+		params, perr := parseListQuery(c)
+		if perr != nil {
+			return writeError(c, fiber.StatusBadRequest, perr.code, perr.message)
 		}
 
-		res, err := docSvc.List(c.UserContext(), limit, offset)
+		res, err := docSvc.List(c.UserContext(), params)
 		if err != nil {
+			if errors.Is(err, service.ErrInvalidCursor) {
+				return writeError(c, fiber.StatusBadRequest, "INVALID_CURSOR", "invalid cursor")
+			}
 			return writeError(c, fiber.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
 		}
 		return c.JSON(res)
@@ -81,36 +94,54 @@ func ListDocuments(docSvc service.DocumentService) fiber.Handler {
 }
 
 // UploadDocument handles document upload.
-// @Summary Upload document
-// @Description Upload a new document
+// @Summary Upload documents
+// @Description Upload one or more documents as "file" parts. A single file without atomic is answered with the created document.
+// @Description Otherwise the files are uploaded in parallel and the response lists a result per file, in request order: 201 when
+// @Description every file was created and 207 otherwise. With atomic=true the first failure aborts the batch and removes every
+// @Description document created so far; the other files are then reported as rolled_back.
+// @Description Created documents are processed before they are returned: their status is available, or quarantined when malware
+// @Description was found.
 // @Tags documents
 // @Accept multipart/form-data
 // @Produce json
-// @Param file formData file true "Document file"
-// @Success 201 {object} model.Document
+// @Param file formData file true "Document file; repeat the part to upload several files"
+// @Param atomic query bool false "Create all files or none"
+// @Success 201 {object} model.Document "single file"
+// @Success 207 {object} uploadResponse "several files, or atomic"
 // @Failure 400 {object} errorPayload
 // @Failure 500 {object} errorPayload
+// @Failure 503 {object} errorPayload "content could not be scanned for malware"
 // @Router /documents [post]
 func UploadDocument(docSvc service.DocumentService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		fh, err := c.FormFile("file")
-		if err != nil {
+		form, err := c.MultipartForm()
+		if err != nil || len(form.File["file"]) == 0 {
 			return writeError(c, fiber.StatusBadRequest, "FILE_REQUIRED", "file is required")
 		}
+		files := form.File["file"]
+
+		if v := c.Query("atomic"); v != "" || len(files) > 1 {
+			atomic := false
+			if v != "" {
+				if atomic, err = strconv.ParseBool(v); err != nil {
+					return writeError(c, fiber.StatusBadRequest, "INVALID_ATOMIC", "atomic must be true or false")
+				}
+			}
+			return uploadMany(c, docSvc, files, atomic)
+		}
 
+		fh := files[0]
 		f, err := fh.Open()
 		if err != nil {
 			return writeError(c, fiber.StatusBadRequest, "FILE_OPEN_ERROR", "cannot open uploaded file")
 		}
 		defer f.Close()
 
-		ct := fh.Header.Get("Content-Type")
-		if ct == "" {
-			ct = "application/octet-stream"
-		}
-
-		doc, err := docSvc.Upload(c.UserContext(), f, fh.Filename, ct, fh.Size)
+		doc, err := docSvc.Upload(c.UserContext(), f, fh.Filename, partContentType(fh), fh.Size)
 		if err != nil {
+			if errors.Is(err, service.ErrScanFailed) {
+				return writeError(c, fiber.StatusServiceUnavailable, scanUnavailable.Code, scanUnavailable.Message)
+			}
 			return writeError(c, fiber.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
 		}
 		return c.Status(fiber.StatusCreated).JSON(doc)
@@ -119,13 +150,20 @@ func UploadDocument(docSvc service.DocumentService) fiber.Handler {
 
 // GetDocument handles getting a document by ID.
 // @Summary Get document
-// @Description Get a document by ID
+// @Description Get a document by ID. The ETag is the document's version, for use in If-Match when updating or deleting it.
+// @Description Conditional requests with If-None-Match or If-Modified-Since get 304 while the document is unchanged.
 // @Tags documents
 // @Produce json
 // @Param id path string true "Document ID"
+// @Param If-None-Match header string false "ETag of a cached version"
+// @Param If-Modified-Since header string false "Last-Modified of a cached version"
 // @Success 200 {object} model.Document
+// @Header 200 {string} ETag "Version of the document"
+// @Header 200 {string} Last-Modified "When the document was last changed"
+// @Success 304 "Not Modified"
 // @Failure 400 {object} errorPayload
 // @Failure 404 {object} errorPayload
+// @Failure 412 {object} errorPayload
 // @Failure 500 {object} errorPayload
 // @Router /documents/{id} [get]
 func GetDocument(docSvc service.DocumentService) fiber.Handler {
@@ -137,23 +175,33 @@ func GetDocument(docSvc service.DocumentService) fiber.Handler {
 		doc, err := docSvc.Get(c.UserContext(), id)
 		if err != nil {
 			// Translate not found
-			if errors.Is(err, sql.ErrNoRows) {
+			if isNotFound(err) {
 				return writeError(c, fiber.StatusNotFound, "NOT_FOUND", "document not found")
 			}
 			return writeError(c, fiber.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
 		}
+		etag, modified := documentETag(doc), lastModified(doc)
+		setValidators(c, etag, modified, cacheRevalidate)
+		if status := evaluateRead(c, etag, modified); status != 0 {
+			return writeConditional(c, status)
+		}
 		return c.JSON(doc)
 	}
 }
 
 // DeleteDocument handles deleting a document by ID.
 // @Summary Delete document
-// @Description Delete a document by ID
+// @Description Delete a document by ID. With If-Match or If-Unmodified-Since, the document is only deleted while it is at the
+// @Description given version; otherwise the response is 412.
 // @Tags documents
 // @Param id path string true "Document ID"
+// @Param If-Match header string false "ETag of the version to delete"
+// @Param If-Unmodified-Since header string false "Last-Modified of the version to delete"
 // @Success 204 "No Content"
 // @Failure 400 {object} errorPayload
 // @Failure 404 {object} errorPayload
+// @Failure 409 {object} errorPayload "document is being changed concurrently"
+// @Failure 412 {object} errorPayload
 // @Failure 500 {object} errorPayload
 // @Router /documents/{id} [delete]
 func DeleteDocument(docSvc service.DocumentService) fiber.Handler {
@@ -162,16 +210,205 @@ func DeleteDocument(docSvc service.DocumentService) fiber.Handler {
 		if _, err := uuid.Parse(id); err != nil {
 			return writeError(c, fiber.StatusBadRequest, "INVALID_ID", "invalid id format")
 		}
-		if err := docSvc.Delete(c.UserContext(), id); err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
+		if err := docSvc.Delete(c.UserContext(), id, writePrecondition(c)); err != nil {
+			if isNotFound(err) {
 				return writeError(c, fiber.StatusNotFound, "NOT_FOUND", "document not found")
 			}
+			if errors.Is(err, service.ErrPreconditionFailed) {
+				return writeError(c, fiber.StatusPreconditionFailed, "PRECONDITION_FAILED", "document does not match the request's preconditions")
+			}
+			if errors.Is(err, service.ErrConflict) {
+				return writeError(c, fiber.StatusConflict, "CONFLICT", "document is being changed concurrently, retry")
+			}
 			return writeError(c, fiber.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
 		}
 		return c.SendStatus(fiber.StatusNoContent)
 	}
 }
 
+// DownloadDocument streams a document's content.
+// @Summary Download document
+// @Description Download a document's content. A single byte range may be requested with the Range header, guarded by If-Range.
+// @Description The ETag combines the stored object's ETag with the document's version; conditional requests with If-None-Match or
+// @Description If-Modified-Since get 304 while it is unchanged. Responses are cached as immutable when "version" names the
+// @Description document's current version, and must be revalidated otherwise.
+// @Tags documents
+// @Produce octet-stream
+// @Param id path string true "Document ID"
+// @Param version query int false "Current version of the document, to make the response cacheable as immutable"
+// @Param Range header string false "Byte range, e.g. bytes=0-1023"
+// @Param If-Range header string false "ETag or Last-Modified the range is valid for"
+// @Param If-None-Match header string false "ETag of a cached version"
+// @Param If-Modified-Since header string false "Last-Modified of a cached version"
+// @Success 200 {file} file
+// @Success 206 {file} file
+// @Header 200,206 {string} ETag "Entity tag of the content"
+// @Header 200,206 {string} Content-Disposition "attachment with the document's name, as filename* (RFC 6266) and an ASCII filename"
+// @Success 304 "Not Modified"
+// @Failure 400 {object} errorPayload
+// @Failure 403 {object} errorPayload "content is quarantined"
+// @Failure 404 {object} errorPayload
+// @Failure 409 {object} errorPayload "document failed processing or was rejected"
+// @Failure 412 {object} errorPayload
+// @Failure 416 {object} errorPayload
+// @Failure 500 {object} errorPayload
+// @Router /documents/{id}/content [get]
+func DownloadDocument(docSvc service.DocumentService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if _, err := uuid.Parse(id); err != nil {
+			return writeError(c, fiber.StatusBadRequest, "INVALID_ID", "invalid id format")
+		}
+
+		offset, length, partial := parseRange(c.Get(fiber.HeaderRange))
+		if isConditional(c) {
+			// Answer from the record alone, so that revalidation never touches object storage.
+			doc, err := docSvc.Get(c.UserContext(), id)
+			if err != nil {
+				if isNotFound(err) {
+					return writeError(c, fiber.StatusNotFound, "NOT_FOUND", "document not found")
+				}
+				return writeError(c, fiber.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+			}
+			if doc.Quarantined() {
+				return writeQuarantined(c)
+			}
+			if !doc.Available() {
+				return writeNotAvailable(c)
+			}
+			etag, modified := contentETag(doc), lastModified(doc)
+			if status := evaluateRead(c, etag, modified); status != 0 {
+				setValidators(c, etag, modified, contentCacheControl(c, doc))
+				return writeConditional(c, status)
+			}
+			if partial && !rangeApplies(c, etag, modified) {
+				offset, length, partial = 0, -1, false
+			}
+		}
+		rc, doc, err := docSvc.Download(c.UserContext(), id, offset, length)
+		if err != nil {
+			if isNotFound(err) {
+				return writeError(c, fiber.StatusNotFound, "NOT_FOUND", "document not found")
+			}
+			if errors.Is(err, service.ErrQuarantined) {
+				return writeQuarantined(c)
+			}
+			if errors.Is(err, service.ErrNotAvailable) {
+				return writeNotAvailable(c)
+			}
+			if errors.Is(err, service.ErrInvalidRange) {
+				return writeError(c, fiber.StatusRequestedRangeNotSatisfiable, "RANGE_NOT_SATISFIABLE", "requested range not satisfiable")
+			}
+			return writeError(c, fiber.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		}
+
+		c.Set(fiber.HeaderContentType, doc.ContentType)
+		c.Set(fiber.HeaderContentDisposition, attachment(doc.Name))
+		c.Set(fiber.HeaderAcceptRanges, "bytes")
+		setValidators(c, contentETag(doc), lastModified(doc), contentCacheControl(c, doc))
+
+		if !partial || doc.Size == 0 {
+			return c.Status(fiber.StatusOK).SendStream(rc, int(doc.Size))
+		}
+		if offset < 0 {
+			offset = max(doc.Size+offset, 0)
+		}
+		end := doc.Size - 1
+		if length >= 0 && offset+length-1 < end {
+			end = offset + length - 1
+		}
+		c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", offset, end, doc.Size))
+		return c.Status(fiber.StatusPartialContent).SendStream(rc, int(end-offset+1))
+	}
+}
+
+// contentCacheControl returns the Cache-Control of a content response: immutable when the request
+// names the document's current version in the "version" query parameter.
+func contentCacheControl(c *fiber.Ctx, doc *model.Document) string {
+	if c.Query("version") == strconv.FormatInt(doc.Version, 10) {
+		return cacheImmutable
+	}
+	return cacheRevalidate
+}
+
+// attachment returns a Content-Disposition that saves the content under name (RFC 6266). The
+// filename parameter carries an ASCII approximation for old clients; filename* carries the name
+// itself, percent-encoded as UTF-8.
+func attachment(name string) string {
+	var plain, encoded strings.Builder
+	for _, r := range name {
+		switch {
+		case r < 0x20 || r > 0x7e || r == '"' || r == '\\':
+			plain.WriteByte('_')
+		default:
+			plain.WriteRune(r)
+		}
+	}
+	for _, b := range []byte(name) {
+		if isAttrChar(b) {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, plain.String(), encoded.String())
+}
+
+// isAttrChar reports whether b may appear unencoded in an RFC 8187 ext-value.
+func isAttrChar(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
+
+// PresignDocumentURL returns a presigned download URL.
+// @Summary Presigned download URL
+// @Description Get a time-limited URL to download a document directly from object storage
+// @Tags documents
+// @Produce json
+// @Param id path string true "Document ID"
+// @Param expiry query string false "URL lifetime as a Go duration (1s to 168h)" default(15m)
+// @Success 200 {object} presignedURL
+// @Failure 400 {object} errorPayload
+// @Failure 403 {object} errorPayload "content is quarantined"
+// @Failure 404 {object} errorPayload
+// @Failure 409 {object} errorPayload "document failed processing or was rejected"
+// @Failure 501 {object} errorPayload "storage cannot presign this document, e.g. encrypted or compressed content"
+// @Failure 500 {object} errorPayload
+// @Router /documents/{id}/url [get]
+func PresignDocumentURL(docSvc service.DocumentService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if _, err := uuid.Parse(id); err != nil {
+			return writeError(c, fiber.StatusBadRequest, "INVALID_ID", "invalid id format")
+		}
+		expiry, err := time.ParseDuration(c.Query("expiry", "15m"))
+		if err != nil || expiry < time.Second || expiry > maxPresignExpiry {
+			return writeError(c, fiber.StatusBadRequest, "INVALID_EXPIRY", "expiry must be a duration between 1s and 168h")
+		}
+
+		url, err := docSvc.PresignURL(c.UserContext(), id, expiry)
+		if err != nil {
+			if isNotFound(err) {
+				return writeError(c, fiber.StatusNotFound, "NOT_FOUND", "document not found")
+			}
+			if errors.Is(err, service.ErrQuarantined) {
+				return writeQuarantined(c)
+			}
+			if errors.Is(err, service.ErrNotAvailable) {
+				return writeNotAvailable(c)
+			}
+			if errors.Is(err, service.ErrPresignUnsupported) {
+				return writeError(c, fiber.StatusNotImplemented, "PRESIGN_UNSUPPORTED", "presigned urls are not available for this storage")
+			}
+			return writeError(c, fiber.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		}
+		return c.JSON(presignedURL{URL: url, ExpiresAt: time.Now().Add(expiry).UTC()})
+	}
+}
+
 // RegisterRoutes attaches HTTP routes to the provided Fiber app.
 // Keep handlers minimal and free of business logic in this skeleton.
 func RegisterRoutes(app *fiber.App, db *sql.DB, docSvc service.DocumentService) {
@@ -190,9 +427,30 @@ func RegisterRoutes(app *fiber.App, db *sql.DB, docSvc service.DocumentService)
 	// Get document by ID
 	app.Get("/documents/:id", GetDocument(docSvc))
 
+	// Update document name, content type, tags and metadata (JSON Merge Patch)
+	app.Patch("/documents/:id", UpdateDocument(docSvc))
+
 	// Delete document by ID
 	app.Delete("/documents/:id", DeleteDocument(docSvc))
 
+	// Download several documents as one ZIP archive
+	app.Post("/documents/archive", DownloadArchive(docSvc))
+
+	// Create documents from the files of an uploaded ZIP or tar archive
+	app.Post("/documents/import-archive", ImportArchive(docSvc))
+
+	// Delete documents in bulk (the colon is literal, not a parameter)
+	app.Post("/documents\\:batchDelete", BatchDeleteDocuments(docSvc))
+
+	// Download document content (supports a single Range)
+	app.Get("/documents/:id/content", DownloadDocument(docSvc))
+
+	// Presigned download URL
+	app.Get("/documents/:id/url", PresignDocumentURL(docSvc))
+
+	// Duplicate a document with a server-side copy of its content
+	app.Post("/documents/:id/copy", CopyDocument(docSvc))
+
 	// Prometheus metrics endpoint
 	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
 }
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	_ "docapi/internal/model"
+	"docapi/internal/service"
+)
+
+// updateRequest documents the fields accepted by UpdateDocument; the body is decoded as a JSON
+// Merge Patch (RFC 7396), so omitted fields are kept and null removes a value.
+type updateRequest struct {
+	Name        string             `json:"name" example:"invoice-2024-03.pdf"`
+	ContentType string             `json:"content_type" example:"application/pdf"`
+	Tags        []string           `json:"tags" example:"invoice,2024"`
+	Metadata    map[string]*string `json:"metadata"`
+}
+
+// parseMergePatch decodes a JSON Merge Patch of a document's mutable fields.
+func parseMergePatch(body []byte) (service.DocumentPatch, error) {
+	var patch service.DocumentPatch
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+		return patch, errors.New("body must be a JSON object")
+	}
+	isNull := func(raw json.RawMessage) bool { return string(raw) == "null" }
+
+	for key, raw := range fields {
+		switch key {
+		case "name", "content_type":
+			var s string
+			if isNull(raw) || json.Unmarshal(raw, &s) != nil {
+				return patch, fmt.Errorf("%s must be a string", key)
+			}
+			if key == "name" {
+				patch.Name = &s
+			} else {
+				patch.ContentType = &s
+			}
+		case "tags":
+			tags := []string{}
+			if !isNull(raw) && json.Unmarshal(raw, &tags) != nil {
+				return patch, errors.New("tags must be an array of strings or null")
+			}
+			patch.Tags = &tags
+		case "metadata":
+			if isNull(raw) {
+				patch.ClearMetadata = true
+				continue
+			}
+			if err := json.Unmarshal(raw, &patch.Metadata); err != nil {
+				return patch, errors.New("metadata must be an object of strings or nulls, or null")
+			}
+		default:
+			return patch, fmt.Errorf("%q cannot be changed", key)
+		}
+	}
+	return patch, nil
+}
+
+// UpdateDocument handles changing a document's name, content type, tags and metadata.
+// @Summary Update document
+// @Description Change the mutable fields of a document with a JSON Merge Patch (RFC 7396): fields left out are kept, "tags" replaces
+// @Description the tags (null clears them), and "metadata" is merged key by key, where a null value removes the key and a null
+// @Description object clears all metadata. Every change increments the document's version, returned as its ETag. Send the ETag
+// @Description in If-Match (or its Last-Modified in If-Unmodified-Since) to update only the version you read; a document changed
+// @Description since then fails with 412.
+// @Tags documents
+// @Accept application/merge-patch+json
+// @Accept json
+// @Produce json
+// @Param id path string true "Document ID"
+// @Param If-Match header string false "ETag of the version to update"
+// @Param If-Unmodified-Since header string false "Last-Modified of the version to update"
+// @Param request body updateRequest true "Fields to change"
+// @Success 200 {object} model.Document
+// @Header 200 {string} ETag "Version of the document"
+// @Header 200 {string} Last-Modified "When the document was last changed"
+// @Failure 400 {object} errorPayload
+// @Failure 404 {object} errorPayload
+// @Failure 409 {object} errorPayload
+// @Failure 412 {object} errorPayload
+// @Failure 415 {object} errorPayload
+// @Failure 500 {object} errorPayload
+// @Router /documents/{id} [patch]
+func UpdateDocument(docSvc service.DocumentService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if _, err := uuid.Parse(id); err != nil {
+			return writeError(c, fiber.StatusBadRequest, "INVALID_ID", "invalid id format")
+		}
+		mt, _, _ := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+		if mt != "application/merge-patch+json" && mt != fiber.MIMEApplicationJSON {
+			return writeError(c, fiber.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE", "content type must be application/merge-patch+json")
+		}
+		patch, err := parseMergePatch(c.Body())
+		if err != nil {
+			return writeError(c, fiber.StatusBadRequest, "INVALID_PATCH", err.Error())
+		}
+
+		doc, err := docSvc.Update(c.UserContext(), id, patch, writePrecondition(c))
+		if err != nil {
+			switch {
+			case isNotFound(err):
+				return writeError(c, fiber.StatusNotFound, "NOT_FOUND", "document not found")
+			case errors.Is(err, service.ErrInvalidPatch):
+				return writeError(c, fiber.StatusBadRequest, "INVALID_PATCH", err.Error())
+			case errors.Is(err, service.ErrPreconditionFailed):
+				return writeError(c, fiber.StatusPreconditionFailed, "PRECONDITION_FAILED", "document does not match the request's preconditions")
+			case errors.Is(err, service.ErrConflict):
+				return writeError(c, fiber.StatusConflict, "CONFLICT", "document is being changed concurrently, retry")
+			}
+			return writeError(c, fiber.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		}
+		setValidators(c, documentETag(doc), lastModified(doc), cacheRevalidate)
+		return c.JSON(doc)
+	}
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+
+	"github.com/gofiber/fiber/v2"
+
+	"docapi/internal/model"
+	"docapi/internal/service"
+)
+
+// uploadItem is the outcome for one uploaded file. Document is set when Status is "created";
+// Error when it is "failed".
+type uploadItem struct {
+	Filename string          `json:"filename"`
+	Status   string          `json:"status" enums:"created,failed,rolled_back"`
+	Document *model.Document `json:"document,omitempty"`
+	Error    *errorEnvelope  `json:"error,omitempty"`
+}
+
+type uploadResponse struct {
+	Results    []uploadItem `json:"results"`
+	Created    int          `json:"created"`
+	Failed     int          `json:"failed"`
+	RolledBack int          `json:"rolled_back"`
+}
+
+// partContentType returns the declared content type of a multipart file.
+func partContentType(fh *multipart.FileHeader) string {
+	if ct := fh.Header.Get("Content-Type"); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// uploadMany uploads every file part and responds with a result per file.
+func uploadMany(c *fiber.Ctx, docSvc service.DocumentService, files []*multipart.FileHeader, atomic bool) error {
+	batch := make([]service.UploadFile, len(files))
+	for i, fh := range files {
+		batch[i] = service.UploadFile{
+			Filename:    fh.Filename,
+			ContentType: partContentType(fh),
+			Size:        fh.Size,
+			Open:        func() (io.ReadCloser, error) { return fh.Open() },
+		}
+	}
+
+	results, err := docSvc.UploadMany(c.UserContext(), batch, atomic)
+	if err != nil {
+		if errors.Is(err, service.ErrTooManyFiles) {
+			return writeError(c, fiber.StatusBadRequest, "TOO_MANY_FILES", err.Error())
+		}
+		return writeError(c, fiber.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+	}
+
+	res := uploadResponse{Results: make([]uploadItem, len(results))}
+	for i, r := range results {
+		item := uploadItem{Filename: r.Filename, Status: string(r.Status)}
+		switch r.Status {
+		case service.UploadStatusCreated:
+			res.Created++
+			item.Document = r.Document
+		case service.UploadStatusRolledBack:
+			res.RolledBack++
+		default:
+			res.Failed++
+			switch {
+			case errors.Is(r.Err, service.ErrFileOpen):
+				item.Error = &errorEnvelope{Code: "FILE_OPEN_ERROR", Message: "cannot open uploaded file"}
+			case errors.Is(r.Err, service.ErrScanFailed):
+				item.Error = &scanUnavailable
+			case errors.Is(r.Err, service.ErrRollbackFailed):
+				// The document may still be listed or its content still stored; report it so it
+				// can be deleted later.
+				item.Document = r.Document
+				item.Error = &errorEnvelope{Code: "ROLLBACK_FAILED", Message: "document could not be removed after the batch failed"}
+			default:
+				item.Error = &errorEnvelope{Code: "INTERNAL_ERROR", Message: "internal server error"}
+			}
+		}
+		res.Results[i] = item
+	}
+
+	status := fiber.StatusCreated
+	if res.Created < len(results) {
+		status = fiber.StatusMultiStatus
+	}
+	return c.Status(status).JSON(res)
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BearerToken is a middleware that admits only requests carrying "Authorization: Bearer <token>".
+// Other requests are rejected with 401. Tokens are compared in constant time.
+func BearerToken(token string) fiber.Handler {
+	want := []byte(token)
+	return func(c *fiber.Ctx) error {
+		got, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(got), want) != 1 {
+			c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+			return writeError(c, fiber.StatusUnauthorized, "UNAUTHORIZED", "a valid bearer token is required")
+		}
+		return c.Next()
+	}
+}
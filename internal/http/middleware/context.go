@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestContext makes base the parent of every request's context, so that canceling base
+// cancels the requests being served: storage transfers abort and the services roll back.
+// Shutdown uses it to interrupt requests that outlast the grace period.
+//
+// It must come first, as later middleware, such as tracing, derive their contexts from the
+// request's.
+func RequestContext(base context.Context) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.SetUserContext(base)
+		return c.Next()
+	}
+}
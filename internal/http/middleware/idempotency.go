@@ -0,0 +1,263 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"docapi/internal/repository"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client-chosen key that identifies retries of one request.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set to "true" on responses replayed for a repeated key.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	idempotencyPollInterval = 100 * time.Millisecond
+)
+
+// replayedHeaders are the response headers stored with a response and restored on replay. Other
+// headers, such as X-Request-ID, describe the request being answered rather than the response.
+var replayedHeaders = []string{
+	fiber.HeaderContentType,
+	fiber.HeaderContentDisposition,
+	fiber.HeaderLocation,
+	fiber.HeaderETag,
+	fiber.HeaderLastModified,
+	fiber.HeaderCacheControl,
+}
+
+// IdempotencyOptions configures the Idempotency middleware. Zero values select the defaults.
+type IdempotencyOptions struct {
+	// TTL is how long a response is kept for replay (default 24h).
+	TTL time.Duration
+	// LockTimeout is how long a request may hold its key before another request may claim it,
+	// so that a crashed server does not block the key forever (default 5m).
+	LockTimeout time.Duration
+	// Wait is how long a repeated request waits for the first one to complete before it is
+	// answered with 409 (default 30s).
+	Wait time.Duration
+	// MaxBodySize caps the response bodies that are stored; larger responses are not replayed
+	// (default 1 MiB).
+	MaxBodySize int
+	// Principal returns the identity that keys are scoped to. The default is a hash of the
+	// Authorization header, so clients sharing a key by chance don't see each other's responses.
+	Principal func(c *fiber.Ctx) string
+	// OnError is called when a response cannot be stored or released; the request itself has
+	// already been answered.
+	OnError func(err error)
+}
+
+func (o IdempotencyOptions) withDefaults() IdempotencyOptions {
+	if o.TTL <= 0 {
+		o.TTL = 24 * time.Hour
+	}
+	if o.LockTimeout <= 0 {
+		o.LockTimeout = 5 * time.Minute
+	}
+	if o.Wait <= 0 {
+		o.Wait = 30 * time.Second
+	}
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = 1 << 20
+	}
+	if o.Principal == nil {
+		o.Principal = AuthorizationPrincipal
+	}
+	if o.OnError == nil {
+		o.OnError = func(error) {}
+	}
+	return o
+}
+
+// AuthorizationPrincipal identifies the client by a hash of its Authorization header, or as
+// "anonymous" without one.
+func AuthorizationPrincipal(c *fiber.Ctx) string {
+	auth := c.Get(fiber.HeaderAuthorization)
+	if auth == "" {
+		return "anonymous"
+	}
+	sum := sha256.Sum256([]byte(auth))
+	return hex.EncodeToString(sum[:])
+}
+
+// Idempotency is a middleware that makes POST, PATCH and DELETE requests carrying an
+// Idempotency-Key header safe to retry.
+//
+// Behavior:
+//   - The first request with a key is processed and its response (status, body and the headers
+//     listed in replayedHeaders) is stored for opts.TTL.
+//   - A repeated request with the same key and principal gets the stored response, marked with
+//     Idempotent-Replayed: true, without being processed again.
+//   - A key reused for a different request (method, path, query or body) is rejected with 422.
+//   - A repeated request arriving while the first one is in flight waits for it to complete, up
+//     to opts.Wait, and is then rejected with 409.
+//   - Server errors (5xx), errors returned to the error handler, streamed bodies and bodies over
+//     opts.MaxBodySize are not stored, so the request may be retried.
+func Idempotency(store repository.IdempotencyRepository, opts IdempotencyOptions) fiber.Handler {
+	opts = opts.withDefaults()
+
+	return func(c *fiber.Ctx) error {
+		key := c.Get(IdempotencyKeyHeader)
+		if key == "" || !isMutatingMethod(c.Method()) {
+			return c.Next()
+		}
+		if !validIdempotencyKey(key) {
+			return writeError(c, fiber.StatusBadRequest, "INVALID_IDEMPOTENCY_KEY",
+				"Idempotency-Key must be 1 to 255 printable ASCII characters")
+		}
+
+		// Postgres stores microseconds; the claim's CreatedAt must compare equal after a round trip.
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		claim := &repository.IdempotencyRecord{
+			Principal:   opts.Principal(c),
+			Key:         key,
+			Fingerprint: requestFingerprint(c),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(opts.LockTimeout),
+		}
+
+		held, err := acquire(c.UserContext(), store, claim, now.Add(opts.Wait))
+		if err != nil {
+			if errors.Is(err, errStillInFlight) {
+				c.Set(fiber.HeaderRetryAfter, "1")
+				return writeError(c, fiber.StatusConflict, "IDEMPOTENCY_IN_PROGRESS",
+					"a request with this Idempotency-Key is still being processed, retry later")
+			}
+			if errors.Is(err, errFingerprintMismatch) {
+				return writeError(c, fiber.StatusUnprocessableEntity, "IDEMPOTENCY_KEY_REUSED",
+					"Idempotency-Key was already used for a different request")
+			}
+			return writeError(c, fiber.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		}
+		if held != nil {
+			return replay(c, held)
+		}
+
+		// The claim outlives the request's context: a client that disconnects must not leave its
+		// key blocked until the lock times out.
+		ctx := context.WithoutCancel(c.UserContext())
+		err = c.Next()
+		res := c.Response()
+		if err != nil || res.StatusCode() >= fiber.StatusInternalServerError || res.IsBodyStream() || len(res.Body()) > opts.MaxBodySize {
+			if rerr := store.Release(ctx, claim); rerr != nil {
+				opts.OnError(rerr)
+			}
+			return err
+		}
+
+		claim.Status = res.StatusCode()
+		claim.Body = slices.Clone(res.Body())
+		claim.Headers = make(map[string]string)
+		for _, h := range replayedHeaders {
+			if v := res.Header.Peek(h); len(v) > 0 {
+				claim.Headers[h] = string(v)
+			}
+		}
+		claim.ExpiresAt = time.Now().UTC().Add(opts.TTL)
+		if cerr := store.Complete(ctx, claim); cerr != nil {
+			opts.OnError(cerr)
+		}
+		return nil
+	}
+}
+
+var (
+	errStillInFlight       = errors.New("idempotent request still in flight")
+	errFingerprintMismatch = errors.New("idempotency key reused for a different request")
+)
+
+// acquire claims the key of claim. It returns the completed record holding the key, or nil once
+// the key is claimed; while another request with the same fingerprint holds it, acquire polls
+// until deadline.
+func acquire(ctx context.Context, store repository.IdempotencyRepository, claim *repository.IdempotencyRecord, deadline time.Time) (*repository.IdempotencyRecord, error) {
+	for {
+		held, err := store.Acquire(ctx, claim)
+		if err != nil || held == nil {
+			return nil, err
+		}
+		if held.Fingerprint != claim.Fingerprint {
+			return nil, errFingerprintMismatch
+		}
+		if held.Status != 0 {
+			return held, nil
+		}
+		if time.Now().Add(idempotencyPollInterval).After(deadline) {
+			return nil, errStillInFlight
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(idempotencyPollInterval):
+		}
+		// Claim at the current time, so that the claim of a crashed request is seen to expire.
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		claim.ExpiresAt = now.Add(claim.ExpiresAt.Sub(claim.CreatedAt))
+		claim.CreatedAt = now
+	}
+}
+
+// replay answers the request with a stored response.
+func replay(c *fiber.Ctx, rec *repository.IdempotencyRecord) error {
+	for h, v := range rec.Headers {
+		c.Set(h, v)
+	}
+	c.Set(IdempotentReplayedHeader, "true")
+	return c.Status(rec.Status).Send(rec.Body)
+}
+
+// requestFingerprint hashes the parts of a request that identify it: method, path, query and
+// body. The boundary of a multipart body is left out, since clients choose a new one for every
+// attempt.
+func requestFingerprint(c *fiber.Ctx) string {
+	body := c.Body()
+	if mt, params, err := mime.ParseMediaType(c.Get(fiber.HeaderContentType)); err == nil && strings.HasPrefix(mt, "multipart/") && params["boundary"] != "" {
+		body = bytes.ReplaceAll(body, []byte(params["boundary"]), nil)
+	}
+
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte(c.Method()), []byte(c.Path()), c.Request().URI().QueryString()} {
+		h.Write([]byte(strconv.Itoa(len(part))))
+		h.Write([]byte{':'})
+		h.Write(part)
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func isMutatingMethod(method string) bool {
+	return method == fiber.MethodPost || method == fiber.MethodPatch || method == fiber.MethodDelete
+}
+
+// validIdempotencyKey reports whether key has 1 to 255 printable ASCII characters.
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// writeError writes an error in the shape of the API's error responses.
+func writeError(c *fiber.Ctx, status int, code, message string) error {
+	rid, _ := c.Locals(RequestIDLocalKey).(string)
+	return c.Status(status).JSON(fiber.Map{
+		"request_id": rid,
+		"error":      fiber.Map{"code": code, "message": message},
+	})
+}
@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"docapi/internal/repository/memory"
+)
+
+// newIdempotentApp returns an app whose POST /documents handler counts its calls and answers
+// with the given status after an optional delay.
+func newIdempotentApp(opts IdempotencyOptions, status int, delay time.Duration) (*fiber.App, *atomic.Int32) {
+	var calls atomic.Int32
+	app := fiber.New()
+	app.Use(Idempotency(memory.NewIdempotencyMemory(), opts))
+	app.Post("/documents", func(c *fiber.Ctx) error {
+		n := calls.Add(1)
+		time.Sleep(delay)
+		c.Set(fiber.HeaderLocation, "/documents/"+strconv.Itoa(int(n)))
+		c.Set(RequestIDHeader, "req")
+		return c.Status(status).JSON(fiber.Map{"call": n})
+	})
+	return app, &calls
+}
+
+func idempotentRequest(body, key, auth string) *http.Request {
+	req := httptest.NewRequest(fiber.MethodPost, "/documents", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	if auth != "" {
+		req.Header.Set(fiber.HeaderAuthorization, auth)
+	}
+	return req
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestIdempotency(t *testing.T) {
+	t.Run("replays the first response", func(t *testing.T) {
+		app, calls := newIdempotentApp(IdempotencyOptions{}, fiber.StatusCreated, 0)
+
+		first, err := app.Test(idempotentRequest(`{"a":1}`, "k1", ""))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusCreated, first.StatusCode)
+		assert.Empty(t, first.Header.Get(IdempotentReplayedHeader))
+		firstBody := readBody(t, first)
+
+		second, err := app.Test(idempotentRequest(`{"a":1}`, "k1", ""))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusCreated, second.StatusCode)
+		assert.Equal(t, "true", second.Header.Get(IdempotentReplayedHeader))
+		assert.Equal(t, first.Header.Get(fiber.HeaderLocation), second.Header.Get(fiber.HeaderLocation))
+		assert.Equal(t, fiber.MIMEApplicationJSON, second.Header.Get(fiber.HeaderContentType))
+		assert.Empty(t, second.Header.Get(RequestIDHeader))
+		assert.Equal(t, firstBody, readBody(t, second))
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("requests without a key are not deduplicated", func(t *testing.T) {
+		app, calls := newIdempotentApp(IdempotencyOptions{}, fiber.StatusCreated, 0)
+		for range 2 {
+			resp, err := app.Test(idempotentRequest(`{}`, "", ""))
+			require.NoError(t, err)
+			assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		}
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("keys are scoped to the principal", func(t *testing.T) {
+		app, calls := newIdempotentApp(IdempotencyOptions{}, fiber.StatusCreated, 0)
+		for _, auth := range []string{"Bearer a", "Bearer b"} {
+			resp, err := app.Test(idempotentRequest(`{}`, "k1", auth))
+			require.NoError(t, err)
+			assert.Empty(t, resp.Header.Get(IdempotentReplayedHeader))
+		}
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("key reused for a different request", func(t *testing.T) {
+		app, calls := newIdempotentApp(IdempotencyOptions{}, fiber.StatusCreated, 0)
+		_, err := app.Test(idempotentRequest(`{"a":1}`, "k1", ""))
+		require.NoError(t, err)
+
+		resp, err := app.Test(idempotentRequest(`{"a":2}`, "k1", ""))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Contains(t, readBody(t, resp), "IDEMPOTENCY_KEY_REUSED")
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		app, calls := newIdempotentApp(IdempotencyOptions{}, fiber.StatusCreated, 0)
+		resp, err := app.Test(idempotentRequest(`{}`, strings.Repeat("k", 256), ""))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+		assert.Contains(t, readBody(t, resp), "INVALID_IDEMPOTENCY_KEY")
+		assert.Zero(t, calls.Load())
+	})
+
+	t.Run("server errors are not stored", func(t *testing.T) {
+		app, calls := newIdempotentApp(IdempotencyOptions{}, fiber.StatusBadGateway, 0)
+		for range 2 {
+			resp, err := app.Test(idempotentRequest(`{}`, "k1", ""))
+			require.NoError(t, err)
+			assert.Equal(t, fiber.StatusBadGateway, resp.StatusCode)
+			assert.Empty(t, resp.Header.Get(IdempotentReplayedHeader))
+		}
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("concurrent duplicates wait for the first request", func(t *testing.T) {
+		app, calls := newIdempotentApp(IdempotencyOptions{}, fiber.StatusCreated, 300*time.Millisecond)
+
+		var wg sync.WaitGroup
+		bodies := make([]string, 3)
+		for i := range bodies {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := app.Test(idempotentRequest(`{}`, "k1", ""), -1)
+				if assert.NoError(t, err) {
+					assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+					bodies[i] = readBody(t, resp)
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), calls.Load())
+		assert.Equal(t, bodies[0], bodies[1])
+		assert.Equal(t, bodies[0], bodies[2])
+	})
+
+	t.Run("duplicate gives up waiting", func(t *testing.T) {
+		app, calls := newIdempotentApp(IdempotencyOptions{Wait: 150 * time.Millisecond}, fiber.StatusCreated, 500*time.Millisecond)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, _ = app.Test(idempotentRequest(`{}`, "k1", ""), -1)
+		}()
+		time.Sleep(50 * time.Millisecond)
+
+		resp, err := app.Test(idempotentRequest(`{}`, "k1", ""), -1)
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+		assert.Equal(t, "1", resp.Header.Get(fiber.HeaderRetryAfter))
+		assert.Contains(t, readBody(t, resp), "IDEMPOTENCY_IN_PROGRESS")
+		<-done
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("multipart boundary is ignored", func(t *testing.T) {
+		app, calls := newIdempotentApp(IdempotencyOptions{}, fiber.StatusCreated, 0)
+		for range 2 {
+			var buf bytes.Buffer
+			w := multipart.NewWriter(&buf)
+			part, err := w.CreateFormFile("file", "a.txt")
+			require.NoError(t, err)
+			_, _ = part.Write([]byte("hello"))
+			require.NoError(t, w.Close())
+
+			req := httptest.NewRequest(fiber.MethodPost, "/documents", &buf)
+			req.Header.Set(fiber.HeaderContentType, w.FormDataContentType())
+			req.Header.Set(IdempotencyKeyHeader, "k1")
+			_, err = app.Test(req)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int32(1), calls.Load())
+	})
+}
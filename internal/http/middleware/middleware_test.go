@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http/httptest"
 	"testing"
@@ -99,3 +100,69 @@ func TestLogger(t *testing.T) {
 	assert.NotNil(t, logData["latency"])
 	assert.NotEmpty(t, logData["ts"])
 }
+
+func TestBearerToken(t *testing.T) {
+	app := fiber.New()
+	app.Use(BearerToken("s3cret"))
+	app.Get("/test", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	tests := []struct {
+		name       string
+		auth       string
+		wantStatus int
+	}{
+		{"valid token", "Bearer s3cret", fiber.StatusOK},
+		{"missing header", "", fiber.StatusUnauthorized},
+		{"wrong token", "Bearer s3cre", fiber.StatusUnauthorized},
+		{"other scheme", "Basic s3cret", fiber.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			if tt.auth != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tt.auth)
+			}
+			resp, _ := app.Test(req)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus == fiber.StatusUnauthorized {
+				assert.Equal(t, "Bearer", resp.Header.Get(fiber.HeaderWWWAuthenticate))
+				var body struct {
+					Error struct{ Code string } `json:"error"`
+				}
+				assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Equal(t, "UNAUTHORIZED", body.Error.Code)
+			}
+		})
+	}
+
+	t.Run("empty token admits nobody", func(t *testing.T) {
+		app := fiber.New()
+		app.Use(BearerToken(""))
+		app.Get("/test", func(c *fiber.Ctx) error { return c.SendString("ok") })
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer ")
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func TestRequestContext(t *testing.T) {
+	base, cancel := context.WithCancel(context.Background())
+	app := fiber.New()
+	app.Use(RequestContext(base))
+	app.Get("/test", func(c *fiber.Ctx) error {
+		select {
+		case <-c.UserContext().Done():
+			return c.Status(fiber.StatusServiceUnavailable).SendString(c.UserContext().Err().Error())
+		case <-time.After(time.Second):
+			return c.SendString("ok")
+		}
+	})
+
+	time.AfterFunc(20*time.Millisecond, cancel)
+	resp, err := app.Test(httptest.NewRequest("GET", "/test", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusServiceUnavailable, resp.StatusCode, "canceling the base cancels the request")
+}
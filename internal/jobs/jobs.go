@@ -0,0 +1,92 @@
+// Package jobs runs work outside the request path. Jobs are stored with a repository.JobRepository,
+// so they survive restarts, and run by a Pool of workers with retries and backoff.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"docapi/internal/repository"
+)
+
+// DefaultMaxAttempts is the number of runs a job gets when the queue is not configured otherwise.
+const DefaultMaxAttempts = 5
+
+// Handler runs one job. A returned error makes the job run again after a backoff, until its
+// attempts are exhausted; wrap it with Permanent when retrying cannot help. ctx is canceled when
+// the pool is shut down before the job finishes, or when the job's claim was lost.
+type Handler func(ctx context.Context, job *repository.Job) error
+
+// Permanent marks err as a failure that retrying cannot fix: the job fails without further
+// attempts.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// isPermanent reports whether err was marked with Permanent.
+func isPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// Register adds the handler of jobs of the given kind to p. Payloads are decoded from JSON into T;
+// a payload that cannot be decoded fails the job permanently. Register must be called before
+// p.Start and panics if kind already has a handler.
+func Register[T any](p *Pool, kind string, fn func(ctx context.Context, payload T) error) {
+	p.handle(kind, func(ctx context.Context, job *repository.Job) error {
+		var payload T
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return Permanent(fmt.Errorf("decode payload: %w", err))
+		}
+		return fn(ctx, payload)
+	})
+}
+
+// Queue adds jobs to a repository.
+type Queue struct {
+	repo        repository.JobRepository
+	maxAttempts int
+}
+
+// NewQueue creates a queue whose jobs run at most maxAttempts times; values below 1 select
+// DefaultMaxAttempts.
+func NewQueue(repo repository.JobRepository, maxAttempts int) *Queue {
+	if maxAttempts < 1 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	return &Queue{repo: repo, maxAttempts: maxAttempts}
+}
+
+// Enqueue adds a job of the given kind that is due right away. payload is encoded as JSON.
+func (q *Queue) Enqueue(ctx context.Context, kind string, payload any) (*repository.Job, error) {
+	return q.Schedule(ctx, kind, payload, time.Time{})
+}
+
+// Schedule adds a job of the given kind that becomes due at runAt; a zero runAt means now.
+func (q *Queue) Schedule(ctx context.Context, kind string, payload any, runAt time.Time) (*repository.Job, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("encode payload: %w", err)
+	}
+	return q.repo.Enqueue(ctx, &repository.Job{
+		ID:          uuid.NewString(),
+		Kind:        kind,
+		Payload:     b,
+		MaxAttempts: q.maxAttempts,
+		RunAt:       runAt,
+		CreatedAt:   time.Now().UTC(),
+	})
+}
@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"docapi/internal/repository"
+)
+
+// Outcomes of a job run, as reported by the outcome label of jobs_processed_total.
+const (
+	outcomeSucceeded = "succeeded"
+	outcomeRetried   = "retried"
+	outcomeFailed    = "failed"
+	outcomeReleased  = "released"
+	outcomeLost      = "lost"
+)
+
+// Metrics holds the Prometheus collectors reporting on jobs.
+type Metrics struct {
+	processed *prometheus.CounterVec
+	duration  *prometheus.HistogramVec
+	running   *prometheus.GaugeVec
+	jobs      *prometheus.GaugeVec
+	claimErrs prometheus.Counter
+}
+
+// NewMetrics creates and registers the job collectors. Registering twice on the same registerer
+// reuses the existing collectors.
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	register := func(c prometheus.Collector) (prometheus.Collector, error) {
+		if err := reg.Register(c); err != nil {
+			var are prometheus.AlreadyRegisteredError
+			if !errors.As(err, &are) {
+				return nil, err
+			}
+			return are.ExistingCollector, nil
+		}
+		return c, nil
+	}
+
+	var m Metrics
+	c, err := register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "jobs_processed_total",
+		Help: "Job runs finished by this process, by kind and outcome (succeeded, retried, failed, released, lost).",
+	}, []string{"kind", "outcome"}))
+	if err != nil {
+		return nil, err
+	}
+	m.processed = c.(*prometheus.CounterVec)
+
+	if c, err = register(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "job_duration_seconds",
+		Help:    "Duration of job runs, by kind.",
+		Buckets: []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 300, 900},
+	}, []string{"kind"})); err != nil {
+		return nil, err
+	}
+	m.duration = c.(*prometheus.HistogramVec)
+
+	if c, err = register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "jobs_running",
+		Help: "Jobs being run by this process, by kind.",
+	}, []string{"kind"})); err != nil {
+		return nil, err
+	}
+	m.running = c.(*prometheus.GaugeVec)
+
+	if c, err = register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "jobs",
+		Help: "Jobs stored in the queue, by kind and status (queued, running, failed), as last counted.",
+	}, []string{"kind", "status"})); err != nil {
+		return nil, err
+	}
+	m.jobs = c.(*prometheus.GaugeVec)
+
+	if c, err = register(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "job_claim_errors_total",
+		Help: "Failed attempts to claim jobs from the queue.",
+	})); err != nil {
+		return nil, err
+	}
+	m.claimErrs = c.(prometheus.Counter)
+	return &m, nil
+}
+
+func (m *Metrics) started(kind string) {
+	if m == nil {
+		return
+	}
+	m.running.WithLabelValues(kind).Inc()
+}
+
+func (m *Metrics) finished(kind, outcome string, took time.Duration) {
+	if m == nil {
+		return
+	}
+	m.running.WithLabelValues(kind).Dec()
+	m.processed.WithLabelValues(kind, outcome).Inc()
+	m.duration.WithLabelValues(kind).Observe(took.Seconds())
+}
+
+func (m *Metrics) claimFailed() {
+	if m == nil {
+		return
+	}
+	m.claimErrs.Inc()
+}
+
+// counted replaces the queue sizes with counts, so that kinds and statuses without jobs drop out.
+func (m *Metrics) counted(counts []repository.JobCount) {
+	if m == nil {
+		return
+	}
+	m.jobs.Reset()
+	for _, c := range counts {
+		m.jobs.WithLabelValues(c.Kind, c.Status).Set(float64(c.Count))
+	}
+}
@@ -0,0 +1,344 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"docapi/internal/repository"
+)
+
+// Defaults for PoolOptions.
+const (
+	DefaultWorkers           = 4
+	DefaultPollInterval      = time.Second
+	DefaultVisibilityTimeout = 5 * time.Minute
+	DefaultBackoffBase       = 10 * time.Second
+	DefaultBackoffMax        = time.Hour
+	DefaultCountInterval     = 15 * time.Second
+)
+
+// PoolOptions configures a Pool. Zero values select the defaults.
+type PoolOptions struct {
+	// Workers is the number of jobs run at once.
+	Workers int
+	// PollInterval is how long an idle worker waits before it looks for due jobs again.
+	PollInterval time.Duration
+	// VisibilityTimeout is how long a claimed job stays invisible to other workers. Running jobs
+	// extend their claim every half timeout; the job of a worker that crashed is claimed again
+	// once the timeout expires.
+	VisibilityTimeout time.Duration
+	// BackoffBase and BackoffMax bound the delay before a failed job runs again: BackoffBase
+	// doubled for every attempt made, at most BackoffMax, of which up to half is random jitter.
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// CountInterval is how often the jobs in the queue are counted for the jobs gauge.
+	CountInterval time.Duration
+	// Log receives events worth logging, such as failed jobs, in the shape of the API's
+	// structured logs.
+	Log func(level, msg string, fields map[string]any)
+}
+
+func (o PoolOptions) withDefaults() PoolOptions {
+	if o.Workers <= 0 {
+		o.Workers = DefaultWorkers
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = DefaultPollInterval
+	}
+	if o.VisibilityTimeout <= 0 {
+		o.VisibilityTimeout = DefaultVisibilityTimeout
+	}
+	if o.BackoffBase <= 0 {
+		o.BackoffBase = DefaultBackoffBase
+	}
+	if o.BackoffMax <= 0 {
+		o.BackoffMax = DefaultBackoffMax
+	}
+	if o.CountInterval <= 0 {
+		o.CountInterval = DefaultCountInterval
+	}
+	if o.Log == nil {
+		o.Log = func(string, string, map[string]any) {}
+	}
+	return o
+}
+
+// Pool runs the jobs of the kinds registered with it. Several pools, in one or many processes,
+// may share a repository: each job is run by one worker at a time.
+type Pool struct {
+	repo     repository.JobRepository
+	opts     PoolOptions
+	metrics  *Metrics
+	handlers map[string]Handler
+	kinds    []string
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	started   atomic.Bool
+	stopped   atomic.Bool
+	// claimErr holds the error of the latest claim, nil if it succeeded.
+	claimErr atomic.Pointer[error]
+	// jobCtx is the parent of the jobs' contexts; canceling it interrupts running jobs.
+	jobCtx     context.Context
+	cancelJobs context.CancelFunc
+}
+
+// NewPool creates a pool that takes jobs from repo. metrics may be nil.
+func NewPool(repo repository.JobRepository, opts PoolOptions, metrics *Metrics) *Pool {
+	jobCtx, cancel := context.WithCancel(context.Background())
+	return &Pool{
+		repo:       repo,
+		opts:       opts.withDefaults(),
+		metrics:    metrics,
+		handlers:   make(map[string]Handler),
+		stop:       make(chan struct{}),
+		jobCtx:     jobCtx,
+		cancelJobs: cancel,
+	}
+}
+
+// handle adds the handler of a kind; see Register.
+func (p *Pool) handle(kind string, h Handler) {
+	if _, ok := p.handlers[kind]; ok {
+		panic(fmt.Sprintf("jobs: handler for %q registered twice", kind))
+	}
+	p.handlers[kind] = h
+	p.kinds = append(p.kinds, kind)
+	sort.Strings(p.kinds)
+}
+
+// Kinds returns the kinds of jobs the pool runs.
+func (p *Pool) Kinds() []string {
+	return append([]string(nil), p.kinds...)
+}
+
+// Start starts the workers. It does nothing if no handler is registered or the pool was already
+// started.
+func (p *Pool) Start() {
+	if len(p.handlers) == 0 {
+		return
+	}
+	p.startOnce.Do(func() {
+		for range p.opts.Workers {
+			p.wg.Add(1)
+			go p.work()
+		}
+		p.wg.Add(1)
+		go p.count()
+		p.started.Store(true)
+	})
+}
+
+// Healthy returns an error if the pool is not running jobs: it was not started, is shut down, or
+// its latest attempt to claim jobs failed.
+func (p *Pool) Healthy() error {
+	switch {
+	case !p.started.Load():
+		return errors.New("job workers not started")
+	case p.stopped.Load():
+		return errors.New("job workers shut down")
+	}
+	if err := p.claimErr.Load(); err != nil {
+		return fmt.Errorf("claim jobs: %w", *err)
+	}
+	return nil
+}
+
+// Shutdown stops claiming jobs and waits for the running ones to finish. If ctx ends first, the
+// running jobs are canceled and queued again without counting the attempt, and Shutdown returns
+// ctx's error once their workers have returned.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() {
+		p.stopped.Store(true)
+		close(p.stop)
+	})
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	defer p.cancelJobs()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.cancelJobs()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// work claims and runs one job at a time until the pool is stopped.
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for {
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		claimed, err := p.repo.Claim(p.jobCtx, p.kinds, time.Now().UTC(), p.opts.VisibilityTimeout, 1)
+		if err != nil {
+			p.claimErr.Store(&err)
+			p.metrics.claimFailed()
+			p.opts.Log("error", "job_claim_failed", map[string]any{"error": err.Error()})
+		} else {
+			p.claimErr.Store(nil)
+		}
+		if len(claimed) == 0 {
+			select {
+			case <-p.stop:
+				return
+			case <-time.After(p.opts.PollInterval):
+			}
+			continue
+		}
+		p.run(&claimed[0])
+	}
+}
+
+// run runs a claimed job, extending its claim while it runs, and records the outcome.
+func (p *Pool) run(job *repository.Job) {
+	start := time.Now()
+	p.metrics.started(job.Kind)
+
+	var err error
+	var lost bool
+	if job.Attempts > job.MaxAttempts {
+		// The claims of earlier runs expired, for example because the job crashed its worker.
+		err = fmt.Errorf("visibility timeout expired %d times", job.MaxAttempts)
+	} else {
+		lost, err = p.call(job)
+	}
+
+	// Record the outcome even if the pool is being shut down.
+	ctx := context.WithoutCancel(p.jobCtx)
+	var outcome string
+	var settleErr error
+	switch {
+	case lost:
+		outcome = outcomeLost
+	case err == nil:
+		outcome, settleErr = outcomeSucceeded, p.repo.Complete(ctx, job)
+	case p.jobCtx.Err() != nil:
+		outcome, settleErr = outcomeReleased, p.repo.Release(ctx, job)
+	case isPermanent(err) || job.Attempts >= job.MaxAttempts:
+		outcome, settleErr = outcomeFailed, p.repo.Fail(ctx, job, err.Error())
+	default:
+		outcome, settleErr = outcomeRetried, p.repo.Retry(ctx, job, time.Now().UTC().Add(p.backoff(job.Attempts)), err.Error())
+	}
+	if errors.Is(settleErr, repository.ErrJobLost) {
+		outcome, settleErr = outcomeLost, nil
+	}
+	took := time.Since(start)
+	p.metrics.finished(job.Kind, outcome, took)
+
+	fields := map[string]any{
+		"job_id":      job.ID,
+		"kind":        job.Kind,
+		"attempt":     job.Attempts,
+		"outcome":     outcome,
+		"duration_ms": took.Milliseconds(),
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	switch {
+	case settleErr != nil:
+		fields["settle_error"] = settleErr.Error()
+		p.opts.Log("error", "job_settle_failed", fields)
+	case outcome == outcomeLost:
+		p.opts.Log("warn", "job_lost", fields)
+	case outcome == outcomeFailed:
+		p.opts.Log("error", "job_failed", fields)
+	case outcome == outcomeRetried:
+		p.opts.Log("warn", "job_retry_scheduled", fields)
+	}
+}
+
+// call runs the handler of job while a heartbeat extends its claim. It reports lost if the claim
+// was taken over, in which case the handler's context was canceled and its result is moot.
+// Panics are logged and returned as errors.
+func (p *Pool) call(job *repository.Job) (lost bool, err error) {
+	ctx, cancel := context.WithCancel(p.jobCtx)
+	defer cancel()
+
+	// lost is written by the heartbeat and read once it is done.
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		ticker := time.NewTicker(p.opts.VisibilityTimeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := p.repo.Extend(ctx, job, time.Now().UTC().Add(p.opts.VisibilityTimeout))
+				if errors.Is(err, repository.ErrJobLost) {
+					lost = true
+					cancel()
+					return
+				}
+				if err != nil && ctx.Err() == nil {
+					p.opts.Log("warn", "job_extend_failed", map[string]any{"job_id": job.ID, "kind": job.Kind, "error": err.Error()})
+				}
+			}
+		}
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+				p.opts.Log("error", "job_panicked", map[string]any{"job_id": job.ID, "kind": job.Kind, "error": err.Error(), "stack": string(debug.Stack())})
+			}
+		}()
+		err = p.handlers[job.Kind](ctx, job)
+	}()
+	cancel()
+	<-heartbeatDone
+
+	return lost, err
+}
+
+// backoff returns the delay before the run following attempt: the base doubled per attempt,
+// capped, with its upper half drawn at random so that jobs failing together spread out.
+func (p *Pool) backoff(attempt int) time.Duration {
+	d := p.opts.BackoffBase
+	for i := 1; i < attempt && d < p.opts.BackoffMax; i++ {
+		d *= 2
+	}
+	d = min(d, p.opts.BackoffMax)
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// count refreshes the jobs gauge until the pool is stopped.
+func (p *Pool) count() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.opts.CountInterval)
+	defer ticker.Stop()
+	for {
+		counts, err := p.repo.Count(p.jobCtx)
+		if err == nil {
+			p.metrics.counted(counts)
+		}
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
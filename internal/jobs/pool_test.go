@@ -0,0 +1,385 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"docapi/internal/repository"
+	"docapi/internal/repository/memory"
+)
+
+type mailPayload struct {
+	To string `json:"to"`
+}
+
+// fastOptions keep the pool's timings short enough for tests.
+var fastOptions = PoolOptions{
+	Workers:           2,
+	PollInterval:      5 * time.Millisecond,
+	VisibilityTimeout: time.Second,
+	BackoffBase:       time.Millisecond,
+	BackoffMax:        5 * time.Millisecond,
+}
+
+func newTestPool(t *testing.T, opts PoolOptions) (*Pool, *memory.JobMemory, *Metrics) {
+	t.Helper()
+	repo := memory.NewJobMemory()
+	metrics, err := NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	p := NewPool(repo, opts, metrics)
+	t.Cleanup(func() { _ = p.Shutdown(context.Background()) })
+	return p, repo, metrics
+}
+
+// waitGone waits until the job with id was completed.
+func waitGone(t *testing.T, repo *memory.JobMemory, id string) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		_, ok := repo.Get(id)
+		return !ok
+	}, 2*time.Second, 5*time.Millisecond)
+}
+
+// waitStatus waits until the job with id has the given status and returns it.
+func waitStatus(t *testing.T, repo *memory.JobMemory, id, status string) repository.Job {
+	t.Helper()
+	var job repository.Job
+	assert.Eventually(t, func() bool {
+		job, _ = repo.Get(id)
+		return job.Status == status
+	}, 2*time.Second, 5*time.Millisecond)
+	return job
+}
+
+func TestPool_Run(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("runs and deletes a job", func(t *testing.T) {
+		p, repo, metrics := newTestPool(t, fastOptions)
+		got := make(chan mailPayload, 1)
+		Register(p, "mail", func(ctx context.Context, m mailPayload) error {
+			got <- m
+			return nil
+		})
+		p.Start()
+
+		job, err := NewQueue(repo, 3).Enqueue(ctx, "mail", mailPayload{To: "a@example.com"})
+		require.NoError(t, err)
+		assert.Equal(t, mailPayload{To: "a@example.com"}, <-got)
+		waitGone(t, repo, job.ID)
+		assert.Equal(t, 1.0, testutil.ToFloat64(metrics.processed.WithLabelValues("mail", outcomeSucceeded)))
+		assert.Equal(t, 0.0, testutil.ToFloat64(metrics.running.WithLabelValues("mail")))
+	})
+
+	t.Run("retries until the job succeeds", func(t *testing.T) {
+		p, repo, metrics := newTestPool(t, fastOptions)
+		var calls atomic.Int32
+		Register(p, "mail", func(ctx context.Context, m mailPayload) error {
+			if calls.Add(1) < 3 {
+				return errors.New("smtp unavailable")
+			}
+			return nil
+		})
+		p.Start()
+
+		job, err := NewQueue(repo, 3).Enqueue(ctx, "mail", mailPayload{})
+		require.NoError(t, err)
+		waitGone(t, repo, job.ID)
+		assert.Equal(t, int32(3), calls.Load())
+		assert.Equal(t, 2.0, testutil.ToFloat64(metrics.processed.WithLabelValues("mail", outcomeRetried)))
+	})
+
+	t.Run("fails once attempts are exhausted", func(t *testing.T) {
+		p, repo, _ := newTestPool(t, fastOptions)
+		var calls atomic.Int32
+		Register(p, "mail", func(ctx context.Context, m mailPayload) error {
+			calls.Add(1)
+			return errors.New("smtp unavailable")
+		})
+		p.Start()
+
+		job, err := NewQueue(repo, 3).Enqueue(ctx, "mail", mailPayload{})
+		require.NoError(t, err)
+		failed := waitStatus(t, repo, job.ID, repository.JobFailed)
+		assert.Equal(t, 3, failed.Attempts)
+		assert.Equal(t, "smtp unavailable", failed.LastError)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("permanent errors are not retried", func(t *testing.T) {
+		p, repo, _ := newTestPool(t, fastOptions)
+		Register(p, "mail", func(ctx context.Context, m mailPayload) error {
+			return Permanent(errors.New("no such mailbox"))
+		})
+		p.Start()
+
+		job, err := NewQueue(repo, 3).Enqueue(ctx, "mail", mailPayload{})
+		require.NoError(t, err)
+		failed := waitStatus(t, repo, job.ID, repository.JobFailed)
+		assert.Equal(t, 1, failed.Attempts)
+		assert.Equal(t, "no such mailbox", failed.LastError)
+	})
+
+	t.Run("undecodable payload fails permanently", func(t *testing.T) {
+		p, repo, _ := newTestPool(t, fastOptions)
+		Register(p, "mail", func(ctx context.Context, m mailPayload) error { return nil })
+		p.Start()
+
+		job, err := NewQueue(repo, 3).Enqueue(ctx, "mail", []int{1})
+		require.NoError(t, err)
+		failed := waitStatus(t, repo, job.ID, repository.JobFailed)
+		assert.Equal(t, 1, failed.Attempts)
+		assert.Contains(t, failed.LastError, "decode payload")
+	})
+
+	t.Run("panics are retried", func(t *testing.T) {
+		p, repo, _ := newTestPool(t, fastOptions)
+		var calls atomic.Int32
+		Register(p, "mail", func(ctx context.Context, m mailPayload) error {
+			if calls.Add(1) == 1 {
+				panic("nil map")
+			}
+			return nil
+		})
+		p.Start()
+
+		job, err := NewQueue(repo, 3).Enqueue(ctx, "mail", mailPayload{})
+		require.NoError(t, err)
+		waitGone(t, repo, job.ID)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("other kinds are left alone", func(t *testing.T) {
+		p, repo, _ := newTestPool(t, fastOptions)
+		Register(p, "mail", func(ctx context.Context, m mailPayload) error { return nil })
+		p.Start()
+
+		job, err := NewQueue(repo, 3).Enqueue(ctx, "thumbnail", mailPayload{})
+		require.NoError(t, err)
+		time.Sleep(50 * time.Millisecond)
+		left, ok := repo.Get(job.ID)
+		require.True(t, ok)
+		assert.Equal(t, repository.JobQueued, left.Status)
+	})
+}
+
+func TestPool_VisibilityTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("job of a crashed worker runs again", func(t *testing.T) {
+		p, repo, _ := newTestPool(t, fastOptions)
+		var calls atomic.Int32
+		Register(p, "mail", func(ctx context.Context, m mailPayload) error {
+			calls.Add(1)
+			return nil
+		})
+
+		job, err := NewQueue(repo, 3).Enqueue(ctx, "mail", mailPayload{})
+		require.NoError(t, err)
+		// A worker claims the job and dies without reporting on it.
+		_, err = repo.Claim(ctx, []string{"mail"}, time.Now().UTC(), 50*time.Millisecond, 1)
+		require.NoError(t, err)
+		p.Start()
+
+		waitGone(t, repo, job.ID)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("job crashing its workers every time fails", func(t *testing.T) {
+		p, repo, _ := newTestPool(t, fastOptions)
+		Register(p, "mail", func(ctx context.Context, m mailPayload) error { return nil })
+
+		job, err := NewQueue(repo, 1).Enqueue(ctx, "mail", mailPayload{})
+		require.NoError(t, err)
+		_, err = repo.Claim(ctx, []string{"mail"}, time.Now().UTC(), time.Millisecond, 1)
+		require.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+		p.Start()
+
+		failed := waitStatus(t, repo, job.ID, repository.JobFailed)
+		assert.Contains(t, failed.LastError, "visibility timeout expired")
+	})
+
+	t.Run("running jobs keep their claim", func(t *testing.T) {
+		opts := fastOptions
+		opts.VisibilityTimeout = 40 * time.Millisecond
+		p, repo, _ := newTestPool(t, opts)
+		var calls atomic.Int32
+		Register(p, "mail", func(ctx context.Context, m mailPayload) error {
+			calls.Add(1)
+			time.Sleep(150 * time.Millisecond)
+			return nil
+		})
+		p.Start()
+
+		job, err := NewQueue(repo, 3).Enqueue(ctx, "mail", mailPayload{})
+		require.NoError(t, err)
+		waitGone(t, repo, job.ID)
+		assert.Equal(t, int32(1), calls.Load(), "the job was not claimed twice")
+	})
+
+	t.Run("lost claim cancels the job", func(t *testing.T) {
+		opts := fastOptions
+		opts.Workers = 1
+		opts.VisibilityTimeout = 40 * time.Millisecond
+		p, repo, metrics := newTestPool(t, opts)
+		canceled := make(chan struct{})
+		var calls atomic.Int32
+		Register(p, "mail", func(ctx context.Context, m mailPayload) error {
+			if calls.Add(1) > 1 {
+				// The run of the worker that took the job over.
+				return nil
+			}
+			<-ctx.Done()
+			close(canceled)
+			return ctx.Err()
+		})
+		p.Start()
+
+		job, err := NewQueue(repo, 3).Enqueue(ctx, "mail", mailPayload{})
+		require.NoError(t, err)
+		held := waitStatus(t, repo, job.ID, repository.JobRunning)
+		// Another worker takes the job over, as if the claim had expired.
+		require.NoError(t, repo.Release(ctx, &held))
+
+		select {
+		case <-canceled:
+		case <-time.After(2 * time.Second):
+			t.Fatal("job was not canceled")
+		}
+		assert.Eventually(t, func() bool {
+			return testutil.ToFloat64(metrics.processed.WithLabelValues("mail", outcomeLost)) == 1
+		}, time.Second, 5*time.Millisecond)
+	})
+}
+
+func TestPool_Shutdown(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("waits for running jobs", func(t *testing.T) {
+		p, repo, _ := newTestPool(t, fastOptions)
+		started := make(chan struct{})
+		Register(p, "mail", func(ctx context.Context, m mailPayload) error {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			return ctx.Err()
+		})
+		p.Start()
+
+		job, err := NewQueue(repo, 3).Enqueue(ctx, "mail", mailPayload{})
+		require.NoError(t, err)
+		<-started
+		require.NoError(t, p.Shutdown(ctx))
+		_, ok := repo.Get(job.ID)
+		assert.False(t, ok, "the job completed")
+	})
+
+	t.Run("requeues jobs still running at the deadline", func(t *testing.T) {
+		p, repo, metrics := newTestPool(t, fastOptions)
+		started := make(chan struct{})
+		Register(p, "mail", func(ctx context.Context, m mailPayload) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		p.Start()
+
+		job, err := NewQueue(repo, 3).Enqueue(ctx, "mail", mailPayload{})
+		require.NoError(t, err)
+		<-started
+		drain, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, p.Shutdown(drain), context.DeadlineExceeded)
+
+		released, ok := repo.Get(job.ID)
+		require.True(t, ok)
+		assert.Equal(t, repository.JobQueued, released.Status)
+		assert.Zero(t, released.Attempts, "an interrupted run is not counted")
+		assert.Equal(t, 1.0, testutil.ToFloat64(metrics.processed.WithLabelValues("mail", outcomeReleased)))
+	})
+
+	t.Run("stops claiming", func(t *testing.T) {
+		p, repo, _ := newTestPool(t, fastOptions)
+		Register(p, "mail", func(ctx context.Context, m mailPayload) error { return nil })
+		p.Start()
+		require.NoError(t, p.Shutdown(ctx))
+
+		job, err := NewQueue(repo, 3).Enqueue(ctx, "mail", mailPayload{})
+		require.NoError(t, err)
+		time.Sleep(30 * time.Millisecond)
+		left, ok := repo.Get(job.ID)
+		require.True(t, ok)
+		assert.Equal(t, repository.JobQueued, left.Status)
+	})
+}
+
+// failingClaims is a job repository whose claims fail while fail is set.
+type failingClaims struct {
+	*memory.JobMemory
+	fail atomic.Bool
+}
+
+func (r *failingClaims) Claim(ctx context.Context, kinds []string, now time.Time, lease time.Duration, limit int) ([]repository.Job, error) {
+	if r.fail.Load() {
+		return nil, errors.New("connection refused")
+	}
+	return r.JobMemory.Claim(ctx, kinds, now, lease, limit)
+}
+
+func TestPool_Healthy(t *testing.T) {
+	repo := &failingClaims{JobMemory: memory.NewJobMemory()}
+	p := NewPool(repo, fastOptions, nil)
+	Register(p, "mail", func(ctx context.Context, m mailPayload) error { return nil })
+	assert.EqualError(t, p.Healthy(), "job workers not started")
+
+	p.Start()
+	assert.Eventually(t, func() bool { return p.Healthy() == nil }, time.Second, 5*time.Millisecond)
+
+	repo.fail.Store(true)
+	assert.Eventually(t, func() bool { return p.Healthy() != nil }, time.Second, 5*time.Millisecond)
+	assert.EqualError(t, p.Healthy(), "claim jobs: connection refused")
+
+	repo.fail.Store(false)
+	assert.Eventually(t, func() bool { return p.Healthy() == nil }, time.Second, 5*time.Millisecond, "recovers with the next claim")
+
+	require.NoError(t, p.Shutdown(context.Background()))
+	assert.EqualError(t, p.Healthy(), "job workers shut down")
+}
+
+func TestPool_Backoff(t *testing.T) {
+	p := NewPool(memory.NewJobMemory(), PoolOptions{BackoffBase: 10 * time.Second, BackoffMax: time.Minute}, nil)
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, 5 * time.Second, 10 * time.Second},
+		{2, 10 * time.Second, 20 * time.Second},
+		{3, 20 * time.Second, 40 * time.Second},
+		{4, 30 * time.Second, time.Minute},
+		{100, 30 * time.Second, time.Minute},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			d := p.backoff(tt.attempt)
+			assert.GreaterOrEqual(t, d, tt.min, "attempt %d", tt.attempt)
+			assert.LessOrEqual(t, d, tt.max, "attempt %d", tt.attempt)
+		}
+	}
+}
+
+func TestRegister_Twice(t *testing.T) {
+	p := NewPool(memory.NewJobMemory(), PoolOptions{}, nil)
+	Register(p, "mail", func(ctx context.Context, m mailPayload) error { return nil })
+	assert.Panics(t, func() {
+		Register(p, "mail", func(ctx context.Context, m mailPayload) error { return nil })
+	})
+	assert.Equal(t, []string{"mail"}, p.Kinds())
+}
@@ -2,14 +2,78 @@ package model
 
 import "time"
 
+// Scan statuses of a document's content.
+const (
+	// ScanUnscanned marks content stored while scanning was disabled, or before it was introduced.
+	ScanUnscanned = "unscanned"
+	// ScanClean marks content in which the scanner found no malware.
+	ScanClean = "clean"
+	// ScanInfected marks content in which the scanner found malware. It is quarantined: kept for
+	// investigation but never served.
+	ScanInfected = "infected"
+)
+
+// Processing statuses of a document. A new document is pending until it has been processed, and
+// its content is only served while it is available. Processing happens within the upload
+// request, so clients only see documents that are available, quarantined or failed.
+const (
+	// StatusPending marks a document whose content is stored but not yet processed.
+	StatusPending = "pending"
+	// StatusProcessing marks a document being processed, e.g. scanned for malware.
+	StatusProcessing = "processing"
+	// StatusAvailable marks a processed document whose content may be served.
+	StatusAvailable = "available"
+	// StatusQuarantined marks a document whose content was found infected. It is kept for review
+	// until an administrator releases or rejects it.
+	StatusQuarantined = "quarantined"
+	// StatusFailed marks a document that could not be processed or was rejected after review. Its
+	// content is never served.
+	StatusFailed = "failed"
+)
+
 // Document represents a stored file in the system.
 // This is a pure domain model with no database-specific dependencies or tags.
 // It can be used across layers (HTTP, service, storage) without coupling to persistence.
 type Document struct {
 	ID          string    `json:"id"`
 	Filename    string    `json:"filename"`
+	Name        string    `json:"name"` // original filename as uploaded
 	StoragePath string    `json:"storage_path"`
 	Size        int64     `json:"size"`
+	StoredSize  int64     `json:"stored_size"`
 	ContentType string    `json:"content_type"`
 	CreatedAt   time.Time `json:"created_at"`
+	// Metadata holds free-form string attributes, such as where an imported document came from.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Tags are free-form labels, in the order they were set.
+	Tags []string `json:"tags,omitempty"`
+	// UpdatedAt is when the document was created or its attributes last changed.
+	UpdatedAt time.Time `json:"updated_at"`
+	// ContentETag is the storage ETag of the document's object, recorded when it was stored. It is
+	// empty for documents stored before it was recorded.
+	ContentETag string `json:"-"`
+	// Version starts at 1 and is incremented by every update, for optimistic concurrency control.
+	Version int64 `json:"version"`
+	// ScanStatus is the verdict of the malware scan of the content: ScanUnscanned, ScanClean or
+	// ScanInfected.
+	ScanStatus string `json:"scan_status"`
+	// ScanEngine identifies the engine and signature database that scanned the content.
+	ScanEngine string `json:"scan_engine,omitempty"`
+	// ScanSignature names the malware found in infected content.
+	ScanSignature string `json:"scan_signature,omitempty"`
+	// ScannedAt is when the content was scanned; nil if it was not.
+	ScannedAt *time.Time `json:"scanned_at,omitempty"`
+	// Status is the processing status: StatusAvailable, StatusQuarantined or StatusFailed.
+	// StatusPending and StatusProcessing only last while the document is being uploaded.
+	Status string `json:"status"`
+}
+
+// Available reports whether the document's content may be served.
+func (d *Document) Available() bool {
+	return d.Status == StatusAvailable
+}
+
+// Quarantined reports whether the document's content was found infected and awaits review.
+func (d *Document) Quarantined() bool {
+	return d.Status == StatusQuarantined
 }
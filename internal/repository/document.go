@@ -2,32 +2,168 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"docapi/internal/model"
 )
 
+// ErrVersionConflict is returned by Update and DeleteVersion when the document was changed since
+// the given version.
+var ErrVersionConflict = errors.New("version conflict")
+
+// ErrStatusConflict is returned by UpdateStatus when the document's status is no longer the
+// expected one.
+var ErrStatusConflict = errors.New("status conflict")
+
 // DocumentRepository defines data access for documents using SQL queries only.
 // No business logic here — strictly persistence operations.
 type DocumentRepository interface {
 	// Create inserts a new document record.
 	// The caller should provide required fields (e.g., ID, CreatedAt) according to the database schema defaults.
+	// The version of a new document is 1, UpdatedAt defaults to CreatedAt, ScanStatus to
+	// model.ScanUnscanned and Status to model.StatusPending.
 	// Returns the stored document (may include values set by the DB).
 	Create(ctx context.Context, doc *model.Document) (*model.Document, error)
 
-	// FindByID returns a document by its ID.
+	// Update replaces the mutable fields of the document doc.ID (Name, ContentType, Metadata and
+	// Tags), sets its updated_at to doc.UpdatedAt and increments its version, provided its version
+	// is still doc.Version. It returns the stored document, sql.ErrNoRows if it does not exist, or
+	// ErrVersionConflict if it was changed meanwhile.
+	Update(ctx context.Context, doc *model.Document) (*model.Document, error)
+
+	// UpdateStatus sets the status of the document doc.ID to doc.Status, together with the state
+	// that changes along with it (StoragePath, ContentETag and the scan result), sets its updated_at
+	// to doc.UpdatedAt and increments its version, provided its status is still from. It returns
+	// the stored document, sql.ErrNoRows if it does not exist, or ErrStatusConflict if its status
+	// was changed meanwhile.
+	UpdateStatus(ctx context.Context, doc *model.Document, from string) (*model.Document, error)
+
+	// FindByID returns a document by its ID, or sql.ErrNoRows if it does not exist.
 	FindByID(ctx context.Context, id string) (*model.Document, error)
 
 	// List returns a paginated list of documents and total rows count for the given filter.
+	// Items are ordered by pq.Sort (created_at DESC by default), then by id in the same direction,
+	// so pages are stable when sort keys tie. Result.Next is set when more items follow the page.
 	List(ctx context.Context, pq PageQuery) (*PageResult[model.Document], error)
 
+	// FindByIDs returns the documents with the given IDs in no particular order. IDs without a
+	// document are skipped.
+	FindByIDs(ctx context.Context, ids []string) ([]model.Document, error)
+
+	// FindByStoragePaths returns the documents stored under the given object keys in no particular
+	// order. Keys without a document are skipped.
+	FindByStoragePaths(ctx context.Context, paths []string) ([]model.Document, error)
+
 	// Delete removes a document by ID. It returns nil if the row was deleted or did not exist.
 	Delete(ctx context.Context, id string) error
+
+	// DeleteVersion removes the document id provided its version is still version. It returns
+	// sql.ErrNoRows if it does not exist, or ErrVersionConflict if it was changed meanwhile.
+	DeleteVersion(ctx context.Context, id string, version int64) error
+
+	// DeleteMany removes the documents with the given IDs in a single statement and returns the
+	// number of rows deleted. IDs without a document are ignored.
+	DeleteMany(ctx context.Context, ids []string) (int64, error)
+
+	// DeleteUnmoved removes the given documents in a single statement, each only if its
+	// storage_path still equals its StoragePath, and returns the number of rows deleted.
+	// Documents whose content moved since they were read are kept.
+	DeleteUnmoved(ctx context.Context, docs []model.Document) (int64, error)
 }
 
-// PageQuery holds limit/offset pagination parameters.
+// PageQuery holds pagination parameters. Pages are selected by Offset unless After is set.
 type PageQuery struct {
 	Limit  int
 	Offset int
+	// After selects keyset pagination: only items strictly after this position in the listing
+	// order are returned, and Offset is ignored. Unlike offsets, keyset pages neither skip nor
+	// repeat items when rows are inserted or deleted between requests.
+	After *Cursor
+	// SkipTotal skips counting all rows; Total is then -1.
+	SkipTotal bool
+	// Filter restricts the listed items; the zero value matches everything.
+	Filter DocumentFilter
+	// Sort orders the listing; the zero value is created_at DESC.
+	Sort Sort
+}
+
+// DocumentFilter restricts a document listing. Conditions are combined with AND.
+type DocumentFilter struct {
+	// ContentType matches the media type, ignoring parameters and case. It is either exact
+	// ("application/pdf") or a wildcard over the subtype ("image/*").
+	ContentType string
+	// CreatedFrom and CreatedTo bound created_at to [CreatedFrom, CreatedTo); zero means unbounded.
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// MinSize and MaxSize bound size inclusively; nil means unbounded.
+	MinSize *int64
+	MaxSize *int64
+	// Name matches a case-insensitive substring of the original filename.
+	Name string
+	// Statuses matches documents in any of the given statuses; empty matches every status.
+	Statuses []string
+}
+
+// SortField is a column documents can be listed by.
+type SortField string
+
+// Sortable fields.
+const (
+	SortCreatedAt   SortField = "created_at"
+	SortName        SortField = "name"
+	SortSize        SortField = "size"
+	SortContentType SortField = "content_type"
+)
+
+// Valid reports whether f is one of the sortable fields.
+func (f SortField) Valid() bool {
+	switch f {
+	case SortCreatedAt, SortName, SortSize, SortContentType:
+		return true
+	}
+	return false
+}
+
+// Sort is a listing order. Strings compare bytewise.
+type Sort struct {
+	Field SortField
+	Asc   bool
+}
+
+// DefaultSort lists the newest documents first.
+var DefaultSort = Sort{Field: SortCreatedAt}
+
+// OrDefault returns s, or DefaultSort if s has no field.
+func (s Sort) OrDefault() Sort {
+	if s.Field == "" {
+		return DefaultSort
+	}
+	return s
+}
+
+// String formats s as the field name, prefixed with "-" when descending.
+func (s Sort) String() string {
+	s = s.OrDefault()
+	if s.Asc {
+		return string(s.Field)
+	}
+	return "-" + string(s.Field)
+}
+
+// Cursor is the position of an item in a listing. It carries every sortable field so it
+// serves any order.
+type Cursor struct {
+	CreatedAt   time.Time
+	Name        string
+	Size        int64
+	ContentType string
+	ID          string
+}
+
+// CursorOf returns the position of doc.
+func CursorOf(doc model.Document) *Cursor {
+	return &Cursor{CreatedAt: doc.CreatedAt, Name: doc.Name, Size: doc.Size, ContentType: doc.ContentType, ID: doc.ID}
 }
 
 // PageResult is a generic pagination result wrapper.
@@ -35,4 +171,6 @@ type PageQuery struct {
 type PageResult[T any] struct {
 	Items []T
 	Total int
+	// Next is the position of the last item, for use as PageQuery.After; nil on the last page.
+	Next *Cursor
 }
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotencyRecord is the outcome of a request sent with an Idempotency-Key. While the request is
+// in flight Status is zero; once completed the record holds the response to replay.
+type IdempotencyRecord struct {
+	// Principal and Key identify the record: keys are scoped to the client that chose them.
+	Principal string
+	Key       string
+	// Fingerprint identifies the request, so that a key reused for another request is detected.
+	Fingerprint string
+	Status      int
+	Headers     map[string]string
+	Body        []byte
+	// CreatedAt is when the key was claimed; it also identifies the claim in Complete and Release.
+	CreatedAt time.Time
+	// ExpiresAt is when the record may be replaced: the lock timeout of an in-flight request or
+	// the retention of a completed one.
+	ExpiresAt time.Time
+}
+
+// IdempotencyRepository stores idempotency records.
+type IdempotencyRepository interface {
+	// Acquire claims rec.Principal and rec.Key for an in-flight request. If the key is held by a
+	// record that has not expired by rec.CreatedAt, that record is returned unchanged; otherwise
+	// rec is stored and Acquire returns nil.
+	Acquire(ctx context.Context, rec *IdempotencyRecord) (*IdempotencyRecord, error)
+
+	// Complete stores the response (Status, Headers and Body) and ExpiresAt of the claim made by
+	// rec. It does nothing if the claim expired and the key was claimed again.
+	Complete(ctx context.Context, rec *IdempotencyRecord) error
+
+	// Release removes the claim made by rec so that the request can be retried. It does nothing
+	// if the claim expired and the key was claimed again.
+	Release(ctx context.Context, rec *IdempotencyRecord) error
+
+	// DeleteExpired removes the records that expired by now and returns how many were removed.
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Job statuses. A queued job waits until RunAt; a running job is held by a worker until
+// LockedUntil; a failed job exhausted its attempts or failed permanently. Succeeded jobs are
+// deleted.
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobFailed  = "failed"
+)
+
+// ErrJobLost is returned when a worker reports on a job it no longer holds: its visibility
+// timeout expired and another worker claimed it, or the job was removed.
+var ErrJobLost = errors.New("job no longer held")
+
+// Job is a unit of background work.
+type Job struct {
+	ID string
+	// Kind selects the handler that runs the job.
+	Kind string
+	// Payload is the JSON-encoded input of the handler.
+	Payload json.RawMessage
+	Status  string
+	// Attempts counts the runs started so far, including the current one. Together with ID it
+	// identifies a claim, so that a worker whose claim expired cannot report on a later run.
+	Attempts    int
+	MaxAttempts int
+	// RunAt is when a queued job becomes due.
+	RunAt time.Time
+	// LockedUntil is when the claim of a running job expires.
+	LockedUntil *time.Time
+	// LastError is the error of the last failed run.
+	LastError string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// JobCount is the number of jobs of one kind in one status.
+type JobCount struct {
+	Kind   string
+	Status string
+	Count  int
+}
+
+// JobRepository stores background jobs. Extend, Complete, Retry, Release and Fail act on the
+// claim identified by job.ID and job.Attempts and return ErrJobLost if the job is no longer
+// running under that claim.
+type JobRepository interface {
+	// Enqueue stores a new job. Status is set to queued and Attempts to zero; a zero RunAt
+	// means now.
+	Enqueue(ctx context.Context, job *Job) (*Job, error)
+
+	// Claim marks up to limit jobs of the given kinds as running until now+lease and returns
+	// them, oldest due first. A job is due when it is queued with RunAt at or before now, or
+	// running with an expired claim. Jobs claimed by others are skipped rather than waited for.
+	Claim(ctx context.Context, kinds []string, now time.Time, lease time.Duration, limit int) ([]Job, error)
+
+	// Extend moves the claim on job forward to until.
+	Extend(ctx context.Context, job *Job, until time.Time) error
+
+	// Complete deletes a job that succeeded.
+	Complete(ctx context.Context, job *Job) error
+
+	// Retry queues job again to run at runAt, recording lastErr.
+	Retry(ctx context.Context, job *Job, runAt time.Time, lastErr string) error
+
+	// Release queues job again to run right away without counting the current attempt, for
+	// runs interrupted by a shutdown.
+	Release(ctx context.Context, job *Job) error
+
+	// Fail marks job as failed for good, recording lastErr.
+	Fail(ctx context.Context, job *Job, lastErr string) error
+
+	// Count returns the number of jobs by kind and status.
+	Count(ctx context.Context) ([]JobCount, error)
+}
@@ -0,0 +1,343 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"docapi/internal/model"
+	"docapi/internal/repository"
+)
+
+// ErrDuplicateID is returned by Create when a document with the same ID already exists.
+var ErrDuplicateID = errors.New("duplicate document id")
+
+// DocumentMemory is an in-memory implementation of repository.DocumentRepository.
+// It is intended for tests and local development and mirrors the PostgreSQL semantics.
+type DocumentMemory struct {
+	mu   sync.RWMutex
+	docs map[string]model.Document
+}
+
+// NewDocumentMemory creates an empty in-memory document repository.
+func NewDocumentMemory() *DocumentMemory {
+	return &DocumentMemory{docs: make(map[string]model.Document)}
+}
+
+var _ repository.DocumentRepository = (*DocumentMemory)(nil)
+
+// Create stores a copy of doc at version 1. It fails if a document with the same ID already exists.
+func (r *DocumentMemory) Create(ctx context.Context, doc *model.Document) (*model.Document, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.docs[doc.ID]; ok {
+		return nil, ErrDuplicateID
+	}
+	stored := clone(*doc)
+	stored.Version = 1
+	if stored.UpdatedAt.IsZero() {
+		stored.UpdatedAt = stored.CreatedAt
+	}
+	if stored.ScanStatus == "" {
+		stored.ScanStatus = model.ScanUnscanned
+	}
+	if stored.Status == "" {
+		stored.Status = model.StatusPending
+	}
+	r.docs[doc.ID] = stored
+	out := clone(stored)
+	return &out, nil
+}
+
+// Update replaces the mutable fields of the stored document if its version is doc.Version.
+func (r *DocumentMemory) Update(ctx context.Context, doc *model.Document) (*model.Document, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.docs[doc.ID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	if stored.Version != doc.Version {
+		return nil, repository.ErrVersionConflict
+	}
+	stored.Name = doc.Name
+	stored.ContentType = doc.ContentType
+	stored.Metadata = maps.Clone(doc.Metadata)
+	stored.Tags = slices.Clone(doc.Tags)
+	stored.UpdatedAt = doc.UpdatedAt
+	stored.Version++
+	r.docs[doc.ID] = stored
+	out := clone(stored)
+	return &out, nil
+}
+
+// UpdateStatus sets the status and the state that goes with it if the stored status is from.
+func (r *DocumentMemory) UpdateStatus(ctx context.Context, doc *model.Document, from string) (*model.Document, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.docs[doc.ID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	if stored.Status != from {
+		return nil, repository.ErrStatusConflict
+	}
+	stored.Status = doc.Status
+	stored.StoragePath = doc.StoragePath
+	stored.ContentETag = doc.ContentETag
+	stored.ScanStatus = doc.ScanStatus
+	stored.ScanEngine = doc.ScanEngine
+	stored.ScanSignature = doc.ScanSignature
+	stored.ScannedAt = doc.ScannedAt
+	stored.UpdatedAt = doc.UpdatedAt
+	stored.Version++
+	stored = clone(stored)
+	r.docs[doc.ID] = stored
+	out := clone(stored)
+	return &out, nil
+}
+
+// clone returns a copy of d that shares no maps or slices with it.
+func clone(d model.Document) model.Document {
+	d.Metadata = maps.Clone(d.Metadata)
+	d.Tags = slices.Clone(d.Tags)
+	if d.ScannedAt != nil {
+		t := *d.ScannedAt
+		d.ScannedAt = &t
+	}
+	return d
+}
+
+// FindByID returns a copy of the document or sql.ErrNoRows.
+func (r *DocumentMemory) FindByID(ctx context.Context, id string) (*model.Document, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.docs[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	d = clone(d)
+	return &d, nil
+}
+
+// FindByIDs returns copies of the documents with the given IDs.
+func (r *DocumentMemory) FindByIDs(ctx context.Context, ids []string) ([]model.Document, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	docs := make([]model.Document, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if d, ok := r.docs[id]; ok && !seen[id] {
+			seen[id] = true
+			docs = append(docs, d)
+		}
+	}
+	return docs, nil
+}
+
+// List returns filtered documents in the requested order using limit/offset or keyset pagination.
+func (r *DocumentMemory) List(ctx context.Context, pq repository.PageQuery) (*repository.PageResult[model.Document], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	order := pq.Sort.OrDefault()
+	if !order.Field.Valid() {
+		return nil, fmt.Errorf("unsupported sort field %q", order.Field)
+	}
+	r.mu.RLock()
+	all := make([]model.Document, 0, len(r.docs))
+	for _, d := range r.docs {
+		if matches(pq.Filter, d) {
+			all = append(all, d)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		return before(order, repository.CursorOf(all[i]), repository.CursorOf(all[j]))
+	})
+
+	start := pq.Offset
+	if pq.After != nil {
+		start = sort.Search(len(all), func(i int) bool {
+			return before(order, pq.After, repository.CursorOf(all[i]))
+		})
+	}
+	items := make([]model.Document, 0)
+	var next *repository.Cursor
+	if start < len(all) {
+		end := len(all)
+		if pq.Limit >= 0 && start+pq.Limit < end {
+			end = start + pq.Limit
+			if end > start {
+				next = repository.CursorOf(all[end-1])
+			}
+		}
+		items = append(items, all[start:end]...)
+	}
+	total := len(all)
+	if pq.SkipTotal {
+		total = -1
+	}
+	return &repository.PageResult[model.Document]{Items: items, Total: total, Next: next}, nil
+}
+
+// before reports whether a precedes b in the given order, breaking ties by id.
+func before(order repository.Sort, a, b *repository.Cursor) bool {
+	c := 0
+	switch order.Field {
+	case repository.SortName:
+		c = strings.Compare(a.Name, b.Name)
+	case repository.SortSize:
+		c = cmp.Compare(a.Size, b.Size)
+	case repository.SortContentType:
+		c = strings.Compare(a.ContentType, b.ContentType)
+	default:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if c == 0 {
+		c = strings.Compare(a.ID, b.ID)
+	}
+	if order.Asc {
+		return c < 0
+	}
+	return c > 0
+}
+
+// matches mirrors the PostgreSQL filter semantics.
+func matches(f repository.DocumentFilter, d model.Document) bool {
+	if f.ContentType != "" {
+		mediaType, _, _ := strings.Cut(d.ContentType, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		pattern := strings.ToLower(f.ContentType)
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+			if !strings.HasPrefix(mediaType, prefix+"/") {
+				return false
+			}
+		} else if mediaType != pattern {
+			return false
+		}
+	}
+	if !f.CreatedFrom.IsZero() && d.CreatedAt.Before(f.CreatedFrom) {
+		return false
+	}
+	if !f.CreatedTo.IsZero() && !d.CreatedAt.Before(f.CreatedTo) {
+		return false
+	}
+	if (f.MinSize != nil && d.Size < *f.MinSize) || (f.MaxSize != nil && d.Size > *f.MaxSize) {
+		return false
+	}
+	if f.Name != "" && !strings.Contains(strings.ToLower(d.Name), strings.ToLower(f.Name)) {
+		return false
+	}
+	if len(f.Statuses) > 0 && !slices.Contains(f.Statuses, d.Status) {
+		return false
+	}
+	return true
+}
+
+// FindByStoragePaths returns copies of the documents stored under the given keys.
+func (r *DocumentMemory) FindByStoragePaths(ctx context.Context, paths []string) ([]model.Document, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	want := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		want[p] = true
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	docs := make([]model.Document, 0, len(paths))
+	for _, d := range r.docs {
+		if want[d.StoragePath] {
+			docs = append(docs, d)
+		}
+	}
+	return docs, nil
+}
+
+// Delete removes a document by ID. Missing rows are not an error.
+func (r *DocumentMemory) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	delete(r.docs, id)
+	r.mu.Unlock()
+	return nil
+}
+
+// DeleteVersion removes the stored document if its version is version.
+func (r *DocumentMemory) DeleteVersion(ctx context.Context, id string, version int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.docs[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if stored.Version != version {
+		return repository.ErrVersionConflict
+	}
+	delete(r.docs, id)
+	return nil
+}
+
+// DeleteMany removes the documents with the given IDs and returns how many existed.
+func (r *DocumentMemory) DeleteMany(ctx context.Context, ids []string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for _, id := range ids {
+		if _, ok := r.docs[id]; ok {
+			delete(r.docs, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// DeleteUnmoved removes the given documents that are still stored where docs says, and returns
+// how many were removed.
+func (r *DocumentMemory) DeleteUnmoved(ctx context.Context, docs []model.Document) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for _, d := range docs {
+		if stored, ok := r.docs[d.ID]; ok && stored.StoragePath == d.StoragePath {
+			delete(r.docs, d.ID)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"docapi/internal/repository"
+	"docapi/internal/repository/repotest"
+)
+
+func TestDocumentMemory_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repository.DocumentRepository {
+		return NewDocumentMemory()
+	})
+}
@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"docapi/internal/repository"
+)
+
+type idempotencyKey struct{ principal, key string }
+
+// IdempotencyMemory is an in-memory implementation of repository.IdempotencyRepository.
+// It is intended for tests and local development and mirrors the PostgreSQL semantics.
+type IdempotencyMemory struct {
+	mu      sync.Mutex
+	records map[idempotencyKey]repository.IdempotencyRecord
+}
+
+// NewIdempotencyMemory creates an empty in-memory idempotency repository.
+func NewIdempotencyMemory() *IdempotencyMemory {
+	return &IdempotencyMemory{records: make(map[idempotencyKey]repository.IdempotencyRecord)}
+}
+
+var _ repository.IdempotencyRepository = (*IdempotencyMemory)(nil)
+
+// Acquire stores a copy of rec unless an unexpired record holds its key, which is returned instead.
+func (r *IdempotencyMemory) Acquire(ctx context.Context, rec *repository.IdempotencyRecord) (*repository.IdempotencyRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k := idempotencyKey{rec.Principal, rec.Key}
+	if held, ok := r.records[k]; ok && held.ExpiresAt.After(rec.CreatedAt) {
+		held = cloneRecord(held)
+		return &held, nil
+	}
+	claim := cloneRecord(*rec)
+	claim.Status, claim.Headers, claim.Body = 0, nil, nil
+	r.records[k] = claim
+	return nil, nil
+}
+
+// Complete stores the response of the claim made by rec.
+func (r *IdempotencyMemory) Complete(ctx context.Context, rec *repository.IdempotencyRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k := idempotencyKey{rec.Principal, rec.Key}
+	held, ok := r.records[k]
+	if !ok || !held.CreatedAt.Equal(rec.CreatedAt) {
+		return nil
+	}
+	held.Status = rec.Status
+	held.Headers = maps.Clone(rec.Headers)
+	held.Body = slices.Clone(rec.Body)
+	held.ExpiresAt = rec.ExpiresAt
+	r.records[k] = held
+	return nil
+}
+
+// Release deletes the claim made by rec.
+func (r *IdempotencyMemory) Release(ctx context.Context, rec *repository.IdempotencyRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k := idempotencyKey{rec.Principal, rec.Key}
+	if held, ok := r.records[k]; ok && held.CreatedAt.Equal(rec.CreatedAt) {
+		delete(r.records, k)
+	}
+	return nil
+}
+
+// DeleteExpired deletes the records that expired by now.
+func (r *IdempotencyMemory) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for k, rec := range r.records {
+		if !rec.ExpiresAt.After(now) {
+			delete(r.records, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// cloneRecord returns a copy of rec that shares no maps or slices with it.
+func cloneRecord(rec repository.IdempotencyRecord) repository.IdempotencyRecord {
+	rec.Headers = maps.Clone(rec.Headers)
+	rec.Body = slices.Clone(rec.Body)
+	return rec
+}
@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"docapi/internal/repository"
+)
+
+func TestIdempotencyMemory(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	claim := func(fingerprint string, at time.Time) *repository.IdempotencyRecord {
+		return &repository.IdempotencyRecord{
+			Principal:   "alice",
+			Key:         "key-1",
+			Fingerprint: fingerprint,
+			CreatedAt:   at,
+			ExpiresAt:   at.Add(time.Minute),
+		}
+	}
+
+	t.Run("second claim returns the held record", func(t *testing.T) {
+		r := NewIdempotencyMemory()
+		held, err := r.Acquire(ctx, claim("fp", now))
+		require.NoError(t, err)
+		assert.Nil(t, held)
+
+		held, err = r.Acquire(ctx, claim("other", now.Add(time.Second)))
+		require.NoError(t, err)
+		require.NotNil(t, held)
+		assert.Equal(t, "fp", held.Fingerprint)
+		assert.Zero(t, held.Status)
+	})
+
+	t.Run("keys are scoped to the principal", func(t *testing.T) {
+		r := NewIdempotencyMemory()
+		_, err := r.Acquire(ctx, claim("fp", now))
+		require.NoError(t, err)
+
+		other := claim("fp", now)
+		other.Principal = "bob"
+		held, err := r.Acquire(ctx, other)
+		require.NoError(t, err)
+		assert.Nil(t, held)
+	})
+
+	t.Run("completed response is returned", func(t *testing.T) {
+		r := NewIdempotencyMemory()
+		rec := claim("fp", now)
+		_, err := r.Acquire(ctx, rec)
+		require.NoError(t, err)
+
+		rec.Status = 201
+		rec.Headers = map[string]string{"Content-Type": "application/json"}
+		rec.Body = []byte(`{"id":"1"}`)
+		rec.ExpiresAt = now.Add(24 * time.Hour)
+		require.NoError(t, r.Complete(ctx, rec))
+
+		held, err := r.Acquire(ctx, claim("fp", now.Add(time.Hour)))
+		require.NoError(t, err)
+		require.NotNil(t, held)
+		assert.Equal(t, 201, held.Status)
+		assert.Equal(t, rec.Headers, held.Headers)
+		assert.Equal(t, rec.Body, held.Body)
+	})
+
+	t.Run("expired claim is replaced", func(t *testing.T) {
+		r := NewIdempotencyMemory()
+		stale := claim("fp", now)
+		_, err := r.Acquire(ctx, stale)
+		require.NoError(t, err)
+
+		held, err := r.Acquire(ctx, claim("fp", now.Add(time.Minute)))
+		require.NoError(t, err)
+		assert.Nil(t, held)
+
+		// The stale claim can neither complete nor release the new one.
+		stale.Status = 201
+		require.NoError(t, r.Complete(ctx, stale))
+		require.NoError(t, r.Release(ctx, stale))
+		held, err = r.Acquire(ctx, claim("fp", now.Add(time.Minute+time.Second)))
+		require.NoError(t, err)
+		require.NotNil(t, held)
+		assert.Zero(t, held.Status)
+	})
+
+	t.Run("released key can be claimed again", func(t *testing.T) {
+		r := NewIdempotencyMemory()
+		rec := claim("fp", now)
+		_, err := r.Acquire(ctx, rec)
+		require.NoError(t, err)
+		require.NoError(t, r.Release(ctx, rec))
+
+		held, err := r.Acquire(ctx, claim("other", now.Add(time.Second)))
+		require.NoError(t, err)
+		assert.Nil(t, held)
+	})
+
+	t.Run("delete expired", func(t *testing.T) {
+		r := NewIdempotencyMemory()
+		_, err := r.Acquire(ctx, claim("fp", now))
+		require.NoError(t, err)
+
+		n, err := r.DeleteExpired(ctx, now)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+		n, err = r.DeleteExpired(ctx, now.Add(time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+	})
+}
@@ -0,0 +1,213 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"docapi/internal/repository"
+)
+
+// JobMemory is an in-memory implementation of repository.JobRepository.
+// It is intended for tests and local development and mirrors the PostgreSQL semantics.
+type JobMemory struct {
+	mu   sync.Mutex
+	jobs map[string]repository.Job
+}
+
+// NewJobMemory creates an empty in-memory job repository.
+func NewJobMemory() *JobMemory {
+	return &JobMemory{jobs: make(map[string]repository.Job)}
+}
+
+var _ repository.JobRepository = (*JobMemory)(nil)
+
+// Enqueue stores a copy of job as queued.
+func (r *JobMemory) Enqueue(ctx context.Context, job *repository.Job) (*repository.Job, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	stored := cloneJob(*job)
+	if len(stored.Payload) == 0 {
+		stored.Payload = []byte("{}")
+	}
+	if stored.CreatedAt.IsZero() {
+		stored.CreatedAt = time.Now().UTC()
+	}
+	if stored.RunAt.IsZero() {
+		stored.RunAt = stored.CreatedAt
+	}
+	stored.Status = repository.JobQueued
+	stored.Attempts = 0
+	stored.LockedUntil = nil
+	stored.LastError = ""
+	stored.UpdatedAt = stored.CreatedAt
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs[stored.ID] = stored
+	out := cloneJob(stored)
+	return &out, nil
+}
+
+// Claim marks up to limit due jobs as running, oldest due first.
+func (r *JobMemory) Claim(ctx context.Context, kinds []string, now time.Time, lease time.Duration, limit int) ([]repository.Job, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []repository.Job
+	for _, job := range r.jobs {
+		if !slices.Contains(kinds, job.Kind) {
+			continue
+		}
+		queued := job.Status == repository.JobQueued && !job.RunAt.After(now)
+		expired := job.Status == repository.JobRunning && job.LockedUntil != nil && !job.LockedUntil.After(now)
+		if queued || expired {
+			due = append(due, job)
+		}
+	}
+	slices.SortFunc(due, func(a, b repository.Job) int {
+		if c := a.RunAt.Compare(b.RunAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	until := now.Add(lease)
+	claimed := make([]repository.Job, 0, len(due))
+	for _, job := range due {
+		job.Status = repository.JobRunning
+		job.Attempts++
+		job.LockedUntil = &until
+		job.UpdatedAt = now
+		r.jobs[job.ID] = job
+		claimed = append(claimed, cloneJob(job))
+	}
+	return claimed, nil
+}
+
+// Extend moves the claim on job forward.
+func (r *JobMemory) Extend(ctx context.Context, job *repository.Job, until time.Time) error {
+	return r.update(ctx, job, func(held *repository.Job) {
+		held.LockedUntil = &until
+	})
+}
+
+// Complete deletes a job that succeeded.
+func (r *JobMemory) Complete(ctx context.Context, job *repository.Job) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.holds(job) {
+		return repository.ErrJobLost
+	}
+	delete(r.jobs, job.ID)
+	return nil
+}
+
+// Retry queues job again to run at runAt.
+func (r *JobMemory) Retry(ctx context.Context, job *repository.Job, runAt time.Time, lastErr string) error {
+	return r.update(ctx, job, func(held *repository.Job) {
+		held.Status = repository.JobQueued
+		held.RunAt = runAt
+		held.LockedUntil = nil
+		held.LastError = lastErr
+	})
+}
+
+// Release queues job again to run right away, giving back its attempt.
+func (r *JobMemory) Release(ctx context.Context, job *repository.Job) error {
+	return r.update(ctx, job, func(held *repository.Job) {
+		held.Status = repository.JobQueued
+		held.Attempts--
+		held.RunAt = time.Now().UTC()
+		held.LockedUntil = nil
+	})
+}
+
+// Fail marks job as failed for good.
+func (r *JobMemory) Fail(ctx context.Context, job *repository.Job, lastErr string) error {
+	return r.update(ctx, job, func(held *repository.Job) {
+		held.Status = repository.JobFailed
+		held.LockedUntil = nil
+		held.LastError = lastErr
+	})
+}
+
+// Count returns the number of jobs by kind and status.
+func (r *JobMemory) Count(ctx context.Context) ([]repository.JobCount, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	type group struct{ kind, status string }
+	n := make(map[group]int)
+	for _, job := range r.jobs {
+		n[group{job.Kind, job.Status}]++
+	}
+	counts := make([]repository.JobCount, 0, len(n))
+	for g, c := range n {
+		counts = append(counts, repository.JobCount{Kind: g.kind, Status: g.status, Count: c})
+	}
+	slices.SortFunc(counts, func(a, b repository.JobCount) int {
+		if c := strings.Compare(a.Kind, b.Kind); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Status, b.Status)
+	})
+	return counts, nil
+}
+
+// Get returns a copy of the job with the given ID, in any status. It lets tests inspect jobs
+// that the repository interface only hands out when they are claimed.
+func (r *JobMemory) Get(id string) (repository.Job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	return cloneJob(job), ok
+}
+
+// update applies fn to the job held under the claim of job.
+func (r *JobMemory) update(ctx context.Context, job *repository.Job, fn func(held *repository.Job)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.holds(job) {
+		return repository.ErrJobLost
+	}
+	held := r.jobs[job.ID]
+	fn(&held)
+	held.UpdatedAt = time.Now().UTC()
+	r.jobs[job.ID] = held
+	return nil
+}
+
+// holds reports whether job is running under the claim of job. r.mu must be held.
+func (r *JobMemory) holds(job *repository.Job) bool {
+	held, ok := r.jobs[job.ID]
+	return ok && held.Status == repository.JobRunning && held.Attempts == job.Attempts
+}
+
+// cloneJob returns a copy of job that shares no slices or pointers with it.
+func cloneJob(job repository.Job) repository.Job {
+	job.Payload = slices.Clone(job.Payload)
+	if job.LockedUntil != nil {
+		until := *job.LockedUntil
+		job.LockedUntil = &until
+	}
+	return job
+}
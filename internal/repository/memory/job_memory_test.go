@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"docapi/internal/repository"
+)
+
+func TestJobMemory(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	enqueue := func(t *testing.T, r *JobMemory, id, kind string, runAt time.Time) {
+		t.Helper()
+		_, err := r.Enqueue(ctx, &repository.Job{ID: id, Kind: kind, MaxAttempts: 3, RunAt: runAt, CreatedAt: now})
+		require.NoError(t, err)
+	}
+	ids := func(jobs []repository.Job) []string {
+		var out []string
+		for _, j := range jobs {
+			out = append(out, j.ID)
+		}
+		return out
+	}
+
+	t.Run("claims due jobs of the given kinds, oldest first", func(t *testing.T) {
+		r := NewJobMemory()
+		enqueue(t, r, "b", "mail", now.Add(-time.Minute))
+		enqueue(t, r, "a", "mail", now.Add(-time.Hour))
+		enqueue(t, r, "c", "mail", now.Add(time.Minute))
+		enqueue(t, r, "d", "thumbnail", now.Add(-time.Hour))
+
+		claimed, err := r.Claim(ctx, []string{"mail"}, now, time.Minute, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, ids(claimed))
+		for _, j := range claimed {
+			assert.Equal(t, repository.JobRunning, j.Status)
+			assert.Equal(t, 1, j.Attempts)
+			assert.Equal(t, now.Add(time.Minute), *j.LockedUntil)
+		}
+
+		// Claimed jobs are invisible until their claim expires.
+		claimed, err = r.Claim(ctx, []string{"mail"}, now.Add(30*time.Second), time.Minute, 10)
+		require.NoError(t, err)
+		assert.Empty(t, claimed)
+		claimed, err = r.Claim(ctx, []string{"mail"}, now.Add(time.Minute), time.Minute, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a"}, ids(claimed))
+		assert.Equal(t, 2, claimed[0].Attempts)
+	})
+
+	t.Run("stale claim cannot report", func(t *testing.T) {
+		r := NewJobMemory()
+		enqueue(t, r, "a", "mail", now)
+		first, err := r.Claim(ctx, []string{"mail"}, now, time.Minute, 1)
+		require.NoError(t, err)
+		second, err := r.Claim(ctx, []string{"mail"}, now.Add(time.Minute), time.Minute, 1)
+		require.NoError(t, err)
+		require.Len(t, second, 1)
+
+		stale := &first[0]
+		assert.ErrorIs(t, r.Extend(ctx, stale, now.Add(time.Hour)), repository.ErrJobLost)
+		assert.ErrorIs(t, r.Complete(ctx, stale), repository.ErrJobLost)
+		assert.ErrorIs(t, r.Retry(ctx, stale, now, "boom"), repository.ErrJobLost)
+		assert.ErrorIs(t, r.Release(ctx, stale), repository.ErrJobLost)
+		assert.ErrorIs(t, r.Fail(ctx, stale, "boom"), repository.ErrJobLost)
+		require.NoError(t, r.Complete(ctx, &second[0]))
+		_, ok := r.Get("a")
+		assert.False(t, ok)
+	})
+
+	t.Run("retry, release and fail", func(t *testing.T) {
+		r := NewJobMemory()
+		enqueue(t, r, "a", "mail", now)
+		claimed, err := r.Claim(ctx, []string{"mail"}, now, time.Minute, 1)
+		require.NoError(t, err)
+		require.NoError(t, r.Retry(ctx, &claimed[0], now.Add(time.Hour), "boom"))
+		job, _ := r.Get("a")
+		assert.Equal(t, repository.JobQueued, job.Status)
+		assert.Equal(t, "boom", job.LastError)
+		assert.Nil(t, job.LockedUntil)
+
+		claimed, err = r.Claim(ctx, []string{"mail"}, now.Add(time.Hour), time.Minute, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 2, claimed[0].Attempts)
+		require.NoError(t, r.Release(ctx, &claimed[0]))
+		job, _ = r.Get("a")
+		assert.Equal(t, 1, job.Attempts, "a released run is not counted")
+
+		claimed, err = r.Claim(ctx, []string{"mail"}, time.Now().UTC(), time.Minute, 1)
+		require.NoError(t, err)
+		require.NoError(t, r.Fail(ctx, &claimed[0], "bad payload"))
+		claimed, err = r.Claim(ctx, []string{"mail"}, now.Add(24*time.Hour), time.Minute, 1)
+		require.NoError(t, err)
+		assert.Empty(t, claimed, "failed jobs are never claimed")
+
+		counts, err := r.Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []repository.JobCount{{Kind: "mail", Status: repository.JobFailed, Count: 1}}, counts)
+	})
+}
@@ -20,6 +20,22 @@ func (m *MockDocumentRepository) Create(ctx context.Context, doc *model.Document
 	return args.Get(0).(*model.Document), args.Error(1)
 }
 
+func (m *MockDocumentRepository) Update(ctx context.Context, doc *model.Document) (*model.Document, error) {
+	args := m.Called(ctx, doc)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Document), args.Error(1)
+}
+
+func (m *MockDocumentRepository) UpdateStatus(ctx context.Context, doc *model.Document, from string) (*model.Document, error) {
+	args := m.Called(ctx, doc, from)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Document), args.Error(1)
+}
+
 func (m *MockDocumentRepository) FindByID(ctx context.Context, id string) (*model.Document, error) {
 	args := m.Called(ctx, id)
 	if args.Get(0) == nil {
@@ -28,6 +44,22 @@ func (m *MockDocumentRepository) FindByID(ctx context.Context, id string) (*mode
 	return args.Get(0).(*model.Document), args.Error(1)
 }
 
+func (m *MockDocumentRepository) FindByIDs(ctx context.Context, ids []string) ([]model.Document, error) {
+	args := m.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Document), args.Error(1)
+}
+
+func (m *MockDocumentRepository) FindByStoragePaths(ctx context.Context, paths []string) ([]model.Document, error) {
+	args := m.Called(ctx, paths)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Document), args.Error(1)
+}
+
 func (m *MockDocumentRepository) List(ctx context.Context, pq repository.PageQuery) (*repository.PageResult[model.Document], error) {
 	args := m.Called(ctx, pq)
 	if args.Get(0) == nil {
@@ -40,3 +72,18 @@ func (m *MockDocumentRepository) Delete(ctx context.Context, id string) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
 }
+
+func (m *MockDocumentRepository) DeleteVersion(ctx context.Context, id string, version int64) error {
+	args := m.Called(ctx, id, version)
+	return args.Error(0)
+}
+
+func (m *MockDocumentRepository) DeleteMany(ctx context.Context, ids []string) (int64, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockDocumentRepository) DeleteUnmoved(ctx context.Context, docs []model.Document) (int64, error) {
+	args := m.Called(ctx, docs)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -0,0 +1,40 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/require"
+
+	"docapi/internal/database"
+	"docapi/internal/repository"
+	"docapi/internal/repository/repotest"
+)
+
+// TestDocumentPostgres_Conformance runs the repository conformance suite against a real database.
+// It is skipped unless DOCAPI_TEST_DATABASE_DSN is set; the schema is migrated up first and
+// the documents table is truncated before every case.
+func TestDocumentPostgres_Conformance(t *testing.T) {
+	dsn := os.Getenv("DOCAPI_TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("DOCAPI_TEST_DATABASE_DSN not set")
+	}
+
+	db, err := sql.Open("pgx", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	m, err := database.NewMigrator(db)
+	require.NoError(t, err)
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+
+	repotest.Run(t, func(t *testing.T) repository.DocumentRepository {
+		_, err := db.Exec(`TRUNCATE documents`)
+		require.NoError(t, err)
+		return NewDocumentPostgres(db)
+	})
+}
@@ -3,7 +3,11 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"docapi/internal/model"
 	"docapi/internal/repository"
@@ -25,74 +29,201 @@ var _ repository.DocumentRepository = (*DocumentPostgres)(nil)
 // Create inserts a new document row and returns the stored record.
 func (r *DocumentPostgres) Create(ctx context.Context, doc *model.Document) (*model.Document, error) {
 	const q = `
-		INSERT INTO documents (id, filename, storage_path, size, content_type, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, filename, storage_path, size, content_type, created_at
+		INSERT INTO documents (id, filename, name, storage_path, size, stored_size, content_type, created_at, metadata, tags, updated_at, content_etag, scan_status, scan_engine, scan_signature, scanned_at, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		RETURNING id, filename, name, storage_path, size, stored_size, content_type, created_at, metadata, tags, updated_at, version, content_etag, scan_status, scan_engine, scan_signature, scanned_at, status
 	`
+	metadata, tags, err := encodeAttributes(doc)
+	if err != nil {
+		return nil, err
+	}
+	updatedAt := doc.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = doc.CreatedAt
+	}
+	scanStatus := doc.ScanStatus
+	if scanStatus == "" {
+		scanStatus = model.ScanUnscanned
+	}
+	status := doc.Status
+	if status == "" {
+		status = model.StatusPending
+	}
 	row := r.db.QueryRowContext(ctx, q,
 		doc.ID,
 		doc.Filename,
+		doc.Name,
 		doc.StoragePath,
 		doc.Size,
+		doc.StoredSize,
 		doc.ContentType,
 		doc.CreatedAt,
+		metadata,
+		tags,
+		updatedAt,
+		doc.ContentETag,
+		scanStatus,
+		doc.ScanEngine,
+		doc.ScanSignature,
+		doc.ScannedAt,
+		status,
 	)
-	var out model.Document
-	if err := row.Scan(
-		&out.ID,
-		&out.Filename,
-		&out.StoragePath,
-		&out.Size,
-		&out.ContentType,
-		&out.CreatedAt,
-	); err != nil {
+	out, err := scanDocument(row)
+	if err != nil {
 		return nil, err
 	}
-	return &out, nil
+	return out, nil
 }
 
 // FindByID fetches a single document by its ID.
 func (r *DocumentPostgres) FindByID(ctx context.Context, id string) (*model.Document, error) {
 	const q = `
-		SELECT id, filename, storage_path, size, content_type, created_at
+		SELECT id, filename, name, storage_path, size, stored_size, content_type, created_at, metadata, tags, updated_at, version, content_etag, scan_status, scan_engine, scan_signature, scanned_at, status
 		FROM documents
 		WHERE id = $1
 	`
-	row := r.db.QueryRowContext(ctx, q, id)
-	var d model.Document
-	if err := row.Scan(
-		&d.ID,
-		&d.Filename,
-		&d.StoragePath,
-		&d.Size,
-		&d.ContentType,
-		&d.CreatedAt,
-	); err != nil {
+	d, err := scanDocument(r.db.QueryRowContext(ctx, q, id))
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, err
 		}
 		return nil, err
 	}
-	return &d, nil
+	return d, nil
 }
 
-// List returns documents using LIMIT/OFFSET pagination and a total count.
-func (r *DocumentPostgres) List(ctx context.Context, pq repository.PageQuery) (*repository.PageResult[model.Document], error) {
-	// Count total rows
-	const qCount = `SELECT COUNT(*) FROM documents`
-	var total int
-	if err := r.db.QueryRowContext(ctx, qCount).Scan(&total); err != nil {
+// Update changes the mutable columns in one statement guarded by the expected version. When no row
+// matches, the document is looked up to tell a missing document from a conflicting edit.
+func (r *DocumentPostgres) Update(ctx context.Context, doc *model.Document) (*model.Document, error) {
+	const q = `
+		UPDATE documents
+		SET name = $2, content_type = $3, metadata = $4, tags = $5, updated_at = $6, version = version + 1
+		WHERE id = $1 AND version = $7
+		RETURNING id, filename, name, storage_path, size, stored_size, content_type, created_at, metadata, tags, updated_at, version, content_etag, scan_status, scan_engine, scan_signature, scanned_at, status
+	`
+	metadata, tags, err := encodeAttributes(doc)
+	if err != nil {
+		return nil, err
+	}
+	out, err := scanDocument(r.db.QueryRowContext(ctx, q, doc.ID, doc.Name, doc.ContentType, metadata, tags, doc.UpdatedAt, doc.Version))
+	if !errors.Is(err, sql.ErrNoRows) {
+		return out, err
+	}
+	if _, err := r.FindByID(ctx, doc.ID); err != nil {
+		return nil, err
+	}
+	return nil, repository.ErrVersionConflict
+}
+
+// UpdateStatus changes the status and the state that goes with it in one statement guarded by the
+// expected status. When no row matches, the document is looked up to tell a missing document from
+// a concurrent transition.
+func (r *DocumentPostgres) UpdateStatus(ctx context.Context, doc *model.Document, from string) (*model.Document, error) {
+	const q = `
+		UPDATE documents
+		SET status = $2, storage_path = $3, content_etag = $4, scan_status = $5, scan_engine = $6, scan_signature = $7, scanned_at = $8,
+			updated_at = $9, version = version + 1
+		WHERE id = $1 AND status = $10
+		RETURNING id, filename, name, storage_path, size, stored_size, content_type, created_at, metadata, tags, updated_at, version, content_etag, scan_status, scan_engine, scan_signature, scanned_at, status
+	`
+	out, err := scanDocument(r.db.QueryRowContext(ctx, q,
+		doc.ID, doc.Status, doc.StoragePath, doc.ContentETag, doc.ScanStatus, doc.ScanEngine, doc.ScanSignature, doc.ScannedAt,
+		doc.UpdatedAt, from))
+	if !errors.Is(err, sql.ErrNoRows) {
+		return out, err
+	}
+	if _, err := r.FindByID(ctx, doc.ID); err != nil {
 		return nil, err
 	}
+	return nil, repository.ErrStatusConflict
+}
 
-	// Fetch page
-	const qList = `
-		SELECT id, filename, storage_path, size, content_type, created_at
+// FindByIDs fetches the documents with the given IDs in one query.
+func (r *DocumentPostgres) FindByIDs(ctx context.Context, ids []string) ([]model.Document, error) {
+	const q = `
+		SELECT id, filename, name, storage_path, size, stored_size, content_type, created_at, metadata, tags, updated_at, version, content_etag, scan_status, scan_engine, scan_signature, scanned_at, status
 		FROM documents
-		ORDER BY created_at DESC, id DESC
-		LIMIT $1 OFFSET $2
+		WHERE id = ANY($1::uuid[])
 	`
-	rows, err := r.db.QueryContext(ctx, qList, pq.Limit, pq.Offset)
+	return r.queryDocuments(ctx, q, ids)
+}
+
+// FindByStoragePaths fetches the documents stored under the given keys in one query.
+func (r *DocumentPostgres) FindByStoragePaths(ctx context.Context, paths []string) ([]model.Document, error) {
+	const q = `
+		SELECT id, filename, name, storage_path, size, stored_size, content_type, created_at, metadata, tags, updated_at, version, content_etag, scan_status, scan_engine, scan_signature, scanned_at, status
+		FROM documents
+		WHERE storage_path = ANY($1::text[])
+	`
+	return r.queryDocuments(ctx, q, paths)
+}
+
+// queryDocuments runs a query selecting document rows and scans all of them.
+func (r *DocumentPostgres) queryDocuments(ctx context.Context, q string, args ...any) ([]model.Document, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := make([]model.Document, 0)
+	for rows.Next() {
+		d, err := scanDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// sortColumns maps the sortable fields to SQL expressions. Text columns are compared with the C
+// collation so the order is bytewise, as keyset comparisons and the Sort contract expect.
+var sortColumns = map[repository.SortField]string{
+	repository.SortCreatedAt:   "created_at",
+	repository.SortName:        `name COLLATE "C"`,
+	repository.SortSize:        "size",
+	repository.SortContentType: `content_type COLLATE "C"`,
+}
+
+// List returns documents using LIMIT/OFFSET or keyset pagination and, unless skipped, a total count.
+// Filters and the sort column are translated into a parameterized query; column names only ever
+// come from sortColumns. One row beyond the page is fetched to tell whether another page follows.
+func (r *DocumentPostgres) List(ctx context.Context, pq repository.PageQuery) (*repository.PageResult[model.Document], error) {
+	sort := pq.Sort.OrDefault()
+	column, ok := sortColumns[sort.Field]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort field %q", sort.Field)
+	}
+	var q query
+	where := filterConditions(&q, pq.Filter)
+
+	total := -1
+	if !pq.SkipTotal {
+		qCount := `SELECT COUNT(*) FROM documents` + whereClause(where)
+		if err := r.db.QueryRowContext(ctx, qCount, q.args...).Scan(&total); err != nil {
+			return nil, err
+		}
+	}
+
+	dir, cmp := "DESC", "<"
+	if sort.Asc {
+		dir, cmp = "ASC", ">"
+	}
+	if pq.After != nil {
+		where = append(where, fmt.Sprintf("(%s, id) %s (%s, %s)", column, cmp, q.arg(cursorKey(pq.After, sort.Field)), q.arg(pq.After.ID)))
+	}
+	qList := `SELECT id, filename, name, storage_path, size, stored_size, content_type, created_at, metadata, tags, updated_at, version, content_etag, scan_status, scan_engine, scan_signature, scanned_at, status FROM documents` +
+		whereClause(where) +
+		fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %s", column, dir, dir, q.arg(pq.Limit+1))
+	if pq.After == nil {
+		qList += " OFFSET " + q.arg(pq.Offset)
+	}
+
+	rows, err := r.db.QueryContext(ctx, qList, q.args...)
 	if err != nil {
 		return nil, err
 	}
@@ -100,27 +231,151 @@ func (r *DocumentPostgres) List(ctx context.Context, pq repository.PageQuery) (*
 
 	items := make([]model.Document, 0)
 	for rows.Next() {
-		var d model.Document
-		if err := rows.Scan(
-			&d.ID,
-			&d.Filename,
-			&d.StoragePath,
-			&d.Size,
-			&d.ContentType,
-			&d.CreatedAt,
-		); err != nil {
+		d, err := scanDocument(rows)
+		if err != nil {
 			return nil, err
 		}
-		items = append(items, d)
+		items = append(items, *d)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return &repository.PageResult[model.Document]{
-		Items: items,
-		Total: total,
-	}, nil
+	res := &repository.PageResult[model.Document]{Items: items, Total: total}
+	if pq.Limit > 0 && len(items) > pq.Limit {
+		res.Items = items[:pq.Limit]
+		res.Next = repository.CursorOf(res.Items[pq.Limit-1])
+	}
+	return res, nil
+}
+
+// query accumulates positional arguments.
+type query struct{ args []any }
+
+// arg adds v and returns its placeholder.
+func (q *query) arg(v any) string {
+	q.args = append(q.args, v)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+// mediaTypeExpr is content_type without parameters, lower-cased.
+const mediaTypeExpr = `btrim(lower(split_part(content_type, ';', 1)))`
+
+func filterConditions(q *query, f repository.DocumentFilter) []string {
+	var where []string
+	if f.ContentType != "" {
+		ct := strings.ToLower(f.ContentType)
+		if prefix, ok := strings.CutSuffix(ct, "/*"); ok {
+			where = append(where, mediaTypeExpr+" LIKE "+q.arg(escapeLike(prefix)+"/%"))
+		} else {
+			where = append(where, mediaTypeExpr+" = "+q.arg(ct))
+		}
+	}
+	if !f.CreatedFrom.IsZero() {
+		where = append(where, "created_at >= "+q.arg(f.CreatedFrom))
+	}
+	if !f.CreatedTo.IsZero() {
+		where = append(where, "created_at < "+q.arg(f.CreatedTo))
+	}
+	if f.MinSize != nil {
+		where = append(where, "size >= "+q.arg(*f.MinSize))
+	}
+	if f.MaxSize != nil {
+		where = append(where, "size <= "+q.arg(*f.MaxSize))
+	}
+	if f.Name != "" {
+		where = append(where, "name ILIKE "+q.arg("%"+escapeLike(f.Name)+"%"))
+	}
+	if len(f.Statuses) > 0 {
+		where = append(where, "status = ANY("+q.arg(f.Statuses)+"::text[])")
+	}
+	return where
+}
+
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// escapeLike escapes the LIKE wildcards in s, using the default backslash escape character.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// cursorKey returns the cursor's value of the sort field.
+func cursorKey(c *repository.Cursor, field repository.SortField) any {
+	switch field {
+	case repository.SortName:
+		return c.Name
+	case repository.SortSize:
+		return c.Size
+	case repository.SortContentType:
+		return c.ContentType
+	default:
+		return c.CreatedAt
+	}
+}
+
+// scanDocument scans the columns selected by every query of this repository.
+func scanDocument(row interface{ Scan(dest ...any) error }) (*model.Document, error) {
+	var d model.Document
+	var metadata, tags []byte
+	var scannedAt sql.NullTime
+	if err := row.Scan(
+		&d.ID,
+		&d.Filename,
+		&d.Name,
+		&d.StoragePath,
+		&d.Size,
+		&d.StoredSize,
+		&d.ContentType,
+		&d.CreatedAt,
+		&metadata,
+		&tags,
+		&d.UpdatedAt,
+		&d.Version,
+		&d.ContentETag,
+		&d.ScanStatus,
+		&d.ScanEngine,
+		&d.ScanSignature,
+		&scannedAt,
+		&d.Status,
+	); err != nil {
+		return nil, err
+	}
+	if scannedAt.Valid {
+		d.ScannedAt = &scannedAt.Time
+	}
+	if err := json.Unmarshal(metadata, &d.Metadata); err != nil {
+		return nil, fmt.Errorf("decode metadata of document %s: %w", d.ID, err)
+	}
+	if err := json.Unmarshal(tags, &d.Tags); err != nil {
+		return nil, fmt.Errorf("decode tags of document %s: %w", d.ID, err)
+	}
+	return &d, nil
+}
+
+// encodeAttributes returns the JSON stored in the metadata and tags columns; nil is stored as an
+// empty object or array.
+func encodeAttributes(doc *model.Document) (metadata, tags string, err error) {
+	metadata, tags = "{}", "[]"
+	if doc.Metadata != nil {
+		b, err := json.Marshal(doc.Metadata)
+		if err != nil {
+			return "", "", err
+		}
+		metadata = string(b)
+	}
+	if doc.Tags != nil {
+		b, err := json.Marshal(doc.Tags)
+		if err != nil {
+			return "", "", err
+		}
+		tags = string(b)
+	}
+	return metadata, tags, nil
 }
 
 // Delete removes a document by ID. It does not return an error if the row does not exist.
@@ -134,3 +389,49 @@ func (r *DocumentPostgres) Delete(ctx context.Context, id string) error {
 	_, _ = res.RowsAffected()
 	return nil
 }
+
+// DeleteVersion deletes the row in one statement guarded by the expected version. When no row
+// matches, the document is looked up to tell a missing document from a conflicting edit.
+func (r *DocumentPostgres) DeleteVersion(ctx context.Context, id string, version int64) error {
+	const q = `DELETE FROM documents WHERE id = $1 AND version = $2`
+	res, err := r.db.ExecContext(ctx, q, id, version)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	if _, err := r.FindByID(ctx, id); err != nil {
+		return err
+	}
+	return repository.ErrVersionConflict
+}
+
+// DeleteMany removes the documents with the given IDs in one statement.
+func (r *DocumentPostgres) DeleteMany(ctx context.Context, ids []string) (int64, error) {
+	const q = `DELETE FROM documents WHERE id = ANY($1::uuid[])`
+	res, err := r.db.ExecContext(ctx, q, ids)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteUnmoved removes the given documents that are still stored where docs says, in one
+// statement.
+func (r *DocumentPostgres) DeleteUnmoved(ctx context.Context, docs []model.Document) (int64, error) {
+	const q = `
+		DELETE FROM documents d
+		USING unnest($1::uuid[], $2::text[]) AS t(id, storage_path)
+		WHERE d.id = t.id AND d.storage_path = t.storage_path`
+	ids := make([]string, len(docs))
+	paths := make([]string, len(docs))
+	for i, d := range docs {
+		ids[i], paths[i] = d.ID, d.StoragePath
+	}
+	res, err := r.db.ExecContext(ctx, q, ids, paths)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"testing"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 	"docapi/internal/repository"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDocumentPostgres_Create(t *testing.T) {
@@ -26,17 +28,19 @@ func TestDocumentPostgres_Create(t *testing.T) {
 	doc := &model.Document{
 		ID:          "test-uuid",
 		Filename:    "test.txt",
+		Name:        "original.txt",
 		StoragePath: "documents/test.txt",
 		Size:        123,
 		ContentType: "text/plain",
 		CreatedAt:   now,
+		Metadata:    map[string]string{"source": "scan"},
 	}
 
-	rows := sqlmock.NewRows([]string{"id", "filename", "storage_path", "size", "content_type", "created_at"}).
-		AddRow(doc.ID, doc.Filename, doc.StoragePath, doc.Size, doc.ContentType, doc.CreatedAt)
+	rows := sqlmock.NewRows([]string{"id", "filename", "name", "storage_path", "size", "stored_size", "content_type", "created_at", "metadata", "tags", "updated_at", "version", "content_etag", "scan_status", "scan_engine", "scan_signature", "scanned_at", "status"}).
+		AddRow(doc.ID, doc.Filename, doc.Name, doc.StoragePath, doc.Size, doc.StoredSize, doc.ContentType, doc.CreatedAt, []byte(`{"source":"scan"}`), []byte("[]"), doc.CreatedAt, int64(1), "", "unscanned", "", "", nil, "available")
 
 	mock.ExpectQuery("INSERT INTO documents").
-		WithArgs(doc.ID, doc.Filename, doc.StoragePath, doc.Size, doc.ContentType, doc.CreatedAt).
+		WithArgs(doc.ID, doc.Filename, doc.Name, doc.StoragePath, doc.Size, doc.StoredSize, doc.ContentType, doc.CreatedAt, `{"source":"scan"}`, "[]", doc.CreatedAt, doc.ContentETag, model.ScanUnscanned, "", "", nil, model.StatusPending).
 		WillReturnRows(rows)
 
 	result, err := repo.Create(ctx, doc)
@@ -44,6 +48,8 @@ func TestDocumentPostgres_Create(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 	assert.Equal(t, doc.ID, result.ID)
+	assert.Equal(t, doc.Name, result.Name)
+	assert.Equal(t, doc.Metadata, result.Metadata)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -58,8 +64,8 @@ func TestDocumentPostgres_FindByID(t *testing.T) {
 	ctx := context.Background()
 
 	t.Run("found", func(t *testing.T) {
-		rows := sqlmock.NewRows([]string{"id", "filename", "storage_path", "size", "content_type", "created_at"}).
-			AddRow("test-id", "file.txt", "path/file.txt", 100, "text/plain", time.Now())
+		rows := sqlmock.NewRows([]string{"id", "filename", "name", "storage_path", "size", "stored_size", "content_type", "created_at", "metadata", "tags", "updated_at", "version", "content_etag", "scan_status", "scan_engine", "scan_signature", "scanned_at", "status"}).
+			AddRow("test-id", "file.txt", "file.txt", "path/file.txt", 100, 60, "text/plain", time.Now(), []byte("{}"), []byte("[]"), time.Now(), int64(1), "", "unscanned", "", "", nil, "available")
 
 		mock.ExpectQuery("SELECT (.+) FROM documents WHERE id = ?").
 			WithArgs("test-id").
@@ -85,8 +91,117 @@ func TestDocumentPostgres_FindByID(t *testing.T) {
 	})
 }
 
-func TestDocumentPostgres_List(t *testing.T) {
+func TestDocumentPostgres_Update(t *testing.T) {
 	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDocumentPostgres(db)
+	ctx := context.Background()
+	columns := []string{"id", "filename", "name", "storage_path", "size", "stored_size", "content_type", "created_at", "metadata", "tags", "updated_at", "version", "content_etag", "scan_status", "scan_engine", "scan_signature", "scanned_at", "status"}
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	doc := &model.Document{
+		ID:          "test-id",
+		Name:        "renamed.txt",
+		ContentType: "text/markdown",
+		Metadata:    map[string]string{"source": "scan"},
+		Tags:        []string{"draft"},
+		UpdatedAt:   created.Add(time.Hour),
+		Version:     3,
+	}
+
+	t.Run("updated", func(t *testing.T) {
+		mock.ExpectQuery("UPDATE documents SET (.+) WHERE id = \\$1 AND version = \\$7 RETURNING").
+			WithArgs(doc.ID, doc.Name, doc.ContentType, `{"source":"scan"}`, `["draft"]`, doc.UpdatedAt, doc.Version).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(doc.ID, "f.txt", doc.Name, "documents/f.txt", 1, 1, doc.ContentType, created, []byte(`{"source":"scan"}`), []byte(`["draft"]`), doc.UpdatedAt, int64(4), "", "unscanned", "", "", nil, "available"))
+
+		out, err := repo.Update(ctx, doc)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), out.Version)
+		assert.Equal(t, []string{"draft"}, out.Tags)
+		assert.Equal(t, doc.UpdatedAt, out.UpdatedAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("version conflict", func(t *testing.T) {
+		mock.ExpectQuery("UPDATE documents").WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery("SELECT (.+) FROM documents WHERE id = ?").
+			WithArgs(doc.ID).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(doc.ID, "f.txt", "other.txt", "documents/f.txt", 1, 1, "text/plain", created, []byte("{}"), []byte("[]"), created, int64(4), "", "unscanned", "", "", nil, "available"))
+
+		_, err := repo.Update(ctx, doc)
+		assert.ErrorIs(t, err, repository.ErrVersionConflict)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectQuery("UPDATE documents").WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery("SELECT (.+) FROM documents WHERE id = ?").WithArgs(doc.ID).WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.Update(ctx, doc)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDocumentPostgres_UpdateStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDocumentPostgres(db)
+	ctx := context.Background()
+	columns := []string{"id", "filename", "name", "storage_path", "size", "stored_size", "content_type", "created_at", "metadata", "tags", "updated_at", "version", "content_etag", "scan_status", "scan_engine", "scan_signature", "scanned_at", "status"}
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	doc := &model.Document{
+		ID:          "test-id",
+		StoragePath: "documents/f.txt",
+		ContentETag: "etag",
+		ScanStatus:  model.ScanClean,
+		ScanEngine:  "ClamAV",
+		UpdatedAt:   created.Add(time.Hour),
+		Status:      model.StatusAvailable,
+	}
+
+	t.Run("updated", func(t *testing.T) {
+		mock.ExpectQuery("UPDATE documents SET status = \\$2, (.+) WHERE id = \\$1 AND status = \\$10 RETURNING").
+			WithArgs(doc.ID, doc.Status, doc.StoragePath, doc.ContentETag, doc.ScanStatus, doc.ScanEngine, "", nil, doc.UpdatedAt, model.StatusProcessing).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(doc.ID, "f.txt", "f.txt", doc.StoragePath, 1, 1, "text/plain", created, []byte("{}"), []byte("[]"), doc.UpdatedAt, int64(3), "etag", "clean", "ClamAV", "", nil, "available"))
+
+		out, err := repo.UpdateStatus(ctx, doc, model.StatusProcessing)
+		require.NoError(t, err)
+		assert.Equal(t, model.StatusAvailable, out.Status)
+		assert.Equal(t, int64(3), out.Version)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("status conflict", func(t *testing.T) {
+		mock.ExpectQuery("UPDATE documents").WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery("SELECT (.+) FROM documents WHERE id = ?").
+			WithArgs(doc.ID).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(doc.ID, "f.txt", "f.txt", doc.StoragePath, 1, 1, "text/plain", created, []byte("{}"), []byte("[]"), created, int64(2), "", "unscanned", "", "", nil, "failed"))
+
+		_, err := repo.UpdateStatus(ctx, doc, model.StatusProcessing)
+		assert.ErrorIs(t, err, repository.ErrStatusConflict)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectQuery("UPDATE documents").WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery("SELECT (.+) FROM documents WHERE id = ?").WithArgs(doc.ID).WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.UpdateStatus(ctx, doc, model.StatusProcessing)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDocumentPostgres_List(t *testing.T) {
+	db, mock, err := sqlmock.New(passSlices)
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
@@ -99,11 +214,11 @@ func TestDocumentPostgres_List(t *testing.T) {
 		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM documents").
 			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 
-		rows := sqlmock.NewRows([]string{"id", "filename", "storage_path", "size", "content_type", "created_at"}).
-			AddRow("test-id", "file.txt", "path/file.txt", 100, "text/plain", time.Now())
+		rows := sqlmock.NewRows([]string{"id", "filename", "name", "storage_path", "size", "stored_size", "content_type", "created_at", "metadata", "tags", "updated_at", "version", "content_etag", "scan_status", "scan_engine", "scan_signature", "scanned_at", "status"}).
+			AddRow("test-id", "file.txt", "file.txt", "path/file.txt", 100, 60, "text/plain", time.Now(), []byte("{}"), []byte("[]"), time.Now(), int64(1), "", "unscanned", "", "", nil, "available")
 
 		mock.ExpectQuery("SELECT (.+) FROM documents ORDER BY").
-			WithArgs(10, 0).
+			WithArgs(11, 0).
 			WillReturnRows(rows)
 
 		res, err := repo.List(ctx, repository.PageQuery{Limit: 10, Offset: 0})
@@ -111,6 +226,65 @@ func TestDocumentPostgres_List(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, 1, res.Total)
 		assert.Len(t, res.Items, 1)
+		assert.Nil(t, res.Next)
+	})
+
+	t.Run("keyset without total", func(t *testing.T) {
+		after := &repository.Cursor{CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), ID: "after-id"}
+		rows := sqlmock.NewRows([]string{"id", "filename", "name", "storage_path", "size", "stored_size", "content_type", "created_at", "metadata", "tags", "updated_at", "version", "content_etag", "scan_status", "scan_engine", "scan_signature", "scanned_at", "status"}).
+			AddRow("id-2", "b.txt", "b.txt", "path/b.txt", 1, 1, "text/plain", after.CreatedAt, []byte("{}"), []byte("[]"), after.CreatedAt, int64(1), "", "unscanned", "", "", nil, "available").
+			AddRow("id-1", "a.txt", "a.txt", "path/a.txt", 1, 1, "text/plain", after.CreatedAt.Add(-time.Second), []byte("{}"), []byte("[]"), after.CreatedAt.Add(-time.Second), int64(1), "", "unscanned", "", "", nil, "available")
+
+		mock.ExpectQuery("SELECT (.+) FROM documents WHERE \\(created_at, id\\) < \\(\\$1, \\$2\\) ORDER BY").
+			WithArgs(after.CreatedAt, after.ID, 2).
+			WillReturnRows(rows)
+
+		res, err := repo.List(ctx, repository.PageQuery{Limit: 1, After: after, SkipTotal: true})
+
+		require.NoError(t, err)
+		assert.Equal(t, -1, res.Total)
+		require.Len(t, res.Items, 1)
+		assert.Equal(t, &repository.Cursor{CreatedAt: after.CreatedAt, Name: "b.txt", Size: 1, ContentType: "text/plain", ID: "id-2"}, res.Next)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("filters and sort are parameterized", func(t *testing.T) {
+		from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		minSize, maxSize := int64(10), int64(20)
+		filter := repository.DocumentFilter{
+			ContentType: "Image/*",
+			CreatedFrom: from,
+			MinSize:     &minSize,
+			MaxSize:     &maxSize,
+			Name:        "50%_off",
+			Statuses:    []string{model.StatusPending, model.StatusFailed},
+		}
+		where := `WHERE btrim\(lower\(split_part\(content_type, ';', 1\)\)\) LIKE \$1 AND created_at >= \$2 AND size >= \$3 AND size <= \$4 AND name ILIKE \$5 AND status = ANY\(\$6::text\[\]\)`
+
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM documents `+where+`$`).
+			WithArgs("image/%", from, minSize, maxSize, `%50\%\_off%`, filter.Statuses).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(`SELECT (.+) FROM documents `+where+` AND \(name COLLATE "C", id\) > \(\$7, \$8\) ORDER BY name COLLATE "C" ASC, id ASC LIMIT \$9$`).
+			WithArgs("image/%", from, minSize, maxSize, `%50\%\_off%`, filter.Statuses, "m", "after-id", 11).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "filename", "name", "storage_path", "size", "stored_size", "content_type", "created_at", "metadata", "tags", "updated_at", "version", "content_etag", "scan_status", "scan_engine", "scan_signature", "scanned_at", "status"}))
+
+		res, err := repo.List(ctx, repository.PageQuery{
+			Limit:  10,
+			After:  &repository.Cursor{Name: "m", ID: "after-id"},
+			Filter: filter,
+			Sort:   repository.Sort{Field: repository.SortName, Asc: true},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, res.Total)
+		assert.Empty(t, res.Items)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown sort field", func(t *testing.T) {
+		_, err := repo.List(ctx, repository.PageQuery{Limit: 10, Sort: repository.Sort{Field: "id; DROP TABLE documents"}})
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 }
 
@@ -134,6 +308,139 @@ func TestDocumentPostgres_Delete(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+// passSlices lets sqlmock accept the []string arguments that pgx encodes as arrays.
+var passSlices = sqlmock.ValueConverterOption(driver.ValueConverter(sliceConverter{}))
+
+type sliceConverter struct{}
+
+func (sliceConverter) ConvertValue(v any) (driver.Value, error) {
+	if s, ok := v.([]string); ok {
+		return s, nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+func TestDocumentPostgres_FindByIDs(t *testing.T) {
+	db, mock, err := sqlmock.New(passSlices)
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDocumentPostgres(db)
+	ids := []string{"id-1", "id-2"}
+	now := time.Now()
+
+	mock.ExpectQuery(`WHERE id = ANY\(\$1::uuid\[\]\)`).
+		WithArgs(ids).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "filename", "name", "storage_path", "size", "stored_size", "content_type", "created_at", "metadata", "tags", "updated_at", "version", "content_etag", "scan_status", "scan_engine", "scan_signature", "scanned_at", "status"}).
+			AddRow("id-2", "f.txt", "a.txt", "documents/f.txt", int64(1), int64(1), "text/plain", now, []byte("{}"), []byte("[]"), now, int64(1), "", "unscanned", "", "", nil, "available"))
+
+	docs, err := repo.FindByIDs(context.Background(), ids)
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	assert.Equal(t, "id-2", docs[0].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDocumentPostgres_FindByStoragePaths(t *testing.T) {
+	db, mock, err := sqlmock.New(passSlices)
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDocumentPostgres(db)
+	paths := []string{"documents/f.txt", "documents/g.txt"}
+
+	mock.ExpectQuery(`WHERE storage_path = ANY\(\$1::text\[\]\)`).
+		WithArgs(paths).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "filename", "name", "storage_path", "size", "stored_size", "content_type", "created_at", "metadata", "tags", "updated_at", "version", "content_etag", "scan_status", "scan_engine", "scan_signature", "scanned_at", "status"}).
+			AddRow("id-2", "f.txt", "a.txt", "documents/f.txt", int64(1), int64(1), "text/plain", time.Now(), []byte("{}"), []byte("[]"), time.Now(), int64(1), "", "unscanned", "", "", nil, "available"))
+
+	docs, err := repo.FindByStoragePaths(context.Background(), paths)
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	assert.Equal(t, "documents/f.txt", docs[0].StoragePath)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDocumentPostgres_DeleteMany(t *testing.T) {
+	db, mock, err := sqlmock.New(passSlices)
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDocumentPostgres(db)
+	ids := []string{"id-1", "id-2", "id-3"}
+
+	mock.ExpectExec(`DELETE FROM documents WHERE id = ANY\(\$1::uuid\[\]\)`).
+		WithArgs(ids).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	n, err := repo.DeleteMany(context.Background(), ids)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDocumentPostgres_DeleteVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDocumentPostgres(db)
+	ctx := context.Background()
+	columns := []string{"id", "filename", "name", "storage_path", "size", "stored_size", "content_type", "created_at", "metadata", "tags", "updated_at", "version", "content_etag", "scan_status", "scan_engine", "scan_signature", "scanned_at", "status"}
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("deleted", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM documents WHERE id = \$1 AND version = \$2`).
+			WithArgs("test-id", int64(3)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		require.NoError(t, repo.DeleteVersion(ctx, "test-id", 3))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("version conflict", func(t *testing.T) {
+		mock.ExpectExec("DELETE FROM documents").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT (.+) FROM documents WHERE id = ?").
+			WithArgs("test-id").
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("test-id", "f.txt", "other.txt", "documents/f.txt", 1, 1, "text/plain", created, []byte("{}"), []byte("[]"), created, int64(4), "", "unscanned", "", "", nil, "available"))
+
+		err := repo.DeleteVersion(ctx, "test-id", 3)
+		assert.ErrorIs(t, err, repository.ErrVersionConflict)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mock.ExpectExec("DELETE FROM documents").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT (.+) FROM documents WHERE id = ?").WithArgs("test-id").WillReturnError(sql.ErrNoRows)
+
+		err := repo.DeleteVersion(ctx, "test-id", 3)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDocumentPostgres_DeleteUnmoved(t *testing.T) {
+	db, mock, err := sqlmock.New(passSlices)
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewDocumentPostgres(db)
+	docs := []model.Document{
+		{ID: "id-1", StoragePath: "documents/a.txt"},
+		{ID: "id-2", StoragePath: "quarantine/b.txt"},
+	}
+
+	mock.ExpectExec(`DELETE FROM documents d\s+USING unnest\(\$1::uuid\[\], \$2::text\[\]\) AS t\(id, storage_path\)\s+WHERE d.id = t.id AND d.storage_path = t.storage_path`).
+		WithArgs([]string{"id-1", "id-2"}, []string{"documents/a.txt", "quarantine/b.txt"}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := repo.DeleteUnmoved(context.Background(), docs)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func IsNoRowsError(err error) bool {
 	return err == sql.ErrNoRows
 }
@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"docapi/internal/repository"
+)
+
+// acquireAttempts bounds how often Acquire retries when the record holding a key expires and is
+// deleted between claiming the key and reading the record.
+const acquireAttempts = 3
+
+// IdempotencyPostgres is a PostgreSQL implementation of repository.IdempotencyRepository.
+type IdempotencyPostgres struct {
+	db *sql.DB
+}
+
+// NewIdempotencyPostgres creates a new IdempotencyPostgres repository.
+func NewIdempotencyPostgres(db *sql.DB) *IdempotencyPostgres {
+	return &IdempotencyPostgres{db: db}
+}
+
+var _ repository.IdempotencyRepository = (*IdempotencyPostgres)(nil)
+
+// Acquire inserts the claim, replacing an expired record in the same statement so that concurrent
+// requests with the same key cannot both claim it.
+func (r *IdempotencyPostgres) Acquire(ctx context.Context, rec *repository.IdempotencyRecord) (*repository.IdempotencyRecord, error) {
+	const claim = `
+		INSERT INTO idempotency_keys (principal, key, fingerprint, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (principal, key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, status = NULL, headers = NULL, body = NULL,
+		    created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= EXCLUDED.created_at
+	`
+	const existing = `
+		SELECT principal, key, fingerprint, status, headers, body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE principal = $1 AND key = $2
+	`
+	for range acquireAttempts {
+		res, err := r.db.ExecContext(ctx, claim, rec.Principal, rec.Key, rec.Fingerprint, rec.CreatedAt, rec.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 1 {
+			return nil, err
+		}
+		held, err := scanIdempotencyRecord(r.db.QueryRowContext(ctx, existing, rec.Principal, rec.Key))
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		return held, err
+	}
+	return nil, fmt.Errorf("acquire idempotency key: record changed %d times", acquireAttempts)
+}
+
+// Complete stores the response of the claim made by rec.
+func (r *IdempotencyPostgres) Complete(ctx context.Context, rec *repository.IdempotencyRecord) error {
+	const q = `
+		UPDATE idempotency_keys SET status = $4, headers = $5, body = $6, expires_at = $7
+		WHERE principal = $1 AND key = $2 AND created_at = $3
+	`
+	headers, err := json.Marshal(rec.Headers)
+	if err != nil {
+		return fmt.Errorf("encode headers: %w", err)
+	}
+	body := rec.Body
+	if body == nil {
+		body = []byte{}
+	}
+	_, err = r.db.ExecContext(ctx, q, rec.Principal, rec.Key, rec.CreatedAt, rec.Status, string(headers), body, rec.ExpiresAt)
+	return err
+}
+
+// Release deletes the claim made by rec.
+func (r *IdempotencyPostgres) Release(ctx context.Context, rec *repository.IdempotencyRecord) error {
+	const q = `DELETE FROM idempotency_keys WHERE principal = $1 AND key = $2 AND created_at = $3`
+	_, err := r.db.ExecContext(ctx, q, rec.Principal, rec.Key, rec.CreatedAt)
+	return err
+}
+
+// DeleteExpired deletes the records that expired by now.
+func (r *IdempotencyPostgres) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	const q = `DELETE FROM idempotency_keys WHERE expires_at <= $1`
+	res, err := r.db.ExecContext(ctx, q, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanIdempotencyRecord(row interface{ Scan(dest ...any) error }) (*repository.IdempotencyRecord, error) {
+	var rec repository.IdempotencyRecord
+	var status sql.NullInt64
+	var headers []byte
+	if err := row.Scan(&rec.Principal, &rec.Key, &rec.Fingerprint, &status, &headers, &rec.Body, &rec.CreatedAt, &rec.ExpiresAt); err != nil {
+		return nil, err
+	}
+	rec.Status = int(status.Int64)
+	if len(headers) > 0 {
+		if err := json.Unmarshal(headers, &rec.Headers); err != nil {
+			return nil, fmt.Errorf("decode headers: %w", err)
+		}
+	}
+	return &rec, nil
+}
@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"docapi/internal/repository"
+)
+
+func TestIdempotencyPostgres_Acquire(t *testing.T) {
+	now := time.Now().UTC()
+	rec := &repository.IdempotencyRecord{
+		Principal:   "alice",
+		Key:         "key-1",
+		Fingerprint: "fp",
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(time.Minute),
+	}
+	columns := []string{"principal", "key", "fingerprint", "status", "headers", "body", "created_at", "expires_at"}
+
+	t.Run("claimed", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectExec("INSERT INTO idempotency_keys").
+			WithArgs(rec.Principal, rec.Key, rec.Fingerprint, rec.CreatedAt, rec.ExpiresAt).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		held, err := NewIdempotencyPostgres(db).Acquire(context.Background(), rec)
+		require.NoError(t, err)
+		assert.Nil(t, held)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("held by a completed request", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectExec("INSERT INTO idempotency_keys").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT (.+) FROM idempotency_keys").
+			WithArgs(rec.Principal, rec.Key).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("alice", "key-1", "fp", int64(201), []byte(`{"Content-Type":"application/json"}`), []byte(`{"id":"1"}`), now, now.Add(time.Hour)))
+
+		held, err := NewIdempotencyPostgres(db).Acquire(context.Background(), rec)
+		require.NoError(t, err)
+		require.NotNil(t, held)
+		assert.Equal(t, 201, held.Status)
+		assert.Equal(t, map[string]string{"Content-Type": "application/json"}, held.Headers)
+		assert.Equal(t, []byte(`{"id":"1"}`), held.Body)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("held by an in-flight request", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectExec("INSERT INTO idempotency_keys").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT (.+) FROM idempotency_keys").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("alice", "key-1", "fp", nil, nil, nil, now, now.Add(time.Minute)))
+
+		held, err := NewIdempotencyPostgres(db).Acquire(context.Background(), rec)
+		require.NoError(t, err)
+		require.NotNil(t, held)
+		assert.Zero(t, held.Status)
+		assert.Nil(t, held.Headers)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("held record deleted meanwhile", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectExec("INSERT INTO idempotency_keys").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT (.+) FROM idempotency_keys").WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectExec("INSERT INTO idempotency_keys").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		held, err := NewIdempotencyPostgres(db).Acquire(context.Background(), rec)
+		require.NoError(t, err)
+		assert.Nil(t, held)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestIdempotencyPostgres_Complete(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now().UTC()
+	rec := &repository.IdempotencyRecord{
+		Principal: "alice",
+		Key:       "key-1",
+		Status:    201,
+		Headers:   map[string]string{"Location": "/documents/1"},
+		Body:      []byte(`{"id":"1"}`),
+		CreatedAt: now,
+		ExpiresAt: now.Add(24 * time.Hour),
+	}
+	mock.ExpectExec("UPDATE idempotency_keys").
+		WithArgs("alice", "key-1", now, 201, `{"Location":"/documents/1"}`, rec.Body, rec.ExpiresAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, NewIdempotencyPostgres(db).Complete(context.Background(), rec))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotencyPostgres_DeleteExpired(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now().UTC()
+	mock.ExpectExec("DELETE FROM idempotency_keys WHERE expires_at").
+		WithArgs(now).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	n, err := NewIdempotencyPostgres(db).DeleteExpired(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,179 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"time"
+
+	"docapi/internal/repository"
+)
+
+const jobColumns = `id, kind, payload, status, attempts, max_attempts, run_at, locked_until, last_error, created_at, updated_at`
+
+// JobPostgres is a PostgreSQL implementation of repository.JobRepository.
+type JobPostgres struct {
+	db *sql.DB
+}
+
+// NewJobPostgres creates a new JobPostgres repository.
+func NewJobPostgres(db *sql.DB) *JobPostgres {
+	return &JobPostgres{db: db}
+}
+
+var _ repository.JobRepository = (*JobPostgres)(nil)
+
+// Enqueue inserts a queued job and returns the stored record.
+func (r *JobPostgres) Enqueue(ctx context.Context, job *repository.Job) (*repository.Job, error) {
+	const q = `
+		INSERT INTO jobs (id, kind, payload, status, max_attempts, run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, 'queued', $4, $5, $6, $6)
+		RETURNING ` + jobColumns
+	payload := []byte(job.Payload)
+	if len(payload) == 0 {
+		payload = []byte("{}")
+	}
+	createdAt := job.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now().UTC()
+	}
+	runAt := job.RunAt
+	if runAt.IsZero() {
+		runAt = createdAt
+	}
+	return scanJob(r.db.QueryRowContext(ctx, q, job.ID, job.Kind, string(payload), job.MaxAttempts, runAt, createdAt))
+}
+
+// Claim locks due jobs with FOR UPDATE SKIP LOCKED, so that concurrent workers never claim the
+// same job and never block on each other, and marks them running in the same statement.
+func (r *JobPostgres) Claim(ctx context.Context, kinds []string, now time.Time, lease time.Duration, limit int) ([]repository.Job, error) {
+	const q = `
+		UPDATE jobs SET status = 'running', attempts = attempts + 1, locked_until = $3, updated_at = $2
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE kind = ANY($1::text[])
+			  AND ((status = 'queued' AND run_at <= $2) OR (status = 'running' AND locked_until <= $2))
+			ORDER BY run_at, id
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + jobColumns
+	rows, err := r.db.QueryContext(ctx, q, kinds, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []repository.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// UPDATE ... RETURNING does not keep the subquery's order.
+	slices.SortFunc(jobs, func(a, b repository.Job) int {
+		if c := a.RunAt.Compare(b.RunAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return jobs, nil
+}
+
+// Extend moves the claim on job forward.
+func (r *JobPostgres) Extend(ctx context.Context, job *repository.Job, until time.Time) error {
+	const q = `
+		UPDATE jobs SET locked_until = $3, updated_at = now()
+		WHERE id = $1 AND attempts = $2 AND status = 'running'
+	`
+	return r.execClaim(ctx, q, job.ID, job.Attempts, until)
+}
+
+// Complete deletes a job that succeeded.
+func (r *JobPostgres) Complete(ctx context.Context, job *repository.Job) error {
+	const q = `DELETE FROM jobs WHERE id = $1 AND attempts = $2 AND status = 'running'`
+	return r.execClaim(ctx, q, job.ID, job.Attempts)
+}
+
+// Retry queues job again to run at runAt.
+func (r *JobPostgres) Retry(ctx context.Context, job *repository.Job, runAt time.Time, lastErr string) error {
+	const q = `
+		UPDATE jobs SET status = 'queued', run_at = $3, locked_until = NULL, last_error = $4, updated_at = now()
+		WHERE id = $1 AND attempts = $2 AND status = 'running'
+	`
+	return r.execClaim(ctx, q, job.ID, job.Attempts, runAt, lastErr)
+}
+
+// Release queues job again to run right away, giving back its attempt.
+func (r *JobPostgres) Release(ctx context.Context, job *repository.Job) error {
+	const q = `
+		UPDATE jobs SET status = 'queued', attempts = attempts - 1, run_at = now(), locked_until = NULL, updated_at = now()
+		WHERE id = $1 AND attempts = $2 AND status = 'running'
+	`
+	return r.execClaim(ctx, q, job.ID, job.Attempts)
+}
+
+// Fail marks job as failed for good.
+func (r *JobPostgres) Fail(ctx context.Context, job *repository.Job, lastErr string) error {
+	const q = `
+		UPDATE jobs SET status = 'failed', locked_until = NULL, last_error = $3, updated_at = now()
+		WHERE id = $1 AND attempts = $2 AND status = 'running'
+	`
+	return r.execClaim(ctx, q, job.ID, job.Attempts, lastErr)
+}
+
+// Count returns the number of jobs by kind and status.
+func (r *JobPostgres) Count(ctx context.Context) ([]repository.JobCount, error) {
+	const q = `SELECT kind, status, COUNT(*) FROM jobs GROUP BY kind, status ORDER BY kind, status`
+	rows, err := r.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []repository.JobCount
+	for rows.Next() {
+		var c repository.JobCount
+		if err := rows.Scan(&c.Kind, &c.Status, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// execClaim runs a statement guarded by a claim and reports ErrJobLost if it matched no row.
+func (r *JobPostgres) execClaim(ctx context.Context, q string, args ...any) error {
+	res, err := r.db.ExecContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repository.ErrJobLost
+	}
+	return nil
+}
+
+func scanJob(row interface{ Scan(dest ...any) error }) (*repository.Job, error) {
+	var job repository.Job
+	var payload []byte
+	var lockedUntil sql.NullTime
+	if err := row.Scan(&job.ID, &job.Kind, &payload, &job.Status, &job.Attempts, &job.MaxAttempts, &job.RunAt, &lockedUntil, &job.LastError, &job.CreatedAt, &job.UpdatedAt); err != nil {
+		return nil, err
+	}
+	job.Payload = payload
+	if lockedUntil.Valid {
+		job.LockedUntil = &lockedUntil.Time
+	}
+	return &job, nil
+}
@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"docapi/internal/repository"
+)
+
+var jobTestColumns = []string{"id", "kind", "payload", "status", "attempts", "max_attempts", "run_at", "locked_until", "last_error", "created_at", "updated_at"}
+
+func TestJobPostgres_Enqueue(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now().UTC()
+	mock.ExpectQuery("INSERT INTO jobs").
+		WithArgs("job-1", "mail", `{}`, 3, now, now).
+		WillReturnRows(sqlmock.NewRows(jobTestColumns).
+			AddRow("job-1", "mail", []byte(`{}`), "queued", 0, 3, now, nil, "", now, now))
+
+	job, err := NewJobPostgres(db).Enqueue(context.Background(), &repository.Job{ID: "job-1", Kind: "mail", MaxAttempts: 3, CreatedAt: now})
+	require.NoError(t, err)
+	assert.Equal(t, repository.JobQueued, job.Status)
+	assert.Nil(t, job.LockedUntil)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestJobPostgres_Claim(t *testing.T) {
+	db, mock, err := sqlmock.New(passSlices)
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now().UTC()
+	until := now.Add(time.Minute)
+	mock.ExpectQuery(`UPDATE jobs SET status = 'running'(.+)FOR UPDATE SKIP LOCKED`).
+		WithArgs([]string{"mail"}, now, until, 2).
+		WillReturnRows(sqlmock.NewRows(jobTestColumns).
+			AddRow("job-2", "mail", []byte(`{"to":"b"}`), "running", 1, 3, now, until, "", now, now).
+			AddRow("job-1", "mail", []byte(`{"to":"a"}`), "running", 2, 3, now.Add(-time.Hour), until, "timeout", now, now))
+
+	jobs, err := NewJobPostgres(db).Claim(context.Background(), []string{"mail"}, now, time.Minute, 2)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "job-1", jobs[0].ID, "oldest due first")
+	assert.Equal(t, `{"to":"a"}`, string(jobs[0].Payload))
+	assert.Equal(t, until, *jobs[0].LockedUntil)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestJobPostgres_Settle(t *testing.T) {
+	now := time.Now().UTC()
+	job := &repository.Job{ID: "job-1", Attempts: 2}
+
+	tests := []struct {
+		name   string
+		query  string
+		args   []driver.Value
+		settle func(r *JobPostgres) error
+	}{
+		{"extend", "UPDATE jobs SET locked_until", []driver.Value{"job-1", 2, now}, func(r *JobPostgres) error {
+			return r.Extend(context.Background(), job, now)
+		}},
+		{"complete", "DELETE FROM jobs", []driver.Value{"job-1", 2}, func(r *JobPostgres) error {
+			return r.Complete(context.Background(), job)
+		}},
+		{"retry", "UPDATE jobs SET status = 'queued', run_at", []driver.Value{"job-1", 2, now, "boom"}, func(r *JobPostgres) error {
+			return r.Retry(context.Background(), job, now, "boom")
+		}},
+		{"release", "UPDATE jobs SET status = 'queued', attempts = attempts - 1", []driver.Value{"job-1", 2}, func(r *JobPostgres) error {
+			return r.Release(context.Background(), job)
+		}},
+		{"fail", "UPDATE jobs SET status = 'failed'", []driver.Value{"job-1", 2, "boom"}, func(r *JobPostgres) error {
+			return r.Fail(context.Background(), job, "boom")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+			r := NewJobPostgres(db)
+			mock.ExpectExec(tt.query).WithArgs(tt.args...).WillReturnResult(sqlmock.NewResult(0, 1))
+			require.NoError(t, tt.settle(r))
+
+			// A claim that expired and was taken over matches no row.
+			mock.ExpectExec(tt.query).WithArgs(tt.args...).WillReturnResult(sqlmock.NewResult(0, 0))
+			assert.ErrorIs(t, tt.settle(r), repository.ErrJobLost)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestJobPostgres_Count(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT kind, status, COUNT").
+		WillReturnRows(sqlmock.NewRows([]string{"kind", "status", "count"}).
+			AddRow("mail", "failed", 1).
+			AddRow("mail", "queued", 4))
+
+	counts, err := NewJobPostgres(db).Count(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []repository.JobCount{
+		{Kind: "mail", Status: repository.JobFailed, Count: 1},
+		{Kind: "mail", Status: repository.JobQueued, Count: 4},
+	}, counts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
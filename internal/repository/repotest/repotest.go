@@ -0,0 +1,681 @@
+// Package repotest provides a conformance suite for repository.DocumentRepository implementations.
+//
+// Every implementation should run the suite against itself from its own tests:
+//
+//	func TestMyRepository_Conformance(t *testing.T) {
+//		repotest.Run(t, func(t *testing.T) repository.DocumentRepository {
+//			return newEmptyRepo(t)
+//		})
+//	}
+//
+// The factory is called once per subtest and must return a repository with no documents,
+// because the pagination cases assert on totals.
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"docapi/internal/model"
+	"docapi/internal/repository"
+)
+
+// Factory returns an empty DocumentRepository under test.
+type Factory func(t *testing.T) repository.DocumentRepository
+
+// Run executes the full conformance suite against repositories returned by newRepo.
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, r repository.DocumentRepository)
+	}{
+		{"create and find round trip", testCreateFind},
+		{"create without metadata", testCreateNoMetadata},
+		{"create duplicate id", testCreateDuplicate},
+		{"find missing returns sql.ErrNoRows", testFindMissing},
+		{"create starts at version 1", testCreateVersion},
+		{"create keeps the scan result", testCreateScanResult},
+		{"update increments version", testUpdate},
+		{"update with stale version conflicts", testUpdateConflict},
+		{"update missing returns sql.ErrNoRows", testUpdateMissing},
+		{"update status", testUpdateStatus},
+		{"update status from another status conflicts", testUpdateStatusConflict},
+		{"update status of missing returns sql.ErrNoRows", testUpdateStatusMissing},
+		{"list orders by created_at desc", testListOrder},
+		{"list breaks created_at ties by id desc", testListTies},
+		{"list pages do not overlap", testListPaging},
+		{"list offset past end", testListPastEnd},
+		{"list keyset pages match the full listing", testListKeyset},
+		{"list keyset is stable under inserts", testListKeysetInserts},
+		{"list skips total", testListSkipTotal},
+		{"list filters", testListFilters},
+		{"list sorts", testListSorts},
+		{"list keyset pages follow the sort", testListKeysetSorted},
+		{"delete", testDelete},
+		{"delete missing returns nil", testDeleteMissing},
+		{"delete version", testDeleteVersion},
+		{"find by ids skips missing", testFindByIDs},
+		{"find by storage paths skips missing", testFindByStoragePaths},
+		{"delete many", testDeleteMany},
+		{"delete unmoved", testDeleteUnmoved},
+		{"concurrent creates", testConcurrentCreates},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRepo(t)
+			require.NotNil(t, r)
+			tt.run(t, r)
+		})
+	}
+}
+
+// baseTime is truncated to microseconds, the resolution of PostgreSQL timestamptz.
+var baseTime = time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)
+
+func newDoc(createdAt time.Time) *model.Document {
+	id := uuid.NewString()
+	return &model.Document{
+		ID:          id,
+		Filename:    id + ".txt",
+		Name:        "report.txt",
+		StoragePath: "documents/" + id + ".txt",
+		Size:        42,
+		StoredSize:  17,
+		ContentType: "text/plain",
+		CreatedAt:   createdAt,
+		Metadata:    map[string]string{"source": "repotest"},
+		ContentETag: "etag-" + id,
+	}
+}
+
+func mustCreate(t *testing.T, r repository.DocumentRepository, doc *model.Document) *model.Document {
+	t.Helper()
+	out, err := r.Create(context.Background(), doc)
+	require.NoError(t, err)
+	return out
+}
+
+func ids(docs []model.Document) []string {
+	out := make([]string, len(docs))
+	for i, d := range docs {
+		out[i] = d.ID
+	}
+	return out
+}
+
+func assertSameDocument(t *testing.T, want, got *model.Document) {
+	t.Helper()
+	assert.Equal(t, want.ID, got.ID)
+	assert.Equal(t, want.Filename, got.Filename)
+	assert.Equal(t, want.Name, got.Name)
+	assert.Equal(t, want.StoragePath, got.StoragePath)
+	assert.Equal(t, want.Size, got.Size)
+	assert.Equal(t, want.StoredSize, got.StoredSize)
+	assert.Equal(t, want.ContentType, got.ContentType)
+	assert.True(t, want.CreatedAt.Equal(got.CreatedAt), "created_at: want %s, got %s", want.CreatedAt, got.CreatedAt)
+	assert.Equal(t, want.ContentETag, got.ContentETag)
+	if len(want.Metadata) == 0 {
+		// Implementations may return either nil or an empty map.
+		assert.Empty(t, got.Metadata)
+	} else {
+		assert.Equal(t, want.Metadata, got.Metadata)
+	}
+	if len(want.Tags) == 0 {
+		assert.Empty(t, got.Tags)
+	} else {
+		assert.Equal(t, want.Tags, got.Tags)
+	}
+}
+
+func testCreateFind(t *testing.T, r repository.DocumentRepository) {
+	doc := newDoc(baseTime)
+	created := mustCreate(t, r, doc)
+	assertSameDocument(t, doc, created)
+
+	found, err := r.FindByID(context.Background(), doc.ID)
+	require.NoError(t, err)
+	assertSameDocument(t, doc, found)
+}
+
+func testCreateNoMetadata(t *testing.T, r repository.DocumentRepository) {
+	doc := newDoc(baseTime)
+	doc.Metadata = nil
+	mustCreate(t, r, doc)
+
+	found, err := r.FindByID(context.Background(), doc.ID)
+	require.NoError(t, err)
+	assertSameDocument(t, doc, found)
+}
+
+func testCreateDuplicate(t *testing.T, r repository.DocumentRepository) {
+	doc := newDoc(baseTime)
+	mustCreate(t, r, doc)
+
+	dup := *doc
+	dup.StoragePath = "documents/other.txt"
+	_, err := r.Create(context.Background(), &dup)
+	assert.Error(t, err)
+}
+
+func testFindMissing(t *testing.T, r repository.DocumentRepository) {
+	doc, err := r.FindByID(context.Background(), uuid.NewString())
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.Nil(t, doc)
+}
+
+func testCreateVersion(t *testing.T, r repository.DocumentRepository) {
+	doc := newDoc(baseTime)
+	doc.Tags = []string{"invoice", "2024"}
+	created := mustCreate(t, r, doc)
+	assertSameDocument(t, doc, created)
+	assert.Equal(t, int64(1), created.Version)
+	assert.True(t, baseTime.Equal(created.UpdatedAt), "updated_at defaults to created_at, got %s", created.UpdatedAt)
+	assert.Equal(t, model.ScanUnscanned, created.ScanStatus)
+	assert.Nil(t, created.ScannedAt)
+	assert.Equal(t, model.StatusPending, created.Status)
+}
+
+func testCreateScanResult(t *testing.T, r repository.DocumentRepository) {
+	doc := newDoc(baseTime)
+	scannedAt := baseTime.Add(time.Second)
+	doc.ScanStatus = model.ScanInfected
+	doc.ScanEngine = "ClamAV 1.2.1/27100"
+	doc.ScanSignature = "Eicar-Test-Signature"
+	doc.ScannedAt = &scannedAt
+	doc.Status = model.StatusQuarantined
+	mustCreate(t, r, doc)
+
+	found, err := r.FindByID(context.Background(), doc.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.ScanInfected, found.ScanStatus)
+	assert.Equal(t, doc.ScanEngine, found.ScanEngine)
+	assert.Equal(t, doc.ScanSignature, found.ScanSignature)
+	require.NotNil(t, found.ScannedAt)
+	assert.True(t, scannedAt.Equal(*found.ScannedAt))
+	assert.True(t, found.Quarantined())
+}
+
+func testUpdate(t *testing.T, r repository.DocumentRepository) {
+	ctx := context.Background()
+	created := mustCreate(t, r, newDoc(baseTime))
+
+	change := *created
+	change.Name = "renamed.txt"
+	change.ContentType = "text/markdown"
+	change.Metadata = map[string]string{"owner": "alice"}
+	change.Tags = []string{"draft"}
+	change.UpdatedAt = baseTime.Add(time.Hour)
+	// Immutable fields are ignored.
+	change.Size = 1
+
+	updated, err := r.Update(ctx, &change)
+	require.NoError(t, err)
+	assert.Equal(t, created.Version+1, updated.Version)
+	assert.True(t, change.UpdatedAt.Equal(updated.UpdatedAt))
+
+	found, err := r.FindByID(ctx, created.ID)
+	require.NoError(t, err)
+	want := change
+	want.Size = created.Size
+	assertSameDocument(t, &want, found)
+	assert.Equal(t, updated.Version, found.Version)
+}
+
+func testUpdateConflict(t *testing.T, r repository.DocumentRepository) {
+	ctx := context.Background()
+	created := mustCreate(t, r, newDoc(baseTime))
+
+	first := *created
+	first.Name = "first.txt"
+	_, err := r.Update(ctx, &first)
+	require.NoError(t, err)
+
+	second := *created
+	second.Name = "second.txt"
+	_, err = r.Update(ctx, &second)
+	assert.ErrorIs(t, err, repository.ErrVersionConflict)
+
+	found, err := r.FindByID(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "first.txt", found.Name)
+}
+
+func testUpdateMissing(t *testing.T, r repository.DocumentRepository) {
+	doc := newDoc(baseTime)
+	doc.Version = 1
+	_, err := r.Update(context.Background(), doc)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func testUpdateStatus(t *testing.T, r repository.DocumentRepository) {
+	ctx := context.Background()
+	created := mustCreate(t, r, newDoc(baseTime))
+
+	scannedAt := baseTime.Add(time.Minute)
+	change := *created
+	change.Status = model.StatusQuarantined
+	change.StoragePath = "quarantine/" + created.Filename
+	change.ContentETag = "etag-quarantined"
+	change.ScanStatus = model.ScanInfected
+	change.ScanEngine = "ClamAV 1.2.1/27100"
+	change.ScanSignature = "Eicar-Test-Signature"
+	change.ScannedAt = &scannedAt
+	change.UpdatedAt = baseTime.Add(time.Hour)
+	// Attributes are left as they are.
+	change.Name = "renamed.txt"
+
+	updated, err := r.UpdateStatus(ctx, &change, model.StatusPending)
+	require.NoError(t, err)
+	assert.Equal(t, created.Version+1, updated.Version)
+
+	found, err := r.FindByID(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusQuarantined, found.Status)
+	assert.Equal(t, change.StoragePath, found.StoragePath)
+	assert.Equal(t, change.ContentETag, found.ContentETag)
+	assert.Equal(t, model.ScanInfected, found.ScanStatus)
+	assert.Equal(t, change.ScanEngine, found.ScanEngine)
+	assert.Equal(t, change.ScanSignature, found.ScanSignature)
+	require.NotNil(t, found.ScannedAt)
+	assert.True(t, scannedAt.Equal(*found.ScannedAt))
+	assert.True(t, change.UpdatedAt.Equal(found.UpdatedAt))
+	assert.Equal(t, created.Name, found.Name)
+	assert.Equal(t, updated.Version, found.Version)
+}
+
+func testUpdateStatusConflict(t *testing.T, r repository.DocumentRepository) {
+	ctx := context.Background()
+	created := mustCreate(t, r, newDoc(baseTime))
+
+	change := *created
+	change.Status = model.StatusProcessing
+	_, err := r.UpdateStatus(ctx, &change, model.StatusPending)
+	require.NoError(t, err)
+
+	// A second worker that read the document while it was pending loses.
+	_, err = r.UpdateStatus(ctx, &change, model.StatusPending)
+	assert.ErrorIs(t, err, repository.ErrStatusConflict)
+
+	found, err := r.FindByID(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusProcessing, found.Status)
+	assert.Equal(t, created.Version+1, found.Version)
+}
+
+func testUpdateStatusMissing(t *testing.T, r repository.DocumentRepository) {
+	doc := newDoc(baseTime)
+	doc.Status = model.StatusProcessing
+	_, err := r.UpdateStatus(context.Background(), doc, model.StatusPending)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func testListOrder(t *testing.T, r repository.DocumentRepository) {
+	oldest := mustCreate(t, r, newDoc(baseTime))
+	newest := mustCreate(t, r, newDoc(baseTime.Add(2*time.Hour)))
+	middle := mustCreate(t, r, newDoc(baseTime.Add(time.Hour)))
+
+	res, err := r.List(context.Background(), repository.PageQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.Total)
+	assert.Equal(t, []string{newest.ID, middle.ID, oldest.ID}, ids(res.Items))
+}
+
+func testListTies(t *testing.T, r repository.DocumentRepository) {
+	// Same timestamp: ordering must fall back to id DESC. Fixed IDs make the expected order explicit.
+	tieIDs := []string{
+		"00000000-0000-4000-8000-000000000001",
+		"00000000-0000-4000-8000-000000000003",
+		"00000000-0000-4000-8000-000000000002",
+	}
+	for _, id := range tieIDs {
+		d := newDoc(baseTime)
+		d.ID = id
+		d.Filename = id + ".txt"
+		d.StoragePath = "documents/" + id + ".txt"
+		mustCreate(t, r, d)
+	}
+
+	res, err := r.List(context.Background(), repository.PageQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []string{tieIDs[1], tieIDs[2], tieIDs[0]}, ids(res.Items))
+}
+
+func testListPaging(t *testing.T, r repository.DocumentRepository) {
+	const n = 7
+	for i := 0; i < n; i++ {
+		// Pairs of documents share a timestamp so page boundaries fall inside ties.
+		mustCreate(t, r, newDoc(baseTime.Add(time.Duration(i/2)*time.Minute)))
+	}
+
+	full, err := r.List(context.Background(), repository.PageQuery{Limit: n})
+	require.NoError(t, err)
+	require.Len(t, full.Items, n)
+
+	var paged []string
+	for offset := 0; offset < n; offset += 3 {
+		res, err := r.List(context.Background(), repository.PageQuery{Limit: 3, Offset: offset})
+		require.NoError(t, err)
+		assert.Equal(t, n, res.Total)
+		paged = append(paged, ids(res.Items)...)
+	}
+	assert.Equal(t, ids(full.Items), paged, "concatenated pages must equal the full listing")
+}
+
+func testListPastEnd(t *testing.T, r repository.DocumentRepository) {
+	mustCreate(t, r, newDoc(baseTime))
+
+	res, err := r.List(context.Background(), repository.PageQuery{Limit: 10, Offset: 5})
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Total)
+	assert.NotNil(t, res.Items)
+	assert.Empty(t, res.Items)
+}
+
+func testListKeyset(t *testing.T, r repository.DocumentRepository) {
+	const n = 7
+	for i := 0; i < n; i++ {
+		// Pairs of documents share a timestamp so page boundaries fall inside ties.
+		mustCreate(t, r, newDoc(baseTime.Add(time.Duration(i/2)*time.Minute)))
+	}
+
+	full, err := r.List(context.Background(), repository.PageQuery{Limit: n})
+	require.NoError(t, err)
+	assert.Nil(t, full.Next, "no page follows a complete listing")
+
+	var paged []string
+	pq := repository.PageQuery{Limit: 3}
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, n, "listing does not terminate")
+		res, err := r.List(context.Background(), pq)
+		require.NoError(t, err)
+		paged = append(paged, ids(res.Items)...)
+		if res.Next == nil {
+			break
+		}
+		assert.Equal(t, repository.CursorOf(res.Items[len(res.Items)-1]), res.Next)
+		pq.After = res.Next
+	}
+	assert.Equal(t, ids(full.Items), paged, "concatenated pages must equal the full listing")
+}
+
+func testListKeysetInserts(t *testing.T, r repository.DocumentRepository) {
+	for i := 0; i < 4; i++ {
+		mustCreate(t, r, newDoc(baseTime.Add(time.Duration(i)*time.Minute)))
+	}
+	first, err := r.List(context.Background(), repository.PageQuery{Limit: 2})
+	require.NoError(t, err)
+	require.NotNil(t, first.Next)
+
+	// A newer document shifts offsets but not keyset positions.
+	mustCreate(t, r, newDoc(baseTime.Add(time.Hour)))
+
+	second, err := r.List(context.Background(), repository.PageQuery{Limit: 2, After: first.Next})
+	require.NoError(t, err)
+	full, err := r.List(context.Background(), repository.PageQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, ids(full.Items[3:5]), ids(second.Items))
+	assert.Nil(t, second.Next)
+}
+
+func testListSkipTotal(t *testing.T, r repository.DocumentRepository) {
+	mustCreate(t, r, newDoc(baseTime))
+
+	res, err := r.List(context.Background(), repository.PageQuery{Limit: 10, SkipTotal: true})
+	require.NoError(t, err)
+	assert.Equal(t, -1, res.Total)
+	assert.Len(t, res.Items, 1)
+}
+
+// filterFixture creates documents that differ in every filterable field.
+func filterFixture(t *testing.T, r repository.DocumentRepository) map[string]*model.Document {
+	t.Helper()
+	docs := map[string]*model.Document{}
+	for i, spec := range []struct {
+		key, name, contentType string
+		size                   int64
+		status                 string
+	}{
+		{"pdf", "Quarterly Report.pdf", "application/pdf", 1000, model.StatusAvailable},
+		{"png", "logo.png", "image/png", 50, model.StatusQuarantined},
+		{"jpeg", "photo_2024.JPG", "image/jpeg; foo=bar", 5000, model.StatusAvailable},
+		{"text", "notes 100%.txt", "Text/Plain; charset=utf-8", 0, model.StatusPending},
+	} {
+		d := newDoc(baseTime.Add(time.Duration(i) * time.Hour))
+		d.Name, d.ContentType, d.Size, d.Status = spec.name, spec.contentType, spec.size, spec.status
+		docs[spec.key] = mustCreate(t, r, d)
+	}
+	return docs
+}
+
+func testListFilters(t *testing.T, r repository.DocumentRepository) {
+	docs := filterFixture(t, r)
+	size := func(n int64) *int64 { return &n }
+
+	tests := []struct {
+		name   string
+		filter repository.DocumentFilter
+		want   []string
+	}{
+		{name: "none", want: []string{"text", "jpeg", "png", "pdf"}},
+		{name: "exact type", filter: repository.DocumentFilter{ContentType: "application/pdf"}, want: []string{"pdf"}},
+		{name: "exact type ignores parameters and case", filter: repository.DocumentFilter{ContentType: "text/plain"}, want: []string{"text"}},
+		{name: "wildcard type", filter: repository.DocumentFilter{ContentType: "image/*"}, want: []string{"jpeg", "png"}},
+		{name: "wildcard is not a prefix match", filter: repository.DocumentFilter{ContentType: "imag/*"}},
+		{name: "created from", filter: repository.DocumentFilter{CreatedFrom: baseTime.Add(2 * time.Hour)}, want: []string{"text", "jpeg"}},
+		{name: "created to is exclusive", filter: repository.DocumentFilter{CreatedTo: baseTime.Add(time.Hour)}, want: []string{"pdf"}},
+		{name: "size range is inclusive", filter: repository.DocumentFilter{MinSize: size(50), MaxSize: size(1000)}, want: []string{"png", "pdf"}},
+		{name: "max size zero", filter: repository.DocumentFilter{MaxSize: size(0)}, want: []string{"text"}},
+		{name: "name is case-insensitive", filter: repository.DocumentFilter{Name: "REPORT"}, want: []string{"pdf"}},
+		{name: "name wildcards are literal", filter: repository.DocumentFilter{Name: "100%"}, want: []string{"text"}},
+		{name: "name underscore is literal", filter: repository.DocumentFilter{Name: "o_2"}, want: []string{"jpeg"}},
+		{name: "status", filter: repository.DocumentFilter{Statuses: []string{model.StatusAvailable}}, want: []string{"jpeg", "pdf"}},
+		{
+			name:   "any of several statuses",
+			filter: repository.DocumentFilter{Statuses: []string{model.StatusPending, model.StatusQuarantined}},
+			want:   []string{"text", "png"},
+		},
+		{name: "status without documents", filter: repository.DocumentFilter{Statuses: []string{model.StatusFailed}}},
+		{
+			name:   "combined",
+			filter: repository.DocumentFilter{ContentType: "image/*", MinSize: size(100)},
+			want:   []string{"jpeg"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := r.List(context.Background(), repository.PageQuery{Limit: 10, Filter: tt.filter})
+			require.NoError(t, err)
+			want := make([]string, 0, len(tt.want))
+			for _, k := range tt.want {
+				want = append(want, docs[k].ID)
+			}
+			assert.Equal(t, want, ids(res.Items))
+			assert.Equal(t, len(tt.want), res.Total, "total counts filtered rows")
+		})
+	}
+}
+
+func testListSorts(t *testing.T, r repository.DocumentRepository) {
+	docs := filterFixture(t, r)
+
+	tests := []struct {
+		sort repository.Sort
+		want []string
+	}{
+		{sort: repository.Sort{Field: repository.SortCreatedAt, Asc: true}, want: []string{"pdf", "png", "jpeg", "text"}},
+		{sort: repository.Sort{Field: repository.SortName, Asc: true}, want: []string{"pdf", "logo", "notes", "photo"}},
+		{sort: repository.Sort{Field: repository.SortSize}, want: []string{"jpeg", "pdf", "png", "text"}},
+		{sort: repository.Sort{Field: repository.SortContentType, Asc: true}, want: []string{"text", "pdf", "jpeg", "png"}},
+	}
+	byName := map[string]string{"logo": "png", "notes": "text", "photo": "jpeg", "pdf": "pdf"}
+	for _, tt := range tests {
+		t.Run(tt.sort.String(), func(t *testing.T) {
+			res, err := r.List(context.Background(), repository.PageQuery{Limit: 10, Sort: tt.sort})
+			require.NoError(t, err)
+			want := make([]string, 0, len(tt.want))
+			for _, k := range tt.want {
+				if key, ok := byName[k]; ok {
+					k = key
+				}
+				want = append(want, docs[k].ID)
+			}
+			assert.Equal(t, want, ids(res.Items))
+		})
+	}
+}
+
+func testListKeysetSorted(t *testing.T, r repository.DocumentRepository) {
+	const n = 7
+	for i := 0; i < n; i++ {
+		d := newDoc(baseTime.Add(time.Duration(i) * time.Minute))
+		// Pairs of documents share a size so page boundaries fall inside ties.
+		d.Size = int64(i / 2)
+		mustCreate(t, r, d)
+	}
+	for _, order := range []repository.Sort{{Field: repository.SortSize}, {Field: repository.SortSize, Asc: true}, {Field: repository.SortName, Asc: true}} {
+		t.Run(order.String(), func(t *testing.T) {
+			full, err := r.List(context.Background(), repository.PageQuery{Limit: n, Sort: order})
+			require.NoError(t, err)
+
+			var paged []string
+			pq := repository.PageQuery{Limit: 2, Sort: order}
+			for pages := 0; ; pages++ {
+				require.Less(t, pages, n, "listing does not terminate")
+				res, err := r.List(context.Background(), pq)
+				require.NoError(t, err)
+				paged = append(paged, ids(res.Items)...)
+				if res.Next == nil {
+					break
+				}
+				pq.After = res.Next
+			}
+			assert.Equal(t, ids(full.Items), paged)
+		})
+	}
+}
+
+func testDelete(t *testing.T, r repository.DocumentRepository) {
+	doc := mustCreate(t, r, newDoc(baseTime))
+	require.NoError(t, r.Delete(context.Background(), doc.ID))
+
+	_, err := r.FindByID(context.Background(), doc.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func testDeleteMissing(t *testing.T, r repository.DocumentRepository) {
+	assert.NoError(t, r.Delete(context.Background(), uuid.NewString()))
+}
+
+func testFindByIDs(t *testing.T, r repository.DocumentRepository) {
+	a := mustCreate(t, r, newDoc(baseTime))
+	b := mustCreate(t, r, newDoc(baseTime.Add(time.Second)))
+	mustCreate(t, r, newDoc(baseTime.Add(2*time.Second)))
+
+	docs, err := r.FindByIDs(context.Background(), []string{b.ID, uuid.NewString(), a.ID})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{a.ID, b.ID}, ids(docs))
+
+	docs, err = r.FindByIDs(context.Background(), []string{})
+	require.NoError(t, err)
+	assert.Empty(t, docs)
+}
+
+func testFindByStoragePaths(t *testing.T, r repository.DocumentRepository) {
+	a := mustCreate(t, r, newDoc(baseTime))
+	b := mustCreate(t, r, newDoc(baseTime.Add(time.Second)))
+	mustCreate(t, r, newDoc(baseTime.Add(2*time.Second)))
+
+	docs, err := r.FindByStoragePaths(context.Background(), []string{b.StoragePath, "documents/missing.txt", a.StoragePath})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{a.ID, b.ID}, ids(docs))
+
+	docs, err = r.FindByStoragePaths(context.Background(), []string{})
+	require.NoError(t, err)
+	assert.Empty(t, docs)
+}
+
+func testDeleteMany(t *testing.T, r repository.DocumentRepository) {
+	a := mustCreate(t, r, newDoc(baseTime))
+	b := mustCreate(t, r, newDoc(baseTime.Add(time.Second)))
+	c := mustCreate(t, r, newDoc(baseTime.Add(2*time.Second)))
+
+	n, err := r.DeleteMany(context.Background(), []string{a.ID, uuid.NewString(), c.ID})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	res, err := r.List(context.Background(), repository.PageQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []string{b.ID}, ids(res.Items))
+
+	n, err = r.DeleteMany(context.Background(), []string{a.ID})
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func testDeleteVersion(t *testing.T, r repository.DocumentRepository) {
+	ctx := context.Background()
+	doc := mustCreate(t, r, newDoc(baseTime))
+
+	edit := *doc
+	edit.Name = "renamed.txt"
+	_, err := r.Update(ctx, &edit)
+	require.NoError(t, err)
+
+	err = r.DeleteVersion(ctx, doc.ID, doc.Version)
+	assert.ErrorIs(t, err, repository.ErrVersionConflict)
+	_, err = r.FindByID(ctx, doc.ID)
+	require.NoError(t, err, "an edited document is kept")
+
+	require.NoError(t, r.DeleteVersion(ctx, doc.ID, doc.Version+1))
+	_, err = r.FindByID(ctx, doc.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	err = r.DeleteVersion(ctx, doc.ID, doc.Version+1)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func testDeleteUnmoved(t *testing.T, r repository.DocumentRepository) {
+	a := mustCreate(t, r, newDoc(baseTime))
+	b := mustCreate(t, r, newDoc(baseTime.Add(time.Second)))
+	moved := *b
+	moved.StoragePath = "documents/moved.txt"
+
+	n, err := r.DeleteUnmoved(context.Background(), []model.Document{*a, moved, {ID: uuid.NewString(), StoragePath: a.StoragePath}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	res, err := r.List(context.Background(), repository.PageQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []string{b.ID}, ids(res.Items), "a document stored elsewhere is kept")
+}
+
+func testConcurrentCreates(t *testing.T, r repository.DocumentRepository) {
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = r.Create(context.Background(), newDoc(baseTime.Add(time.Duration(i)*time.Second)))
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		assert.NoError(t, err, fmt.Sprintf("create #%d", i))
+	}
+
+	res, err := r.List(context.Background(), repository.PageQuery{Limit: n})
+	require.NoError(t, err)
+	assert.Equal(t, n, res.Total)
+	assert.Len(t, res.Items, n)
+}
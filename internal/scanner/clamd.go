@@ -0,0 +1,172 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"docapi/internal/config"
+)
+
+// Defaults for Clamd; see config.ScanConfig.
+const (
+	DefaultClamdTimeout   = 30 * time.Second
+	DefaultClamdChunkSize = 64 << 10
+)
+
+// Clamd scans content with a clamd daemon over TCP, streaming it with the INSTREAM command.
+//
+// clamd closes the stream with "INSTREAM size limit exceeded" when content exceeds its
+// StreamMaxLength; such content is reported as not scanned, so clamd's limit must be at least
+// the largest accepted upload.
+type Clamd struct {
+	addr      string
+	timeout   time.Duration
+	chunkSize int
+	dialer    net.Dialer
+}
+
+var _ Scanner = (*Clamd)(nil)
+
+// NewClamd creates a scanner for the clamd daemon at cfg.ClamdAddr ("host:port").
+func NewClamd(cfg config.ScanConfig) (*Clamd, error) {
+	if cfg.ClamdAddr == "" {
+		return nil, fmt.Errorf("clamd address is required")
+	}
+	if _, _, err := net.SplitHostPort(cfg.ClamdAddr); err != nil {
+		return nil, fmt.Errorf("invalid clamd address %q: %w", cfg.ClamdAddr, err)
+	}
+	c := &Clamd{
+		addr:      cfg.ClamdAddr,
+		timeout:   cfg.Timeout,
+		chunkSize: cfg.ChunkSize,
+	}
+	if c.timeout <= 0 {
+		c.timeout = DefaultClamdTimeout
+	}
+	if c.chunkSize <= 0 {
+		c.chunkSize = DefaultClamdChunkSize
+	}
+	return c, nil
+}
+
+// Scan asks clamd for its version, then streams r to it. The timeout applies to every read and
+// write, so large content may take longer as long as clamd keeps up.
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	engine, err := c.Version(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var reply string
+	err = c.session(ctx, "INSTREAM", func(conn net.Conn) error {
+		serr := c.stream(conn, r)
+		if serr != nil && !errors.Is(serr, ErrScanFailed) {
+			return serr
+		}
+		// clamd replies early and closes the connection when it rejects a stream, for example
+		// above its size limit; the reply explains the failed write.
+		var rerr error
+		reply, rerr = c.read(conn)
+		if serr != nil && rerr != nil {
+			return serr
+		}
+		return rerr
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return Result{Engine: engine}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND"), Engine: engine}, nil
+	default:
+		return Result{}, fmt.Errorf("%w: clamd: %s", ErrScanFailed, reply)
+	}
+}
+
+// Version returns clamd's engine and signature database version.
+func (c *Clamd) Version(ctx context.Context) (string, error) {
+	var version string
+	err := c.session(ctx, "VERSION", func(conn net.Conn) (err error) {
+		version, err = c.read(conn)
+		return err
+	})
+	return version, err
+}
+
+// session sends a null-terminated command on a new connection and runs fn to exchange the rest.
+// Canceling ctx interrupts blocked reads and writes.
+func (c *Clamd) session(ctx context.Context, command string, fn func(conn net.Conn) error) error {
+	conn, err := c.dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return fmt.Errorf("%w: connect to clamd: %v", ErrScanFailed, err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	if err := c.write(conn, []byte("z"+command+"\x00")); err != nil {
+		return err
+	}
+	if err := fn(conn); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+// stream sends r as INSTREAM chunks, each prefixed with its length, and the terminating empty
+// chunk.
+func (c *Clamd) stream(conn net.Conn, r io.Reader) error {
+	buf := make([]byte, 4+c.chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if werr := c.write(conn, buf[:4+n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read content: %w", err)
+		}
+	}
+	return c.write(conn, make([]byte, 4))
+}
+
+func (c *Clamd) write(conn net.Conn, p []byte) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return fmt.Errorf("%w: %v", ErrScanFailed, err)
+	}
+	if _, err := conn.Write(p); err != nil {
+		return fmt.Errorf("%w: write to clamd: %v", ErrScanFailed, err)
+	}
+	return nil
+}
+
+// read returns clamd's null-terminated reply.
+func (c *Clamd) read(conn net.Conn) (string, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrScanFailed, err)
+	}
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && (err != io.EOF || reply == "") {
+		return "", fmt.Errorf("%w: read from clamd: %v", ErrScanFailed, err)
+	}
+	return strings.TrimSpace(strings.TrimSuffix(reply, "\x00")), nil
+}
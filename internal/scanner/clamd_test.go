@@ -0,0 +1,193 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"docapi/internal/config"
+)
+
+const (
+	fakeVersion = "ClamAV 1.2.1/27100/Tue Nov 14 08:40:59 2023"
+	eicar       = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+)
+
+// fakeClamd is a minimal clamd speaking VERSION and INSTREAM on a local port. It reports the
+// EICAR test string as infected and rejects streams above maxSize.
+type fakeClamd struct {
+	ln       net.Listener
+	maxSize  int
+	received chan []byte
+}
+
+func newFakeClamd(t *testing.T, maxSize int) *fakeClamd {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	f := &fakeClamd{ln: ln, maxSize: maxSize, received: make(chan []byte, 16)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeClamd) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	command, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+	switch command {
+	case "zVERSION\x00":
+		conn.Write([]byte(fakeVersion + "\x00"))
+	case "zINSTREAM\x00":
+		var content []byte
+		for {
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				return
+			}
+			if size == 0 {
+				break
+			}
+			if len(content)+int(size) > f.maxSize {
+				conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+				return
+			}
+			chunk := make([]byte, size)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return
+			}
+			content = append(content, chunk...)
+		}
+		f.received <- content
+		if bytes.Contains(content, []byte(eicar)) {
+			conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+		} else {
+			conn.Write([]byte("stream: OK\x00"))
+		}
+	default:
+		conn.Write([]byte("UNKNOWN COMMAND\x00"))
+	}
+}
+
+func TestClamd_Scan(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeClamd(t, 1<<20)
+	c, err := NewClamd(config.ScanConfig{ClamdAddr: fake.ln.Addr().String(), ChunkSize: 7})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		content string
+		want    Result
+	}{
+		{"clean", "hello, world", Result{Engine: fakeVersion}},
+		{"empty", "", Result{Engine: fakeVersion}},
+		{"infected", "prefix " + eicar + " suffix", Result{Infected: true, Signature: "Eicar-Test-Signature", Engine: fakeVersion}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := c.Scan(ctx, strings.NewReader(tt.content))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, res)
+			// Content is streamed in several chunks and reassembled intact.
+			assert.Equal(t, tt.content, string(<-fake.received))
+		})
+	}
+}
+
+func TestClamd_ScanFailures(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("size limit exceeded", func(t *testing.T) {
+		fake := newFakeClamd(t, 10)
+		c, err := NewClamd(config.ScanConfig{ClamdAddr: fake.ln.Addr().String(), ChunkSize: 4})
+		require.NoError(t, err)
+
+		_, err = c.Scan(ctx, strings.NewReader(strings.Repeat("x", 1<<16)))
+		assert.ErrorIs(t, err, ErrScanFailed)
+		assert.ErrorContains(t, err, "size limit exceeded")
+	})
+
+	t.Run("clamd unreachable", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := ln.Addr().String()
+		ln.Close()
+
+		c, err := NewClamd(config.ScanConfig{ClamdAddr: addr})
+		require.NoError(t, err)
+		_, err = c.Scan(ctx, strings.NewReader("hello"))
+		assert.ErrorIs(t, err, ErrScanFailed)
+	})
+
+	t.Run("clamd does not answer", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer ln.Close()
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				// Hold the connection open without ever replying.
+				t.Cleanup(func() { conn.Close() })
+			}
+		}()
+
+		c, err := NewClamd(config.ScanConfig{ClamdAddr: ln.Addr().String()})
+		require.NoError(t, err)
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		_, err = c.Scan(ctx, strings.NewReader("hello"))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("content read error", func(t *testing.T) {
+		fake := newFakeClamd(t, 1<<20)
+		c, err := NewClamd(config.ScanConfig{ClamdAddr: fake.ln.Addr().String()})
+		require.NoError(t, err)
+
+		readErr := errors.New("client went away")
+		_, err = c.Scan(ctx, io.MultiReader(strings.NewReader("hello"), &failingReader{readErr}))
+		assert.ErrorIs(t, err, readErr)
+		assert.NotErrorIs(t, err, ErrScanFailed)
+	})
+}
+
+func TestNewClamd(t *testing.T) {
+	_, err := NewClamd(config.ScanConfig{})
+	assert.Error(t, err)
+	_, err = NewClamd(config.ScanConfig{ClamdAddr: "clamd"})
+	assert.Error(t, err)
+
+	c, err := NewClamd(config.ScanConfig{ClamdAddr: "clamd:3310"})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultClamdTimeout, c.timeout)
+	assert.Equal(t, DefaultClamdChunkSize, c.chunkSize)
+}
+
+type failingReader struct{ err error }
+
+func (r *failingReader) Read([]byte) (int, error) { return 0, r.err }
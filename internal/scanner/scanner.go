@@ -0,0 +1,30 @@
+// Package scanner checks document content for malware before it is served.
+package scanner
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrScanFailed is returned, wrapped with the cause, when content could not be scanned, so its
+// verdict is unknown.
+var ErrScanFailed = errors.New("scan failed")
+
+// Result is the verdict of a scan.
+type Result struct {
+	// Infected reports whether malware was found.
+	Infected bool
+	// Signature names the malware found; it is empty for clean content.
+	Signature string
+	// Engine identifies the engine and signature database that scanned the content, e.g.
+	// "ClamAV 1.2.1/27100/Tue Nov 14 08:40:59 2023".
+	Engine string
+}
+
+// Scanner scans content for malware.
+type Scanner interface {
+	// Scan reads r to the end and returns its verdict. It returns an error wrapping ErrScanFailed
+	// if the engine could not scan the content.
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
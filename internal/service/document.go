@@ -3,16 +3,26 @@ package service
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"mime"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
 
+	"docapi/internal/archive"
 	"docapi/internal/model"
 	"docapi/internal/repository"
+	"docapi/internal/scanner"
 	"docapi/internal/storage"
 )
 
@@ -20,75 +30,659 @@ var (
 	ErrIDRequired = errors.New("id is required")
 	ErrNotFound   = errors.New("document not found")
 	ErrReaderNil  = errors.New("reader is nil")
+	// ErrInvalidRange is returned when a download range starts beyond the end of the document.
+	ErrInvalidRange = errors.New("range not satisfiable")
+	// ErrPresignUnsupported is returned when the storage backend cannot issue presigned URLs.
+	ErrPresignUnsupported = errors.New("presigned urls are not supported")
+	// ErrInvalidCursor is returned for list cursors that were not issued by List, or that were
+	// issued for a different sort order.
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidSort is returned by ParseSort for unknown sort fields.
+	ErrInvalidSort = errors.New("invalid sort")
+	// ErrInvalidID marks batch items whose ID is not a UUID.
+	ErrInvalidID = errors.New("invalid id")
+	// ErrTooManyIDs is returned when a batch exceeds the configured number of IDs.
+	ErrTooManyIDs = errors.New("too many ids")
+	// ErrTooManyFiles is returned when a batch upload exceeds the configured number of files.
+	ErrTooManyFiles = errors.New("too many files")
+	// ErrFileOpen marks batch upload items whose content could not be opened.
+	ErrFileOpen = errors.New("cannot open file")
+	// ErrRollbackFailed marks documents of a failed atomic upload that could not be removed again.
+	ErrRollbackFailed = errors.New("rollback failed")
+	// ErrTooManyDocuments is returned when an archive selection exceeds the configured limit.
+	ErrTooManyDocuments = errors.New("too many documents")
+	// ErrInvalidPatch is returned by Update for changes that would leave a field invalid.
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrPreconditionFailed is returned by Update and Delete when the document does not satisfy the
+	// caller's precondition, typically because it was changed since the caller read it.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrConflict is returned by Update when concurrent edits kept it from saving its change.
+	ErrConflict = errors.New("document was changed concurrently")
+	// ErrQuarantined is returned when the content of a quarantined document is requested.
+	ErrQuarantined = errors.New("document is quarantined")
+	// ErrNotAvailable is returned, wrapped with the document's status, when the content of a
+	// document that is pending, processing or failed is requested.
+	ErrNotAvailable = errors.New("document is not available")
+	// ErrInvalidTransition is returned when a document cannot move to the requested status from
+	// its current one, such as releasing a document that is not quarantined.
+	ErrInvalidTransition = errors.New("invalid status transition")
+	// ErrScanFailed is returned, wrapped, by uploads whose content could not be scanned for
+	// malware; nothing is stored.
+	ErrScanFailed = scanner.ErrScanFailed
 )
 
+// updateAttempts bounds how often Update and Delete re-read a document that was changed while it
+// was being updated or deleted.
+const updateAttempts = 3
+
+// Errors of archive imports; see the archive package.
+var (
+	ErrUnsupportedArchive = archive.ErrUnsupportedFormat
+	ErrInvalidArchive     = archive.ErrInvalidArchive
+	ErrTooManyEntries     = archive.ErrTooManyEntries
+	ErrArchiveTooLarge    = archive.ErrArchiveTooLarge
+	ErrEntryTooLarge      = archive.ErrEntryTooLarge
+	ErrRatioExceeded      = archive.ErrRatioExceeded
+	ErrUnsafePath         = archive.ErrUnsafePath
+	ErrNotRegular         = archive.ErrNotRegular
+)
+
+// Metadata keys set on documents created by ImportArchive.
+const (
+	// MetadataArchive is the filename of the archive a document was imported from.
+	MetadataArchive = "archive"
+	// MetadataArchivePath is the document's relative path inside that archive.
+	MetadataArchivePath = "archive_path"
+)
+
+// Defaults for batch operations; see WithBatchDelete and WithBatchUpload.
+const (
+	DefaultBatchDeleteMaxIDs      = 100
+	DefaultBatchDeleteConcurrency = 8
+	DefaultBatchUploadMaxFiles    = 100
+	DefaultBatchUploadConcurrency = 4
+	DefaultArchiveMaxDocuments    = 1000
+)
+
+// DefaultImportLimits bound archive imports unless WithImportLimits is given.
+var DefaultImportLimits = ImportLimits{
+	MaxEntries:   10000,
+	MaxEntrySize: 1 << 30,
+	MaxTotalSize: 10 << 30,
+	MaxRatio:     100,
+}
+
+// ImportLimits bound what ImportArchive extracts; see archive.Limits.
+type ImportLimits = archive.Limits
+
+// ArchiveSelection selects the documents of an archive: the given IDs in order, or, when IDs is
+// empty, every document matching Filter in Sort order.
+type ArchiveSelection struct {
+	IDs    []string
+	Filter DocumentFilter
+	Sort   Sort
+}
+
+// Archive is a resolved ArchiveSelection, ready to be written.
+type Archive struct {
+	Documents []model.Document
+	// Missing lists the selected IDs without a document.
+	Missing []string
+}
+
+// UploadFile is one file of a batch upload.
+type UploadFile struct {
+	Filename    string
+	ContentType string
+	Size        int64
+	// Open returns the file's content when its upload starts; the service closes it.
+	Open func() (io.ReadCloser, error)
+}
+
+// UploadStatus is the outcome of uploading one file of a batch.
+type UploadStatus string
+
+const (
+	UploadStatusCreated UploadStatus = "created"
+	UploadStatusFailed  UploadStatus = "failed"
+	// UploadStatusRolledBack marks files of a failed atomic batch that were undone or never stored
+	// because another file failed.
+	UploadStatusRolledBack UploadStatus = "rolled_back"
+)
+
+// UploadResult reports what happened to one file of a batch upload.
+type UploadResult struct {
+	Filename string
+	Status   UploadStatus
+	// Document is the created document. After ErrRollbackFailed it is the document that could not
+	// be fully removed.
+	Document *model.Document
+	// Err explains a failed upload.
+	Err error
+}
+
+// CopyOptions customise the document created by Copy. Zero fields keep the source's values.
+type CopyOptions struct {
+	// Name is the new document's name; its extension is used for the stored filename, as on upload.
+	Name string
+	// Metadata replaces the source's metadata when not nil; an empty map clears it.
+	Metadata map[string]string
+}
+
+// DocumentPatch changes the mutable fields of a document; nil fields are left as they are.
+type DocumentPatch struct {
+	Name        *string
+	ContentType *string
+	// Tags replaces the document's tags when not nil; an empty slice clears them. Tags are
+	// trimmed and duplicates dropped.
+	Tags *[]string
+	// Metadata is merged into the document's metadata: a nil value removes the key.
+	Metadata map[string]*string
+	// ClearMetadata removes all metadata before Metadata is merged.
+	ClearMetadata bool
+}
+
+// Precondition reports whether a document may be changed or deleted in its current state.
+type Precondition func(doc *model.Document) bool
+
+// ImportStatus is the outcome of importing one file of an archive.
+type ImportStatus string
+
+const (
+	ImportStatusCreated ImportStatus = "created"
+	ImportStatusFailed  ImportStatus = "failed"
+	// ImportStatusRejected marks files refused by the import rules: unsafe paths, links and other
+	// special files, and files over the size or compression ratio limits.
+	ImportStatusRejected ImportStatus = "rejected"
+)
+
+// ImportResult reports what happened to one file of an imported archive.
+type ImportResult struct {
+	// Path is the file's path inside the archive.
+	Path     string
+	Status   ImportStatus
+	Document *model.Document
+	// Err explains a failed or rejected file.
+	Err error
+}
+
+// DeleteStatus is the outcome of deleting one document of a batch.
+type DeleteStatus string
+
+const (
+	DeleteStatusDeleted  DeleteStatus = "deleted"
+	DeleteStatusNotFound DeleteStatus = "not_found"
+	DeleteStatusFailed   DeleteStatus = "failed"
+)
+
+// DeleteResult reports what happened to one ID of a batch deletion.
+type DeleteResult struct {
+	ID     string
+	Status DeleteStatus
+	// Err explains a failed deletion; it is ErrInvalidID for malformed IDs.
+	Err error
+}
+
+// DocumentFilter restricts a document listing; see repository.DocumentFilter.
+type DocumentFilter = repository.DocumentFilter
+
+// Sort is a document listing order; see repository.Sort.
+type Sort = repository.Sort
+
+// SortFields lists the fields documents can be sorted by.
+var SortFields = []repository.SortField{
+	repository.SortCreatedAt,
+	repository.SortName,
+	repository.SortSize,
+	repository.SortContentType,
+}
+
+// ParseSort parses a sort field name, prefixed with "-" for descending order.
+func ParseSort(s string) (Sort, error) {
+	asc := true
+	if rest, ok := strings.CutPrefix(s, "-"); ok {
+		s, asc = rest, false
+	}
+	field := repository.SortField(s)
+	if !field.Valid() {
+		return Sort{}, fmt.Errorf("%w: %q", ErrInvalidSort, s)
+	}
+	return Sort{Field: field, Asc: asc}, nil
+}
+
+// Statuses lists the processing statuses documents can be listed by. Pending and processing are
+// not among them: Upload returns only once a document has left them.
+var Statuses = []string{
+	model.StatusAvailable,
+	model.StatusQuarantined,
+	model.StatusFailed,
+}
+
+// transitions lists the statuses a document may move to from each status. Available and failed
+// documents stay as they are.
+var transitions = map[string][]string{
+	model.StatusPending:     {model.StatusProcessing, model.StatusFailed},
+	model.StatusProcessing:  {model.StatusAvailable, model.StatusQuarantined, model.StatusFailed},
+	model.StatusQuarantined: {model.StatusAvailable, model.StatusFailed},
+}
+
+// ListParams selects a page of documents.
+type ListParams struct {
+	Limit  int
+	Offset int
+	// Cursor continues a listing after the page that returned it as NextCursor. Offset is
+	// ignored when it is set.
+	Cursor string
+	// WithTotal counts all matching documents, which scans the whole table.
+	WithTotal bool
+	// Filter restricts the listed documents.
+	Filter DocumentFilter
+	// Sort orders the listing; the zero value lists the newest documents first. A cursor is only
+	// valid with the sort it was issued for.
+	Sort Sort
+}
+
 // DocumentListResult is the service-level DTO for paginated documents.
 type DocumentListResult struct {
 	Items []model.Document `json:"data"`
-	Total int              `json:"total"`
+	// Total is only set when requested.
+	Total *int `json:"total,omitempty"`
+	// NextCursor fetches the following page; empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // DocumentService defines the use cases for handling documents.
 type DocumentService interface {
 	// Upload uploads the content to object storage, saves metadata to DB, and rolls back storage if DB save fails.
-	// - originalFilename is used only to extract extension; stored filename will be UUID + original extension.
+	// - originalFilename is kept as the document's name; the stored filename will be UUID + original extension.
+	// - The document is created pending and then processed: it is returned available, or quarantined
+	//   if its content was found infected.
 	Upload(ctx context.Context, r io.Reader, originalFilename string, contentType string, size int64) (*model.Document, error)
 
-	// List returns documents using limit/offset and a total count.
-	List(ctx context.Context, limit, offset int) (*DocumentListResult, error)
+	// UploadMany uploads several files with bounded parallelism and reports the outcome per file, in
+	// order. Files fail independently unless atomic is set: then the first failure stops the batch
+	// and every document already created is removed again. The error is only set when the batch as
+	// a whole is rejected (ErrTooManyFiles).
+	UploadMany(ctx context.Context, files []UploadFile, atomic bool) ([]UploadResult, error)
+
+	// ImportArchive creates a document for every regular file of a ZIP, tar or gzip-compressed tar
+	// archive, one at a time in archive order, and reports the outcome per file. Documents are named
+	// after the file; the archive's name and the file's relative path are kept in their metadata.
+	// Files breaking a per-file limit are rejected and the import continues. The error is set when
+	// the archive cannot be read or an archive-wide limit (entry count, total size, or the ratio of
+	// a compressed tar) stops the import; the results then cover the files handled until then, and
+	// documents already created are kept.
+	ImportArchive(ctx context.Context, r io.ReaderAt, size int64, archiveName string) ([]ImportResult, error)
+
+	// List returns a page of documents selected by offset or cursor, and optionally a total count.
+	List(ctx context.Context, params ListParams) (*DocumentListResult, error)
 
 	// Get returns a single document by its ID.
 	Get(ctx context.Context, id string) (*model.Document, error)
 
-	// Delete removes a document by ID from both storage and repository.
-	Delete(ctx context.Context, id string) error
+	// Copy creates an independent document with the content of the document id, copied within
+	// object storage. Only available documents are copied; the copy is created pending and
+	// processed like an upload. Like Upload, it removes the copy again if it cannot be saved or
+	// processed.
+	Copy(ctx context.Context, id string, opts CopyOptions) (*model.Document, error)
+
+	// Update applies patch to the document id and returns it with its new version; a patch that
+	// changes nothing returns the document as it is. If pre is not nil and the document does not
+	// satisfy it, Update fails with ErrPreconditionFailed. Edits saved between reading and writing
+	// the document are never overwritten: Update reads it again and re-checks pre.
+	Update(ctx context.Context, id string, patch DocumentPatch, pre Precondition) (*model.Document, error)
+
+	// Delete removes a document by ID from both storage and repository. If pre is not nil and the
+	// document does not satisfy it, Delete fails with ErrPreconditionFailed. Like Update, it never
+	// deletes a version it has not checked: an edit saved in between makes it read the document
+	// again and re-check pre.
+	Delete(ctx context.Context, id string, pre Precondition) error
+
+	// DeleteMany removes several documents and reports the outcome per ID, in the order the IDs were
+	// first given; duplicates are reported once. Storage objects are deleted in parallel and the
+	// records of those that succeeded are then removed in one statement. The error is only set when
+	// the batch as a whole is rejected (ErrTooManyIDs) or the documents cannot be looked up.
+	DeleteMany(ctx context.Context, ids []string) ([]DeleteResult, error)
+
+	// PrepareArchive resolves the documents of an archive. It fails with ErrTooManyDocuments when
+	// the selection exceeds the configured limit and with ErrInvalidID for malformed IDs.
+	PrepareArchive(ctx context.Context, sel ArchiveSelection) (*Archive, error)
+
+	// WriteArchive streams a ZIP of the archive's documents, followed by a manifest with their
+	// checksums, to w. Documents whose content cannot be opened are listed in the manifest as
+	// skipped; an error while copying content aborts the archive.
+	WriteArchive(ctx context.Context, a *Archive, w io.Writer) error
+
+	// Download streams length bytes of a document's content starting at offset; a negative length reads
+	// to the end. A negative offset selects the last -offset bytes. The caller must close the reader.
+	Download(ctx context.Context, id string, offset, length int64) (io.ReadCloser, *model.Document, error)
+
+	// PresignURL returns a time-limited URL for downloading a document directly from object storage.
+	PresignURL(ctx context.Context, id string, expiry time.Duration) (string, error)
+
+	// Release makes a quarantined document available after review, for example when its scan
+	// result was a false positive. Its content is moved out of quarantine. It fails with
+	// ErrInvalidTransition if the document is not quarantined.
+	Release(ctx context.Context, id string) (*model.Document, error)
+
+	// Reject marks a quarantined document as failed after review. Its content stays in quarantine,
+	// never served, until the document is deleted. It fails with ErrInvalidTransition if the
+	// document is not quarantined.
+	Reject(ctx context.Context, id string) (*model.Document, error)
 }
 
 // documentService is a concrete implementation of DocumentService.
 type documentService struct {
 	store storage.Storage
 	repo  repository.DocumentRepository
+
+	batchDeleteMaxIDs      int
+	batchDeleteConcurrency int
+	batchUploadMaxFiles    int
+	batchUploadConcurrency int
+	archiveMaxDocuments    int
+	importLimits           ImportLimits
+	scanner                scanner.Scanner
+	jobs                   JobQueue
+}
+
+// Option configures a DocumentService.
+type Option func(*documentService)
+
+// WithBatchDelete sets the maximum number of IDs accepted by DeleteMany and how many storage
+// deletions it runs at once. Values below 1 keep the defaults.
+func WithBatchDelete(maxIDs, concurrency int) Option {
+	return func(s *documentService) {
+		if maxIDs > 0 {
+			s.batchDeleteMaxIDs = maxIDs
+		}
+		if concurrency > 0 {
+			s.batchDeleteConcurrency = concurrency
+		}
+	}
+}
+
+// WithBatchUpload sets the maximum number of files accepted by UploadMany and how many of them it
+// uploads at once. Values below 1 keep the defaults.
+func WithBatchUpload(maxFiles, concurrency int) Option {
+	return func(s *documentService) {
+		if maxFiles > 0 {
+			s.batchUploadMaxFiles = maxFiles
+		}
+		if concurrency > 0 {
+			s.batchUploadConcurrency = concurrency
+		}
+	}
+}
+
+// WithArchiveLimit sets the maximum number of documents in one archive. Values below 1 keep the
+// default.
+func WithArchiveLimit(maxDocuments int) Option {
+	return func(s *documentService) {
+		if maxDocuments > 0 {
+			s.archiveMaxDocuments = maxDocuments
+		}
+	}
+}
+
+// WithImportLimits sets the limits applied to archive imports. Zero fields are unlimited.
+func WithImportLimits(limits ImportLimits) Option {
+	return func(s *documentService) {
+		s.importLimits = limits
+	}
+}
+
+// WithScanner scans the content of every new upload with sc. Infected content is quarantined:
+// the document is created, with the scan result, but its content is never served. Uploads that
+// cannot be scanned fail.
+func WithScanner(sc scanner.Scanner) Option {
+	return func(s *documentService) {
+		s.scanner = sc
+	}
+}
+
+// WithJobQueue hands storage deletions that fail during a rollback to background jobs of kind
+// JobDeleteObject, instead of leaving the objects for reconciliation.
+func WithJobQueue(q JobQueue) Option {
+	return func(s *documentService) {
+		s.jobs = q
+	}
 }
 
 // NewDocumentService constructs a new DocumentService.
-func NewDocumentService(store storage.Storage, repo repository.DocumentRepository) DocumentService {
-	return &documentService{store: store, repo: repo}
+func NewDocumentService(store storage.Storage, repo repository.DocumentRepository, opts ...Option) DocumentService {
+	s := &documentService{
+		store:                  store,
+		repo:                   repo,
+		batchDeleteMaxIDs:      DefaultBatchDeleteMaxIDs,
+		batchDeleteConcurrency: DefaultBatchDeleteConcurrency,
+		batchUploadMaxFiles:    DefaultBatchUploadMaxFiles,
+		batchUploadConcurrency: DefaultBatchUploadConcurrency,
+		archiveMaxDocuments:    DefaultArchiveMaxDocuments,
+		importLimits:           DefaultImportLimits,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *documentService) Upload(ctx context.Context, r io.Reader, originalFilename string, contentType string, size int64) (*model.Document, error) {
+	return s.upload(ctx, r, originalFilename, contentType, size, nil)
+}
+
+// upload implements Upload, storing metadata with the document record.
+func (s *documentService) upload(ctx context.Context, r io.Reader, originalFilename string, contentType string, size int64, metadata map[string]string) (*model.Document, error) {
 	if r == nil {
 		return nil, ErrReaderNil
 	}
 	// Generate filename using UUID + extension
 	ext := filepath.Ext(originalFilename)
 	genName := uuid.New().String() + ext
-	key := filepath.ToSlash(filepath.Join("documents", genName))
+	key := documentPrefix + genName
 
-	// Upload to object storage
-	objInfo, err := s.store.Put(ctx, key, r, storage.PutObjectOptions{
+	// Upload to object storage, scanning the content on the way
+	opt := storage.PutObjectOptions{
 		Size:        size,
 		ContentType: contentType,
 		Metadata: map[string]string{
 			"original-filename": originalFilename,
 		},
-	})
+	}
+	var objInfo storage.ObjectInfo
+	var scan *scanner.Result
+	var err error
+	if s.scanner == nil {
+		if objInfo, err = s.store.Put(ctx, key, r, opt); err != nil {
+			err = fmt.Errorf("upload to storage: %w", err)
+		}
+	} else {
+		objInfo, scan, err = s.putScanned(ctx, key, r, opt)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("upload to storage: %w", err)
+		return nil, err
+	}
+
+	// Backends that don't report a separate stored size store the content as-is
+	storedSize := objInfo.StoredSize
+	if storedSize == 0 {
+		storedSize = objInfo.Size
 	}
 
 	// Save metadata to database
 	doc := &model.Document{
 		ID:          uuid.New().String(),
 		Filename:    genName,
+		Name:        originalFilename,
 		StoragePath: objInfo.Key,
 		Size:        objInfo.Size,
+		StoredSize:  storedSize,
 		ContentType: objInfo.ContentType,
 		CreatedAt:   time.Now().UTC(),
+		Metadata:    metadata,
+		ContentETag: strings.Trim(objInfo.ETag, `"`),
+		ScanStatus:  model.ScanUnscanned,
+		Status:      model.StatusPending,
+	}
+	if scan != nil {
+		doc.ScanStatus = model.ScanClean
+		if scan.Infected {
+			doc.ScanStatus = model.ScanInfected
+		}
+		doc.ScanEngine = scan.Engine
+		doc.ScanSignature = scan.Signature
+		scannedAt := doc.CreatedAt
+		doc.ScannedAt = &scannedAt
+	}
+	created, err := s.create(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	processed, err := s.process(ctx, created)
+	if err != nil {
+		// Don't leave a document behind that never becomes available.
+		if rbErr := s.discard(context.WithoutCancel(ctx), created); rbErr != nil {
+			return nil, fmt.Errorf("process: %v; rollback failed: %v", err, rbErr)
+		}
+		return nil, fmt.Errorf("process: %w", err)
+	}
+	return processed, nil
+}
+
+// process runs the processing steps of a pending document and moves it to its final status:
+// quarantined if its content was found infected, available otherwise. Malware scanning happens
+// while the content is stored, so only its verdict is applied here. Processing is part of the
+// upload request; pending and processing are never reported to the client.
+func (s *documentService) process(ctx context.Context, doc *model.Document) (*model.Document, error) {
+	to := model.StatusAvailable
+	if doc.ScanStatus == model.ScanInfected {
+		to = model.StatusQuarantined
+	}
+	return s.processAs(ctx, doc, to)
+}
+
+// processAs moves a pending document through processing to status to.
+func (s *documentService) processAs(ctx context.Context, doc *model.Document, to string) (*model.Document, error) {
+	doc, err := s.transition(ctx, doc, model.StatusProcessing)
+	if err != nil {
+		return nil, err
+	}
+	return s.transition(ctx, doc, to)
+}
+
+// transition moves doc to status to, saving the other state changes made to doc along with it. It
+// fails with ErrInvalidTransition if the move is not allowed, and with ErrConflict if the
+// document's status changed since doc was read.
+func (s *documentService) transition(ctx context.Context, doc *model.Document, to string) (*model.Document, error) {
+	if !slices.Contains(transitions[doc.Status], to) {
+		return nil, fmt.Errorf("%w: document is %s, cannot become %s", ErrInvalidTransition, doc.Status, to)
+	}
+	next := *doc
+	next.Status = to
+	next.UpdatedAt = time.Now().UTC()
+	updated, err := s.repo.UpdateStatus(ctx, &next, doc.Status)
+	switch {
+	case err == nil:
+		return updated, nil
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrNotFound
+	case errors.Is(err, repository.ErrStatusConflict):
+		return nil, ErrConflict
+	}
+	return nil, err
+}
+
+// discard deletes a document, record first so that it is never visible without its content.
+func (s *documentService) discard(ctx context.Context, doc *model.Document) error {
+	if err := s.repo.Delete(ctx, doc.ID); err != nil {
+		return fmt.Errorf("delete record: %w", err)
 	}
+	if err := s.deleteObject(ctx, doc.StoragePath); err != nil {
+		return fmt.Errorf("delete storage: %w", err)
+	}
+	return nil
+}
+
+// servable returns why the content of doc may not be served, or nil if it may.
+func servable(doc *model.Document) error {
+	switch {
+	case doc.Available():
+		return nil
+	case doc.Quarantined():
+		return ErrQuarantined
+	default:
+		return fmt.Errorf("%w: document is %s", ErrNotAvailable, doc.Status)
+	}
+}
+
+// putScanned stores r under key while streaming the same bytes to the scanner. Infected content
+// is moved under quarantinePrefix; the returned info describes the object where it ended up. If
+// the content cannot be scanned, or quarantined, the object is deleted again.
+func (s *documentService) putScanned(ctx context.Context, key string, r io.Reader, opt storage.PutObjectOptions) (storage.ObjectInfo, *scanner.Result, error) {
+	pr, pw := io.Pipe()
+	type verdict struct {
+		res scanner.Result
+		err error
+	}
+	done := make(chan verdict, 1)
+	go func() {
+		res, err := s.scanner.Scan(ctx, pr)
+		if err != nil {
+			// Fail the upload instead of blocking it on a scanner that stopped reading.
+			pr.CloseWithError(err)
+		} else {
+			_, _ = io.Copy(io.Discard, pr)
+		}
+		done <- verdict{res, err}
+	}()
+
+	objInfo, err := s.store.Put(ctx, key, io.TeeReader(r, pw), opt)
+	pw.CloseWithError(err)
+	v := <-done
+	if err != nil {
+		if errors.Is(v.err, ErrScanFailed) {
+			// The scanner failing aborted the upload.
+			return storage.ObjectInfo{}, nil, fmt.Errorf("scan: %w", v.err)
+		}
+		return storage.ObjectInfo{}, nil, fmt.Errorf("upload to storage: %w", err)
+	}
+	// Never keep content that was stored but not scanned, even if ctx was canceled.
+	cleanup := context.WithoutCancel(ctx)
+	if v.err != nil {
+		if delErr := s.deleteObject(cleanup, key); delErr != nil {
+			return storage.ObjectInfo{}, nil, fmt.Errorf("scan: %w; delete unscanned object: %v", v.err, delErr)
+		}
+		return storage.ObjectInfo{}, nil, fmt.Errorf("scan: %w", v.err)
+	}
+	if !v.res.Infected {
+		return objInfo, &v.res, nil
+	}
+
+	quarantineKey := quarantinePrefix + strings.TrimPrefix(key, documentPrefix)
+	quarantined, err := s.store.Copy(cleanup, key, quarantineKey)
+	if err == nil {
+		err = s.store.Delete(cleanup, key)
+	}
+	if err != nil {
+		// Remove the copy as well, or whatever part of it a failed copy left behind.
+		if delErr := s.deleteObject(cleanup, quarantineKey); delErr != nil {
+			err = fmt.Errorf("%w; delete quarantine copy: %v", err, delErr)
+		}
+		if delErr := s.deleteObject(cleanup, key); delErr != nil {
+			return storage.ObjectInfo{}, nil, fmt.Errorf("quarantine: %v; delete infected object: %v", err, delErr)
+		}
+		return storage.ObjectInfo{}, nil, fmt.Errorf("quarantine: %w", err)
+	}
+	return quarantined, &v.res, nil
+}
+
+// create saves the record of a document whose content is already stored, and deletes the content
+// again if that fails.
+func (s *documentService) create(ctx context.Context, doc *model.Document) (*model.Document, error) {
 	stored, err := s.repo.Create(ctx, doc)
 	if err != nil {
-		// Rollback: delete the object from storage
-		if delErr := s.store.Delete(ctx, key); delErr != nil {
+		// Rollback: delete the object from storage, even if ctx was canceled
+		if delErr := s.deleteObject(context.WithoutCancel(ctx), doc.StoragePath); delErr != nil {
 			return nil, fmt.Errorf("db save failed: %v; rollback delete failed: %v", err, delErr)
 		}
 		return nil, fmt.Errorf("db save failed: %w", err)
@@ -96,20 +690,180 @@ func (s *documentService) Upload(ctx context.Context, r io.Reader, originalFilen
 	return stored, nil
 }
 
+// UploadMany runs Upload for each file. In atomic mode a failure cancels the uploads still in
+// flight, and the documents created so far are deleted, records first so that none is ever
+// visible without its content.
+func (s *documentService) UploadMany(ctx context.Context, files []UploadFile, atomic bool) ([]UploadResult, error) {
+	if len(files) > s.batchUploadMaxFiles {
+		return nil, fmt.Errorf("%w: at most %d per request", ErrTooManyFiles, s.batchUploadMaxFiles)
+	}
+
+	results := make([]UploadResult, len(files))
+	uploadCtx, abort := context.WithCancel(ctx)
+	defer abort()
+
+	var g errgroup.Group
+	g.SetLimit(s.batchUploadConcurrency)
+	for i, f := range files {
+		res := &results[i]
+		res.Filename = f.Filename
+		g.Go(func() error {
+			if err := uploadCtx.Err(); err != nil {
+				res.Status, res.Err = UploadStatusFailed, err
+				return nil
+			}
+			doc, err := s.uploadFile(uploadCtx, f)
+			if err != nil {
+				res.Status, res.Err = UploadStatusFailed, err
+				if atomic {
+					abort()
+				}
+				return nil
+			}
+			res.Status, res.Document = UploadStatusCreated, doc
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	if !atomic || uploadCtx.Err() == nil {
+		return results, nil
+	}
+	// Files that only failed because the batch was aborted are reported as rolled back, unless the
+	// caller's own context ended.
+	if ctx.Err() == nil {
+		for i := range results {
+			if res := &results[i]; res.Status == UploadStatusFailed && errors.Is(res.Err, context.Canceled) {
+				res.Status, res.Err = UploadStatusRolledBack, nil
+			}
+		}
+	}
+	s.rollbackUploads(context.WithoutCancel(ctx), results)
+	return results, nil
+}
+
+func (s *documentService) uploadFile(ctx context.Context, f UploadFile) (*model.Document, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFileOpen, err)
+	}
+	defer rc.Close()
+	return s.Upload(ctx, rc, f.Filename, f.ContentType, f.Size)
+}
+
+// rollbackUploads deletes the documents created by a failed atomic batch.
+func (s *documentService) rollbackUploads(ctx context.Context, results []UploadResult) {
+	var created []*UploadResult
+	var ids []string
+	for i := range results {
+		if results[i].Status == UploadStatusCreated {
+			created = append(created, &results[i])
+			ids = append(ids, results[i].Document.ID)
+		}
+	}
+	if len(created) == 0 {
+		return
+	}
+	if _, err := s.repo.DeleteMany(ctx, ids); err != nil {
+		for _, res := range created {
+			res.Status, res.Err = UploadStatusFailed, fmt.Errorf("%w: delete records: %w", ErrRollbackFailed, err)
+		}
+		return
+	}
+
+	var g errgroup.Group
+	g.SetLimit(s.batchDeleteConcurrency)
+	for _, res := range created {
+		g.Go(func() error {
+			if err := s.deleteObject(ctx, res.Document.StoragePath); err != nil {
+				res.Status, res.Err = UploadStatusFailed, fmt.Errorf("%w: delete storage: %w", ErrRollbackFailed, err)
+				return nil
+			}
+			res.Status, res.Document = UploadStatusRolledBack, nil
+			return nil
+		})
+	}
+	_ = g.Wait()
+}
+
 // List returns paginated documents without exposing repository types.
-func (s *documentService) List(ctx context.Context, limit, offset int) (*DocumentListResult, error) {
-	if limit <= 0 {
-		limit = 10
+func (s *documentService) List(ctx context.Context, params ListParams) (*DocumentListResult, error) {
+	pq := repository.PageQuery{
+		Limit:     params.Limit,
+		Offset:    params.Offset,
+		SkipTotal: !params.WithTotal,
+		Filter:    params.Filter,
+		Sort:      params.Sort.OrDefault(),
 	}
-	if offset < 0 {
-		offset = 0
+	if pq.Limit <= 0 {
+		pq.Limit = 10
+	}
+	if pq.Offset < 0 {
+		pq.Offset = 0
+	}
+	if params.Cursor != "" {
+		after, err := decodeCursor(params.Cursor, pq.Sort)
+		if err != nil {
+			return nil, err
+		}
+		pq.After, pq.Offset = after, 0
 	}
 
-	res, err := s.repo.List(ctx, repository.PageQuery{Limit: limit, Offset: offset})
+	res, err := s.repo.List(ctx, pq)
 	if err != nil {
 		return nil, err
 	}
-	return &DocumentListResult{Items: res.Items, Total: res.Total}, nil
+	out := &DocumentListResult{Items: res.Items}
+	if params.WithTotal {
+		out.Total = &res.Total
+	}
+	if res.Next != nil {
+		out.NextCursor = encodeCursor(res.Next, pq.Sort)
+	}
+	return out, nil
+}
+
+// cursorToken is the JSON form of a list cursor. Clients treat the base64 encoding as opaque.
+type cursorToken struct {
+	Sort        string    `json:"o"`
+	CreatedAt   time.Time `json:"t"`
+	Name        string    `json:"n,omitempty"`
+	Size        int64     `json:"s,omitempty"`
+	ContentType string    `json:"c,omitempty"`
+	ID          string    `json:"id"`
+}
+
+func encodeCursor(c *repository.Cursor, sort Sort) string {
+	b, _ := json.Marshal(cursorToken{
+		Sort:        sort.String(),
+		CreatedAt:   c.CreatedAt,
+		Name:        c.Name,
+		Size:        c.Size,
+		ContentType: c.ContentType,
+		ID:          c.ID,
+	})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string, sort Sort) (*repository.Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var tok cursorToken
+	if err := json.Unmarshal(b, &tok); err != nil || tok.CreatedAt.IsZero() || tok.Sort != sort.String() {
+		return nil, ErrInvalidCursor
+	}
+	if _, err := uuid.Parse(tok.ID); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &repository.Cursor{
+		CreatedAt:   tok.CreatedAt,
+		Name:        tok.Name,
+		Size:        tok.Size,
+		ContentType: tok.ContentType,
+		ID:          tok.ID,
+	}, nil
 }
 
 // Get returns a document by ID.
@@ -127,23 +881,506 @@ func (s *documentService) Get(ctx context.Context, id string) (*model.Document,
 	return doc, nil
 }
 
-// Delete removes a document from storage, then deletes its record.
-func (s *documentService) Delete(ctx context.Context, id string) error {
+// Copy copies the source's object under a new key, then saves the new record.
+func (s *documentService) Copy(ctx context.Context, id string, opts CopyOptions) (*model.Document, error) {
+	src, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := servable(src); err != nil {
+		return nil, err
+	}
+
+	name := src.Name
+	if opts.Name != "" {
+		name = opts.Name
+	}
+	metadata := maps.Clone(src.Metadata)
+	if opts.Metadata != nil {
+		metadata = maps.Clone(opts.Metadata)
+	}
+
+	genName := uuid.New().String() + filepath.Ext(name)
+	objInfo, err := s.store.Copy(ctx, src.StoragePath, documentPrefix+genName)
+	if err != nil {
+		return nil, fmt.Errorf("copy in storage: %w", err)
+	}
+	storedSize := objInfo.StoredSize
+	if storedSize == 0 {
+		storedSize = objInfo.Size
+	}
+
+	created, err := s.create(ctx, &model.Document{
+		ID:          uuid.New().String(),
+		Filename:    genName,
+		Name:        name,
+		StoragePath: objInfo.Key,
+		Size:        objInfo.Size,
+		StoredSize:  storedSize,
+		ContentType: src.ContentType,
+		CreatedAt:   time.Now().UTC(),
+		Metadata:    metadata,
+		Tags:        slices.Clone(src.Tags),
+		ContentETag: strings.Trim(objInfo.ETag, `"`),
+		// The content is the same, and so is its scan result.
+		ScanStatus:    src.ScanStatus,
+		ScanEngine:    src.ScanEngine,
+		ScanSignature: src.ScanSignature,
+		ScannedAt:     src.ScannedAt,
+		Status:        model.StatusPending,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The source was processed and found available, a released one included; the copy becomes
+	// available through the same transitions as an upload.
+	copied, err := s.processAs(ctx, created, model.StatusAvailable)
+	if err != nil {
+		if rbErr := s.discard(context.WithoutCancel(ctx), created); rbErr != nil {
+			return nil, fmt.Errorf("process: %v; rollback failed: %v", err, rbErr)
+		}
+		return nil, fmt.Errorf("process: %w", err)
+	}
+	return copied, nil
+}
+
+// Update saves the patched document guarded by the version it was read at, and starts over when
+// another edit got in between.
+func (s *documentService) Update(ctx context.Context, id string, patch DocumentPatch, pre Precondition) (*model.Document, error) {
+	if err := patch.normalize(); err != nil {
+		return nil, err
+	}
+	for range updateAttempts {
+		doc, err := s.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if pre != nil && !pre(doc) {
+			return nil, ErrPreconditionFailed
+		}
+		if !patch.apply(doc) {
+			return doc, nil
+		}
+		doc.UpdatedAt = time.Now().UTC()
+
+		updated, err := s.repo.Update(ctx, doc)
+		switch {
+		case err == nil:
+			return updated, nil
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		case !errors.Is(err, repository.ErrVersionConflict):
+			return nil, err
+		}
+	}
+	return nil, ErrConflict
+}
+
+// normalize validates the patch and trims and deduplicates its tags.
+func (p *DocumentPatch) normalize() error {
+	if p.Name != nil && strings.TrimSpace(*p.Name) == "" {
+		return fmt.Errorf("%w: name must not be empty", ErrInvalidPatch)
+	}
+	if p.ContentType != nil {
+		mt, params, err := mime.ParseMediaType(*p.ContentType)
+		if err != nil {
+			return fmt.Errorf("%w: content type %q: %v", ErrInvalidPatch, *p.ContentType, err)
+		}
+		ct := mime.FormatMediaType(mt, params)
+		p.ContentType = &ct
+	}
+	if p.Tags != nil {
+		tags := make([]string, 0, len(*p.Tags))
+		for _, t := range *p.Tags {
+			t = strings.TrimSpace(t)
+			if t == "" {
+				return fmt.Errorf("%w: tags must not be empty", ErrInvalidPatch)
+			}
+			if !slices.Contains(tags, t) {
+				tags = append(tags, t)
+			}
+		}
+		p.Tags = &tags
+	}
+	for k := range p.Metadata {
+		if k == "" {
+			return fmt.Errorf("%w: metadata keys must not be empty", ErrInvalidPatch)
+		}
+	}
+	return nil
+}
+
+// apply changes doc and reports whether anything changed.
+func (p *DocumentPatch) apply(doc *model.Document) bool {
+	changed := false
+	if p.Name != nil && *p.Name != doc.Name {
+		doc.Name, changed = *p.Name, true
+	}
+	if p.ContentType != nil && *p.ContentType != doc.ContentType {
+		doc.ContentType, changed = *p.ContentType, true
+	}
+	if p.Tags != nil && !slices.Equal(*p.Tags, doc.Tags) {
+		doc.Tags, changed = slices.Clone(*p.Tags), true
+	}
+	if p.ClearMetadata || len(p.Metadata) > 0 {
+		metadata := maps.Clone(doc.Metadata)
+		if metadata == nil || p.ClearMetadata {
+			metadata = make(map[string]string)
+		}
+		for k, v := range p.Metadata {
+			if v == nil {
+				delete(metadata, k)
+			} else {
+				metadata[k] = *v
+			}
+		}
+		if !maps.Equal(metadata, doc.Metadata) {
+			doc.Metadata, changed = metadata, true
+		}
+	}
+	return changed
+}
+
+// Delete deletes a document's record guarded by the version it was read at, then its object, and
+// starts over when an edit got in between.
+func (s *documentService) Delete(ctx context.Context, id string, pre Precondition) error {
 	if id == "" {
 		return ErrIDRequired
 	}
-	// Find the document to get its storage path
-	doc, err := s.repo.FindByID(ctx, id)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+	for range updateAttempts {
+		doc, err := s.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if pre != nil && !pre(doc) {
+			return ErrPreconditionFailed
+		}
+		// Delete the record first, guarded by the version pre was checked on, so that losing a
+		// race to an edit never removes the object.
+		err = s.repo.DeleteVersion(ctx, id, doc.Version)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
 			return ErrNotFound
+		case errors.Is(err, repository.ErrVersionConflict):
+			continue
+		case err != nil:
+			return err
 		}
-		return err
+		// The document is gone at this point. An object that cannot be deleted, not even by a
+		// job, is left as an orphan for reconciliation to remove.
+		_ = s.deleteObject(context.WithoutCancel(ctx), doc.StoragePath)
+		return nil
 	}
-	// Delete from storage first; if this fails, keep DB row to avoid orphaned storage reference loss
-	if err := s.store.Delete(ctx, doc.StoragePath); err != nil {
-		return fmt.Errorf("delete storage: %w", err)
+	return ErrConflict
+}
+
+// DeleteMany deletes a batch of documents, storage first, so that a failure never
+// leaves a record pointing at a deleted object behind unless the final row deletion fails.
+func (s *documentService) DeleteMany(ctx context.Context, ids []string) ([]DeleteResult, error) {
+	if len(ids) > s.batchDeleteMaxIDs {
+		return nil, fmt.Errorf("%w: at most %d per request", ErrTooManyIDs, s.batchDeleteMaxIDs)
+	}
+
+	results := make([]DeleteResult, 0, len(ids))
+	index := make(map[string]int, len(ids))
+	var valid []string
+	for _, id := range ids {
+		if _, dup := index[id]; dup {
+			continue
+		}
+		index[id] = len(results)
+		res := DeleteResult{ID: id, Status: DeleteStatusNotFound}
+		if _, err := uuid.Parse(id); err != nil {
+			res.Status, res.Err = DeleteStatusFailed, ErrInvalidID
+		} else {
+			valid = append(valid, id)
+		}
+		results = append(results, res)
+	}
+	if len(valid) == 0 {
+		return results, nil
+	}
+
+	docs, err := s.repo.FindByIDs(ctx, valid)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each goroutine writes only its own result; the group never cancels, so one failure does not
+	// abort the other deletions.
+	var g errgroup.Group
+	g.SetLimit(s.batchDeleteConcurrency)
+	for _, doc := range docs {
+		res := &results[index[doc.ID]]
+		g.Go(func() error {
+			if err := s.store.Delete(ctx, doc.StoragePath); err != nil {
+				res.Status, res.Err = DeleteStatusFailed, fmt.Errorf("delete storage: %w", err)
+				return nil
+			}
+			res.Status = DeleteStatusDeleted
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	var stored []string
+	for _, doc := range docs {
+		if results[index[doc.ID]].Status == DeleteStatusDeleted {
+			stored = append(stored, doc.ID)
+		}
 	}
-	// Delete DB row (repository ignores missing row errors as per contract)
-	return s.repo.Delete(ctx, id)
+	if len(stored) == 0 {
+		return results, nil
+	}
+	if _, err := s.repo.DeleteMany(ctx, stored); err != nil {
+		for _, id := range stored {
+			res := &results[index[id]]
+			res.Status, res.Err = DeleteStatusFailed, fmt.Errorf("delete records: %w", err)
+		}
+	}
+	return results, nil
+}
+
+// ImportArchive streams each file from the archive into storage; nothing is extracted to disk.
+func (s *documentService) ImportArchive(ctx context.Context, r io.ReaderAt, size int64, archiveName string) ([]ImportResult, error) {
+	results := []ImportResult{}
+	err := archive.Walk(r, size, s.importLimits, func(m archive.Member, content io.Reader) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		res := ImportResult{Path: m.Path}
+		if m.Err != nil {
+			res.Status, res.Err = ImportStatusRejected, m.Err
+			results = append(results, res)
+			return nil
+		}
+
+		er := &errReader{r: content}
+		metadata := map[string]string{MetadataArchive: archiveName, MetadataArchivePath: m.Path}
+		doc, err := s.upload(ctx, er, path.Base(m.Path), importContentType(m.Path), m.Size, metadata)
+		switch {
+		case err == nil:
+			res.Status, res.Document = ImportStatusCreated, doc
+		case er.err != nil:
+			// Report why the content could not be read rather than how the storage upload failed.
+			res.Status, res.Err = ImportStatusFailed, er.err
+			if isImportLimit(er.err) {
+				res.Status = ImportStatusRejected
+			}
+		default:
+			res.Status, res.Err = ImportStatusFailed, err
+		}
+		results = append(results, res)
+		return nil
+	})
+	return results, err
+}
+
+// importContentType guesses the content type of an imported file from its extension.
+func importContentType(name string) string {
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+func isImportLimit(err error) bool {
+	return errors.Is(err, archive.ErrEntryTooLarge) || errors.Is(err, archive.ErrArchiveTooLarge) || errors.Is(err, archive.ErrRatioExceeded)
+}
+
+// errReader records the first error other than io.EOF returned by r.
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (e *errReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err != nil && err != io.EOF && e.err == nil {
+		e.err = err
+	}
+	return n, err
+}
+
+// archivePageSize is the page size used to collect the documents matching an archive filter.
+const archivePageSize = 500
+
+// PrepareArchive looks up the selected documents. Filter selections are read page by page in
+// keyset order and stop as soon as the limit is exceeded.
+func (s *documentService) PrepareArchive(ctx context.Context, sel ArchiveSelection) (*Archive, error) {
+	limit := s.archiveMaxDocuments
+	tooMany := fmt.Errorf("%w: at most %d per archive", ErrTooManyDocuments, limit)
+	a := &Archive{}
+
+	if len(sel.IDs) == 0 {
+		pq := repository.PageQuery{Limit: min(archivePageSize, limit+1), SkipTotal: true, Filter: sel.Filter, Sort: sel.Sort}
+		for {
+			page, err := s.repo.List(ctx, pq)
+			if err != nil {
+				return nil, err
+			}
+			a.Documents = append(a.Documents, page.Items...)
+			if len(a.Documents) > limit {
+				return nil, tooMany
+			}
+			if page.Next == nil {
+				return a, nil
+			}
+			pq.After = page.Next
+		}
+	}
+
+	var ids []string
+	seen := make(map[string]bool, len(sel.IDs))
+	for _, id := range sel.IDs {
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidID, id)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > limit {
+		return nil, tooMany
+	}
+	docs, err := s.repo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]model.Document, len(docs))
+	for _, d := range docs {
+		byID[d.ID] = d
+	}
+	for _, id := range ids {
+		if d, ok := byID[id]; ok {
+			a.Documents = append(a.Documents, d)
+		} else {
+			a.Missing = append(a.Missing, id)
+		}
+	}
+	return a, nil
+}
+
+// WriteArchive reads the documents one at a time, so memory use does not depend on their size.
+func (s *documentService) WriteArchive(ctx context.Context, a *Archive, w io.Writer) error {
+	aw := archive.NewWriter(w)
+	for _, id := range a.Missing {
+		aw.Skip(id, archive.SkipNotFound)
+	}
+	for _, doc := range a.Documents {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if doc.Quarantined() {
+			aw.Skip(doc.ID, archive.SkipQuarantined)
+			continue
+		}
+		if !doc.Available() {
+			aw.Skip(doc.ID, archive.SkipNotAvailable)
+			continue
+		}
+		rc, _, err := s.store.Get(ctx, doc.StoragePath)
+		if err != nil {
+			aw.Skip(doc.ID, archive.SkipUnreadable)
+			continue
+		}
+		err = aw.Add(archive.Entry{ID: doc.ID, Name: doc.Name, ContentType: doc.ContentType, Modified: doc.CreatedAt}, rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("archive document %s: %w", doc.ID, err)
+		}
+	}
+	return aw.Close()
+}
+
+// Download returns the requested byte range of a document's content.
+func (s *documentService) Download(ctx context.Context, id string, offset, length int64) (io.ReadCloser, *model.Document, error) {
+	doc, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := servable(doc); err != nil {
+		return nil, nil, err
+	}
+	if offset < 0 {
+		offset = max(doc.Size+offset, 0)
+	}
+	if offset > doc.Size || (offset == doc.Size && doc.Size > 0) {
+		return nil, nil, ErrInvalidRange
+	}
+	rc, _, err := s.store.GetRange(ctx, doc.StoragePath, offset, length)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read storage: %w", err)
+	}
+	return rc, doc, nil
+}
+
+// PresignURL returns a presigned download URL for a document.
+func (s *documentService) PresignURL(ctx context.Context, id string, expiry time.Duration) (string, error) {
+	doc, err := s.Get(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if err := servable(doc); err != nil {
+		return "", err
+	}
+	url, err := s.store.PresignGet(ctx, doc.StoragePath, expiry)
+	if err != nil {
+		if errors.Is(err, storage.ErrPresignUnsupported) {
+			return "", ErrPresignUnsupported
+		}
+		return "", fmt.Errorf("presign: %w", err)
+	}
+	return url, nil
+}
+
+// Release copies the content back under documentPrefix before the document becomes available, so
+// that it is never served from quarantine, and deletes the quarantined object afterwards.
+func (s *documentService) Release(ctx context.Context, id string) (*model.Document, error) {
+	doc, err := s.reviewed(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	released := *doc
+	if key := documentPrefix + doc.Filename; doc.StoragePath != key {
+		objInfo, err := s.store.Copy(ctx, doc.StoragePath, key)
+		if err != nil {
+			return nil, fmt.Errorf("copy in storage: %w", err)
+		}
+		released.StoragePath, released.ContentETag = objInfo.Key, strings.Trim(objInfo.ETag, `"`)
+	}
+	// If the transition fails, a concurrent review may already use the copy; one left unused is
+	// removed by reconciliation.
+	out, err := s.transition(ctx, &released, model.StatusAvailable)
+	if err != nil {
+		return nil, err
+	}
+	if released.StoragePath != doc.StoragePath {
+		// The document is released at this point. A quarantined object that cannot be deleted,
+		// not even by a job, is left as an orphan for reconciliation to remove.
+		_ = s.deleteObject(context.WithoutCancel(ctx), doc.StoragePath)
+	}
+	return out, nil
+}
+
+// Reject leaves the content where it is; only the status changes.
+func (s *documentService) Reject(ctx context.Context, id string) (*model.Document, error) {
+	doc, err := s.reviewed(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.transition(ctx, doc, model.StatusFailed)
+}
+
+// reviewed returns the quarantined document id, or ErrInvalidTransition if it is in another status.
+func (s *documentService) reviewed(ctx context.Context, id string) (*model.Document, error) {
+	doc, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !doc.Quarantined() {
+		return nil, fmt.Errorf("%w: document is %s, not quarantined", ErrInvalidTransition, doc.Status)
+	}
+	return doc, nil
 }
@@ -1,21 +1,35 @@
 package service
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"docapi/internal/archive"
+	"docapi/internal/jobs"
 	"docapi/internal/model"
 	"docapi/internal/repository"
+	"docapi/internal/repository/memory"
 	repoMocks "docapi/internal/repository/mocks"
+	"docapi/internal/scanner"
 	"docapi/internal/storage"
 	storeMocks "docapi/internal/storage/mocks"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDocumentService_Upload(t *testing.T) {
@@ -50,13 +64,37 @@ func TestDocumentService_Upload(t *testing.T) {
 				}, nil)
 
 				mRepo.On("Create", ctx, mock.MatchedBy(func(doc *model.Document) bool {
-					return doc.Filename != "" && doc.StoragePath == "documents/uuid.txt"
-				})).Return(&model.Document{ID: "gen-id"}, nil)
+					return doc.Filename != "" && doc.Name == "test.txt" && doc.StoragePath == "documents/uuid.txt" && doc.StoredSize == 11 &&
+						doc.Status == model.StatusPending
+				})).Return(&model.Document{ID: "gen-id", Status: model.StatusPending}, nil)
+				expectProcessed(mRepo, "gen-id")
 
 				return r
 			},
 			wantErr: nil,
 		},
+		{
+			name:             "records stored size",
+			originalFilename: "test.txt",
+			contentType:      "text/plain",
+			size:             11,
+			setupMocks: func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository) io.Reader {
+				r := strings.NewReader("hello world")
+				mStore.On("Put", ctx, mock.Anything, r, mock.Anything).Return(storage.ObjectInfo{
+					Key:         "documents/uuid.txt",
+					Size:        11,
+					StoredSize:  7,
+					ContentType: "text/plain",
+				}, nil)
+
+				mRepo.On("Create", ctx, mock.MatchedBy(func(doc *model.Document) bool {
+					return doc.Size == 11 && doc.StoredSize == 7
+				})).Return(&model.Document{ID: "gen-id", Status: model.StatusPending}, nil)
+				expectProcessed(mRepo, "gen-id")
+
+				return r
+			},
+		},
 		{
 			name:             "validation error - nil reader",
 			originalFilename: "test.txt",
@@ -89,7 +127,7 @@ func TestDocumentService_Upload(t *testing.T) {
 					}, nil)
 				mRepo.On("Create", ctx, mock.Anything).
 					Return(nil, errors.New("db fail"))
-				mStore.On("Delete", ctx, mock.Anything).Return(nil)
+				mStore.On("Delete", mock.Anything, mock.Anything).Return(nil)
 				return r
 			},
 			wantErrMsg: "db save failed: db fail",
@@ -106,11 +144,28 @@ func TestDocumentService_Upload(t *testing.T) {
 					}, nil)
 				mRepo.On("Create", ctx, mock.Anything).
 					Return(nil, errors.New("db fail"))
-				mStore.On("Delete", ctx, mock.Anything).Return(errors.New("delete fail"))
+				mStore.On("Delete", mock.Anything, mock.Anything).Return(errors.New("delete fail"))
 				return r
 			},
 			wantErrMsg: "rollback delete failed: delete fail",
 		},
+		{
+			name:             "processing error removes the document",
+			originalFilename: "test.txt",
+			size:             5,
+			setupMocks: func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository) io.Reader {
+				r := strings.NewReader("hello")
+				mStore.On("Put", ctx, mock.Anything, r, mock.Anything).
+					Return(storage.ObjectInfo{Key: "documents/uuid.txt"}, nil)
+				mRepo.On("Create", ctx, mock.Anything).
+					Return(&model.Document{ID: "gen-id", StoragePath: "documents/uuid.txt", Status: model.StatusPending}, nil)
+				mRepo.On("UpdateStatus", ctx, mock.Anything, model.StatusPending).Return(nil, errors.New("db fail"))
+				mRepo.On("Delete", mock.Anything, "gen-id").Return(nil)
+				mStore.On("Delete", mock.Anything, "documents/uuid.txt").Return(nil)
+				return r
+			},
+			wantErrMsg: "process: db fail",
+		},
 	}
 
 	for _, tt := range tests {
@@ -139,23 +194,368 @@ func TestDocumentService_Upload(t *testing.T) {
 	}
 }
 
+func TestDocumentService_DeferredDelete(t *testing.T) {
+	ctx := context.Background()
+	mStore := new(storeMocks.MockStorage)
+	mRepo := new(repoMocks.MockDocumentRepository)
+	jobRepo := memory.NewJobMemory()
+	svc := NewDocumentService(mStore, mRepo, WithJobQueue(jobs.NewQueue(jobRepo, 3)))
+
+	r := strings.NewReader("hello")
+	mStore.On("Put", ctx, mock.Anything, r, mock.Anything).Return(storage.ObjectInfo{Key: "documents/uuid.txt"}, nil)
+	mRepo.On("Create", ctx, mock.Anything).Return(nil, errors.New("db fail"))
+	mStore.On("Delete", mock.Anything, mock.Anything).Return(errors.New("delete fail")).Once()
+
+	_, err := svc.Upload(ctx, r, "test.txt", "text/plain", 5)
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "rollback", "the failed deletion is left to a job")
+
+	claimed, err := jobRepo.Claim(ctx, []string{JobDeleteObject}, time.Now(), time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	var payload DeleteObjectPayload
+	require.NoError(t, json.Unmarshal(claimed[0].Payload, &payload))
+	key := payload.Key
+	assert.True(t, strings.HasPrefix(key, "documents/"), key)
+
+	mStore.On("Delete", mock.Anything, key).Return(nil).Once()
+	require.NoError(t, DeleteObjectHandler(mStore)(ctx, payload))
+	mStore.AssertExpectations(t)
+}
+
+// expectProcessed expects the new document id to move through processing to available.
+func expectProcessed(mRepo *repoMocks.MockDocumentRepository, id string) {
+	mRepo.On("UpdateStatus", mock.Anything, mock.MatchedBy(func(doc *model.Document) bool {
+		return doc.Status == model.StatusProcessing
+	}), model.StatusPending).Return(&model.Document{ID: id, Status: model.StatusProcessing}, nil).Once()
+	mRepo.On("UpdateStatus", mock.Anything, mock.MatchedBy(func(doc *model.Document) bool {
+		return doc.Status == model.StatusAvailable
+	}), model.StatusProcessing).Return(&model.Document{ID: id, Status: model.StatusAvailable}, nil).Once()
+}
+
+// fakeScanner reads the whole content and reports content containing "EICAR" as infected. With
+// err set it fails without reading anything, as clamd does when it is unreachable.
+type fakeScanner struct{ err error }
+
+func (f fakeScanner) Scan(_ context.Context, r io.Reader) (scanner.Result, error) {
+	if f.err != nil {
+		return scanner.Result{}, f.err
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return scanner.Result{}, err
+	}
+	if bytes.Contains(content, []byte("EICAR")) {
+		return scanner.Result{Infected: true, Signature: "Eicar-Test-Signature", Engine: "ClamAV 1.2.1"}, nil
+	}
+	return scanner.Result{Engine: "ClamAV 1.2.1"}, nil
+}
+
+func TestDocumentService_UploadScanned(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("clean content is available", func(t *testing.T) {
+		store, repo := storage.NewMemory(), memory.NewDocumentMemory()
+		svc := NewDocumentService(store, repo, WithScanner(fakeScanner{}))
+
+		doc, err := svc.Upload(ctx, strings.NewReader("hello world"), "a.txt", "text/plain", 11)
+		require.NoError(t, err)
+		assert.Equal(t, model.StatusAvailable, doc.Status)
+		assert.Equal(t, model.ScanClean, doc.ScanStatus)
+		assert.Equal(t, "ClamAV 1.2.1", doc.ScanEngine)
+		assert.NotNil(t, doc.ScannedAt)
+		assert.True(t, strings.HasPrefix(doc.StoragePath, documentPrefix))
+
+		rc, _, err := svc.Download(ctx, doc.ID, 0, -1)
+		require.NoError(t, err)
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		assert.Equal(t, "hello world", string(content))
+	})
+
+	t.Run("infected content is quarantined", func(t *testing.T) {
+		store, repo := storage.NewMemory(), memory.NewDocumentMemory()
+		svc := NewDocumentService(store, repo, WithScanner(fakeScanner{}))
+
+		doc, err := svc.Upload(ctx, strings.NewReader("X5O EICAR test"), "a.txt", "text/plain", 14)
+		require.NoError(t, err)
+		assert.Equal(t, model.StatusQuarantined, doc.Status)
+		assert.Equal(t, model.ScanInfected, doc.ScanStatus)
+		assert.Equal(t, "Eicar-Test-Signature", doc.ScanSignature)
+		assert.True(t, strings.HasPrefix(doc.StoragePath, quarantinePrefix), doc.StoragePath)
+
+		// Only the quarantined object is left.
+		exists, err := store.Exists(ctx, documentPrefix+doc.Filename)
+		require.NoError(t, err)
+		assert.False(t, exists)
+		exists, err = store.Exists(ctx, doc.StoragePath)
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		_, _, err = svc.Download(ctx, doc.ID, 0, -1)
+		assert.ErrorIs(t, err, ErrQuarantined)
+		_, err = svc.PresignURL(ctx, doc.ID, time.Minute)
+		assert.ErrorIs(t, err, ErrQuarantined)
+		_, err = svc.Copy(ctx, doc.ID, CopyOptions{})
+		assert.ErrorIs(t, err, ErrQuarantined)
+
+		var buf bytes.Buffer
+		require.NoError(t, svc.WriteArchive(ctx, &Archive{Documents: []model.Document{*doc}}, &buf))
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		require.Len(t, zr.File, 1)
+		rc, err := zr.File[0].Open()
+		require.NoError(t, err)
+		var manifest archive.Manifest
+		require.NoError(t, json.NewDecoder(rc).Decode(&manifest))
+		rc.Close()
+		assert.Equal(t, []archive.SkippedEntry{{ID: doc.ID, Reason: archive.SkipQuarantined}}, manifest.Skipped)
+	})
+
+	t.Run("scan failure stores nothing", func(t *testing.T) {
+		store, repo := storage.NewMemory(), memory.NewDocumentMemory()
+		svc := NewDocumentService(store, repo, WithScanner(fakeScanner{fmt.Errorf("%w: clamd down", scanner.ErrScanFailed)}))
+
+		_, err := svc.Upload(ctx, strings.NewReader("hello world"), "a.txt", "text/plain", 11)
+		assert.ErrorIs(t, err, ErrScanFailed)
+
+		page, err := store.List(ctx, storage.ListOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, page.Objects)
+		res, err := repo.List(ctx, repository.PageQuery{Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, res.Items)
+	})
+
+	t.Run("quarantine failure removes the partial copy", func(t *testing.T) {
+		store := &partialCopyStorage{Storage: storage.NewMemory()}
+		repo, jobRepo := memory.NewDocumentMemory(), memory.NewJobMemory()
+		svc := NewDocumentService(store, repo, WithScanner(fakeScanner{}), WithJobQueue(jobs.NewQueue(jobRepo, 3)))
+
+		_, err := svc.Upload(ctx, strings.NewReader("X5O EICAR test"), "a.txt", "text/plain", 14)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "quarantine: copy interrupted")
+
+		page, err := store.List(ctx, storage.ListOptions{})
+		require.NoError(t, err)
+		require.Len(t, page.Objects, 1, "only the partial copy is left, for the delete job")
+		assert.True(t, strings.HasPrefix(page.Objects[0].Key, quarantinePrefix), page.Objects[0].Key)
+
+		claimed, err := jobRepo.Claim(ctx, []string{JobDeleteObject}, time.Now(), time.Minute, 10)
+		require.NoError(t, err)
+		require.Len(t, claimed, 1)
+		var payload DeleteObjectPayload
+		require.NoError(t, json.Unmarshal(claimed[0].Payload, &payload))
+		assert.Equal(t, page.Objects[0].Key, payload.Key)
+		require.NoError(t, DeleteObjectHandler(store.Storage)(ctx, payload))
+		exists, err := store.Exists(ctx, payload.Key)
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("copies keep the scan result", func(t *testing.T) {
+		svc := NewDocumentService(storage.NewMemory(), memory.NewDocumentMemory(), WithScanner(fakeScanner{}))
+		doc, err := svc.Upload(ctx, strings.NewReader("hello world"), "a.txt", "text/plain", 11)
+		require.NoError(t, err)
+
+		cp, err := svc.Copy(ctx, doc.ID, CopyOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, model.ScanClean, cp.ScanStatus)
+		assert.Equal(t, doc.ScanEngine, cp.ScanEngine)
+	})
+}
+
+func TestDocumentService_Transitions(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{model.StatusPending, model.StatusProcessing, true},
+		{model.StatusPending, model.StatusFailed, true},
+		{model.StatusPending, model.StatusAvailable, false},
+		{model.StatusProcessing, model.StatusAvailable, true},
+		{model.StatusProcessing, model.StatusQuarantined, true},
+		{model.StatusProcessing, model.StatusFailed, true},
+		{model.StatusProcessing, model.StatusPending, false},
+		{model.StatusQuarantined, model.StatusAvailable, true},
+		{model.StatusQuarantined, model.StatusFailed, true},
+		{model.StatusAvailable, model.StatusQuarantined, false},
+		{model.StatusAvailable, model.StatusPending, false},
+		{model.StatusFailed, model.StatusAvailable, false},
+		{model.StatusFailed, model.StatusPending, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			repo := memory.NewDocumentMemory()
+			svc := NewDocumentService(storage.NewMemory(), repo).(*documentService)
+			doc, err := repo.Create(ctx, &model.Document{ID: uuid.NewString(), StoragePath: "documents/a.txt", Status: tt.from})
+			require.NoError(t, err)
+
+			out, err := svc.transition(ctx, doc, tt.to)
+			if !tt.allowed {
+				assert.ErrorIs(t, err, ErrInvalidTransition)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.to, out.Status)
+			assert.Equal(t, doc.Version+1, out.Version)
+		})
+	}
+
+	t.Run("concurrent transition conflicts", func(t *testing.T) {
+		repo := memory.NewDocumentMemory()
+		svc := NewDocumentService(storage.NewMemory(), repo).(*documentService)
+		doc, err := repo.Create(ctx, &model.Document{ID: uuid.NewString(), StoragePath: "documents/a.txt"})
+		require.NoError(t, err)
+
+		_, err = svc.transition(ctx, doc, model.StatusProcessing)
+		require.NoError(t, err)
+		_, err = svc.transition(ctx, doc, model.StatusFailed)
+		assert.ErrorIs(t, err, ErrConflict)
+	})
+}
+
+func TestDocumentService_NotAvailable(t *testing.T) {
+	ctx := context.Background()
+	store, repo := storage.NewMemory(), memory.NewDocumentMemory()
+	svc := NewDocumentService(store, repo)
+	_, err := store.Put(ctx, "documents/a.txt", strings.NewReader("hello"), storage.PutObjectOptions{Size: 5})
+	require.NoError(t, err)
+
+	for _, status := range []string{model.StatusPending, model.StatusProcessing, model.StatusFailed} {
+		t.Run(status, func(t *testing.T) {
+			doc, err := repo.Create(ctx, &model.Document{ID: uuid.NewString(), Name: "a.txt", StoragePath: "documents/a.txt", Size: 5, Status: status})
+			require.NoError(t, err)
+
+			_, _, err = svc.Download(ctx, doc.ID, 0, -1)
+			assert.ErrorIs(t, err, ErrNotAvailable)
+			assert.ErrorContains(t, err, status)
+			_, err = svc.PresignURL(ctx, doc.ID, time.Minute)
+			assert.ErrorIs(t, err, ErrNotAvailable)
+			_, err = svc.Copy(ctx, doc.ID, CopyOptions{})
+			assert.ErrorIs(t, err, ErrNotAvailable)
+
+			var buf bytes.Buffer
+			require.NoError(t, svc.WriteArchive(ctx, &Archive{Documents: []model.Document{*doc}}, &buf))
+			zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			require.NoError(t, err)
+			require.Len(t, zr.File, 1)
+			rc, err := zr.File[0].Open()
+			require.NoError(t, err)
+			var manifest archive.Manifest
+			require.NoError(t, json.NewDecoder(rc).Decode(&manifest))
+			rc.Close()
+			assert.Equal(t, []archive.SkippedEntry{{ID: doc.ID, Reason: archive.SkipNotAvailable}}, manifest.Skipped)
+		})
+	}
+}
+
+func TestDocumentService_Review(t *testing.T) {
+	ctx := context.Background()
+	upload := func(t *testing.T, svc DocumentService, content string) *model.Document {
+		t.Helper()
+		doc, err := svc.Upload(ctx, strings.NewReader(content), "a.txt", "text/plain", int64(len(content)))
+		require.NoError(t, err)
+		return doc
+	}
+
+	t.Run("release moves the content out of quarantine", func(t *testing.T) {
+		store := storage.NewMemory()
+		svc := NewDocumentService(store, memory.NewDocumentMemory(), WithScanner(fakeScanner{}))
+		doc := upload(t, svc, "X5O EICAR test")
+		require.Equal(t, model.StatusQuarantined, doc.Status)
+
+		released, err := svc.Release(ctx, doc.ID)
+		require.NoError(t, err)
+		assert.Equal(t, model.StatusAvailable, released.Status)
+		assert.Equal(t, documentPrefix+doc.Filename, released.StoragePath)
+		// The verdict is kept for the record.
+		assert.Equal(t, model.ScanInfected, released.ScanStatus)
+
+		exists, err := store.Exists(ctx, doc.StoragePath)
+		require.NoError(t, err)
+		assert.False(t, exists, "quarantined object is deleted")
+		rc, _, err := svc.Download(ctx, doc.ID, 0, -1)
+		require.NoError(t, err)
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		assert.Equal(t, "X5O EICAR test", string(content))
+	})
+
+	t.Run("release succeeds when the quarantined object cannot be deleted", func(t *testing.T) {
+		store := &countingStorage{Storage: storage.NewMemory()}
+		repo := memory.NewDocumentMemory()
+		svc := NewDocumentService(store, repo, WithScanner(fakeScanner{}))
+		doc := upload(t, svc, "X5O EICAR test")
+		store.deleteErr = errors.New("delete failed")
+
+		released, err := svc.Release(ctx, doc.ID)
+		require.NoError(t, err)
+		assert.Equal(t, model.StatusAvailable, released.Status)
+
+		// The quarantined object is left for reconciliation.
+		report, err := NewReconciler(store.Storage, repo, 0, nil).Run(ctx, true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{doc.StoragePath}, report.OrphanObjects)
+	})
+
+	t.Run("reject fails the document", func(t *testing.T) {
+		store := storage.NewMemory()
+		svc := NewDocumentService(store, memory.NewDocumentMemory(), WithScanner(fakeScanner{}))
+		doc := upload(t, svc, "X5O EICAR test")
+
+		rejected, err := svc.Reject(ctx, doc.ID)
+		require.NoError(t, err)
+		assert.Equal(t, model.StatusFailed, rejected.Status)
+		assert.Equal(t, doc.StoragePath, rejected.StoragePath)
+
+		_, _, err = svc.Download(ctx, doc.ID, 0, -1)
+		assert.ErrorIs(t, err, ErrNotAvailable)
+		_, err = svc.Release(ctx, doc.ID)
+		assert.ErrorIs(t, err, ErrInvalidTransition)
+
+		require.NoError(t, svc.Delete(ctx, doc.ID, nil))
+		exists, err := store.Exists(ctx, doc.StoragePath)
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("only quarantined documents are reviewed", func(t *testing.T) {
+		svc := NewDocumentService(storage.NewMemory(), memory.NewDocumentMemory(), WithScanner(fakeScanner{}))
+		doc := upload(t, svc, "hello")
+
+		_, err := svc.Release(ctx, doc.ID)
+		assert.ErrorIs(t, err, ErrInvalidTransition)
+		_, err = svc.Reject(ctx, doc.ID)
+		assert.ErrorIs(t, err, ErrInvalidTransition)
+		_, err = svc.Release(ctx, uuid.NewString())
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
 func TestDocumentService_List(t *testing.T) {
 	ctx := context.Background()
+	cursorAt := time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.UTC)
+	cursorID := "6f1c7a5e-2b1d-4c8e-9f3a-1d2e3f4a5b6c"
+	cursor := encodeCursor(&repository.Cursor{CreatedAt: cursorAt, ID: cursorID}, repository.DefaultSort)
+	bySize := repository.Sort{Field: repository.SortSize, Asc: true}
+	sizeCursor := encodeCursor(&repository.Cursor{CreatedAt: cursorAt, Size: 42, ID: cursorID}, bySize)
+	minSize := int64(1)
 
 	tests := []struct {
 		name       string
-		limit      int
-		offset     int
+		params     ListParams
 		setupMocks func(mRepo *repoMocks.MockDocumentRepository)
 		wantErr    error
 		checkRes   func(t *testing.T, res *DocumentListResult)
 	}{
 		{
 			name:   "happy path",
-			limit:  10,
-			offset: 0,
+			params: ListParams{Limit: 10, WithTotal: true},
 			setupMocks: func(mRepo *repoMocks.MockDocumentRepository) {
-				mRepo.On("List", ctx, repository.PageQuery{Limit: 10, Offset: 0}).
+				mRepo.On("List", ctx, repository.PageQuery{Limit: 10, Offset: 0, Sort: repository.DefaultSort}).
 					Return(&repository.PageResult[model.Document]{
 						Items: []model.Document{{ID: "1"}, {ID: "2"}},
 						Total: 2,
@@ -163,21 +563,83 @@ func TestDocumentService_List(t *testing.T) {
 			},
 			checkRes: func(t *testing.T, res *DocumentListResult) {
 				assert.Equal(t, 2, len(res.Items))
-				assert.Equal(t, 2, res.Total)
+				require.NotNil(t, res.Total)
+				assert.Equal(t, 2, *res.Total)
+				assert.Empty(t, res.NextCursor)
 			},
 		},
 		{
 			name:   "pagination boundary - zero limit uses default",
-			limit:  0,
-			offset: -1,
+			params: ListParams{Limit: 0, Offset: -1},
 			setupMocks: func(mRepo *repoMocks.MockDocumentRepository) {
-				mRepo.On("List", ctx, repository.PageQuery{Limit: 10, Offset: 0}).
-					Return(&repository.PageResult[model.Document]{Items: []model.Document{}, Total: 0}, nil)
+				mRepo.On("List", ctx, repository.PageQuery{Limit: 10, Offset: 0, SkipTotal: true, Sort: repository.DefaultSort}).
+					Return(&repository.PageResult[model.Document]{Items: []model.Document{}, Total: -1}, nil)
+			},
+			checkRes: func(t *testing.T, res *DocumentListResult) {
+				assert.Nil(t, res.Total)
 			},
 		},
 		{
-			name:  "repository error",
-			limit: 10,
+			name:   "next cursor round trips",
+			params: ListParams{Limit: 1},
+			setupMocks: func(mRepo *repoMocks.MockDocumentRepository) {
+				mRepo.On("List", ctx, repository.PageQuery{Limit: 1, SkipTotal: true, Sort: repository.DefaultSort}).
+					Return(&repository.PageResult[model.Document]{
+						Items: []model.Document{{ID: cursorID, CreatedAt: cursorAt}},
+						Total: -1,
+						Next:  &repository.Cursor{CreatedAt: cursorAt, ID: cursorID},
+					}, nil)
+			},
+			checkRes: func(t *testing.T, res *DocumentListResult) {
+				assert.Equal(t, cursor, res.NextCursor)
+			},
+		},
+		{
+			name:   "cursor selects keyset pagination",
+			params: ListParams{Limit: 5, Offset: 20, Cursor: cursor},
+			setupMocks: func(mRepo *repoMocks.MockDocumentRepository) {
+				mRepo.On("List", ctx, repository.PageQuery{
+					Limit:     5,
+					After:     &repository.Cursor{CreatedAt: cursorAt, ID: cursorID},
+					SkipTotal: true,
+					Sort:      repository.DefaultSort,
+				}).Return(&repository.PageResult[model.Document]{Items: []model.Document{}, Total: -1}, nil)
+			},
+		},
+		{
+			name:   "filter and sort are passed through",
+			params: ListParams{Limit: 5, Cursor: sizeCursor, Sort: bySize, Filter: DocumentFilter{ContentType: "image/*", MinSize: &minSize}},
+			setupMocks: func(mRepo *repoMocks.MockDocumentRepository) {
+				mRepo.On("List", ctx, repository.PageQuery{
+					Limit:     5,
+					After:     &repository.Cursor{CreatedAt: cursorAt, Size: 42, ID: cursorID},
+					SkipTotal: true,
+					Filter:    repository.DocumentFilter{ContentType: "image/*", MinSize: &minSize},
+					Sort:      bySize,
+				}).Return(&repository.PageResult[model.Document]{Items: []model.Document{}, Total: -1}, nil)
+			},
+		},
+		{
+			name:       "cursor issued for another sort",
+			params:     ListParams{Cursor: cursor, Sort: bySize},
+			setupMocks: func(mRepo *repoMocks.MockDocumentRepository) {},
+			wantErr:    ErrInvalidCursor,
+		},
+		{
+			name:       "malformed cursor",
+			params:     ListParams{Cursor: "not base64!"},
+			setupMocks: func(mRepo *repoMocks.MockDocumentRepository) {},
+			wantErr:    ErrInvalidCursor,
+		},
+		{
+			name:       "cursor with invalid id",
+			params:     ListParams{Cursor: encodeCursor(&repository.Cursor{CreatedAt: cursorAt, ID: "x' OR 1=1"}, repository.DefaultSort)},
+			setupMocks: func(mRepo *repoMocks.MockDocumentRepository) {},
+			wantErr:    ErrInvalidCursor,
+		},
+		{
+			name:   "repository error",
+			params: ListParams{Limit: 10},
 			setupMocks: func(mRepo *repoMocks.MockDocumentRepository) {
 				mRepo.On("List", ctx, mock.Anything).Return(nil, errors.New("db fail"))
 			},
@@ -192,10 +654,13 @@ func TestDocumentService_List(t *testing.T) {
 
 			tt.setupMocks(mRepo)
 
-			res, err := svc.List(ctx, tt.limit, tt.offset)
+			res, err := svc.List(ctx, tt.params)
 
 			if tt.wantErr != nil {
 				assert.Error(t, err)
+				if errors.Is(tt.wantErr, ErrInvalidCursor) {
+					assert.ErrorIs(t, err, ErrInvalidCursor)
+				}
 			} else {
 				assert.NoError(t, err)
 				if tt.checkRes != nil {
@@ -207,6 +672,33 @@ func TestDocumentService_List(t *testing.T) {
 	}
 }
 
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Sort
+		wantErr bool
+	}{
+		{in: "created_at", want: Sort{Field: repository.SortCreatedAt, Asc: true}},
+		{in: "-size", want: Sort{Field: repository.SortSize}},
+		{in: "name", want: Sort{Field: repository.SortName, Asc: true}},
+		{in: "-content_type", want: Sort{Field: repository.SortContentType}},
+		{in: "storage_path", wantErr: true},
+		{in: "--size", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSort(tt.in)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidSort)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func TestDocumentService_Get(t *testing.T) {
 	ctx := context.Background()
 
@@ -273,12 +765,245 @@ func TestDocumentService_Get(t *testing.T) {
 	}
 }
 
+func TestDocumentService_Copy(t *testing.T) {
+	ctx := context.Background()
+
+	seed := func(t *testing.T, store storage.Storage, repo repository.DocumentRepository) *model.Document {
+		t.Helper()
+		svc := NewDocumentService(store, repo)
+		doc, err := svc.Upload(ctx, strings.NewReader("template"), "template.docx", "application/msword", 8)
+		require.NoError(t, err)
+		return doc
+	}
+
+	t.Run("keeps name and metadata by default", func(t *testing.T) {
+		store, repo := storage.NewMemory(), memory.NewDocumentMemory()
+		src := seed(t, store, repo)
+		svc := NewDocumentService(store, repo)
+
+		doc, err := svc.Copy(ctx, src.ID, CopyOptions{})
+		require.NoError(t, err)
+		assert.NotEqual(t, src.ID, doc.ID)
+		assert.NotEqual(t, src.StoragePath, doc.StoragePath)
+		assert.Equal(t, src.Name, doc.Name)
+		assert.Equal(t, src.Size, doc.Size)
+		assert.Equal(t, src.ContentType, doc.ContentType)
+		assert.True(t, strings.HasSuffix(doc.Filename, ".docx"))
+
+		// The copy outlives its source.
+		require.NoError(t, svc.Delete(ctx, src.ID, nil))
+		rc, _, err := svc.Download(ctx, doc.ID, 0, -1)
+		require.NoError(t, err)
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		assert.Equal(t, "template", string(content))
+	})
+
+	t.Run("new name and metadata", func(t *testing.T) {
+		store, repo := storage.NewMemory(), memory.NewDocumentMemory()
+		src := seed(t, store, repo)
+		svc := NewDocumentService(store, repo)
+
+		doc, err := svc.Copy(ctx, src.ID, CopyOptions{Name: "v2.txt", Metadata: map[string]string{"version": "2"}})
+		require.NoError(t, err)
+		assert.Equal(t, "v2.txt", doc.Name)
+		assert.True(t, strings.HasSuffix(doc.StoragePath, ".txt"))
+		assert.Equal(t, map[string]string{"version": "2"}, doc.Metadata)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		svc := NewDocumentService(storage.NewMemory(), memory.NewDocumentMemory())
+		_, err := svc.Copy(ctx, uuid.NewString(), CopyOptions{})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("copies of released documents become available through processing", func(t *testing.T) {
+		repo := memory.NewDocumentMemory()
+		svc := NewDocumentService(storage.NewMemory(), repo, WithScanner(fakeScanner{}))
+		src, err := svc.Upload(ctx, strings.NewReader("X5O EICAR test"), "a.txt", "text/plain", 14)
+		require.NoError(t, err)
+		_, err = svc.Copy(ctx, src.ID, CopyOptions{})
+		assert.ErrorIs(t, err, ErrQuarantined)
+		_, err = svc.Release(ctx, src.ID)
+		require.NoError(t, err)
+
+		doc, err := svc.Copy(ctx, src.ID, CopyOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, model.StatusAvailable, doc.Status)
+		assert.Equal(t, model.ScanInfected, doc.ScanStatus, "the copy keeps the verdict")
+		assert.Equal(t, int64(3), doc.Version, "created pending, then processing and available")
+	})
+
+	t.Run("rejected sources are not copied", func(t *testing.T) {
+		store, repo := storage.NewMemory(), memory.NewDocumentMemory()
+		svc := NewDocumentService(store, repo, WithScanner(fakeScanner{}))
+		src, err := svc.Upload(ctx, strings.NewReader("X5O EICAR test"), "a.txt", "text/plain", 14)
+		require.NoError(t, err)
+		_, err = svc.Reject(ctx, src.ID)
+		require.NoError(t, err)
+
+		_, err = svc.Copy(ctx, src.ID, CopyOptions{})
+		assert.ErrorIs(t, err, ErrNotAvailable)
+		res, err := repo.List(ctx, repository.PageQuery{Limit: 10})
+		require.NoError(t, err)
+		assert.Len(t, res.Items, 1)
+	})
+
+	t.Run("rolls back a copy that cannot be processed", func(t *testing.T) {
+		src := &model.Document{ID: "src", Name: "a.txt", StoragePath: "documents/a.txt", ContentType: "text/plain", Status: model.StatusAvailable}
+		mRepo := new(repoMocks.MockDocumentRepository)
+		mRepo.On("FindByID", ctx, "src").Return(src, nil)
+		mRepo.On("Create", ctx, mock.MatchedBy(func(doc *model.Document) bool {
+			return doc.Status == model.StatusPending
+		})).Return(&model.Document{ID: "copy", StoragePath: "documents/copy.txt", Status: model.StatusPending}, nil)
+		mRepo.On("UpdateStatus", ctx, mock.Anything, model.StatusPending).Return(nil, errors.New("db down"))
+		mRepo.On("Delete", mock.Anything, "copy").Return(nil)
+		mStore := new(storeMocks.MockStorage)
+		mStore.On("Copy", ctx, "documents/a.txt", mock.Anything).Return(storage.ObjectInfo{Key: "documents/copy.txt", Size: 1}, nil)
+		mStore.On("Delete", mock.Anything, "documents/copy.txt").Return(nil)
+
+		_, err := NewDocumentService(mStore, mRepo).Copy(ctx, "src", CopyOptions{})
+		assert.ErrorContains(t, err, "db down")
+		mStore.AssertExpectations(t)
+		mRepo.AssertExpectations(t)
+	})
+
+	t.Run("rolls back the copied object", func(t *testing.T) {
+		src := &model.Document{ID: "src", Name: "a.txt", StoragePath: "documents/a.txt", ContentType: "text/plain", Status: model.StatusAvailable}
+		mRepo := new(repoMocks.MockDocumentRepository)
+		mRepo.On("FindByID", ctx, "src").Return(src, nil)
+		mRepo.On("Create", ctx, mock.Anything).Return(nil, errors.New("db down"))
+		mStore := new(storeMocks.MockStorage)
+		mStore.On("Copy", ctx, "documents/a.txt", mock.MatchedBy(func(key string) bool {
+			return strings.HasPrefix(key, "documents/") && strings.HasSuffix(key, ".txt")
+		})).Return(storage.ObjectInfo{Key: "documents/copy.txt", Size: 1}, nil)
+		mStore.On("Delete", mock.Anything, "documents/copy.txt").Return(nil)
+
+		_, err := NewDocumentService(mStore, mRepo).Copy(ctx, "src", CopyOptions{})
+		assert.ErrorContains(t, err, "db down")
+		mStore.AssertExpectations(t)
+	})
+}
+
+func TestDocumentService_Update(t *testing.T) {
+	ctx := context.Background()
+	ptr := func(s string) *string { return &s }
+	ifVersion := func(v int64) Precondition {
+		return func(doc *model.Document) bool { return doc.Version == v }
+	}
+
+	seed := func(t *testing.T) (DocumentService, *model.Document) {
+		t.Helper()
+		repo := memory.NewDocumentMemory()
+		doc, err := repo.Create(ctx, &model.Document{
+			ID:          uuid.NewString(),
+			Name:        "scan.bin",
+			ContentType: "application/octet-stream",
+			CreatedAt:   time.Now().Add(-time.Hour).UTC(),
+			Metadata:    map[string]string{"owner": "alice", "source": "scanner"},
+		})
+		require.NoError(t, err)
+		return NewDocumentService(storage.NewMemory(), repo), doc
+	}
+
+	t.Run("applies the patch", func(t *testing.T) {
+		svc, doc := seed(t)
+		tags := []string{" invoice ", "2024", "invoice"}
+		got, err := svc.Update(ctx, doc.ID, DocumentPatch{
+			Name:        ptr("invoice.pdf"),
+			ContentType: ptr("Application/PDF"),
+			Tags:        &tags,
+			Metadata:    map[string]*string{"owner": ptr("bob"), "source": nil},
+		}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "invoice.pdf", got.Name)
+		assert.Equal(t, "application/pdf", got.ContentType)
+		assert.Equal(t, []string{"invoice", "2024"}, got.Tags)
+		assert.Equal(t, map[string]string{"owner": "bob"}, got.Metadata)
+		assert.Equal(t, doc.Version+1, got.Version)
+		assert.True(t, got.UpdatedAt.After(doc.UpdatedAt))
+	})
+
+	t.Run("clears metadata", func(t *testing.T) {
+		svc, doc := seed(t)
+		got, err := svc.Update(ctx, doc.ID, DocumentPatch{ClearMetadata: true, Metadata: map[string]*string{"kept": ptr("yes")}}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"kept": "yes"}, got.Metadata)
+	})
+
+	t.Run("unchanged document keeps its version", func(t *testing.T) {
+		svc, doc := seed(t)
+		got, err := svc.Update(ctx, doc.ID, DocumentPatch{Name: ptr(doc.Name), Metadata: map[string]*string{"missing": nil}}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, doc.Version, got.Version)
+	})
+
+	t.Run("precondition", func(t *testing.T) {
+		svc, doc := seed(t)
+		_, err := svc.Update(ctx, doc.ID, DocumentPatch{Name: ptr("a.txt")}, ifVersion(doc.Version+1))
+		assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+		got, err := svc.Update(ctx, doc.ID, DocumentPatch{Name: ptr("a.txt")}, ifVersion(doc.Version))
+		require.NoError(t, err)
+		assert.Equal(t, "a.txt", got.Name)
+
+		// The version read before the first update is stale now.
+		_, err = svc.Update(ctx, doc.ID, DocumentPatch{Name: ptr("b.txt")}, ifVersion(doc.Version))
+		assert.ErrorIs(t, err, ErrPreconditionFailed)
+	})
+
+	t.Run("invalid patch", func(t *testing.T) {
+		svc, doc := seed(t)
+		for _, patch := range []DocumentPatch{
+			{Name: ptr("  ")},
+			{ContentType: ptr("not a type")},
+			{Tags: &[]string{"ok", ""}},
+			{Metadata: map[string]*string{"": ptr("x")}},
+		} {
+			_, err := svc.Update(ctx, doc.ID, patch, nil)
+			assert.ErrorIs(t, err, ErrInvalidPatch)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		svc, _ := seed(t)
+		_, err := svc.Update(ctx, uuid.NewString(), DocumentPatch{Name: ptr("a.txt")}, nil)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("retries concurrent edits", func(t *testing.T) {
+		mRepo := new(repoMocks.MockDocumentRepository)
+		mRepo.On("FindByID", ctx, "id").Return(&model.Document{ID: "id", Name: "a.txt", Version: 1}, nil).Once()
+		mRepo.On("FindByID", ctx, "id").Return(&model.Document{ID: "id", Name: "a.txt", Version: 2}, nil).Once()
+		mRepo.On("Update", ctx, mock.Anything).Return(nil, repository.ErrVersionConflict).Once()
+		mRepo.On("Update", ctx, mock.Anything).Return(&model.Document{ID: "id", Name: "b.txt", Version: 3}, nil).Once()
+
+		got, err := NewDocumentService(nil, mRepo).Update(ctx, "id", DocumentPatch{Name: ptr("b.txt")}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), got.Version)
+		mRepo.AssertNumberOfCalls(t, "FindByID", 2)
+	})
+
+	t.Run("gives up after repeated conflicts", func(t *testing.T) {
+		mRepo := new(repoMocks.MockDocumentRepository)
+		for v := range int64(updateAttempts) {
+			mRepo.On("FindByID", ctx, "id").Return(&model.Document{ID: "id", Name: "a.txt", Version: v + 1}, nil).Once()
+		}
+		mRepo.On("Update", ctx, mock.Anything).Return(nil, repository.ErrVersionConflict)
+
+		_, err := NewDocumentService(nil, mRepo).Update(ctx, "id", DocumentPatch{Name: ptr("b.txt")}, nil)
+		assert.ErrorIs(t, err, ErrConflict)
+		mRepo.AssertNumberOfCalls(t, "Update", updateAttempts)
+	})
+}
+
 func TestDocumentService_Delete(t *testing.T) {
 	ctx := context.Background()
 
 	tests := []struct {
 		name       string
 		id         string
+		pre        Precondition
 		setupMocks func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository)
 		wantErr    error
 	}{
@@ -286,9 +1011,9 @@ func TestDocumentService_Delete(t *testing.T) {
 			name: "happy path",
 			id:   "valid-id",
 			setupMocks: func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository) {
-				mRepo.On("FindByID", ctx, "valid-id").Return(&model.Document{ID: "valid-id", StoragePath: "path/to/obj"}, nil)
-				mStore.On("Delete", ctx, "path/to/obj").Return(nil)
-				mRepo.On("Delete", ctx, "valid-id").Return(nil)
+				mRepo.On("FindByID", ctx, "valid-id").Return(&model.Document{ID: "valid-id", StoragePath: "path/to/obj", Version: 1}, nil)
+				mRepo.On("DeleteVersion", ctx, "valid-id", int64(1)).Return(nil)
+				mStore.On("Delete", mock.Anything, "path/to/obj").Return(nil)
 			},
 		},
 		{
@@ -306,24 +1031,50 @@ func TestDocumentService_Delete(t *testing.T) {
 			wantErr: ErrNotFound,
 		},
 		{
-			name: "storage delete error",
+			name: "precondition failed",
+			id:   "changed-id",
+			pre:  func(doc *model.Document) bool { return doc.Version == 1 },
+			setupMocks: func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository) {
+				mRepo.On("FindByID", ctx, "changed-id").Return(&model.Document{ID: "changed-id", StoragePath: "path", Version: 2}, nil)
+			},
+			wantErr: ErrPreconditionFailed,
+		},
+		{
+			name: "deleted meanwhile",
+			id:   "gone-id",
+			setupMocks: func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository) {
+				mRepo.On("FindByID", ctx, "gone-id").Return(&model.Document{ID: "gone-id", StoragePath: "path", Version: 1}, nil)
+				mRepo.On("DeleteVersion", ctx, "gone-id", int64(1)).Return(sql.ErrNoRows)
+			},
+			wantErr: ErrNotFound,
+		},
+		{
+			name: "storage delete error leaves an orphan",
 			id:   "storage-fail-id",
 			setupMocks: func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository) {
-				mRepo.On("FindByID", ctx, "storage-fail-id").Return(&model.Document{ID: "id", StoragePath: "path"}, nil)
-				mStore.On("Delete", ctx, "path").Return(errors.New("storage fail"))
+				mRepo.On("FindByID", ctx, "storage-fail-id").Return(&model.Document{ID: "storage-fail-id", StoragePath: "path", Version: 1}, nil)
+				mRepo.On("DeleteVersion", ctx, "storage-fail-id", int64(1)).Return(nil)
+				mStore.On("Delete", mock.Anything, "path").Return(errors.New("storage fail"))
 			},
-			wantErr: errors.New("delete storage: storage fail"),
 		},
 		{
 			name: "repository delete error",
 			id:   "repo-fail-id",
 			setupMocks: func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository) {
-				mRepo.On("FindByID", ctx, "repo-fail-id").Return(&model.Document{ID: "id", StoragePath: "path"}, nil)
-				mStore.On("Delete", ctx, "path").Return(nil)
-				mRepo.On("Delete", ctx, "repo-fail-id").Return(errors.New("db fail"))
+				mRepo.On("FindByID", ctx, "repo-fail-id").Return(&model.Document{ID: "repo-fail-id", StoragePath: "path", Version: 1}, nil)
+				mRepo.On("DeleteVersion", ctx, "repo-fail-id", int64(1)).Return(errors.New("db fail"))
 			},
 			wantErr: errors.New("db fail"),
 		},
+		{
+			name: "changed on every attempt",
+			id:   "busy-id",
+			setupMocks: func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository) {
+				mRepo.On("FindByID", ctx, "busy-id").Return(&model.Document{ID: "busy-id", StoragePath: "path", Version: 1}, nil)
+				mRepo.On("DeleteVersion", ctx, "busy-id", int64(1)).Return(repository.ErrVersionConflict)
+			},
+			wantErr: ErrConflict,
+		},
 	}
 
 	for _, tt := range tests {
@@ -334,7 +1085,7 @@ func TestDocumentService_Delete(t *testing.T) {
 
 			tt.setupMocks(mStore, mRepo)
 
-			err := svc.Delete(ctx, tt.id)
+			err := svc.Delete(ctx, tt.id, tt.pre)
 
 			if tt.wantErr != nil {
 				if errors.Is(tt.wantErr, ErrIDRequired) || errors.Is(tt.wantErr, ErrNotFound) {
@@ -351,3 +1102,642 @@ func TestDocumentService_Delete(t *testing.T) {
 		})
 	}
 }
+
+func TestDocumentService_DeleteRacingEdit(t *testing.T) {
+	ctx := context.Background()
+	store, repo := storage.NewMemory(), memory.NewDocumentMemory()
+	svc := NewDocumentService(store, repo)
+	doc, err := svc.Upload(ctx, strings.NewReader("hello"), "a.txt", "text/plain", 5)
+	require.NoError(t, err)
+
+	// The document is renamed right after the precondition accepted the version it was read at.
+	name := "b.txt"
+	checked := 0
+	pre := func(d *model.Document) bool {
+		checked++
+		if d.Version != doc.Version {
+			return false
+		}
+		_, err := svc.Update(ctx, doc.ID, DocumentPatch{Name: &name}, nil)
+		require.NoError(t, err)
+		return true
+	}
+
+	err = svc.Delete(ctx, doc.ID, pre)
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+	assert.Equal(t, 2, checked, "the edited document is checked again")
+
+	kept, err := svc.Get(ctx, doc.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "b.txt", kept.Name)
+	exists, err := store.Exists(ctx, doc.StoragePath)
+	require.NoError(t, err)
+	assert.True(t, exists, "the object of the edited document is kept")
+}
+
+func TestDocumentService_DeleteMany(t *testing.T) {
+	ctx := context.Background()
+	idA, idB, idC := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	docA := model.Document{ID: idA, StoragePath: "documents/a"}
+	docB := model.Document{ID: idB, StoragePath: "documents/b"}
+
+	tests := []struct {
+		name       string
+		ids        []string
+		setupMocks func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository)
+		want       []DeleteResult
+		wantErr    error
+	}{
+		{
+			name: "deleted and not found",
+			ids:  []string{idA, idC, idB, idA},
+			setupMocks: func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository) {
+				mRepo.On("FindByIDs", ctx, []string{idA, idC, idB}).Return([]model.Document{docB, docA}, nil)
+				mStore.On("Delete", ctx, "documents/a").Return(nil)
+				mStore.On("Delete", ctx, "documents/b").Return(nil)
+				mRepo.On("DeleteMany", ctx, []string{idB, idA}).Return(int64(2), nil)
+			},
+			want: []DeleteResult{
+				{ID: idA, Status: DeleteStatusDeleted},
+				{ID: idC, Status: DeleteStatusNotFound},
+				{ID: idB, Status: DeleteStatusDeleted},
+			},
+		},
+		{
+			name:       "invalid ids are not looked up",
+			ids:        []string{"nope"},
+			setupMocks: func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository) {},
+			want:       []DeleteResult{{ID: "nope", Status: DeleteStatusFailed, Err: ErrInvalidID}},
+		},
+		{
+			name: "storage failure keeps the record",
+			ids:  []string{idA, idB},
+			setupMocks: func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository) {
+				mRepo.On("FindByIDs", ctx, []string{idA, idB}).Return([]model.Document{docA, docB}, nil)
+				mStore.On("Delete", ctx, "documents/a").Return(errors.New("storage fail"))
+				mStore.On("Delete", ctx, "documents/b").Return(nil)
+				mRepo.On("DeleteMany", ctx, []string{idB}).Return(int64(1), nil)
+			},
+			want: []DeleteResult{
+				{ID: idA, Status: DeleteStatusFailed, Err: errors.New("delete storage: storage fail")},
+				{ID: idB, Status: DeleteStatusDeleted},
+			},
+		},
+		{
+			name: "record deletion failure fails the stored items",
+			ids:  []string{idA},
+			setupMocks: func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository) {
+				mRepo.On("FindByIDs", ctx, []string{idA}).Return([]model.Document{docA}, nil)
+				mStore.On("Delete", ctx, "documents/a").Return(nil)
+				mRepo.On("DeleteMany", ctx, []string{idA}).Return(int64(0), errors.New("db fail"))
+			},
+			want: []DeleteResult{{ID: idA, Status: DeleteStatusFailed, Err: errors.New("delete records: db fail")}},
+		},
+		{
+			name: "lookup failure fails the batch",
+			ids:  []string{idA},
+			setupMocks: func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository) {
+				mRepo.On("FindByIDs", ctx, []string{idA}).Return(nil, errors.New("db down"))
+			},
+			wantErr: errors.New("db down"),
+		},
+		{
+			name:       "too many ids",
+			ids:        []string{idA, idB, idC, "x", "y"},
+			setupMocks: func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository) {},
+			wantErr:    ErrTooManyIDs,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mStore := new(storeMocks.MockStorage)
+			mRepo := new(repoMocks.MockDocumentRepository)
+			svc := NewDocumentService(mStore, mRepo, WithBatchDelete(4, 2))
+
+			tt.setupMocks(mStore, mRepo)
+
+			got, err := svc.DeleteMany(ctx, tt.ids)
+
+			if tt.wantErr != nil {
+				require.Error(t, err)
+				if errors.Is(tt.wantErr, ErrTooManyIDs) {
+					assert.ErrorIs(t, err, ErrTooManyIDs)
+				} else {
+					assert.Contains(t, err.Error(), tt.wantErr.Error())
+				}
+			} else {
+				require.NoError(t, err)
+				require.Len(t, got, len(tt.want))
+				for i, want := range tt.want {
+					assert.Equal(t, want.ID, got[i].ID)
+					assert.Equal(t, want.Status, got[i].Status)
+					if want.Err != nil {
+						require.Error(t, got[i].Err)
+						assert.Contains(t, got[i].Err.Error(), want.Err.Error())
+					} else {
+						assert.NoError(t, got[i].Err)
+					}
+				}
+			}
+			mStore.AssertExpectations(t)
+			mRepo.AssertExpectations(t)
+		})
+	}
+}
+
+// partialCopyStorage fails every copy after writing part of the destination object, and
+// fails to delete that object once.
+type partialCopyStorage struct {
+	storage.Storage
+	partial string
+}
+
+func (s *partialCopyStorage) Copy(ctx context.Context, src, dst string) (storage.ObjectInfo, error) {
+	if _, err := s.Storage.Put(ctx, dst, strings.NewReader("X5O"), storage.PutObjectOptions{}); err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	s.partial = dst
+	return storage.ObjectInfo{}, errors.New("copy interrupted")
+}
+
+func (s *partialCopyStorage) Delete(ctx context.Context, key string) error {
+	if key == s.partial {
+		s.partial = ""
+		return errors.New("delete failed")
+	}
+	return s.Storage.Delete(ctx, key)
+}
+
+// countingStorage records the highest number of concurrent uploads and deletions.
+type countingStorage struct {
+	storage.Storage
+	mu        sync.Mutex
+	inflight  int
+	peak      int
+	deleteErr error
+}
+
+func (s *countingStorage) enter() func() {
+	s.mu.Lock()
+	s.inflight++
+	s.peak = max(s.peak, s.inflight)
+	s.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	return func() {
+		s.mu.Lock()
+		s.inflight--
+		s.mu.Unlock()
+	}
+}
+
+func (s *countingStorage) Put(ctx context.Context, key string, r io.Reader, opt storage.PutObjectOptions) (storage.ObjectInfo, error) {
+	defer s.enter()()
+	return s.Storage.Put(ctx, key, r, opt)
+}
+
+func (s *countingStorage) Delete(ctx context.Context, key string) error {
+	defer s.enter()()
+	if s.deleteErr != nil {
+		return s.deleteErr
+	}
+	return s.Storage.Delete(ctx, key)
+}
+
+func TestDocumentService_DeleteManyConcurrency(t *testing.T) {
+	ctx := context.Background()
+	store := &countingStorage{Storage: storage.NewMemory()}
+	repo := memory.NewDocumentMemory()
+	svc := NewDocumentService(store, repo, WithBatchDelete(50, 3))
+
+	var ids []string
+	for i := 0; i < 12; i++ {
+		doc, err := svc.Upload(ctx, strings.NewReader("content"), "a.txt", "text/plain", 7)
+		require.NoError(t, err)
+		ids = append(ids, doc.ID)
+	}
+
+	results, err := svc.DeleteMany(ctx, ids)
+	require.NoError(t, err)
+	for _, res := range results {
+		assert.Equal(t, DeleteStatusDeleted, res.Status, res.ID)
+	}
+	assert.LessOrEqual(t, store.peak, 3)
+	assert.Greater(t, store.peak, 1)
+
+	left, err := repo.FindByIDs(ctx, ids)
+	require.NoError(t, err)
+	assert.Empty(t, left)
+}
+
+func uploadFile(name, content string) UploadFile {
+	return UploadFile{
+		Filename:    name,
+		ContentType: "text/plain",
+		Size:        int64(len(content)),
+		Open:        func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(content)), nil },
+	}
+}
+
+func brokenFile(name string) UploadFile {
+	return UploadFile{Filename: name, Open: func() (io.ReadCloser, error) { return nil, errors.New("gone") }}
+}
+
+func TestDocumentService_UploadMany(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		files      []UploadFile
+		atomic     bool
+		deleteErr  error
+		wantStatus []UploadStatus
+		wantErr    []error
+		wantStored int
+	}{
+		{
+			name:       "all created",
+			files:      []UploadFile{uploadFile("a.txt", "alpha"), uploadFile("b.txt", "bravo")},
+			wantStatus: []UploadStatus{UploadStatusCreated, UploadStatusCreated},
+			wantErr:    []error{nil, nil},
+			wantStored: 2,
+		},
+		{
+			name:       "failures are independent",
+			files:      []UploadFile{uploadFile("a.txt", "alpha"), brokenFile("b.txt"), uploadFile("c.txt", "charlie")},
+			wantStatus: []UploadStatus{UploadStatusCreated, UploadStatusFailed, UploadStatusCreated},
+			wantErr:    []error{nil, ErrFileOpen, nil},
+			wantStored: 2,
+		},
+		{
+			name:       "atomic failure rolls back",
+			files:      []UploadFile{uploadFile("a.txt", "alpha"), brokenFile("b.txt"), uploadFile("c.txt", "charlie")},
+			atomic:     true,
+			wantStatus: []UploadStatus{UploadStatusRolledBack, UploadStatusFailed, UploadStatusRolledBack},
+			wantErr:    []error{nil, ErrFileOpen, nil},
+		},
+		{
+			name:       "atomic success",
+			files:      []UploadFile{uploadFile("a.txt", "alpha")},
+			atomic:     true,
+			wantStatus: []UploadStatus{UploadStatusCreated},
+			wantErr:    []error{nil},
+			wantStored: 1,
+		},
+		{
+			name:       "failed rollback is reported",
+			files:      []UploadFile{uploadFile("a.txt", "alpha"), brokenFile("b.txt")},
+			atomic:     true,
+			deleteErr:  errors.New("storage down"),
+			wantStatus: []UploadStatus{UploadStatusFailed, UploadStatusFailed},
+			wantErr:    []error{ErrRollbackFailed, ErrFileOpen},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := memory.NewDocumentMemory()
+			// One upload at a time keeps the order of failures and successes deterministic.
+			svc := NewDocumentService(&countingStorage{Storage: storage.NewMemory(), deleteErr: tt.deleteErr}, repo, WithBatchUpload(10, 1))
+
+			got, err := svc.UploadMany(ctx, tt.files, tt.atomic)
+			require.NoError(t, err)
+			require.Len(t, got, len(tt.files))
+			for i, res := range got {
+				assert.Equal(t, tt.files[i].Filename, res.Filename)
+				assert.Equal(t, tt.wantStatus[i], res.Status, res.Filename)
+				if tt.wantErr[i] != nil {
+					assert.ErrorIs(t, res.Err, tt.wantErr[i], res.Filename)
+				} else {
+					assert.NoError(t, res.Err, res.Filename)
+				}
+				if res.Status == UploadStatusCreated {
+					require.NotNil(t, res.Document)
+					assert.Equal(t, res.Filename, res.Document.Name)
+				}
+			}
+
+			list, err := repo.List(ctx, repository.PageQuery{Limit: 10})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStored, list.Total)
+		})
+	}
+
+	t.Run("too many files", func(t *testing.T) {
+		svc := NewDocumentService(storage.NewMemory(), memory.NewDocumentMemory(), WithBatchUpload(1, 1))
+		_, err := svc.UploadMany(ctx, []UploadFile{uploadFile("a", "a"), uploadFile("b", "b")}, false)
+		assert.ErrorIs(t, err, ErrTooManyFiles)
+	})
+
+	t.Run("parallelism is bounded", func(t *testing.T) {
+		store := &countingStorage{Storage: storage.NewMemory()}
+		svc := NewDocumentService(store, memory.NewDocumentMemory(), WithBatchUpload(20, 3))
+		files := make([]UploadFile, 12)
+		for i := range files {
+			files[i] = uploadFile("page.txt", "content")
+		}
+		got, err := svc.UploadMany(ctx, files, false)
+		require.NoError(t, err)
+		for _, res := range got {
+			assert.Equal(t, UploadStatusCreated, res.Status)
+		}
+		assert.LessOrEqual(t, store.peak, 3)
+		assert.Greater(t, store.peak, 1)
+	})
+}
+
+func TestDocumentService_Archive(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+	repo := memory.NewDocumentMemory()
+	svc := NewDocumentService(store, repo, WithArchiveLimit(3))
+
+	var docs []*model.Document
+	for _, f := range []struct{ name, content, contentType string }{
+		{"b.txt", "bravo", "text/plain"},
+		{"a.txt", "alpha", "text/plain"},
+		{"a.txt", "again", "text/plain"},
+		{"c.png", "png", "image/png"},
+	} {
+		doc, err := svc.Upload(ctx, strings.NewReader(f.content), f.name, f.contentType, int64(len(f.content)))
+		require.NoError(t, err)
+		docs = append(docs, doc)
+	}
+	missing := uuid.NewString()
+
+	write := func(t *testing.T, a *Archive) (map[string]string, archive.Manifest) {
+		t.Helper()
+		var buf bytes.Buffer
+		require.NoError(t, svc.WriteArchive(ctx, a, &buf))
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		files := map[string]string{}
+		var manifest archive.Manifest
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+			if f.Name == archive.ManifestName {
+				require.NoError(t, json.Unmarshal(content, &manifest))
+			} else {
+				files[f.Name] = string(content)
+			}
+		}
+		return files, manifest
+	}
+
+	t.Run("by ids", func(t *testing.T) {
+		a, err := svc.PrepareArchive(ctx, ArchiveSelection{IDs: []string{docs[1].ID, missing, docs[2].ID, docs[1].ID}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{docs[1].ID, docs[2].ID}, []string{a.Documents[0].ID, a.Documents[1].ID})
+		assert.Equal(t, []string{missing}, a.Missing)
+
+		files, manifest := write(t, a)
+		assert.Equal(t, map[string]string{"a.txt": "alpha", "a (1).txt": "again"}, files)
+		require.Len(t, manifest.Documents, 2)
+		assert.Equal(t, docs[2].ID, manifest.Documents[1].ID)
+		assert.Equal(t, "a (1).txt", manifest.Documents[1].Path)
+		assert.Len(t, manifest.Documents[1].SHA256, 64)
+		assert.Equal(t, []archive.SkippedEntry{{ID: missing, Reason: archive.SkipNotFound}}, manifest.Skipped)
+	})
+
+	t.Run("by filter", func(t *testing.T) {
+		a, err := svc.PrepareArchive(ctx, ArchiveSelection{
+			Filter: DocumentFilter{ContentType: "text/*"},
+			Sort:   Sort{Field: repository.SortName, Asc: true},
+		})
+		require.NoError(t, err)
+		require.Len(t, a.Documents, 3)
+		assert.Equal(t, "b.txt", a.Documents[2].Name)
+	})
+
+	t.Run("unreadable content is skipped", func(t *testing.T) {
+		require.NoError(t, store.Delete(ctx, docs[3].StoragePath))
+		a, err := svc.PrepareArchive(ctx, ArchiveSelection{IDs: []string{docs[3].ID, docs[0].ID}})
+		require.NoError(t, err)
+		files, manifest := write(t, a)
+		assert.Equal(t, map[string]string{"b.txt": "bravo"}, files)
+		assert.Equal(t, []archive.SkippedEntry{{ID: docs[3].ID, Reason: archive.SkipUnreadable}}, manifest.Skipped)
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		_, err := svc.PrepareArchive(ctx, ArchiveSelection{})
+		assert.ErrorIs(t, err, ErrTooManyDocuments)
+		_, err = svc.PrepareArchive(ctx, ArchiveSelection{IDs: []string{docs[0].ID, docs[1].ID, docs[2].ID, missing}})
+		assert.ErrorIs(t, err, ErrTooManyDocuments)
+		_, err = svc.PrepareArchive(ctx, ArchiveSelection{IDs: []string{"nope"}})
+		assert.ErrorIs(t, err, ErrInvalidID)
+	})
+}
+
+func TestDocumentService_ImportArchive(t *testing.T) {
+	ctx := context.Background()
+
+	var zbuf bytes.Buffer
+	zw := zip.NewWriter(&zbuf)
+	for _, f := range []struct{ name, content string }{
+		{"legacy/2019/report.pdf", "%PDF"},
+		{"../escape.txt", "x"},
+		{"notes.txt", strings.Repeat("n", 100)},
+		{"legacy/readme.txt", "hello"},
+	} {
+		fw, err := zw.Create(f.name)
+		require.NoError(t, err)
+		_, err = io.WriteString(fw, f.content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	zipData := zbuf.Bytes()
+
+	t.Run("per-entry report", func(t *testing.T) {
+		store := storage.NewMemory()
+		repo := memory.NewDocumentMemory()
+		svc := NewDocumentService(store, repo, WithImportLimits(ImportLimits{MaxEntrySize: 50}))
+
+		results, err := svc.ImportArchive(ctx, bytes.NewReader(zipData), int64(len(zipData)), "legacy.zip")
+		require.NoError(t, err)
+		require.Len(t, results, 4)
+
+		assert.Equal(t, ImportStatusCreated, results[0].Status)
+		doc := results[0].Document
+		require.NotNil(t, doc)
+		assert.Equal(t, "report.pdf", doc.Name)
+		assert.Equal(t, "application/pdf", doc.ContentType)
+		assert.Equal(t, map[string]string{MetadataArchive: "legacy.zip", MetadataArchivePath: "legacy/2019/report.pdf"}, doc.Metadata)
+		stored, err := repo.FindByID(ctx, doc.ID)
+		require.NoError(t, err)
+		assert.Equal(t, doc.Metadata, stored.Metadata)
+		rc, _, err := store.Get(ctx, doc.StoragePath)
+		require.NoError(t, err)
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		assert.Equal(t, "%PDF", string(content))
+
+		assert.Equal(t, ImportStatusRejected, results[1].Status)
+		assert.ErrorIs(t, results[1].Err, ErrUnsafePath)
+		assert.Equal(t, ImportStatusRejected, results[2].Status)
+		assert.ErrorIs(t, results[2].Err, ErrEntryTooLarge)
+		assert.Equal(t, "notes.txt", results[2].Path)
+		assert.Equal(t, ImportStatusCreated, results[3].Status)
+		assert.Equal(t, "text/plain; charset=utf-8", results[3].Document.ContentType)
+
+		page, err := repo.List(ctx, repository.PageQuery{Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, 2, page.Total)
+	})
+
+	t.Run("storage failure", func(t *testing.T) {
+		mStore := new(storeMocks.MockStorage)
+		mStore.On("Put", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(storage.ObjectInfo{}, errors.New("storage down"))
+		svc := NewDocumentService(mStore, memory.NewDocumentMemory())
+
+		results, err := svc.ImportArchive(ctx, bytes.NewReader(zipData), int64(len(zipData)), "legacy.zip")
+		require.NoError(t, err)
+		require.Len(t, results, 4)
+		assert.Equal(t, ImportStatusFailed, results[0].Status)
+		assert.ErrorContains(t, results[0].Err, "storage down")
+		assert.Equal(t, ImportStatusRejected, results[1].Status)
+	})
+
+	t.Run("archive-wide limit", func(t *testing.T) {
+		svc := NewDocumentService(storage.NewMemory(), memory.NewDocumentMemory(), WithImportLimits(ImportLimits{MaxEntries: 3}))
+		results, err := svc.ImportArchive(ctx, bytes.NewReader(zipData), int64(len(zipData)), "legacy.zip")
+		assert.ErrorIs(t, err, ErrTooManyEntries)
+		assert.Empty(t, results)
+	})
+
+	t.Run("compressed tar expanding too much", func(t *testing.T) {
+		var tbuf bytes.Buffer
+		gz := gzip.NewWriter(&tbuf)
+		tw := tar.NewWriter(gz)
+		bomb := strings.Repeat("\x00", 4<<20)
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "bomb.bin", Mode: 0o644, Size: int64(len(bomb))}))
+		_, err := io.WriteString(tw, bomb)
+		require.NoError(t, err)
+		require.NoError(t, tw.Close())
+		require.NoError(t, gz.Close())
+
+		repo := memory.NewDocumentMemory()
+		svc := NewDocumentService(storage.NewMemory(), repo, WithImportLimits(ImportLimits{MaxRatio: 100}))
+		results, err := svc.ImportArchive(ctx, bytes.NewReader(tbuf.Bytes()), int64(tbuf.Len()), "bomb.tar.gz")
+		assert.ErrorIs(t, err, ErrRatioExceeded)
+		require.Len(t, results, 1)
+		assert.Equal(t, ImportStatusRejected, results[0].Status)
+		assert.ErrorIs(t, results[0].Err, ErrRatioExceeded)
+		page, err := repo.List(ctx, repository.PageQuery{Limit: 10})
+		require.NoError(t, err)
+		assert.Zero(t, page.Total)
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		svc := NewDocumentService(storage.NewMemory(), memory.NewDocumentMemory())
+		_, err := svc.ImportArchive(ctx, strings.NewReader("plain text"), 10, "notes.txt")
+		assert.ErrorIs(t, err, ErrUnsupportedArchive)
+	})
+}
+
+func TestDocumentService_Download(t *testing.T) {
+	ctx := context.Background()
+	doc := &model.Document{ID: "doc-id", StoragePath: "path/to/obj", Size: 10, Status: model.StatusAvailable}
+
+	tests := []struct {
+		name       string
+		offset     int64
+		length     int64
+		setupMocks func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository)
+		wantErr    error
+	}{
+		{
+			name:   "whole document",
+			offset: 0, length: -1,
+			setupMocks: func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository) {
+				mRepo.On("FindByID", ctx, "doc-id").Return(doc, nil)
+				mStore.On("GetRange", ctx, "path/to/obj", int64(0), int64(-1)).
+					Return(io.NopCloser(strings.NewReader("0123456789")), storage.ObjectInfo{}, nil)
+			},
+		},
+		{
+			name:   "suffix range",
+			offset: -4, length: -1,
+			setupMocks: func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository) {
+				mRepo.On("FindByID", ctx, "doc-id").Return(doc, nil)
+				mStore.On("GetRange", ctx, "path/to/obj", int64(6), int64(-1)).
+					Return(io.NopCloser(strings.NewReader("6789")), storage.ObjectInfo{}, nil)
+			},
+		},
+		{
+			name:   "offset past end",
+			offset: 10, length: -1,
+			setupMocks: func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository) {
+				mRepo.On("FindByID", ctx, "doc-id").Return(doc, nil)
+			},
+			wantErr: ErrInvalidRange,
+		},
+		{
+			name:   "not found",
+			offset: 0, length: -1,
+			setupMocks: func(mStore *storeMocks.MockStorage, mRepo *repoMocks.MockDocumentRepository) {
+				mRepo.On("FindByID", ctx, "doc-id").Return(nil, sql.ErrNoRows)
+			},
+			wantErr: ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mStore := new(storeMocks.MockStorage)
+			mRepo := new(repoMocks.MockDocumentRepository)
+			svc := NewDocumentService(mStore, mRepo)
+
+			tt.setupMocks(mStore, mRepo)
+
+			rc, got, err := svc.Download(ctx, "doc-id", tt.offset, tt.length)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, rc)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, doc, got)
+				rc.Close()
+			}
+			mStore.AssertExpectations(t)
+			mRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDocumentService_PresignURL(t *testing.T) {
+	ctx := context.Background()
+	doc := &model.Document{ID: "doc-id", StoragePath: "path/to/obj", Status: model.StatusAvailable}
+
+	tests := []struct {
+		name     string
+		storeErr error
+		want     string
+		wantErr  error
+	}{
+		{name: "happy path", want: "https://minio/path/to/obj?sig"},
+		{name: "unsupported", storeErr: storage.ErrPresignUnsupported, wantErr: ErrPresignUnsupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mStore := new(storeMocks.MockStorage)
+			mRepo := new(repoMocks.MockDocumentRepository)
+			svc := NewDocumentService(mStore, mRepo)
+
+			mRepo.On("FindByID", ctx, "doc-id").Return(doc, nil)
+			mStore.On("PresignGet", ctx, "path/to/obj", 15*time.Minute).Return(tt.want, tt.storeErr)
+
+			got, err := svc.PresignURL(ctx, "doc-id", 15*time.Minute)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+			mStore.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"docapi/internal/repository"
+	"docapi/internal/storage"
+)
+
+// JobDeleteObject is the kind of the background job that deletes a stored object whose deletion
+// failed while a request was rolled back, so that it does not linger until reconciliation.
+const JobDeleteObject = "storage.delete_object"
+
+// DeleteObjectPayload is the payload of JobDeleteObject jobs.
+type DeleteObjectPayload struct {
+	Key string `json:"key"`
+}
+
+// JobQueue adds background jobs; see jobs.Queue.
+type JobQueue interface {
+	Enqueue(ctx context.Context, kind string, payload any) (*repository.Job, error)
+}
+
+// DeleteObjectHandler returns the handler of JobDeleteObject jobs. Objects already gone count as
+// deleted.
+func DeleteObjectHandler(store storage.Storage) func(ctx context.Context, payload DeleteObjectPayload) error {
+	return func(ctx context.Context, payload DeleteObjectPayload) error {
+		return store.Delete(ctx, payload.Key)
+	}
+}
+
+// deleteObject deletes the object under key while a request is rolled back. If that fails and a
+// job queue is configured, the deletion is left to a JobDeleteObject job, and only failing to
+// enqueue it is an error.
+func (s *documentService) deleteObject(ctx context.Context, key string) error {
+	err := s.store.Delete(ctx, key)
+	if err == nil || s.jobs == nil {
+		return err
+	}
+	if _, qerr := s.jobs.Enqueue(ctx, JobDeleteObject, DeleteObjectPayload{Key: key}); qerr != nil {
+		return fmt.Errorf("%w; enqueue deletion: %v", err, qerr)
+	}
+	return nil
+}
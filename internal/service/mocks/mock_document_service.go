@@ -3,6 +3,7 @@ package mocks
 import (
 	"context"
 	"io"
+	"time"
 
 	"docapi/internal/model"
 	"docapi/internal/service"
@@ -21,8 +22,24 @@ func (m *MockDocumentService) Upload(ctx context.Context, r io.Reader, originalF
 	return args.Get(0).(*model.Document), args.Error(1)
 }
 
-func (m *MockDocumentService) List(ctx context.Context, limit, offset int) (*service.DocumentListResult, error) {
-	args := m.Called(ctx, limit, offset)
+func (m *MockDocumentService) UploadMany(ctx context.Context, files []service.UploadFile, atomic bool) ([]service.UploadResult, error) {
+	args := m.Called(ctx, files, atomic)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]service.UploadResult), args.Error(1)
+}
+
+func (m *MockDocumentService) ImportArchive(ctx context.Context, r io.ReaderAt, size int64, archiveName string) ([]service.ImportResult, error) {
+	args := m.Called(ctx, r, size, archiveName)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]service.ImportResult), args.Error(1)
+}
+
+func (m *MockDocumentService) List(ctx context.Context, params service.ListParams) (*service.DocumentListResult, error) {
+	args := m.Called(ctx, params)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -37,7 +54,73 @@ func (m *MockDocumentService) Get(ctx context.Context, id string) (*model.Docume
 	return args.Get(0).(*model.Document), args.Error(1)
 }
 
-func (m *MockDocumentService) Delete(ctx context.Context, id string) error {
-	args := m.Called(ctx, id)
+func (m *MockDocumentService) Copy(ctx context.Context, id string, opts service.CopyOptions) (*model.Document, error) {
+	args := m.Called(ctx, id, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Document), args.Error(1)
+}
+
+func (m *MockDocumentService) Update(ctx context.Context, id string, patch service.DocumentPatch, pre service.Precondition) (*model.Document, error) {
+	args := m.Called(ctx, id, patch, pre)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Document), args.Error(1)
+}
+
+func (m *MockDocumentService) Delete(ctx context.Context, id string, pre service.Precondition) error {
+	args := m.Called(ctx, id, pre)
+	return args.Error(0)
+}
+
+func (m *MockDocumentService) DeleteMany(ctx context.Context, ids []string) ([]service.DeleteResult, error) {
+	args := m.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]service.DeleteResult), args.Error(1)
+}
+
+func (m *MockDocumentService) PrepareArchive(ctx context.Context, sel service.ArchiveSelection) (*service.Archive, error) {
+	args := m.Called(ctx, sel)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.Archive), args.Error(1)
+}
+
+func (m *MockDocumentService) WriteArchive(ctx context.Context, a *service.Archive, w io.Writer) error {
+	args := m.Called(ctx, a, w)
 	return args.Error(0)
 }
+
+func (m *MockDocumentService) Download(ctx context.Context, id string, offset, length int64) (io.ReadCloser, *model.Document, error) {
+	args := m.Called(ctx, id, offset, length)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).(io.ReadCloser), args.Get(1).(*model.Document), args.Error(2)
+}
+
+func (m *MockDocumentService) PresignURL(ctx context.Context, id string, expiry time.Duration) (string, error) {
+	args := m.Called(ctx, id, expiry)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockDocumentService) Release(ctx context.Context, id string) (*model.Document, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Document), args.Error(1)
+}
+
+func (m *MockDocumentService) Reject(ctx context.Context, id string) (*model.Document, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Document), args.Error(1)
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+
+	"docapi/internal/model"
+	"docapi/internal/repository"
+	"docapi/internal/storage"
+)
+
+// documentPrefix is the storage key prefix under which Upload stores document content.
+const documentPrefix = "documents/"
+
+// quarantinePrefix is the storage key prefix under which infected content is kept.
+const quarantinePrefix = "quarantine/"
+
+// reconcileBatchSize is the number of objects looked up, and of documents read, per query.
+const reconcileBatchSize = 500
+
+// reconcileStatConcurrency bounds the storage lookups run in parallel for one page of documents.
+const reconcileStatConcurrency = 8
+
+// ReconcileReport describes the drift found by one reconciliation run.
+type ReconcileReport struct {
+	DryRun           bool
+	ScannedObjects   int
+	ScannedDocuments int
+	// OrphanObjects are the keys of stored objects that no document refers to.
+	OrphanObjects []string
+	// DanglingDocuments are documents whose stored object is missing.
+	DanglingDocuments []model.Document
+	// Repaired counts orphans deleted and dangling documents removed; Failed counts repairs that
+	// did not succeed. Both stay zero in dry runs.
+	Repaired int
+	Failed   int
+}
+
+// ReconcileMetrics holds Prometheus gauges reporting the drift found by the last run.
+type ReconcileMetrics struct {
+	orphans     prometheus.Gauge
+	dangling    prometheus.Gauge
+	lastSuccess prometheus.Gauge
+}
+
+// NewReconcileMetrics creates and registers the reconciliation gauges. Registering twice on the
+// same registerer reuses the existing collectors.
+func NewReconcileMetrics(reg prometheus.Registerer) (*ReconcileMetrics, error) {
+	newGauge := func(name, help string) (prometheus.Gauge, error) {
+		g := prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: help})
+		if err := reg.Register(g); err != nil {
+			var are prometheus.AlreadyRegisteredError
+			if !errors.As(err, &are) {
+				return nil, err
+			}
+			return are.ExistingCollector.(prometheus.Gauge), nil
+		}
+		return g, nil
+	}
+
+	var m ReconcileMetrics
+	var err error
+	if m.orphans, err = newGauge("reconcile_orphan_objects", "Stored objects without a document, as found by the last reconciliation."); err != nil {
+		return nil, err
+	}
+	if m.dangling, err = newGauge("reconcile_dangling_documents", "Documents whose stored object is missing, as found by the last reconciliation."); err != nil {
+		return nil, err
+	}
+	if m.lastSuccess, err = newGauge("reconcile_last_success_timestamp_seconds", "Unix time of the last completed reconciliation."); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// observe records the drift left after a run: what was found, less what was repaired.
+func (m *ReconcileMetrics) observe(orphans, dangling int) {
+	if m == nil {
+		return
+	}
+	m.orphans.Set(float64(orphans))
+	m.dangling.Set(float64(dangling))
+	m.lastSuccess.SetToCurrentTime()
+}
+
+// Reconciler finds and repairs drift between object storage and the document records: objects
+// left behind when an upload crashed or its rollback failed, and records whose object was deleted
+// without the record being removed.
+type Reconciler struct {
+	store   storage.Storage
+	repo    repository.DocumentRepository
+	grace   time.Duration
+	metrics *ReconcileMetrics
+}
+
+// NewReconciler constructs a Reconciler. Objects and documents younger than grace are ignored, so
+// that uploads and deletions in progress are not mistaken for drift. metrics may be nil.
+func NewReconciler(store storage.Storage, repo repository.DocumentRepository, grace time.Duration, metrics *ReconcileMetrics) *Reconciler {
+	return &Reconciler{store: store, repo: repo, grace: grace, metrics: metrics}
+}
+
+// Run compares every stored document object with the document records. Unless dryRun is set,
+// orphan objects are deleted and dangling records removed. A lookup that fails aborts the run;
+// failed repairs are counted and the run goes on.
+func (r *Reconciler) Run(ctx context.Context, dryRun bool) (*ReconcileReport, error) {
+	cutoff := time.Now().Add(-r.grace)
+	report := &ReconcileReport{DryRun: dryRun}
+
+	if err := r.findOrphans(ctx, cutoff, report); err != nil {
+		return nil, fmt.Errorf("find orphan objects: %w", err)
+	}
+	if err := r.findDangling(ctx, cutoff, report); err != nil {
+		return nil, fmt.Errorf("find dangling documents: %w", err)
+	}
+	orphans, dangling := len(report.OrphanObjects), len(report.DanglingDocuments)
+	if !dryRun {
+		orphans, dangling = r.repair(ctx, report)
+	}
+	r.metrics.observe(orphans, dangling)
+	return report, nil
+}
+
+func (r *Reconciler) findOrphans(ctx context.Context, cutoff time.Time, report *ReconcileReport) error {
+	batch := make([]storage.ObjectInfo, 0, reconcileBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		keys := make([]string, len(batch))
+		for i, info := range batch {
+			keys[i] = info.Key
+		}
+		docs, err := r.repo.FindByStoragePaths(ctx, keys)
+		if err != nil {
+			return err
+		}
+		known := make(map[string]bool, len(docs))
+		for _, d := range docs {
+			known[d.StoragePath] = true
+		}
+		for _, info := range batch {
+			if !known[info.Key] && info.LastModified.Before(cutoff) {
+				report.OrphanObjects = append(report.OrphanObjects, info.Key)
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for _, prefix := range []string{documentPrefix, quarantinePrefix} {
+		for info, err := range storage.ListAll(ctx, r.store, prefix) {
+			if err != nil {
+				return err
+			}
+			report.ScannedObjects++
+			batch = append(batch, info)
+			if len(batch) == reconcileBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return flush()
+}
+
+func (r *Reconciler) findDangling(ctx context.Context, cutoff time.Time, report *ReconcileReport) error {
+	pq := repository.PageQuery{Limit: reconcileBatchSize, SkipTotal: true, Sort: repository.DefaultSort}
+	for {
+		page, err := r.repo.List(ctx, pq)
+		if err != nil {
+			return err
+		}
+		report.ScannedDocuments += len(page.Items)
+
+		var mu sync.Mutex
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(reconcileStatConcurrency)
+		for _, doc := range page.Items {
+			if !doc.CreatedAt.Before(cutoff) {
+				continue
+			}
+			g.Go(func() error {
+				_, err := r.store.Stat(gctx, doc.StoragePath)
+				switch {
+				case errors.Is(err, storage.ErrNotFound):
+					mu.Lock()
+					report.DanglingDocuments = append(report.DanglingDocuments, doc)
+					mu.Unlock()
+				case err != nil:
+					return fmt.Errorf("stat %s: %w", doc.StoragePath, err)
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return err
+		}
+
+		if page.Next == nil {
+			return nil
+		}
+		pq.After = page.Next
+	}
+}
+
+// repair deletes the records of dangling documents and the orphan objects, and returns the
+// number of each that could not be repaired.
+func (r *Reconciler) repair(ctx context.Context, report *ReconcileReport) (orphans, dangling int) {
+	for i := 0; i < len(report.DanglingDocuments); i += reconcileBatchSize {
+		chunk := report.DanglingDocuments[i:min(i+reconcileBatchSize, len(report.DanglingDocuments))]
+		// A document whose content moved since it was found dangling, e.g. by a concurrent
+		// release from quarantine, is not deleted.
+		n, err := r.repo.DeleteUnmoved(ctx, chunk)
+		if err != nil {
+			report.Failed += len(chunk)
+			dangling += len(chunk)
+			continue
+		}
+		report.Repaired += int(n)
+	}
+
+	for _, key := range report.OrphanObjects {
+		if err := r.store.Delete(ctx, key); err != nil {
+			report.Failed++
+			orphans++
+			continue
+		}
+		report.Repaired++
+	}
+	return orphans, dangling
+}
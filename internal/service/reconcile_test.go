@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"docapi/internal/model"
+	"docapi/internal/repository/memory"
+	"docapi/internal/storage"
+	storeMocks "docapi/internal/storage/mocks"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconciler(t *testing.T) {
+	ctx := context.Background()
+
+	// seed stores a healthy document, a document without object and an object without document.
+	seed := func(t *testing.T) (storage.Storage, *memory.DocumentMemory, model.Document) {
+		t.Helper()
+		store := storage.NewMemory()
+		repo := memory.NewDocumentMemory()
+		created := time.Now().Add(-time.Minute)
+
+		for _, key := range []string{"documents/ok.txt", "documents/orphan.txt", "quarantine/infected.txt", "other/ignored.txt"} {
+			_, err := store.Put(ctx, key, strings.NewReader("data"), storage.PutObjectOptions{Size: 4})
+			require.NoError(t, err)
+		}
+		_, err := repo.Create(ctx, &model.Document{ID: uuid.NewString(), Name: "ok.txt", StoragePath: "documents/ok.txt", CreatedAt: created})
+		require.NoError(t, err)
+		_, err = repo.Create(ctx, &model.Document{ID: uuid.NewString(), Name: "infected.txt", StoragePath: "quarantine/infected.txt", CreatedAt: created, ScanStatus: model.ScanInfected})
+		require.NoError(t, err)
+		dangling := model.Document{ID: uuid.NewString(), Name: "gone.txt", StoragePath: "documents/gone.txt", CreatedAt: created}
+		_, err = repo.Create(ctx, &dangling)
+		require.NoError(t, err)
+		return store, repo, dangling
+	}
+
+	t.Run("dry run reports drift", func(t *testing.T) {
+		store, repo, dangling := seed(t)
+		reg := prometheus.NewRegistry()
+		metrics, err := NewReconcileMetrics(reg)
+		require.NoError(t, err)
+
+		report, err := NewReconciler(store, repo, 0, metrics).Run(ctx, true)
+		require.NoError(t, err)
+		assert.True(t, report.DryRun)
+		assert.Equal(t, 3, report.ScannedObjects)
+		assert.Equal(t, 3, report.ScannedDocuments)
+		assert.Equal(t, []string{"documents/orphan.txt"}, report.OrphanObjects)
+		require.Len(t, report.DanglingDocuments, 1)
+		assert.Equal(t, dangling.ID, report.DanglingDocuments[0].ID)
+		assert.Zero(t, report.Repaired)
+
+		_, err = store.Stat(ctx, "documents/orphan.txt")
+		assert.NoError(t, err)
+		_, err = repo.FindByID(ctx, dangling.ID)
+		assert.NoError(t, err)
+
+		assert.Equal(t, 1.0, testutil.ToFloat64(metrics.orphans))
+		assert.Equal(t, 1.0, testutil.ToFloat64(metrics.dangling))
+		assert.NotZero(t, testutil.ToFloat64(metrics.lastSuccess))
+	})
+
+	t.Run("repair", func(t *testing.T) {
+		store, repo, dangling := seed(t)
+		metrics, err := NewReconcileMetrics(prometheus.NewRegistry())
+		require.NoError(t, err)
+
+		report, err := NewReconciler(store, repo, 0, metrics).Run(ctx, false)
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Repaired)
+		assert.Zero(t, report.Failed)
+
+		_, err = store.Stat(ctx, "documents/orphan.txt")
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+		_, err = repo.FindByID(ctx, dangling.ID)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		_, err = store.Stat(ctx, "documents/ok.txt")
+		assert.NoError(t, err)
+
+		assert.Zero(t, testutil.ToFloat64(metrics.orphans))
+		assert.Zero(t, testutil.ToFloat64(metrics.dangling))
+
+		report, err = NewReconciler(store, repo, 0, nil).Run(ctx, false)
+		require.NoError(t, err)
+		assert.Empty(t, report.OrphanObjects)
+		assert.Empty(t, report.DanglingDocuments)
+	})
+
+	t.Run("grace period skips recent items", func(t *testing.T) {
+		store, repo, _ := seed(t)
+		report, err := NewReconciler(store, repo, time.Hour, nil).Run(ctx, true)
+		require.NoError(t, err)
+		assert.Empty(t, report.OrphanObjects)
+		assert.Empty(t, report.DanglingDocuments)
+	})
+
+	t.Run("storage failure aborts", func(t *testing.T) {
+		repo := memory.NewDocumentMemory()
+		_, err := repo.Create(ctx, &model.Document{ID: uuid.NewString(), StoragePath: "documents/a", CreatedAt: time.Now().Add(-time.Minute)})
+		require.NoError(t, err)
+
+		mStore := new(storeMocks.MockStorage)
+		mStore.On("List", mock.Anything, storage.ListOptions{Prefix: documentPrefix}).Return(storage.ListPage{}, nil)
+		mStore.On("List", mock.Anything, storage.ListOptions{Prefix: quarantinePrefix}).Return(storage.ListPage{}, nil)
+		mStore.On("Stat", mock.Anything, "documents/a").Return(storage.ObjectInfo{}, errors.New("storage down"))
+
+		_, err = NewReconciler(mStore, repo, 0, nil).Run(ctx, false)
+		assert.ErrorContains(t, err, "storage down")
+		mStore.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+}
+
+func TestReconciler_ReleaseDuringRun(t *testing.T) {
+	ctx := context.Background()
+	store := &statHookStorage{Storage: storage.NewMemory()}
+	repo := memory.NewDocumentMemory()
+	svc := NewDocumentService(store, repo, WithScanner(fakeScanner{}))
+	doc, err := svc.Upload(ctx, strings.NewReader("X5O EICAR test"), "a.txt", "text/plain", 14)
+	require.NoError(t, err)
+	require.Equal(t, model.StatusQuarantined, doc.Status)
+
+	// The document is released after it was listed but before its object is checked, so the
+	// object is gone from where the listed record says.
+	store.hook = func(key string) {
+		if key == doc.StoragePath {
+			_, err := svc.Release(ctx, doc.ID)
+			assert.NoError(t, err)
+		}
+	}
+	report, err := NewReconciler(store, repo, 0, nil).Run(ctx, false)
+	require.NoError(t, err)
+	require.Len(t, report.DanglingDocuments, 1)
+	assert.Zero(t, report.Repaired)
+	assert.Zero(t, report.Failed)
+
+	released, err := repo.FindByID(ctx, doc.ID)
+	require.NoError(t, err, "the released document is kept")
+	assert.Equal(t, model.StatusAvailable, released.Status)
+}
+
+// statHookStorage calls hook with the key of every Stat before looking it up.
+type statHookStorage struct {
+	storage.Storage
+	hook func(key string)
+}
+
+func (s *statHookStorage) Stat(ctx context.Context, key string) (storage.ObjectInfo, error) {
+	if s.hook != nil {
+		s.hook(key)
+	}
+	return s.Storage.Stat(ctx, key)
+}
+
+func TestNewReconcileMetrics_Reregister(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	first, err := NewReconcileMetrics(reg)
+	require.NoError(t, err)
+	second, err := NewReconcileMetrics(reg)
+	require.NoError(t, err)
+	assert.Same(t, first.orphans, second.orphans)
+}
@@ -0,0 +1,420 @@
+package storage
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"docapi/internal/config"
+)
+
+const (
+	CompressionZstd = "zstd"
+	CompressionGzip = "gzip"
+
+	metaCompression      = "docapi-compression"
+	metaUncompressedSize = "docapi-uncompressed-size"
+)
+
+// CompressionOptions configure the compression decorator.
+type CompressionOptions struct {
+	// Algorithm is CompressionZstd or CompressionGzip.
+	Algorithm string
+	// MinSize is the smallest object, in bytes, worth compressing.
+	MinSize int64
+	// ContentTypes are the media types to compress; "type/*" matches a whole top-level type.
+	ContentTypes []string
+}
+
+// CompressionOptionsFromConfig converts configuration into CompressionOptions.
+func CompressionOptionsFromConfig(cfg config.CompressionConfig) CompressionOptions {
+	var types []string
+	for _, t := range strings.Split(cfg.ContentTypes, ",") {
+		if t = strings.TrimSpace(strings.ToLower(t)); t != "" {
+			types = append(types, t)
+		}
+	}
+	return CompressionOptions{Algorithm: cfg.Algorithm, MinSize: cfg.MinSize, ContentTypes: types}
+}
+
+// CompressionMetrics holds Prometheus counters describing compression effectiveness.
+type CompressionMetrics struct {
+	objects    *prometheus.CounterVec
+	bytesIn    *prometheus.CounterVec
+	bytesOut   *prometheus.CounterVec
+	bytesSaved *prometheus.CounterVec
+}
+
+// NewCompressionMetrics creates and registers the compression counters. Registering twice on the same
+// registerer reuses the existing collectors.
+func NewCompressionMetrics(reg prometheus.Registerer) (*CompressionMetrics, error) {
+	newCounter := func(name, help string) (*prometheus.CounterVec, error) {
+		c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, []string{"algorithm"})
+		if err := reg.Register(c); err != nil {
+			var are prometheus.AlreadyRegisteredError
+			if !errors.As(err, &are) {
+				return nil, err
+			}
+			return are.ExistingCollector.(*prometheus.CounterVec), nil
+		}
+		return c, nil
+	}
+
+	var m CompressionMetrics
+	var err error
+	if m.objects, err = newCounter("storage_compressed_objects_total", "Number of objects stored compressed."); err != nil {
+		return nil, err
+	}
+	if m.bytesIn, err = newCounter("storage_compression_input_bytes_total", "Uncompressed bytes of objects stored compressed."); err != nil {
+		return nil, err
+	}
+	if m.bytesOut, err = newCounter("storage_compression_output_bytes_total", "Compressed bytes written for objects stored compressed."); err != nil {
+		return nil, err
+	}
+	if m.bytesSaved, err = newCounter("storage_compression_saved_bytes_total", "Bytes saved by compression (input minus output, when positive)."); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (m *CompressionMetrics) observe(algorithm string, in, out int64) {
+	if m == nil {
+		return
+	}
+	m.objects.WithLabelValues(algorithm).Inc()
+	m.bytesIn.WithLabelValues(algorithm).Add(float64(in))
+	m.bytesOut.WithLabelValues(algorithm).Add(float64(out))
+	if in > out {
+		m.bytesSaved.WithLabelValues(algorithm).Add(float64(in - out))
+	}
+}
+
+// compressedStorage is a Storage decorator that compresses compressible content before storing it.
+// Objects are tagged in metadata, so uncompressed objects (or ones written with another algorithm)
+// are always read back correctly.
+type compressedStorage struct {
+	inner   Storage
+	opts    CompressionOptions
+	metrics *CompressionMetrics
+}
+
+// NewCompressed wraps inner with transparent compression. metrics may be nil.
+func NewCompressed(inner Storage, opts CompressionOptions, metrics *CompressionMetrics) (Storage, error) {
+	if inner == nil {
+		return nil, fmt.Errorf("inner storage is required")
+	}
+	switch opts.Algorithm {
+	case CompressionZstd, CompressionGzip:
+	default:
+		return nil, fmt.Errorf("unsupported compression algorithm %q", opts.Algorithm)
+	}
+	return &compressedStorage{inner: inner, opts: opts, metrics: metrics}, nil
+}
+
+// Put compresses the stream on the fly when the object qualifies. Objects of unknown size are
+// stored as-is, since their size cannot be checked against the threshold up front.
+func (c *compressedStorage) Put(ctx context.Context, key string, r io.Reader, opt PutObjectOptions) (ObjectInfo, error) {
+	if opt.Size < 0 || opt.Size < c.opts.MinSize || !c.compressible(opt.ContentType) {
+		info, err := c.inner.Put(ctx, key, r, PutObjectOptions{
+			Size:        opt.Size,
+			ContentType: opt.ContentType,
+			Metadata:    stripCompressionMetadata(opt.Metadata),
+		})
+		if err != nil {
+			return ObjectInfo{}, err
+		}
+		info.Metadata = stripCompressionMetadata(info.Metadata)
+		return info, nil
+	}
+
+	md := stripCompressionMetadata(opt.Metadata)
+	if md == nil {
+		md = make(map[string]string, 2)
+	}
+	md[metaCompression] = c.opts.Algorithm
+	md[metaUncompressedSize] = strconv.FormatInt(opt.Size, 10)
+
+	pr, pw := io.Pipe()
+	counted := &countingReader{r: io.LimitReader(r, opt.Size)}
+	go func() {
+		pw.CloseWithError(c.compress(pw, counted))
+	}()
+
+	info, err := c.inner.Put(ctx, key, pr, PutObjectOptions{
+		Size:        -1,
+		ContentType: opt.ContentType,
+		Metadata:    md,
+	})
+	// Unblock the compressor if the backend stopped reading early.
+	pr.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	if counted.n != opt.Size {
+		_ = c.inner.Delete(ctx, key)
+		return ObjectInfo{}, fmt.Errorf("short read: got %d bytes, want %d", counted.n, opt.Size)
+	}
+
+	c.metrics.observe(c.opts.Algorithm, opt.Size, info.Size)
+	if info.StoredSize == 0 {
+		info.StoredSize = info.Size
+	}
+	info.Size = opt.Size
+	info.Metadata = stripCompressionMetadata(info.Metadata)
+	return info, nil
+}
+
+// Get returns a reader that decompresses the object while streaming.
+func (c *compressedStorage) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	rc, info, err := c.inner.Get(ctx, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	return c.decompress(rc, info)
+}
+
+// GetRange serves ranges of compressed objects by decompressing from the start and discarding the
+// prefix, since compressed streams are not seekable. Uncompressed objects use native range reads.
+func (c *compressedStorage) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, ObjectInfo, error) {
+	info, err := c.inner.Stat(ctx, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	if info.Metadata[metaCompression] == "" {
+		rc, info, err := c.inner.GetRange(ctx, key, offset, length)
+		if err != nil {
+			return nil, ObjectInfo{}, err
+		}
+		info.Metadata = stripCompressionMetadata(info.Metadata)
+		return rc, info, nil
+	}
+
+	plainInfo, err := uncompressedInfo(info)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	end, err := rangeEnd(offset, length, plainInfo.Size)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	if end == offset {
+		return io.NopCloser(strings.NewReader("")), plainInfo, nil
+	}
+
+	rc, info, err := c.inner.Get(ctx, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	dr, info, err := c.decompress(rc, info)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	if _, err := io.CopyN(io.Discard, dr, offset); err != nil {
+		dr.Close()
+		return nil, ObjectInfo{}, err
+	}
+	return &readCloser{Reader: io.LimitReader(dr, end-offset), Closer: dr}, info, nil
+}
+
+// Stat returns the info of the original content of the object.
+func (c *compressedStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	info, err := c.inner.Stat(ctx, key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return originalInfo(info)
+}
+
+// Exists delegates to the inner storage.
+func (c *compressedStorage) Exists(ctx context.Context, key string) (bool, error) {
+	return c.inner.Exists(ctx, key)
+}
+
+// List delegates to the inner storage; listed sizes are the compressed sizes.
+func (c *compressedStorage) List(ctx context.Context, opts ListOptions) (ListPage, error) {
+	return c.inner.List(ctx, opts)
+}
+
+// Copy copies the object as stored, compressed or not.
+func (c *compressedStorage) Copy(ctx context.Context, src, dst string) (ObjectInfo, error) {
+	info, err := c.inner.Copy(ctx, src, dst)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return originalInfo(info)
+}
+
+// originalInfo converts the info of a stored object to describe its original content.
+func originalInfo(info ObjectInfo) (ObjectInfo, error) {
+	if info.Metadata[metaCompression] == "" {
+		info.Metadata = stripCompressionMetadata(info.Metadata)
+		return info, nil
+	}
+	return uncompressedInfo(info)
+}
+
+// UpdateMetadata replaces user metadata while keeping the compression metadata intact.
+func (c *compressedStorage) UpdateMetadata(ctx context.Context, key string, metadata map[string]string) error {
+	mu, ok := c.inner.(MetadataUpdater)
+	if !ok {
+		return fmt.Errorf("inner storage does not support metadata updates")
+	}
+	info, err := c.inner.Stat(ctx, key)
+	if err != nil {
+		return err
+	}
+	md := stripCompressionMetadata(metadata)
+	if md == nil {
+		md = make(map[string]string, 2)
+	}
+	for _, k := range []string{metaCompression, metaUncompressedSize} {
+		if v, ok := info.Metadata[k]; ok {
+			md[k] = v
+		}
+	}
+	return mu.UpdateMetadata(ctx, key, md)
+}
+
+// Delete removes an object by key.
+func (c *compressedStorage) Delete(ctx context.Context, key string) error {
+	return c.inner.Delete(ctx, key)
+}
+
+// PresignGet presigns uncompressed objects through the inner storage. Compressed objects return
+// ErrPresignUnsupported, since a presigned URL would serve the compressed bytes.
+func (c *compressedStorage) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	info, err := c.inner.Stat(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if info.Metadata[metaCompression] != "" {
+		return "", fmt.Errorf("object %q is compressed: %w", key, ErrPresignUnsupported)
+	}
+	return c.inner.PresignGet(ctx, key, expiry)
+}
+
+func (c *compressedStorage) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range c.opts.ContentTypes {
+		if t == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(t, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *compressedStorage) compress(w io.Writer, r io.Reader) error {
+	var zw io.WriteCloser
+	switch c.opts.Algorithm {
+	case CompressionZstd:
+		enc, err := zstd.NewWriter(w)
+		if err != nil {
+			return err
+		}
+		zw = enc
+	default:
+		zw = gzip.NewWriter(w)
+	}
+	if _, err := io.Copy(zw, r); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// decompress wraps rc in a decompressing reader if info marks the object as compressed.
+func (c *compressedStorage) decompress(rc io.ReadCloser, info ObjectInfo) (io.ReadCloser, ObjectInfo, error) {
+	algorithm := info.Metadata[metaCompression]
+	if algorithm == "" {
+		info.Metadata = stripCompressionMetadata(info.Metadata)
+		return rc, info, nil
+	}
+	plainInfo, err := uncompressedInfo(info)
+	if err != nil {
+		rc.Close()
+		return nil, ObjectInfo{}, err
+	}
+
+	var dr io.ReadCloser
+	switch algorithm {
+	case CompressionZstd:
+		dec, err := zstd.NewReader(rc)
+		if err != nil {
+			rc.Close()
+			return nil, ObjectInfo{}, err
+		}
+		dr = &readCloser{Reader: dec, Closer: closerFunc(func() error {
+			dec.Close()
+			return rc.Close()
+		})}
+	case CompressionGzip:
+		gr, err := gzip.NewReader(rc)
+		if err != nil {
+			rc.Close()
+			return nil, ObjectInfo{}, err
+		}
+		dr = &readCloser{Reader: gr, Closer: rc}
+	default:
+		rc.Close()
+		return nil, ObjectInfo{}, fmt.Errorf("object %q: unsupported compression %q", info.Key, algorithm)
+	}
+
+	return dr, plainInfo, nil
+}
+
+// uncompressedInfo converts the info of a compressed object into the info of its original content.
+func uncompressedInfo(info ObjectInfo) (ObjectInfo, error) {
+	size, err := strconv.ParseInt(info.Metadata[metaUncompressedSize], 10, 64)
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("object %q: invalid uncompressed size: %w", info.Key, err)
+	}
+	if info.StoredSize == 0 {
+		info.StoredSize = info.Size
+	}
+	info.Size = size
+	info.Metadata = stripCompressionMetadata(info.Metadata)
+	return info, nil
+}
+
+// stripCompressionMetadata returns a lower-cased copy of md without the compression bookkeeping keys.
+func stripCompressionMetadata(md map[string]string) map[string]string {
+	out := normalizeMetadata(md)
+	delete(out, metaCompression)
+	delete(out, metaUncompressedSize)
+	return out
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"docapi/internal/config"
+)
+
+func newTestCompressed(t *testing.T, algorithm string, metrics *CompressionMetrics) (Storage, Storage) {
+	t.Helper()
+	inner := NewMemory()
+	c, err := NewCompressed(inner, CompressionOptions{
+		Algorithm:    algorithm,
+		MinSize:      1024,
+		ContentTypes: []string{"text/*", "application/json"},
+	}, metrics)
+	require.NoError(t, err)
+	return c, inner
+}
+
+func TestCompressedStorage_Put(t *testing.T) {
+	ctx := context.Background()
+	text := bytes.Repeat([]byte("lorem ipsum dolor sit amet "), 1000)
+
+	tests := []struct {
+		name           string
+		content        []byte
+		size           int64
+		contentType    string
+		wantCompressed bool
+	}{
+		{name: "compressible text", content: text, size: int64(len(text)), contentType: "text/plain; charset=utf-8", wantCompressed: true},
+		{name: "exact type", content: text, size: int64(len(text)), contentType: "application/json", wantCompressed: true},
+		{name: "below min size", content: text[:100], size: 100, contentType: "text/plain"},
+		{name: "unlisted type", content: text, size: int64(len(text)), contentType: "image/png"},
+		{name: "unknown size", content: text, size: -1, contentType: "text/plain"},
+	}
+
+	for _, algorithm := range []string{CompressionZstd, CompressionGzip} {
+		for _, tt := range tests {
+			t.Run(algorithm+"/"+tt.name, func(t *testing.T) {
+				c, inner := newTestCompressed(t, algorithm, nil)
+
+				info, err := c.Put(ctx, "doc", bytes.NewReader(tt.content), PutObjectOptions{
+					Size:        tt.size,
+					ContentType: tt.contentType,
+					Metadata:    map[string]string{"original-filename": "a.txt"},
+				})
+				require.NoError(t, err)
+				assert.Equal(t, int64(len(tt.content)), info.Size)
+				assert.Equal(t, map[string]string{"original-filename": "a.txt"}, info.Metadata)
+
+				rc, raw, err := inner.Get(ctx, "doc")
+				require.NoError(t, err)
+				stored := readAll(t, rc)
+				assert.Equal(t, int64(len(stored)), info.StoredSize)
+				if tt.wantCompressed {
+					assert.Equal(t, algorithm, raw.Metadata[metaCompression])
+					assert.Less(t, len(stored), len(tt.content))
+				} else {
+					assert.NotContains(t, raw.Metadata, metaCompression)
+					assert.Equal(t, tt.content, stored)
+				}
+
+				rc, got, err := c.Get(ctx, "doc")
+				require.NoError(t, err)
+				assert.Equal(t, tt.content, readAll(t, rc))
+				assert.Equal(t, int64(len(tt.content)), got.Size)
+				assert.Equal(t, info.StoredSize, got.StoredSize)
+				assert.Equal(t, map[string]string{"original-filename": "a.txt"}, got.Metadata)
+			})
+		}
+	}
+}
+
+func TestCompressedStorage_ReadsOtherAlgorithm(t *testing.T) {
+	ctx := context.Background()
+	content := bytes.Repeat([]byte("0123456789"), 500)
+
+	gz, inner := newTestCompressed(t, CompressionGzip, nil)
+	_, err := gz.Put(ctx, "doc", bytes.NewReader(content), PutObjectOptions{Size: int64(len(content)), ContentType: "text/plain"})
+	require.NoError(t, err)
+
+	zs, err := NewCompressed(inner, CompressionOptions{Algorithm: CompressionZstd}, nil)
+	require.NoError(t, err)
+	rc, _, err := zs.Get(ctx, "doc")
+	require.NoError(t, err)
+	assert.Equal(t, content, readAll(t, rc))
+
+	rc, info, err := zs.GetRange(ctx, "doc", 4995, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("56789"), readAll(t, rc))
+	assert.Equal(t, int64(len(content)), info.Size)
+}
+
+func TestCompressedStorage_OverEncrypted(t *testing.T) {
+	ctx := context.Background()
+	enc, inner, _ := newTestEncrypted(t)
+	c, err := NewCompressed(enc, CompressionOptions{Algorithm: CompressionZstd, ContentTypes: []string{"text/*"}}, nil)
+	require.NoError(t, err)
+	content := bytes.Repeat([]byte("confidential "), 10000)
+
+	info, err := c.Put(ctx, "doc", bytes.NewReader(content), PutObjectOptions{Size: int64(len(content)), ContentType: "text/plain"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), info.Size)
+
+	rc, raw, err := inner.Get(ctx, "doc")
+	require.NoError(t, err)
+	stored := readAll(t, rc)
+	assert.Equal(t, int64(len(stored)), info.StoredSize)
+	assert.Equal(t, raw.Size, info.StoredSize)
+	assert.Less(t, info.StoredSize, info.Size)
+
+	st, err := c.Stat(ctx, "doc")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), st.Size)
+	assert.Equal(t, raw.Size, st.StoredSize, "the stored size is the size of the ciphertext")
+	rc, ranged, err := c.GetRange(ctx, "doc", 0, 0)
+	require.NoError(t, err)
+	rc.Close()
+	assert.Equal(t, raw.Size, ranged.StoredSize)
+
+	rc, got, err := c.Get(ctx, "doc")
+	require.NoError(t, err)
+	assert.Equal(t, content, readAll(t, rc))
+	assert.Equal(t, raw.Size, got.StoredSize)
+}
+
+func TestCompressedStorage_UpdateMetadataKeepsCompression(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestCompressed(t, CompressionZstd, nil)
+	content := bytes.Repeat([]byte("abc"), 1000)
+	_, err := c.Put(ctx, "doc", bytes.NewReader(content), PutObjectOptions{Size: int64(len(content)), ContentType: "text/plain"})
+	require.NoError(t, err)
+
+	require.NoError(t, c.(MetadataUpdater).UpdateMetadata(ctx, "doc", map[string]string{"k": "v"}))
+
+	rc, info, err := c.Get(ctx, "doc")
+	require.NoError(t, err)
+	assert.Equal(t, content, readAll(t, rc))
+	assert.Equal(t, map[string]string{"k": "v"}, info.Metadata)
+}
+
+func TestCompressedStorage_PresignGet(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestCompressed(t, CompressionZstd, nil)
+	text := bytes.Repeat([]byte("abc"), 1000)
+	_, err := c.Put(ctx, "compressed", bytes.NewReader(text), PutObjectOptions{Size: int64(len(text)), ContentType: "text/plain"})
+	require.NoError(t, err)
+	_, err = c.Put(ctx, "plain", bytes.NewReader(text), PutObjectOptions{Size: int64(len(text)), ContentType: "image/png"})
+	require.NoError(t, err)
+
+	_, err = c.PresignGet(ctx, "compressed", time.Minute)
+	assert.ErrorIs(t, err, ErrPresignUnsupported, "compressed bytes must not be handed out")
+
+	url, err := c.PresignGet(ctx, "plain", time.Minute)
+	require.NoError(t, err)
+	assert.Contains(t, url, "plain")
+
+	_, err = c.PresignGet(ctx, "missing", time.Minute)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestCompressedStorage_Metrics(t *testing.T) {
+	ctx := context.Background()
+	reg := prometheus.NewRegistry()
+	m, err := NewCompressionMetrics(reg)
+	require.NoError(t, err)
+	again, err := NewCompressionMetrics(reg)
+	require.NoError(t, err)
+	assert.Same(t, m.objects, again.objects)
+
+	c, _ := newTestCompressed(t, CompressionZstd, m)
+	content := bytes.Repeat([]byte("x"), 4096)
+	info, err := c.Put(ctx, "doc", bytes.NewReader(content), PutObjectOptions{Size: int64(len(content)), ContentType: "text/plain"})
+	require.NoError(t, err)
+	_, err = c.Put(ctx, "small", bytes.NewReader(content[:10]), PutObjectOptions{Size: 10, ContentType: "text/plain"})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.objects.WithLabelValues(CompressionZstd)))
+	assert.Equal(t, 4096.0, testutil.ToFloat64(m.bytesIn.WithLabelValues(CompressionZstd)))
+	assert.Equal(t, float64(info.StoredSize), testutil.ToFloat64(m.bytesOut.WithLabelValues(CompressionZstd)))
+	assert.Equal(t, float64(4096-info.StoredSize), testutil.ToFloat64(m.bytesSaved.WithLabelValues(CompressionZstd)))
+}
+
+func TestCompressionOptionsFromConfig(t *testing.T) {
+	opts := CompressionOptionsFromConfig(config.CompressionConfig{
+		Algorithm:    CompressionGzip,
+		MinSize:      10,
+		ContentTypes: " Text/* , application/json,,",
+	})
+	assert.Equal(t, CompressionOptions{
+		Algorithm:    CompressionGzip,
+		MinSize:      10,
+		ContentTypes: []string{"text/*", "application/json"},
+	}, opts)
+
+	_, err := NewCompressed(NewMemory(), CompressionOptions{Algorithm: "brotli"}, nil)
+	assert.Error(t, err)
+}
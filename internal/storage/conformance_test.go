@@ -0,0 +1,51 @@
+package storage_test
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"docapi/internal/storage"
+	"docapi/internal/storage/storagetest"
+)
+
+func TestMemoryStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return storage.NewMemory()
+	})
+}
+
+func TestEncryptedStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		key := make([]byte, 32)
+		_, err := rand.Read(key)
+		require.NoError(t, err)
+		kr, err := storage.NewKeyRing("k1", map[string][]byte{"k1": key})
+		require.NoError(t, err)
+		enc, err := storage.NewEncrypted(storage.NewMemory(), kr)
+		require.NoError(t, err)
+		return enc
+	})
+}
+
+func TestCompressedStorage_Conformance(t *testing.T) {
+	for _, algorithm := range []string{storage.CompressionZstd, storage.CompressionGzip} {
+		t.Run(algorithm, func(t *testing.T) {
+			storagetest.Run(t, func(t *testing.T) storage.Storage {
+				c, err := storage.NewCompressed(storage.NewMemory(), storage.CompressionOptions{
+					Algorithm:    algorithm,
+					ContentTypes: []string{"text/*", "application/octet-stream"},
+				}, nil)
+				require.NoError(t, err)
+				return c
+			})
+		})
+	}
+}
+
+func TestSwappableStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return storage.NewSwappable(storage.NewMemory())
+	})
+}
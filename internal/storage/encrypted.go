@@ -0,0 +1,521 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Objects written by encryptedStorage use a chunked AES-256-GCM format so they can be decrypted
+// as a stream and read by range without downloading the whole object:
+//
+//   - every object gets a random 256-bit data key (DEK) and a random 7-byte nonce prefix;
+//   - the plaintext is split into 64 KiB chunks, each sealed independently, so the stored object is
+//     the concatenation of (chunk || 16-byte tag) segments;
+//   - the nonce of chunk i is prefix || uint32(i) || lastFlag, which authenticates chunk order and
+//     makes truncation at a chunk boundary detectable;
+//   - the DEK is wrapped with a master key from the KeyRing and stored, together with the master key
+//     ID and nonce prefix, in the object's metadata. Rotating master keys only rewrites metadata.
+const (
+	encScheme          = "aes256gcm-chunked-v1"
+	encChunkSize       = 64 * 1024
+	encTagSize         = 16
+	encSegmentSize     = encChunkSize + encTagSize
+	encNoncePrefixSize = 7
+
+	metaEncScheme = "docapi-enc"
+	metaEncKeyID  = "docapi-enc-key-id"
+	metaEncDEK    = "docapi-enc-dek"
+	metaEncNonce  = "docapi-enc-nonce"
+)
+
+// ErrPresignUnsupported is returned by storages that cannot hand out direct download URLs,
+// e.g. because the stored bytes are encrypted and must be decrypted by the application.
+var ErrPresignUnsupported = errors.New("presigned urls are not supported by this storage")
+
+// encryptedStorage is a Storage decorator that encrypts object content at rest.
+// Objects without encryption metadata (written before encryption was enabled) are passed through.
+type encryptedStorage struct {
+	inner Storage
+	keys  *KeyRing
+}
+
+// NewEncrypted wraps inner so that all content is encrypted with per-object data keys
+// wrapped by the current master key in keys.
+func NewEncrypted(inner Storage, keys *KeyRing) (Storage, error) {
+	if inner == nil {
+		return nil, fmt.Errorf("inner storage is required")
+	}
+	if keys == nil {
+		return nil, fmt.Errorf("key ring is required")
+	}
+	return &encryptedStorage{inner: inner, keys: keys}, nil
+}
+
+// Put encrypts the stream chunk by chunk while uploading; the plaintext is never buffered whole.
+func (e *encryptedStorage) Put(ctx context.Context, key string, r io.Reader, opt PutObjectOptions) (ObjectInfo, error) {
+	dek := make([]byte, masterKeySize)
+	if _, err := rand.Read(dek); err != nil {
+		return ObjectInfo{}, fmt.Errorf("generate data key: %w", err)
+	}
+	prefix := make([]byte, encNoncePrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return ObjectInfo{}, fmt.Errorf("generate nonce: %w", err)
+	}
+	keyID, wrapped, err := e.keys.wrap(dek)
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("wrap data key: %w", err)
+	}
+	aead, err := newGCM(dek)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	md := stripEncMetadata(opt.Metadata)
+	if md == nil {
+		md = make(map[string]string, 4)
+	}
+	md[metaEncScheme] = encScheme
+	md[metaEncKeyID] = keyID
+	md[metaEncDEK] = base64.StdEncoding.EncodeToString(wrapped)
+	md[metaEncNonce] = base64.StdEncoding.EncodeToString(prefix)
+
+	size := int64(-1)
+	if opt.Size >= 0 {
+		size = encryptedSize(opt.Size)
+	}
+	er := &encryptReader{
+		src:    bufio.NewReaderSize(r, encChunkSize),
+		aead:   aead,
+		prefix: prefix,
+		buf:    make([]byte, encChunkSize),
+	}
+	info, err := e.inner.Put(ctx, key, er, PutObjectOptions{
+		Size:        size,
+		ContentType: opt.ContentType,
+		Metadata:    md,
+	})
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	if info.StoredSize == 0 {
+		info.StoredSize = info.Size
+	}
+	info.Size = er.plain
+	info.Metadata = stripEncMetadata(info.Metadata)
+	return info, nil
+}
+
+// Get returns a reader that decrypts and authenticates the object while streaming.
+func (e *encryptedStorage) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	rc, info, err := e.inner.Get(ctx, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	if info.Metadata[metaEncScheme] == "" {
+		return rc, info, nil
+	}
+	dr, plainInfo, err := e.decryptFrom(rc, info, 0, -1)
+	if err != nil {
+		rc.Close()
+		return nil, ObjectInfo{}, err
+	}
+	return dr, plainInfo, nil
+}
+
+// GetRange fetches only the encrypted chunks covering the requested plaintext range.
+func (e *encryptedStorage) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, ObjectInfo, error) {
+	if offset < 0 {
+		return nil, ObjectInfo{}, fmt.Errorf("invalid range: negative offset %d", offset)
+	}
+
+	first := offset / encChunkSize
+	ctOffset, ctLength := first*encSegmentSize, int64(-1)
+	switch {
+	case length == 0:
+		ctOffset, ctLength = 0, 0
+	case length > 0:
+		last := (offset + length - 1) / encChunkSize
+		ctLength = (last - first + 1) * encSegmentSize
+	}
+
+	rc, info, err := e.inner.GetRange(ctx, key, ctOffset, ctLength)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	if info.Metadata[metaEncScheme] == "" {
+		// Plaintext object: the ciphertext offsets computed above do not apply.
+		rc.Close()
+		return e.inner.GetRange(ctx, key, offset, length)
+	}
+	dr, plainInfo, err := e.decryptFrom(rc, info, offset, length)
+	if err != nil {
+		rc.Close()
+		return nil, ObjectInfo{}, err
+	}
+	return dr, plainInfo, nil
+}
+
+// Stat returns the info of the plaintext object.
+func (e *encryptedStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	info, err := e.inner.Stat(ctx, key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return plaintextInfo(info)
+}
+
+// Exists delegates to the inner storage.
+func (e *encryptedStorage) Exists(ctx context.Context, key string) (bool, error) {
+	return e.inner.Exists(ctx, key)
+}
+
+// List delegates to the inner storage; listed sizes are the encrypted sizes.
+func (e *encryptedStorage) List(ctx context.Context, opts ListOptions) (ListPage, error) {
+	return e.inner.List(ctx, opts)
+}
+
+// Copy copies the ciphertext together with its wrapped data key. Chunks are not bound to the
+// object key, so the copy decrypts as is.
+func (e *encryptedStorage) Copy(ctx context.Context, src, dst string) (ObjectInfo, error) {
+	info, err := e.inner.Copy(ctx, src, dst)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return plaintextInfo(info)
+}
+
+// UpdateMetadata replaces user metadata while keeping the encryption metadata intact.
+func (e *encryptedStorage) UpdateMetadata(ctx context.Context, key string, metadata map[string]string) error {
+	mu, ok := e.inner.(MetadataUpdater)
+	if !ok {
+		return fmt.Errorf("inner storage does not support metadata updates")
+	}
+	info, err := e.inner.Stat(ctx, key)
+	if err != nil {
+		return err
+	}
+	md := stripEncMetadata(metadata)
+	if md == nil {
+		md = make(map[string]string, 4)
+	}
+	for k, v := range info.Metadata {
+		if strings.HasPrefix(k, metaEncScheme) {
+			md[k] = v
+		}
+	}
+	return mu.UpdateMetadata(ctx, key, md)
+}
+
+// Delete removes an object by key.
+func (e *encryptedStorage) Delete(ctx context.Context, key string) error {
+	return e.inner.Delete(ctx, key)
+}
+
+// PresignGet is not supported: a presigned URL would hand out ciphertext.
+func (e *encryptedStorage) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return "", ErrPresignUnsupported
+}
+
+// decryptFrom wraps rc, which must start at the chunk containing offset, in a decrypting reader
+// and converts info to describe the plaintext object.
+func (e *encryptedStorage) decryptFrom(rc io.ReadCloser, info ObjectInfo, offset, length int64) (io.ReadCloser, ObjectInfo, error) {
+	aead, prefix, err := e.openObjectKey(info.Metadata)
+	if err != nil {
+		return nil, ObjectInfo{}, fmt.Errorf("object %q: %w", info.Key, err)
+	}
+	plain, segments, err := plaintextSize(info.Size)
+	if err != nil {
+		return nil, ObjectInfo{}, fmt.Errorf("object %q: %w", info.Key, err)
+	}
+	if offset > plain {
+		return nil, ObjectInfo{}, fmt.Errorf("invalid range: offset %d outside object of %d bytes", offset, plain)
+	}
+
+	first := offset / encChunkSize
+	remaining := length
+	if length == 0 {
+		// Info-only request: nothing to decrypt.
+		rc.Close()
+		rc = io.NopCloser(strings.NewReader(""))
+	}
+
+	if info.StoredSize == 0 {
+		info.StoredSize = info.Size
+	}
+	info.Size = plain
+	info.Metadata = stripEncMetadata(info.Metadata)
+	return &decryptReader{
+		src:       rc,
+		aead:      aead,
+		prefix:    prefix,
+		counter:   first,
+		lastChunk: segments - 1,
+		skip:      offset - first*encChunkSize,
+		remaining: remaining,
+		buf:       make([]byte, encSegmentSize),
+	}, info, nil
+}
+
+// openObjectKey unwraps the data key recorded in an object's metadata.
+func (e *encryptedStorage) openObjectKey(md map[string]string) (cipher.AEAD, []byte, error) {
+	if s := md[metaEncScheme]; s != encScheme {
+		return nil, nil, fmt.Errorf("unsupported encryption scheme %q", s)
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(md[metaEncDEK])
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode wrapped data key: %w", err)
+	}
+	prefix, err := base64.StdEncoding.DecodeString(md[metaEncNonce])
+	if err != nil || len(prefix) != encNoncePrefixSize {
+		return nil, nil, fmt.Errorf("invalid nonce prefix")
+	}
+	dek, err := e.keys.unwrap(md[metaEncKeyID], wrapped)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := newGCM(dek)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, prefix, nil
+}
+
+// RewrapKey re-wraps the data key of one object with the current master key of keys.
+// Only the object's metadata is rewritten; the encrypted payload is left untouched.
+// inner must be the storage underneath the encryption decorator and implement MetadataUpdater.
+// It reports whether the object was changed; plaintext objects and objects already wrapped with
+// the current key are skipped.
+func RewrapKey(ctx context.Context, inner Storage, keys *KeyRing, key string) (bool, error) {
+	mu, ok := inner.(MetadataUpdater)
+	if !ok {
+		return false, fmt.Errorf("storage does not support metadata updates")
+	}
+	info, err := inner.Stat(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if info.Metadata[metaEncScheme] == "" || info.Metadata[metaEncKeyID] == keys.CurrentKeyID() {
+		return false, nil
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(info.Metadata[metaEncDEK])
+	if err != nil {
+		return false, fmt.Errorf("decode wrapped data key: %w", err)
+	}
+	dek, err := keys.unwrap(info.Metadata[metaEncKeyID], wrapped)
+	if err != nil {
+		return false, err
+	}
+	keyID, rewrapped, err := keys.wrap(dek)
+	if err != nil {
+		return false, err
+	}
+
+	md := make(map[string]string, len(info.Metadata))
+	for k, v := range info.Metadata {
+		md[k] = v
+	}
+	md[metaEncKeyID] = keyID
+	md[metaEncDEK] = base64.StdEncoding.EncodeToString(rewrapped)
+	if err := mu.UpdateMetadata(ctx, key, md); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// EncryptionKeyID returns the ID of the master key wrapping the object's data key, or "" if the
+// object is stored in plaintext. s must be the storage underneath the encryption decorator.
+func EncryptionKeyID(ctx context.Context, s Storage, key string) (string, error) {
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return info.Metadata[metaEncKeyID], nil
+}
+
+// plaintextInfo converts the info of a stored object to describe its plaintext.
+func plaintextInfo(info ObjectInfo) (ObjectInfo, error) {
+	if info.Metadata[metaEncScheme] == "" {
+		return info, nil
+	}
+	plain, _, err := plaintextSize(info.Size)
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("object %q: %w", info.Key, err)
+	}
+	if info.StoredSize == 0 {
+		info.StoredSize = info.Size
+	}
+	info.Size = plain
+	info.Metadata = stripEncMetadata(info.Metadata)
+	return info, nil
+}
+
+// stripEncMetadata returns a lower-cased copy of md without the encryption bookkeeping keys.
+func stripEncMetadata(md map[string]string) map[string]string {
+	out := normalizeMetadata(md)
+	for k := range out {
+		if strings.HasPrefix(k, metaEncScheme) {
+			delete(out, k)
+		}
+	}
+	return out
+}
+
+// encryptedSize returns the stored size of a plaintext of n bytes. Empty input still produces
+// one (empty) final chunk so the end of the stream is authenticated.
+func encryptedSize(n int64) int64 {
+	chunks := (n + encChunkSize - 1) / encChunkSize
+	if chunks == 0 {
+		chunks = 1
+	}
+	return n + chunks*encTagSize
+}
+
+// plaintextSize is the inverse of encryptedSize; it also returns the number of segments.
+func plaintextSize(stored int64) (int64, int64, error) {
+	if stored < encTagSize {
+		return 0, 0, fmt.Errorf("encrypted object truncated")
+	}
+	segments := (stored + encSegmentSize - 1) / encSegmentSize
+	if stored-(segments-1)*encSegmentSize < encTagSize {
+		return 0, 0, fmt.Errorf("encrypted object truncated")
+	}
+	return stored - segments*encTagSize, segments, nil
+}
+
+func chunkNonce(prefix []byte, counter int64, last bool) []byte {
+	nonce := make([]byte, encNoncePrefixSize+5)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[encNoncePrefixSize:], uint32(counter))
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// encryptReader turns a plaintext stream into a stream of sealed segments.
+type encryptReader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter int64
+	buf     []byte
+	out     []byte
+	sealed  []byte
+	plain   int64
+	done    bool
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *encryptReader) next() error {
+	if r.counter > 1<<32-1 {
+		return fmt.Errorf("object too large to encrypt")
+	}
+	n, err := io.ReadFull(r.src, r.buf)
+	last := false
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		// A full chunk is the last one only if nothing follows it.
+		if _, perr := r.src.Peek(1); perr == io.EOF {
+			last = true
+		} else if perr != nil {
+			return perr
+		}
+	}
+	r.sealed = r.aead.Seal(r.sealed[:0], chunkNonce(r.prefix, r.counter, last), r.buf[:n], nil)
+	r.out = r.sealed
+	r.counter++
+	r.plain += int64(n)
+	r.done = last
+	return nil
+}
+
+// decryptReader verifies and decrypts sealed segments starting at chunk counter.
+type decryptReader struct {
+	src       io.ReadCloser
+	aead      cipher.AEAD
+	prefix    []byte
+	counter   int64
+	lastChunk int64
+	skip      int64 // plaintext bytes to drop from the first chunk
+	remaining int64 // plaintext bytes still to return; negative means unlimited
+	buf       []byte
+	out       []byte
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+	for len(r.out) == 0 {
+		if r.counter > r.lastChunk {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	if r.remaining >= 0 && int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	if r.remaining > 0 {
+		r.remaining -= int64(n)
+	}
+	return n, nil
+}
+
+func (r *decryptReader) next() error {
+	last := r.counter == r.lastChunk
+	n, err := io.ReadFull(r.src, r.buf)
+	switch {
+	case err == io.EOF:
+		return io.ErrUnexpectedEOF
+	case err == io.ErrUnexpectedEOF && !last:
+		return err
+	case err != nil && err != io.ErrUnexpectedEOF:
+		return err
+	}
+	plain, err := r.aead.Open(r.buf[:0], chunkNonce(r.prefix, r.counter, last), r.buf[:n], nil)
+	if err != nil {
+		return fmt.Errorf("decrypt chunk %d: %w", r.counter, err)
+	}
+	if r.skip > 0 {
+		plain = plain[r.skip:]
+		r.skip = 0
+	}
+	r.out = plain
+	r.counter++
+	return nil
+}
+
+func (r *decryptReader) Close() error {
+	return r.src.Close()
+}
@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"docapi/internal/config"
+)
+
+func newTestKey(t *testing.T) []byte {
+	t.Helper()
+	k := make([]byte, masterKeySize)
+	_, err := rand.Read(k)
+	require.NoError(t, err)
+	return k
+}
+
+func newTestEncrypted(t *testing.T) (Storage, Storage, map[string][]byte) {
+	t.Helper()
+	keys := map[string][]byte{"k1": newTestKey(t)}
+	kr, err := NewKeyRing("k1", keys)
+	require.NoError(t, err)
+	inner := NewMemory()
+	enc, err := NewEncrypted(inner, kr)
+	require.NoError(t, err)
+	return enc, inner, keys
+}
+
+func readAll(t *testing.T, rc io.ReadCloser) []byte {
+	t.Helper()
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return b
+}
+
+func TestEncryptedStorage_StoresCiphertext(t *testing.T) {
+	ctx := context.Background()
+	enc, inner, _ := newTestEncrypted(t)
+	content := bytes.Repeat([]byte("patient record "), 10000)
+
+	info, err := enc.Put(ctx, "doc", bytes.NewReader(content), PutObjectOptions{
+		Size:     int64(len(content)),
+		Metadata: map[string]string{"original-filename": "a.txt"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), info.Size)
+	assert.NotContains(t, info.Metadata, metaEncKeyID)
+
+	rc, raw, err := inner.Get(ctx, "doc")
+	require.NoError(t, err)
+	stored := readAll(t, rc)
+	assert.Equal(t, encryptedSize(int64(len(content))), raw.Size)
+	assert.Equal(t, raw.Size, info.StoredSize, "the stored size is the size of the ciphertext")
+	st, err := enc.Stat(ctx, "doc")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), st.Size)
+	assert.Equal(t, raw.Size, st.StoredSize)
+	assert.False(t, bytes.Contains(stored, []byte("patient record")), "payload must not contain plaintext")
+	assert.Equal(t, "k1", raw.Metadata[metaEncKeyID])
+	assert.Equal(t, encScheme, raw.Metadata[metaEncScheme])
+	assert.Equal(t, "a.txt", raw.Metadata["original-filename"])
+}
+
+func TestEncryptedStorage_ChunkBoundaries(t *testing.T) {
+	ctx := context.Background()
+	enc, _, _ := newTestEncrypted(t)
+
+	for _, n := range []int{0, 1, encChunkSize - 1, encChunkSize, encChunkSize + 1, 3 * encChunkSize} {
+		content := make([]byte, n)
+		_, _ = rand.Read(content)
+		for _, size := range []int64{int64(n), -1} {
+			_, err := enc.Put(ctx, "obj", bytes.NewReader(content), PutObjectOptions{Size: size})
+			require.NoError(t, err, "n=%d size=%d", n, size)
+
+			rc, info, err := enc.Get(ctx, "obj")
+			require.NoError(t, err)
+			assert.Equal(t, int64(n), info.Size)
+			assert.True(t, bytes.Equal(content, readAll(t, rc)), "n=%d size=%d", n, size)
+
+			// Range straddling the last chunk boundary.
+			if n > 2 {
+				rc, _, err := enc.GetRange(ctx, "obj", int64(n-2), 2)
+				require.NoError(t, err)
+				assert.Equal(t, content[n-2:], readAll(t, rc))
+			}
+		}
+	}
+}
+
+func TestEncryptedStorage_DetectsTampering(t *testing.T) {
+	ctx := context.Background()
+	enc, inner, _ := newTestEncrypted(t)
+	content := bytes.Repeat([]byte{7}, 2*encChunkSize+100)
+	_, err := enc.Put(ctx, "doc", bytes.NewReader(content), PutObjectOptions{Size: int64(len(content))})
+	require.NoError(t, err)
+
+	rc, raw, err := inner.Get(ctx, "doc")
+	require.NoError(t, err)
+	stored := readAll(t, rc)
+
+	t.Run("flipped byte", func(t *testing.T) {
+		bad := append([]byte(nil), stored...)
+		bad[encSegmentSize+10] ^= 0xff
+		_, err := inner.Put(ctx, "doc", bytes.NewReader(bad), PutObjectOptions{Size: int64(len(bad)), Metadata: raw.Metadata})
+		require.NoError(t, err)
+
+		rc, _, err := enc.Get(ctx, "doc")
+		require.NoError(t, err)
+		_, err = io.ReadAll(rc)
+		assert.Error(t, err)
+	})
+
+	t.Run("truncated at chunk boundary", func(t *testing.T) {
+		bad := stored[:2*encSegmentSize]
+		_, err := inner.Put(ctx, "doc", bytes.NewReader(bad), PutObjectOptions{Size: int64(len(bad)), Metadata: raw.Metadata})
+		require.NoError(t, err)
+
+		rc, _, err := enc.Get(ctx, "doc")
+		require.NoError(t, err)
+		_, err = io.ReadAll(rc)
+		assert.Error(t, err, "dropping the final chunk must not go unnoticed")
+	})
+}
+
+func TestEncryptedStorage_PlaintextPassthrough(t *testing.T) {
+	ctx := context.Background()
+	enc, inner, _ := newTestEncrypted(t)
+	_, err := inner.Put(ctx, "legacy", strings.NewReader("plain old data"), PutObjectOptions{Size: 14})
+	require.NoError(t, err)
+
+	rc, info, err := enc.Get(ctx, "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "plain old data", string(readAll(t, rc)))
+	assert.Equal(t, int64(14), info.Size)
+
+	rc, _, err = enc.GetRange(ctx, "legacy", 6, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(readAll(t, rc)))
+}
+
+func TestEncryptedStorage_PresignUnsupported(t *testing.T) {
+	enc, _, _ := newTestEncrypted(t)
+	_, err := enc.PresignGet(context.Background(), "doc", 0)
+	assert.ErrorIs(t, err, ErrPresignUnsupported)
+}
+
+func TestRewrapKey(t *testing.T) {
+	ctx := context.Background()
+	enc, inner, keys := newTestEncrypted(t)
+	content := bytes.Repeat([]byte("abc"), 50000)
+	_, err := enc.Put(ctx, "doc", bytes.NewReader(content), PutObjectOptions{Size: int64(len(content))})
+	require.NoError(t, err)
+
+	rc, before, err := inner.Get(ctx, "doc")
+	require.NoError(t, err)
+	payloadBefore := readAll(t, rc)
+
+	keys["k2"] = newTestKey(t)
+	rotated, err := NewKeyRing("k2", keys)
+	require.NoError(t, err)
+
+	changed, err := RewrapKey(ctx, inner, rotated, "doc")
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	changed, err = RewrapKey(ctx, inner, rotated, "doc")
+	require.NoError(t, err)
+	assert.False(t, changed, "already wrapped with the current key")
+
+	rc, after, err := inner.Get(ctx, "doc")
+	require.NoError(t, err)
+	assert.Equal(t, payloadBefore, readAll(t, rc), "payload must not be rewritten")
+	assert.Equal(t, "k2", after.Metadata[metaEncKeyID])
+	assert.NotEqual(t, before.Metadata[metaEncDEK], after.Metadata[metaEncDEK])
+
+	// The old master key can now be retired.
+	onlyNew, err := NewKeyRing("k2", map[string][]byte{"k2": keys["k2"]})
+	require.NoError(t, err)
+	enc2, err := NewEncrypted(inner, onlyNew)
+	require.NoError(t, err)
+	rc, _, err = enc2.Get(ctx, "doc")
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(content, readAll(t, rc)))
+
+	// And objects still wrapped with a retired key fail loudly.
+	_, err = enc.Put(ctx, "old", strings.NewReader("x"), PutObjectOptions{Size: 1})
+	require.NoError(t, err)
+	_, _, err = enc2.Get(ctx, "old")
+	assert.ErrorIs(t, err, ErrUnknownKeyID)
+}
+
+func TestLoadKeyRing(t *testing.T) {
+	k1 := base64.StdEncoding.EncodeToString(newTestKey(t))
+	k2 := base64.StdEncoding.EncodeToString(newTestKey(t))
+	file := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(file, []byte("# rotated 2024-06\nk2:"+k2+"\n\n"), 0o600))
+
+	tests := []struct {
+		name    string
+		cfg     config.EncryptionConfig
+		wantErr string
+	}{
+		{name: "inline keys", cfg: config.EncryptionConfig{KeyID: "k1", Keys: "k1:" + k1 + ", k2:" + k2}},
+		{name: "inline and file", cfg: config.EncryptionConfig{KeyID: "k2", Keys: "k1:" + k1, KeysFile: file}},
+		{name: "missing key id", cfg: config.EncryptionConfig{Keys: "k1:" + k1}, wantErr: "current master key id is required"},
+		{name: "current key absent", cfg: config.EncryptionConfig{KeyID: "k3", Keys: "k1:" + k1}, wantErr: `current master key "k3" not found`},
+		{name: "duplicate id", cfg: config.EncryptionConfig{KeyID: "k2", Keys: "k2:" + k1, KeysFile: file}, wantErr: "defined more than once"},
+		{name: "short key", cfg: config.EncryptionConfig{KeyID: "k1", Keys: "k1:" + base64.StdEncoding.EncodeToString([]byte("short"))}, wantErr: "must be 32 bytes"},
+		{name: "malformed entry", cfg: config.EncryptionConfig{KeyID: "k1", Keys: k1}, wantErr: "invalid master key entry"},
+		{name: "missing file", cfg: config.EncryptionConfig{KeyID: "k1", KeysFile: file + ".missing"}, wantErr: "read master keys file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kr, err := LoadKeyRing(tt.cfg)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.cfg.KeyID, kr.CurrentKeyID())
+		})
+	}
+}
+
+// statOnlyStorage fails range reads of the first zero bytes, which the decorators used to stat
+// objects before the inner storage's Stat was called directly.
+type statOnlyStorage struct {
+	Storage
+}
+
+func (s statOnlyStorage) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, ObjectInfo, error) {
+	if offset == 0 && length == 0 {
+		return nil, ObjectInfo{}, errors.New("unexpected zero-length range read")
+	}
+	return s.Storage.GetRange(ctx, key, offset, length)
+}
+
+func (s statOnlyStorage) UpdateMetadata(ctx context.Context, key string, metadata map[string]string) error {
+	return s.Storage.(MetadataUpdater).UpdateMetadata(ctx, key, metadata)
+}
+
+func TestDecorators_StatInner(t *testing.T) {
+	ctx := context.Background()
+	inner := statOnlyStorage{NewMemory()}
+	kr, err := NewKeyRing("k1", map[string][]byte{"k1": newTestKey(t)})
+	require.NoError(t, err)
+	enc, err := NewEncrypted(inner, kr)
+	require.NoError(t, err)
+	c, err := NewCompressed(enc, CompressionOptions{Algorithm: CompressionZstd, ContentTypes: []string{"text/*"}}, nil)
+	require.NoError(t, err)
+	content := bytes.Repeat([]byte("abc"), 1000)
+	_, err = c.Put(ctx, "doc", bytes.NewReader(content), PutObjectOptions{Size: int64(len(content)), ContentType: "text/plain"})
+	require.NoError(t, err)
+
+	rc, _, err := c.GetRange(ctx, "doc", 3, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("abc"), readAll(t, rc))
+
+	require.NoError(t, c.(MetadataUpdater).UpdateMetadata(ctx, "doc", map[string]string{"k": "v"}))
+
+	id, err := EncryptionKeyID(ctx, inner, "doc")
+	require.NoError(t, err)
+	assert.Equal(t, "k1", id)
+
+	_, err = RewrapKey(ctx, inner, kr, "doc")
+	require.NoError(t, err)
+
+	rc, info, err := c.Get(ctx, "doc")
+	require.NoError(t, err)
+	assert.Equal(t, content, readAll(t, rc))
+	assert.Equal(t, map[string]string{"k": "v"}, info.Metadata)
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"docapi/internal/config"
+)
+
+// masterKeySize is the length of master keys and per-object data keys (AES-256).
+const masterKeySize = 32
+
+// ErrUnknownKeyID is returned when an object was wrapped with a master key that is not in the KeyRing.
+var ErrUnknownKeyID = errors.New("unknown master key id")
+
+// KeyRing holds the master keys used to wrap per-object data keys.
+// New data keys are always wrapped with the current key; older keys are kept to unwrap existing objects.
+type KeyRing struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyRing builds a KeyRing from raw 32-byte master keys indexed by ID.
+func NewKeyRing(currentID string, keys map[string][]byte) (*KeyRing, error) {
+	if currentID == "" {
+		return nil, fmt.Errorf("current master key id is required")
+	}
+	kr := &KeyRing{current: currentID, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if len(key) != masterKeySize {
+			return nil, fmt.Errorf("master key %q: must be %d bytes, got %d", id, masterKeySize, len(key))
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, fmt.Errorf("master key %q: %w", id, err)
+		}
+		kr.keys[id] = aead
+	}
+	if _, ok := kr.keys[currentID]; !ok {
+		return nil, fmt.Errorf("current master key %q not found", currentID)
+	}
+	return kr, nil
+}
+
+// LoadKeyRing builds a KeyRing from configuration. Keys from Keys and KeysFile are merged;
+// an ID defined in both places is an error.
+func LoadKeyRing(cfg config.EncryptionConfig) (*KeyRing, error) {
+	keys := make(map[string][]byte)
+	if err := parseMasterKeys(cfg.Keys, keys); err != nil {
+		return nil, err
+	}
+	if cfg.KeysFile != "" {
+		b, err := os.ReadFile(cfg.KeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("read master keys file: %w", err)
+		}
+		if err := parseMasterKeys(string(b), keys); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.KeysFile, err)
+		}
+	}
+	return NewKeyRing(cfg.KeyID, keys)
+}
+
+// CurrentKeyID returns the ID of the master key used to wrap new data keys.
+func (k *KeyRing) CurrentKeyID() string {
+	return k.current
+}
+
+// wrap encrypts a data key with the current master key. The result is nonce || ciphertext.
+func (k *KeyRing) wrap(dek []byte) (keyID string, wrapped []byte, err error) {
+	aead := k.keys[k.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	// The key ID is authenticated so a wrapped key cannot be replayed under a different ID.
+	return k.current, aead.Seal(nonce, nonce, dek, []byte(k.current)), nil
+}
+
+// unwrap decrypts a data key previously produced by wrap.
+func (k *KeyRing) unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKeyID, keyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, fmt.Errorf("wrapped data key too short")
+	}
+	nonce, ct := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	dek, err := aead.Open(nil, nonce, ct, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key with %q: %w", keyID, err)
+	}
+	return dek, nil
+}
+
+// parseMasterKeys parses "id:base64key" entries separated by commas or newlines into dst.
+// Blank lines and lines starting with # are ignored.
+func parseMasterKeys(spec string, dst map[string][]byte) error {
+	fields := strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' })
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if f == "" || strings.HasPrefix(f, "#") {
+			continue
+		}
+		id, enc, ok := strings.Cut(f, ":")
+		id, enc = strings.TrimSpace(id), strings.TrimSpace(enc)
+		if !ok || id == "" || enc == "" {
+			return fmt.Errorf("invalid master key entry: want id:base64key")
+		}
+		key, err := base64.StdEncoding.DecodeString(enc)
+		if err != nil {
+			return fmt.Errorf("master key %q: invalid base64: %w", id, err)
+		}
+		if _, dup := dst[id]; dup {
+			return fmt.Errorf("master key %q defined more than once", id)
+		}
+		dst[id] = key
+	}
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
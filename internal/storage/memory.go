@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryObject is a single object held by memoryStorage.
+type memoryObject struct {
+	data []byte
+	info ObjectInfo
+}
+
+// memoryStorage is an in-process implementation of Storage intended for tests and local development.
+// Objects live only in memory and are lost when the process exits. It is safe for concurrent use.
+type memoryStorage struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+// NewMemory creates an empty in-memory Storage.
+func NewMemory() Storage {
+	return &memoryStorage{objects: make(map[string]memoryObject)}
+}
+
+// Put reads the whole stream into memory and stores it under key.
+func (m *memoryStorage) Put(ctx context.Context, key string, r io.Reader, opt PutObjectOptions) (ObjectInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return ObjectInfo{}, err
+	}
+	var src io.Reader = r
+	if opt.Size >= 0 {
+		src = io.LimitReader(r, opt.Size)
+	}
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	if opt.Size >= 0 && int64(len(data)) != opt.Size {
+		return ObjectInfo{}, fmt.Errorf("short read: got %d bytes, want %d", len(data), opt.Size)
+	}
+
+	sum := md5.Sum(data)
+	info := ObjectInfo{
+		Key:          key,
+		Size:         int64(len(data)),
+		StoredSize:   int64(len(data)),
+		ETag:         hex.EncodeToString(sum[:]),
+		ContentType:  opt.ContentType,
+		LastModified: time.Now().UTC(),
+		Metadata:     normalizeMetadata(opt.Metadata),
+	}
+
+	m.mu.Lock()
+	m.objects[key] = memoryObject{data: data, info: info}
+	m.mu.Unlock()
+
+	return copyInfo(info), nil
+}
+
+// Get returns a reader over a snapshot of the object's content.
+func (m *memoryStorage) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	m.mu.RLock()
+	obj, ok := m.objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ObjectInfo{}, fmt.Errorf("object %q: %w", key, ErrNotFound)
+	}
+	return io.NopCloser(bytes.NewReader(obj.data)), copyInfo(obj.info), nil
+}
+
+// GetRange returns a reader over part of a snapshot of the object's content.
+func (m *memoryStorage) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, ObjectInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	m.mu.RLock()
+	obj, ok := m.objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ObjectInfo{}, fmt.Errorf("object %q: %w", key, ErrNotFound)
+	}
+	end, err := rangeEnd(offset, length, int64(len(obj.data)))
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	return io.NopCloser(bytes.NewReader(obj.data[offset:end])), copyInfo(obj.info), nil
+}
+
+// Stat returns the object's info.
+func (m *memoryStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return ObjectInfo{}, err
+	}
+	m.mu.RLock()
+	obj, ok := m.objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		return ObjectInfo{}, fmt.Errorf("object %q: %w", key, ErrNotFound)
+	}
+	return copyInfo(obj.info), nil
+}
+
+// Exists reports whether an object is stored under key.
+func (m *memoryStorage) Exists(ctx context.Context, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	m.mu.RLock()
+	_, ok := m.objects[key]
+	m.mu.RUnlock()
+	return ok, nil
+}
+
+// List returns a page of a snapshot of the keys. The continuation token is the last object key or
+// common prefix of the previous page.
+func (m *memoryStorage) List(ctx context.Context, opts ListOptions) (ListPage, error) {
+	if err := ctx.Err(); err != nil {
+		return ListPage{}, err
+	}
+	maxKeys := opts.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = DefaultListMaxKeys
+	}
+	after := opts.ContinuationToken
+	// A token naming a common prefix covers every key under it.
+	skipPrefix := opts.Delimiter != "" && strings.HasSuffix(after, opts.Delimiter)
+
+	m.mu.RLock()
+	keys := make([]string, 0, len(m.objects))
+	for key := range m.objects {
+		if !strings.HasPrefix(key, opts.Prefix) || (after != "" && key <= after) || (skipPrefix && strings.HasPrefix(key, after)) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	infos := make(map[string]ObjectInfo, len(keys))
+	for _, key := range keys {
+		info := m.objects[key].info
+		info.Metadata = nil
+		infos[key] = info
+	}
+	m.mu.RUnlock()
+	slices.Sort(keys)
+
+	var page ListPage
+	n := 0
+	for _, key := range keys {
+		if n == maxKeys {
+			page.NextContinuationToken = last(page)
+			break
+		}
+		if opts.Delimiter != "" {
+			if i := strings.Index(key[len(opts.Prefix):], opts.Delimiter); i >= 0 {
+				cp := key[:len(opts.Prefix)+i+len(opts.Delimiter)]
+				if len(page.CommonPrefixes) == 0 || page.CommonPrefixes[len(page.CommonPrefixes)-1] != cp {
+					page.CommonPrefixes = append(page.CommonPrefixes, cp)
+					n++
+				}
+				continue
+			}
+		}
+		page.Objects = append(page.Objects, infos[key])
+		n++
+	}
+	return page, nil
+}
+
+// last returns the greatest key or common prefix of page.
+func last(page ListPage) string {
+	var key, cp string
+	if len(page.Objects) > 0 {
+		key = page.Objects[len(page.Objects)-1].Key
+	}
+	if len(page.CommonPrefixes) > 0 {
+		cp = page.CommonPrefixes[len(page.CommonPrefixes)-1]
+	}
+	return max(key, cp)
+}
+
+// Copy stores a snapshot of the object under src as dst.
+func (m *memoryStorage) Copy(ctx context.Context, src, dst string) (ObjectInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return ObjectInfo{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.objects[src]
+	if !ok {
+		return ObjectInfo{}, fmt.Errorf("object %q: %w", src, ErrNotFound)
+	}
+	obj.info = copyInfo(obj.info)
+	obj.info.Key = dst
+	obj.info.LastModified = time.Now().UTC()
+	// Objects are never modified in place, so the copy can share the content.
+	m.objects[dst] = obj
+	return copyInfo(obj.info), nil
+}
+
+// UpdateMetadata replaces the object's user metadata.
+func (m *memoryStorage) UpdateMetadata(ctx context.Context, key string, metadata map[string]string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.objects[key]
+	if !ok {
+		return fmt.Errorf("object %q: %w", key, ErrNotFound)
+	}
+	obj.info.Metadata = normalizeMetadata(metadata)
+	m.objects[key] = obj
+	return nil
+}
+
+// Delete removes an object by key. Deleting a missing key is not an error.
+func (m *memoryStorage) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	delete(m.objects, key)
+	m.mu.Unlock()
+	return nil
+}
+
+// PresignGet returns a memory:// URL; it is only meaningful to code holding this Storage instance.
+func (m *memoryStorage) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	u := url.URL{
+		Scheme:   "memory",
+		Path:     "/" + key,
+		RawQuery: url.Values{"expires": {time.Now().Add(expiry).UTC().Format(time.RFC3339)}}.Encode(),
+	}
+	return u.String(), nil
+}
+
+// rangeEnd validates a GetRange request against an object of the given size and returns the
+// exclusive end offset, truncated to the object size.
+func rangeEnd(offset, length, size int64) (int64, error) {
+	if offset < 0 || offset > size {
+		return 0, fmt.Errorf("invalid range: offset %d outside object of %d bytes", offset, size)
+	}
+	if length < 0 || offset+length > size {
+		return size, nil
+	}
+	return offset + length, nil
+}
+
+// normalizeMetadata returns a copy of md with lower-cased keys, matching what S3-compatible backends return.
+func normalizeMetadata(md map[string]string) map[string]string {
+	if len(md) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(md))
+	for k, v := range md {
+		out[strings.ToLower(k)] = v
+	}
+	return out
+}
+
+// copyInfo returns a copy of info that does not share its Metadata map.
+func copyInfo(info ObjectInfo) ObjectInfo {
+	info.Metadata = normalizeMetadata(info.Metadata)
+	return info
+}
@@ -1,10 +1,13 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/minio/minio-go/v7"
@@ -18,6 +21,7 @@ import (
 // It is safe for concurrent use by multiple goroutines.
 type minioStorage struct {
 	client *minio.Client
+	core   minio.Core
 	bucket string
 }
 
@@ -43,7 +47,7 @@ func NewMinIO(cfg config.MinIOConfig) (Storage, error) {
 		return nil, fmt.Errorf("create minio client: %w", err)
 	}
 
-	ms := &minioStorage{client: cli, bucket: cfg.Bucket}
+	ms := &minioStorage{client: cli, core: minio.Core{Client: cli}, bucket: cfg.Bucket}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -62,23 +66,44 @@ func NewMinIO(cfg config.MinIOConfig) (Storage, error) {
 	return ms, nil
 }
 
-// Put uploads an object using streaming I/O only (no local disk).
+// unknownSizePartSize is the part size of uploads whose size is not known up front. minio-go
+// buffers one part in memory; left to itself it picks parts of over 500 MiB for such uploads.
+const unknownSizePartSize = 16 << 20
+
+// Put uploads an object using streaming I/O only (no local disk). Content of unknown size is
+// read up to one part ahead: if it ends there, it is put with a known size in a single request,
+// otherwise it is uploaded in parts of unknownSizePartSize.
 func (m *minioStorage) Put(ctx context.Context, key string, r io.Reader, opt PutObjectOptions) (ObjectInfo, error) {
 	putOpts := minio.PutObjectOptions{
 		ContentType:  opt.ContentType,
 		UserMetadata: opt.Metadata,
 	}
-	info, err := m.client.PutObject(ctx, m.bucket, key, r, opt.Size, putOpts)
+	size := opt.Size
+	if size < 0 {
+		head, err := io.ReadAll(io.LimitReader(r, unknownSizePartSize+1))
+		if err != nil {
+			return ObjectInfo{}, err
+		}
+		if len(head) <= unknownSizePartSize {
+			r, size = bytes.NewReader(head), int64(len(head))
+			putOpts.DisableMultipart = true
+		} else {
+			r = io.MultiReader(bytes.NewReader(head), r)
+			putOpts.PartSize = unknownSizePartSize
+		}
+	}
+	info, err := m.client.PutObject(ctx, m.bucket, key, r, size, putOpts)
 	if err != nil {
 		return ObjectInfo{}, err
 	}
 	return ObjectInfo{
 		Key:          key,
 		Size:         info.Size,
+		StoredSize:   info.Size,
 		ETag:         info.ETag,
 		ContentType:  opt.ContentType,
 		LastModified: time.Now(), // MinIO PutObjectInfo doesn't return LastModified
-		Metadata:     opt.Metadata,
+		Metadata:     normalizeMetadata(opt.Metadata),
 	}, nil
 }
 
@@ -86,25 +111,130 @@ func (m *minioStorage) Put(ctx context.Context, key string, r io.Reader, opt Put
 func (m *minioStorage) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
 	obj, err := m.client.GetObject(ctx, m.bucket, key, minio.GetObjectOptions{})
 	if err != nil {
-		return nil, ObjectInfo{}, err
+		return nil, ObjectInfo{}, notFound(key, err)
 	}
 	// Fetch stat to populate info; avoid reading content into memory.
 	st, err := obj.Stat()
 	if err != nil {
 		obj.Close()
+		return nil, ObjectInfo{}, notFound(key, err)
+	}
+	return obj, objectInfo(key, st), nil
+}
+
+// GetRange downloads part of an object. The object is stat'ed first so the returned info describes the
+// whole object rather than the requested range.
+func (m *minioStorage) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, ObjectInfo, error) {
+	st, err := m.client.StatObject(ctx, m.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, ObjectInfo{}, notFound(key, err)
+	}
+	info := objectInfo(key, st)
+
+	end, err := rangeEnd(offset, length, st.Size)
+	if err != nil {
 		return nil, ObjectInfo{}, err
 	}
-	info := ObjectInfo{
-		Key:          key,
-		Size:         st.Size,
-		ETag:         st.ETag,
-		ContentType:  st.ContentType,
-		LastModified: st.LastModified,
-		Metadata:     st.UserMetadata,
+	if end <= offset {
+		return io.NopCloser(strings.NewReader("")), info, nil
+	}
+
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetRange(offset, end-1); err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	obj, err := m.client.GetObject(ctx, m.bucket, key, opts)
+	if err != nil {
+		return nil, ObjectInfo{}, err
 	}
 	return obj, info, nil
 }
 
+// Stat reads the object's info with a HEAD request.
+func (m *minioStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	st, err := m.client.StatObject(ctx, m.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return ObjectInfo{}, notFound(key, err)
+	}
+	return objectInfo(key, st), nil
+}
+
+// Exists checks for the object with a HEAD request.
+func (m *minioStorage) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := m.Stat(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// List fetches one page with ListObjectsV2. The continuation token is the backend's own.
+func (m *minioStorage) List(ctx context.Context, opts ListOptions) (ListPage, error) {
+	// The client's ListObjectsV2 takes no context; honour cancellation before the request at least.
+	if err := ctx.Err(); err != nil {
+		return ListPage{}, err
+	}
+	maxKeys := opts.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = DefaultListMaxKeys
+	}
+	res, err := m.core.ListObjectsV2(m.bucket, opts.Prefix, "", opts.ContinuationToken, opts.Delimiter, maxKeys)
+	if err != nil {
+		return ListPage{}, err
+	}
+	page := ListPage{Objects: make([]ObjectInfo, 0, len(res.Contents))}
+	for _, obj := range res.Contents {
+		page.Objects = append(page.Objects, ObjectInfo{
+			Key:          obj.Key,
+			Size:         obj.Size,
+			StoredSize:   obj.Size,
+			ETag:         strings.Trim(obj.ETag, `"`),
+			LastModified: obj.LastModified,
+		})
+	}
+	for _, cp := range res.CommonPrefixes {
+		page.CommonPrefixes = append(page.CommonPrefixes, cp.Prefix)
+	}
+	if res.IsTruncated {
+		page.NextContinuationToken = res.NextContinuationToken
+	}
+	return page, nil
+}
+
+// Copy performs a server-side copy, keeping the content type and user metadata of src.
+func (m *minioStorage) Copy(ctx context.Context, src, dst string) (ObjectInfo, error) {
+	_, err := m.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: m.bucket, Object: dst},
+		minio.CopySrcOptions{Bucket: m.bucket, Object: src},
+	)
+	if err != nil {
+		return ObjectInfo{}, notFound(src, err)
+	}
+	return m.Stat(ctx, dst)
+}
+
+// UpdateMetadata replaces the object's user metadata using a server-side copy onto itself.
+// Objects above 5 GiB, the limit of a single copy, are copied part by part in a multipart upload.
+func (m *minioStorage) UpdateMetadata(ctx context.Context, key string, metadata map[string]string) error {
+	st, err := m.client.StatObject(ctx, m.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return notFound(key, err)
+	}
+	md := make(map[string]string, len(metadata)+1)
+	for k, v := range metadata {
+		md[k] = v
+	}
+	// Content-Type is sent as a standard header and would otherwise be reset by the REPLACE directive.
+	md["Content-Type"] = st.ContentType
+
+	// ComposeObject issues a single copy for objects that allow it, and a multipart copy otherwise.
+	_, err = m.client.ComposeObject(ctx,
+		minio.CopyDestOptions{Bucket: m.bucket, Object: key, UserMetadata: md, ReplaceMetadata: true},
+		minio.CopySrcOptions{Bucket: m.bucket, Object: key},
+	)
+	return err
+}
+
 // Delete removes an object by key.
 func (m *minioStorage) Delete(ctx context.Context, key string) error {
 	return m.client.RemoveObject(ctx, m.bucket, key, minio.RemoveObjectOptions{})
@@ -118,3 +248,27 @@ func (m *minioStorage) PresignGet(ctx context.Context, key string, expiry time.D
 	}
 	return u.String(), nil
 }
+
+// objectInfo converts the info MinIO reports for key.
+func objectInfo(key string, st minio.ObjectInfo) ObjectInfo {
+	return ObjectInfo{
+		Key:          key,
+		Size:         st.Size,
+		StoredSize:   st.Size,
+		ETag:         st.ETag,
+		ContentType:  st.ContentType,
+		LastModified: st.LastModified,
+		// MinIO returns user metadata keys in canonical header form; normalize to the lower-case contract.
+		Metadata: normalizeMetadata(st.UserMetadata),
+	}
+}
+
+// notFound translates MinIO's missing-object error responses to ErrNotFound and returns other
+// errors unchanged.
+func notFound(key string, err error) error {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NoSuchObject":
+		return fmt.Errorf("object %q: %w", key, ErrNotFound)
+	}
+	return err
+}
@@ -0,0 +1,184 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"docapi/internal/config"
+	"docapi/internal/storage"
+	"docapi/internal/storage/storagetest"
+)
+
+// TestMinIOStorage_Conformance runs the storage conformance suite against a real MinIO server.
+// It is skipped unless DOCAPI_TEST_MINIO_ENDPOINT is set; credentials and bucket default to the
+// values used by the local docker setup.
+func TestMinIOStorage_Conformance(t *testing.T) {
+	endpoint := os.Getenv("DOCAPI_TEST_MINIO_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DOCAPI_TEST_MINIO_ENDPOINT not set")
+	}
+
+	cfg := config.MinIOConfig{
+		Endpoint:  endpoint,
+		AccessKey: envOr("DOCAPI_TEST_MINIO_ACCESS_KEY", "minioadmin"),
+		SecretKey: envOr("DOCAPI_TEST_MINIO_SECRET_KEY", "minioadmin123"),
+		Bucket:    envOr("DOCAPI_TEST_MINIO_BUCKET", "docapi-conformance"),
+	}
+	s, err := storage.NewMinIO(cfg)
+	require.NoError(t, err)
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage { return s })
+}
+
+func TestMinIOStorage_UpdateMetadataLargeObject(t *testing.T) {
+	const size = 6 << 30 // above the 5 GiB limit of a single copy
+	var (
+		initiated http.Header
+		parts     int64
+		copied    int64
+		completed bool
+	)
+	s := newFakeS3(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case r.Method == http.MethodHead:
+			w.Header().Set("Content-Length", fmt.Sprint(size))
+			w.Header().Set("Content-Type", "video/mp4")
+			w.Header().Set("ETag", `"abc-2"`)
+			w.Header().Set("Last-Modified", "Mon, 01 Jan 2024 00:00:00 GMT")
+			w.Header().Set("X-Amz-Meta-Old", "1")
+		case r.Method == http.MethodPost && q.Has("uploads"):
+			initiated = r.Header.Clone()
+			fmt.Fprint(w, `<InitiateMultipartUploadResult><Bucket>docs</Bucket><Key>big.mp4</Key><UploadId>up-1</UploadId></InitiateMultipartUploadResult>`)
+		case r.Method == http.MethodPut && q.Get("uploadId") == "up-1":
+			var start, end int64
+			_, err := fmt.Sscanf(r.Header.Get("X-Amz-Copy-Source-Range"), "bytes=%d-%d", &start, &end)
+			assert.NoError(t, err)
+			assert.Equal(t, "docs/big.mp4", mustUnescape(t, r.Header.Get("X-Amz-Copy-Source")))
+			parts++
+			copied += end - start + 1
+			fmt.Fprintf(w, `<CopyPartResult><ETag>"part-%d"</ETag><LastModified>2024-01-01T00:00:00Z</LastModified></CopyPartResult>`, parts)
+		case r.Method == http.MethodPost && q.Get("uploadId") == "up-1":
+			completed = true
+			fmt.Fprint(w, `<CompleteMultipartUploadResult><Bucket>docs</Bucket><Key>big.mp4</Key><ETag>"new-2"</ETag></CompleteMultipartUploadResult>`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	})
+
+	err := s.(storage.MetadataUpdater).UpdateMetadata(context.Background(), "big.mp4", map[string]string{"new": "2"})
+	require.NoError(t, err)
+
+	assert.True(t, completed, "the multipart copy is completed")
+	assert.Greater(t, parts, int64(1))
+	assert.Equal(t, int64(size), copied, "every byte is copied")
+	assert.Equal(t, "2", initiated.Get("X-Amz-Meta-New"))
+	assert.Empty(t, initiated.Get("X-Amz-Meta-Old"), "metadata is replaced")
+	assert.Equal(t, "video/mp4", initiated.Get("Content-Type"))
+}
+
+func TestMinIOStorage_PutUnknownSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		wantPuts  []int64 // bytes sent by each single put
+		wantParts []int64 // bytes sent by each part of a multipart upload
+	}{
+		{name: "empty", size: 0, wantPuts: []int64{0}},
+		{name: "small body is put with its size", size: 2 << 10, wantPuts: []int64{2 << 10}},
+		{name: "exactly one part", size: 16 << 20, wantPuts: []int64{16 << 20}},
+		{name: "larger body is uploaded in bounded parts", size: 40 << 20, wantParts: []int64{16 << 20, 16 << 20, 8 << 20}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var puts, parts []int64
+			s := newFakeS3(t, func(w http.ResponseWriter, r *http.Request) {
+				q := r.URL.Query()
+				n, err := io.Copy(io.Discard, r.Body)
+				assert.NoError(t, err)
+				if v := r.Header.Get("X-Amz-Decoded-Content-Length"); v != "" {
+					// Streaming signatures frame the content in signed chunks.
+					n, err = strconv.ParseInt(v, 10, 64)
+					assert.NoError(t, err)
+				}
+				switch {
+				case r.Method == http.MethodPut && q.Has("partNumber"):
+					parts = append(parts, n)
+					w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, len(parts)))
+				case r.Method == http.MethodPut:
+					puts = append(puts, n)
+					w.Header().Set("ETag", `"etag"`)
+				case r.Method == http.MethodPost && q.Has("uploads"):
+					fmt.Fprint(w, `<InitiateMultipartUploadResult><Bucket>docs</Bucket><Key>k</Key><UploadId>up-1</UploadId></InitiateMultipartUploadResult>`)
+				case r.Method == http.MethodPost && q.Get("uploadId") == "up-1":
+					fmt.Fprint(w, `<CompleteMultipartUploadResult><Bucket>docs</Bucket><Key>k</Key><ETag>"etag-3"</ETag></CompleteMultipartUploadResult>`)
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, r.URL)
+					w.WriteHeader(http.StatusNotImplemented)
+				}
+			})
+
+			info, err := s.Put(context.Background(), "k", bytes.NewReader(make([]byte, tt.size)), storage.PutObjectOptions{Size: -1, ContentType: "text/csv"})
+			require.NoError(t, err)
+			assert.Equal(t, int64(tt.size), info.Size)
+			assert.Equal(t, tt.wantPuts, puts)
+			assert.Equal(t, tt.wantParts, parts)
+		})
+	}
+}
+
+// newFakeS3 returns a MinIO storage for bucket "docs" on a fake S3 server. Bucket requests are
+// answered by the server; object requests are passed to handle, one at a time.
+func newFakeS3(t *testing.T, handle http.HandlerFunc) storage.Storage {
+	t.Helper()
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/docs" || r.URL.Path == "/docs/" {
+			if r.URL.Query().Has("location") {
+				fmt.Fprint(w, `<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">us-east-1</LocationConstraint>`)
+			}
+			return
+		}
+		handle(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	s, err := storage.NewMinIO(config.MinIOConfig{
+		Endpoint:  strings.TrimPrefix(srv.URL, "http://"),
+		AccessKey: "key",
+		SecretKey: "secret",
+		Bucket:    "docs",
+	})
+	require.NoError(t, err)
+	return s
+}
+
+func mustUnescape(t *testing.T, s string) string {
+	t.Helper()
+	u, err := url.PathUnescape(s)
+	require.NoError(t, err)
+	return u
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -27,6 +27,31 @@ func (m *MockStorage) Get(ctx context.Context, key string) (io.ReadCloser, stora
 	return args.Get(0).(io.ReadCloser), args.Get(1).(storage.ObjectInfo), args.Error(2)
 }
 
+func (m *MockStorage) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, storage.ObjectInfo, error) {
+	args := m.Called(ctx, key, offset, length)
+	return args.Get(0).(io.ReadCloser), args.Get(1).(storage.ObjectInfo), args.Error(2)
+}
+
+func (m *MockStorage) Stat(ctx context.Context, key string) (storage.ObjectInfo, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(storage.ObjectInfo), args.Error(1)
+}
+
+func (m *MockStorage) Exists(ctx context.Context, key string) (bool, error) {
+	args := m.Called(ctx, key)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStorage) List(ctx context.Context, opts storage.ListOptions) (storage.ListPage, error) {
+	args := m.Called(ctx, opts)
+	return args.Get(0).(storage.ListPage), args.Error(1)
+}
+
+func (m *MockStorage) Copy(ctx context.Context, src, dst string) (storage.ObjectInfo, error) {
+	args := m.Called(ctx, src, dst)
+	return args.Get(0).(storage.ObjectInfo), args.Error(1)
+}
+
 func (m *MockStorage) Delete(ctx context.Context, key string) error {
 	args := m.Called(ctx, key)
 	return args.Error(0)
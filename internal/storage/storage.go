@@ -2,42 +2,137 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"io"
+	"iter"
 	"time"
 )
 
 // Package storage contains file/object storage abstractions and utilities for object stores (S3-compatible).
 // Implementations must avoid using local disk and rely on streaming I/O only.
 
+// ErrNotFound is returned, wrapped with the key, by methods that need an existing object when
+// there is none. Backend-specific not-found errors are translated to it.
+var ErrNotFound = errors.New("object not found")
+
+// DefaultListMaxKeys is the page size of List when ListOptions.MaxKeys is not set.
+const DefaultListMaxKeys = 1000
+
 // PutObjectOptions define optional parameters for uploading objects.
 // Size should be the exact number of bytes if known; if unknown, set to -1 and the implementation
 // will buffer/chunk as supported by the backend.
-// ContentType and Metadata are optional.
- type PutObjectOptions struct {
+// ContentType and Metadata are optional. Metadata keys are case-insensitive; implementations
+// return them in lower case.
+type PutObjectOptions struct {
 	Size        int64
 	ContentType string
 	Metadata    map[string]string
 }
 
 // ObjectInfo contains basic information about an object in storage.
- type ObjectInfo struct {
+// Size is the logical content size seen by callers; StoredSize is the number of bytes the object
+// occupies in the backend, which differs from Size when a decorator compresses or encrypts content.
+type ObjectInfo struct {
 	Key          string
 	Size         int64
+	StoredSize   int64
 	ETag         string
 	ContentType  string
 	LastModified time.Time
 	Metadata     map[string]string
 }
 
+// ListOptions select a page of a listing.
+type ListOptions struct {
+	// Prefix restricts the listing to keys starting with it.
+	Prefix string
+	// Delimiter, when set, groups the keys that contain it after Prefix into CommonPrefixes, each
+	// ending at the first delimiter, as S3 does; "/" lists one directory level. Without a
+	// delimiter the listing is recursive.
+	Delimiter string
+	// MaxKeys caps the objects plus common prefixes of one page; zero means DefaultListMaxKeys.
+	MaxKeys int
+	// ContinuationToken resumes a listing; it is the NextContinuationToken of the previous page.
+	ContinuationToken string
+}
+
+// ListPage is one page of a listing. Objects and CommonPrefixes are in lexical key order.
+type ListPage struct {
+	Objects        []ObjectInfo
+	CommonPrefixes []string
+	// NextContinuationToken is set when more results follow. It is opaque and only valid for the
+	// same storage, prefix and delimiter.
+	NextContinuationToken string
+}
+
 // Storage is a reusable, S3-compatible object storage client interface.
 // Methods use context and streaming readers/writers; no local disk is used.
- type Storage interface {
+type Storage interface {
 	// Put uploads an object under the given key using the provided reader and options.
+	// Putting an existing key replaces the object. The returned info reports the number of bytes stored.
 	Put(ctx context.Context, key string, r io.Reader, opt PutObjectOptions) (ObjectInfo, error)
 	// Get retrieves an object's content as a streaming reader alongside its info.
+	// It returns an error wrapping ErrNotFound if the object does not exist.
 	Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error)
-	// Delete removes an object by key.
+	// GetRange retrieves length bytes of an object's content starting at offset; a negative length reads
+	// to the end and ranges extending past the end are truncated. A length of 0 returns an empty body,
+	// which is a cheap way to read the info alone. The returned info always describes the whole object.
+	// It returns an error wrapping ErrNotFound if the object does not exist, and an error if offset
+	// is beyond its size.
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, ObjectInfo, error)
+	// Stat returns an object's info without reading its content. It returns an error wrapping
+	// ErrNotFound if the object does not exist.
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// Exists reports whether an object exists under key.
+	Exists(ctx context.Context, key string) (bool, error)
+	// List returns one page of the objects selected by opts. Listed infos carry the key, ETag and
+	// modification time, and Size and StoredSize as stored in the backend, since decorators cannot
+	// tell an object's logical size without its metadata; Metadata is not set. Use ListAll to
+	// iterate over every page.
+	List(ctx context.Context, opts ListOptions) (ListPage, error)
+	// Copy copies the object under src to dst within the backend, without streaming its content
+	// through the caller, and returns the info of the copy. Content type and metadata are kept;
+	// an existing dst is replaced. It returns an error wrapping ErrNotFound if src does not exist.
+	Copy(ctx context.Context, src, dst string) (ObjectInfo, error)
+	// Delete removes an object by key. It returns nil if the object did not exist.
 	Delete(ctx context.Context, key string) error
 	// PresignGet returns a time-limited URL that can be used to download the object without credentials.
 	PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error)
 }
+
+// Ping checks that s can be used by listing at most one object, which fails if the backend is
+// unreachable, the credentials are rejected or the bucket does not exist.
+func Ping(ctx context.Context, s Storage) error {
+	_, err := s.List(ctx, ListOptions{MaxKeys: 1})
+	return err
+}
+
+// ListAll yields every object whose key starts with prefix, in lexical key order, fetching pages
+// from s as iteration proceeds. Iteration stops after the first error.
+func ListAll(ctx context.Context, s Storage, prefix string) iter.Seq2[ObjectInfo, error] {
+	return func(yield func(ObjectInfo, error) bool) {
+		opts := ListOptions{Prefix: prefix}
+		for {
+			page, err := s.List(ctx, opts)
+			if err != nil {
+				yield(ObjectInfo{}, err)
+				return
+			}
+			for _, info := range page.Objects {
+				if !yield(info, nil) {
+					return
+				}
+			}
+			if page.NextContinuationToken == "" {
+				return
+			}
+			opts.ContinuationToken = page.NextContinuationToken
+		}
+	}
+}
+
+// MetadataUpdater is implemented by storages that can replace an object's user metadata in place,
+// without the caller re-uploading its content. The content type is preserved.
+type MetadataUpdater interface {
+	UpdateMetadata(ctx context.Context, key string, metadata map[string]string) error
+}
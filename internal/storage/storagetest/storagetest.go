@@ -0,0 +1,526 @@
+// Package storagetest provides a conformance suite for storage.Storage implementations.
+//
+// Every backend should run the suite against itself from its own tests:
+//
+//	func TestMyStorage_Conformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Storage {
+//			return newMyStorage(t)
+//		})
+//	}
+//
+// The factory is called once per subtest; it may return a shared backend as long as keys do not
+// collide, because every case writes under its own unique key prefix.
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"docapi/internal/storage"
+)
+
+// Factory returns the Storage under test.
+type Factory func(t *testing.T) storage.Storage
+
+// Run executes the full conformance suite against the storage returned by newStorage.
+func Run(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s storage.Storage, prefix string)
+	}{
+		{"put and get round trip", testRoundTrip},
+		{"put with unknown size", testUnknownSize},
+		{"put empty object", testEmptyObject},
+		{"metadata round trip", testMetadataRoundTrip},
+		{"put overwrites existing key", testOverwrite},
+		{"get missing key", testGetMissing},
+		{"get range missing key", testGetRangeMissing},
+		{"get range", testGetRange},
+		{"get range info only", testGetRangeInfoOnly},
+		{"get range beyond end", testGetRangeBeyondEnd},
+		{"update metadata", testUpdateMetadata},
+		{"stat", testStat},
+		{"stat missing key", testStatMissing},
+		{"exists", testExists},
+		{"list", testList},
+		{"list with delimiter", testListDelimiter},
+		{"list pages", testListPages},
+		{"list all", testListAll},
+		{"copy", testCopy},
+		{"copy missing key", testCopyMissing},
+		{"delete", testDelete},
+		{"delete missing key", testDeleteMissing},
+		{"concurrent puts", testConcurrentPuts},
+		{"presign get", testPresignGet},
+		{"ping", testPing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStorage(t)
+			require.NotNil(t, s)
+			prefix := "conformance/" + uuid.NewString() + "/"
+			tt.run(t, s, prefix)
+		})
+	}
+}
+
+func testRoundTrip(t *testing.T, s storage.Storage, prefix string) {
+	ctx := context.Background()
+	key := prefix + "hello.txt"
+	content := []byte("hello world")
+
+	info, err := s.Put(ctx, key, bytes.NewReader(content), storage.PutObjectOptions{
+		Size:        int64(len(content)),
+		ContentType: "text/plain",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, key, info.Key)
+	assert.Equal(t, int64(len(content)), info.Size)
+	assert.Equal(t, "text/plain", info.ContentType)
+
+	got, gotInfo := mustGet(t, s, key)
+	assert.Equal(t, content, got)
+	assert.Equal(t, key, gotInfo.Key)
+	assert.Equal(t, int64(len(content)), gotInfo.Size)
+	assert.Equal(t, "text/plain", gotInfo.ContentType)
+	assert.NotEmpty(t, gotInfo.ETag)
+	assert.False(t, gotInfo.LastModified.IsZero())
+}
+
+func testUnknownSize(t *testing.T, s storage.Storage, prefix string) {
+	ctx := context.Background()
+	key := prefix + "stream.bin"
+	// Larger than typical read buffers so implementations must actually stream.
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+
+	// Hide the concrete type so implementations cannot sniff the length.
+	r := io.MultiReader(bytes.NewReader(content))
+	info, err := s.Put(ctx, key, r, storage.PutObjectOptions{Size: -1, ContentType: "application/octet-stream"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), info.Size)
+
+	got, gotInfo := mustGet(t, s, key)
+	assert.Equal(t, len(content), len(got))
+	assert.True(t, bytes.Equal(content, got), "content mismatch")
+	assert.Equal(t, int64(len(content)), gotInfo.Size)
+}
+
+func testEmptyObject(t *testing.T, s storage.Storage, prefix string) {
+	ctx := context.Background()
+	key := prefix + "empty"
+
+	info, err := s.Put(ctx, key, bytes.NewReader(nil), storage.PutObjectOptions{Size: 0})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size)
+
+	got, gotInfo := mustGet(t, s, key)
+	assert.Empty(t, got)
+	assert.Equal(t, int64(0), gotInfo.Size)
+}
+
+func testMetadataRoundTrip(t *testing.T, s storage.Storage, prefix string) {
+	ctx := context.Background()
+	key := prefix + "meta.txt"
+
+	_, err := s.Put(ctx, key, strings.NewReader("x"), storage.PutObjectOptions{
+		Size:        1,
+		ContentType: "text/plain",
+		Metadata: map[string]string{
+			"original-filename": "report final.txt",
+			"Mixed-Case":        "value",
+		},
+	})
+	require.NoError(t, err)
+
+	_, info := mustGet(t, s, key)
+	assert.Equal(t, "report final.txt", info.Metadata["original-filename"])
+	assert.Equal(t, "value", info.Metadata["mixed-case"], "metadata keys must be returned in lower case")
+}
+
+func testOverwrite(t *testing.T, s storage.Storage, prefix string) {
+	ctx := context.Background()
+	key := prefix + "overwrite.txt"
+
+	_, err := s.Put(ctx, key, strings.NewReader("first"), storage.PutObjectOptions{Size: 5})
+	require.NoError(t, err)
+	_, err = s.Put(ctx, key, strings.NewReader("second!"), storage.PutObjectOptions{Size: 7})
+	require.NoError(t, err)
+
+	got, info := mustGet(t, s, key)
+	assert.Equal(t, "second!", string(got))
+	assert.Equal(t, int64(7), info.Size)
+}
+
+func testGetMissing(t *testing.T, s storage.Storage, prefix string) {
+	_, _, err := s.Get(context.Background(), prefix+"does-not-exist")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testGetRangeMissing(t *testing.T, s storage.Storage, prefix string) {
+	_, _, err := s.GetRange(context.Background(), prefix+"does-not-exist", 0, 10)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testGetRange(t *testing.T, s storage.Storage, prefix string) {
+	ctx := context.Background()
+	key := prefix + "range.bin"
+	content := make([]byte, 200*1024)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	_, err := s.Put(ctx, key, bytes.NewReader(content), storage.PutObjectOptions{Size: int64(len(content))})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name           string
+		offset, length int64
+		want           []byte
+	}{
+		{"prefix", 0, 10, content[:10]},
+		{"middle", 70000, 5000, content[70000:75000]},
+		{"to end", 150000, -1, content[150000:]},
+		{"truncated past end", int64(len(content)) - 5, 100, content[len(content)-5:]},
+		{"at end", int64(len(content)), -1, []byte{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rc, info, err := s.GetRange(ctx, key, tc.offset, tc.length)
+			require.NoError(t, err)
+			defer rc.Close()
+			got, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			assert.True(t, bytes.Equal(tc.want, got), "range content mismatch: got %d bytes, want %d", len(got), len(tc.want))
+			assert.Equal(t, int64(len(content)), info.Size, "info must describe the whole object")
+		})
+	}
+}
+
+func testGetRangeInfoOnly(t *testing.T, s storage.Storage, prefix string) {
+	ctx := context.Background()
+	key := prefix + "info.txt"
+	_, err := s.Put(ctx, key, strings.NewReader("hello"), storage.PutObjectOptions{
+		Size:        5,
+		ContentType: "text/plain",
+		Metadata:    map[string]string{"k": "v"},
+	})
+	require.NoError(t, err)
+
+	rc, info, err := s.GetRange(ctx, key, 0, 0)
+	require.NoError(t, err)
+	got, err := io.ReadAll(rc)
+	rc.Close()
+	require.NoError(t, err)
+	assert.Empty(t, got)
+	assert.Equal(t, int64(5), info.Size)
+	assert.Equal(t, "text/plain", info.ContentType)
+	assert.Equal(t, "v", info.Metadata["k"])
+}
+
+func testGetRangeBeyondEnd(t *testing.T, s storage.Storage, prefix string) {
+	ctx := context.Background()
+	key := prefix + "short.txt"
+	_, err := s.Put(ctx, key, strings.NewReader("abc"), storage.PutObjectOptions{Size: 3})
+	require.NoError(t, err)
+
+	rc, _, err := s.GetRange(ctx, key, 10, 1)
+	if err == nil {
+		rc.Close()
+	}
+	assert.Error(t, err)
+}
+
+func testUpdateMetadata(t *testing.T, s storage.Storage, prefix string) {
+	mu, ok := s.(storage.MetadataUpdater)
+	if !ok {
+		t.Skip("storage does not implement MetadataUpdater")
+	}
+	ctx := context.Background()
+	key := prefix + "update-meta.txt"
+	_, err := s.Put(ctx, key, strings.NewReader("body"), storage.PutObjectOptions{
+		Size:        4,
+		ContentType: "text/plain",
+		Metadata:    map[string]string{"a": "1", "b": "2"},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, mu.UpdateMetadata(ctx, key, map[string]string{"a": "10", "c": "3"}))
+
+	got, info := mustGet(t, s, key)
+	assert.Equal(t, "body", string(got))
+	assert.Equal(t, "text/plain", info.ContentType)
+	assert.Equal(t, "10", info.Metadata["a"])
+	assert.Equal(t, "3", info.Metadata["c"])
+	assert.NotContains(t, info.Metadata, "b", "metadata must be replaced, not merged")
+}
+
+func testStat(t *testing.T, s storage.Storage, prefix string) {
+	ctx := context.Background()
+	key := prefix + "stat.txt"
+	content := strings.Repeat("stat me ", 512)
+	_, err := s.Put(ctx, key, strings.NewReader(content), storage.PutObjectOptions{
+		Size:        int64(len(content)),
+		ContentType: "text/plain",
+		Metadata:    map[string]string{"k": "v"},
+	})
+	require.NoError(t, err)
+
+	info, err := s.Stat(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, key, info.Key)
+	assert.Equal(t, int64(len(content)), info.Size)
+	assert.Equal(t, "text/plain", info.ContentType)
+	assert.Equal(t, map[string]string{"k": "v"}, info.Metadata)
+	assert.False(t, info.LastModified.IsZero())
+}
+
+func testStatMissing(t *testing.T, s storage.Storage, prefix string) {
+	_, err := s.Stat(context.Background(), prefix+"does-not-exist")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testExists(t *testing.T, s storage.Storage, prefix string) {
+	ctx := context.Background()
+	key := prefix + "exists.txt"
+	_, err := s.Put(ctx, key, strings.NewReader("x"), storage.PutObjectOptions{Size: 1})
+	require.NoError(t, err)
+
+	ok, err := s.Exists(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = s.Exists(ctx, prefix+"does-not-exist")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// putKeys stores a small object under prefix+name for every name.
+func putKeys(t *testing.T, s storage.Storage, prefix string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		_, err := s.Put(context.Background(), prefix+name, strings.NewReader(name), storage.PutObjectOptions{Size: int64(len(name))})
+		require.NoError(t, err)
+	}
+}
+
+func listedKeys(page storage.ListPage, prefix string) []string {
+	var keys []string
+	for _, info := range page.Objects {
+		keys = append(keys, strings.TrimPrefix(info.Key, prefix))
+	}
+	return keys
+}
+
+func testList(t *testing.T, s storage.Storage, prefix string) {
+	ctx := context.Background()
+	putKeys(t, s, prefix, "list/b.txt", "list/a.txt", "list/sub/c.txt", "other.txt")
+
+	page, err := s.List(ctx, storage.ListOptions{Prefix: prefix + "list/"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list/a.txt", "list/b.txt", "list/sub/c.txt"}, listedKeys(page, prefix))
+	assert.Empty(t, page.CommonPrefixes)
+	assert.Empty(t, page.NextContinuationToken)
+	for _, info := range page.Objects {
+		assert.NotEmpty(t, info.ETag)
+		assert.Positive(t, info.StoredSize)
+		assert.False(t, info.LastModified.IsZero())
+	}
+}
+
+func testListDelimiter(t *testing.T, s storage.Storage, prefix string) {
+	ctx := context.Background()
+	putKeys(t, s, prefix, "a.txt", "dir/x.txt", "dir/sub/y.txt", "dis.txt", "z/1.txt")
+
+	page, err := s.List(ctx, storage.ListOptions{Prefix: prefix, Delimiter: "/"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "dis.txt"}, listedKeys(page, prefix))
+	assert.Equal(t, []string{prefix + "dir/", prefix + "z/"}, page.CommonPrefixes)
+
+	page, err = s.List(ctx, storage.ListOptions{Prefix: prefix + "dir/", Delimiter: "/"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir/x.txt"}, listedKeys(page, prefix))
+	assert.Equal(t, []string{prefix + "dir/sub/"}, page.CommonPrefixes)
+}
+
+func testListPages(t *testing.T, s storage.Storage, prefix string) {
+	ctx := context.Background()
+	putKeys(t, s, prefix, "a", "b/1", "b/2", "b/3", "c", "d/1", "e")
+
+	var keys, prefixes []string
+	opts := storage.ListOptions{Prefix: prefix, Delimiter: "/", MaxKeys: 2}
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 10, "listing must terminate")
+		page, err := s.List(ctx, opts)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(page.Objects)+len(page.CommonPrefixes), 2)
+		keys = append(keys, listedKeys(page, prefix)...)
+		prefixes = append(prefixes, page.CommonPrefixes...)
+		if page.NextContinuationToken == "" {
+			break
+		}
+		opts.ContinuationToken = page.NextContinuationToken
+	}
+	assert.Equal(t, []string{"a", "c", "e"}, keys)
+	assert.Equal(t, []string{prefix + "b/", prefix + "d/"}, prefixes, "common prefixes must not repeat across pages")
+}
+
+func testListAll(t *testing.T, s storage.Storage, prefix string) {
+	ctx := context.Background()
+	names := make([]string, 0, storage.DefaultListMaxKeys+5)
+	for i := range storage.DefaultListMaxKeys + 5 {
+		names = append(names, fmt.Sprintf("all/%04d", i))
+	}
+	putKeys(t, s, prefix, names...)
+
+	var keys []string
+	for info, err := range storage.ListAll(ctx, s, prefix+"all/") {
+		require.NoError(t, err)
+		keys = append(keys, strings.TrimPrefix(info.Key, prefix))
+	}
+	assert.Equal(t, names, keys)
+
+	n := 0
+	for range storage.ListAll(ctx, s, prefix) {
+		n++
+		break
+	}
+	assert.Equal(t, 1, n, "iteration must stop when the loop breaks")
+}
+
+func testCopy(t *testing.T, s storage.Storage, prefix string) {
+	ctx := context.Background()
+	src, dst := prefix+"copy-src.txt", prefix+"copy-dst.txt"
+	content := strings.Repeat("copy me ", 512)
+	_, err := s.Put(ctx, src, strings.NewReader(content), storage.PutObjectOptions{
+		Size:        int64(len(content)),
+		ContentType: "text/plain",
+		Metadata:    map[string]string{"k": "v"},
+	})
+	require.NoError(t, err)
+	// An existing destination is replaced.
+	putKeys(t, s, prefix, "copy-dst.txt")
+
+	info, err := s.Copy(ctx, src, dst)
+	require.NoError(t, err)
+	assert.Equal(t, dst, info.Key)
+	assert.Equal(t, int64(len(content)), info.Size)
+	assert.Equal(t, "text/plain", info.ContentType)
+	assert.Equal(t, map[string]string{"k": "v"}, info.Metadata)
+
+	got, gotInfo := mustGet(t, s, dst)
+	assert.Equal(t, content, string(got))
+	assert.Equal(t, "v", gotInfo.Metadata["k"])
+
+	// The copy is independent of the source.
+	require.NoError(t, s.Delete(ctx, src))
+	got, _ = mustGet(t, s, dst)
+	assert.Equal(t, content, string(got))
+}
+
+func testCopyMissing(t *testing.T, s storage.Storage, prefix string) {
+	_, err := s.Copy(context.Background(), prefix+"does-not-exist", prefix+"dst")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testDelete(t *testing.T, s storage.Storage, prefix string) {
+	ctx := context.Background()
+	key := prefix + "delete.txt"
+
+	_, err := s.Put(ctx, key, strings.NewReader("bye"), storage.PutObjectOptions{Size: 3})
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(ctx, key))
+
+	rc, _, err := s.Get(ctx, key)
+	if err == nil {
+		_, err = io.ReadAll(rc)
+		rc.Close()
+	}
+	assert.Error(t, err, "object must be gone after Delete")
+}
+
+func testDeleteMissing(t *testing.T, s storage.Storage, prefix string) {
+	assert.NoError(t, s.Delete(context.Background(), prefix+"never-existed"))
+}
+
+func testConcurrentPuts(t *testing.T, s storage.Storage, prefix string) {
+	ctx := context.Background()
+	const n = 16
+
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := []byte(fmt.Sprintf("object-%02d", i))
+			_, errs[i] = s.Put(ctx, fmt.Sprintf("%sobj-%02d", prefix, i), bytes.NewReader(body), storage.PutObjectOptions{
+				Size: int64(len(body)),
+			})
+		}(i)
+	}
+	// Concurrent writers to the same key: the final object must be one complete write, never a mix.
+	shared := prefix + "shared"
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := bytes.Repeat([]byte{byte('a' + i)}, 4096)
+			_, err := s.Put(ctx, shared, bytes.NewReader(body), storage.PutObjectOptions{Size: int64(len(body))})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		require.NoError(t, errs[i])
+		got, _ := mustGet(t, s, fmt.Sprintf("%sobj-%02d", prefix, i))
+		assert.Equal(t, fmt.Sprintf("object-%02d", i), string(got))
+	}
+
+	got, _ := mustGet(t, s, shared)
+	require.Len(t, got, 4096)
+	assert.Equal(t, bytes.Repeat(got[:1], 4096), got, "concurrent writes to one key must not interleave")
+}
+
+func testPresignGet(t *testing.T, s storage.Storage, prefix string) {
+	ctx := context.Background()
+	key := prefix + "presign.txt"
+
+	_, err := s.Put(ctx, key, strings.NewReader("x"), storage.PutObjectOptions{Size: 1})
+	require.NoError(t, err)
+
+	raw, err := s.PresignGet(ctx, key, time.Minute)
+	if errors.Is(err, storage.ErrPresignUnsupported) {
+		t.Skip("storage does not support presigned urls")
+	}
+	require.NoError(t, err)
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	assert.NotEmpty(t, u.Scheme)
+}
+
+func mustGet(t *testing.T, s storage.Storage, key string) ([]byte, storage.ObjectInfo) {
+	t.Helper()
+	rc, info, err := s.Get(context.Background(), key)
+	require.NoError(t, err)
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return data, info
+}
+
+func testPing(t *testing.T, s storage.Storage, prefix string) {
+	assert.NoError(t, storage.Ping(context.Background(), s))
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwappable_Swap(t *testing.T) {
+	ctx := context.Background()
+	old, replacement := NewMemory(), NewMemory()
+	s := NewSwappable(old)
+
+	_, err := s.Put(ctx, "a", strings.NewReader("old"), PutObjectOptions{Size: 3})
+	require.NoError(t, err)
+	rc, _, err := s.Get(ctx, "a")
+	require.NoError(t, err)
+
+	s.Swap(replacement)
+	assert.Same(t, replacement, s.Current())
+
+	exists, err := s.Exists(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, exists, "calls go to the new storage")
+	_, err = s.Put(ctx, "b", strings.NewReader("new"), PutObjectOptions{Size: 3})
+	require.NoError(t, err)
+	exists, err = replacement.Exists(ctx, "b")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	defer rc.Close()
+	buf := make([]byte, 3)
+	_, err = rc.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(buf), "reads started before the swap continue")
+}